@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/inmem"
+)
+
+func main() {
+	var (
+		addr string
+		org  string
+		apps []string
+	)
+
+	rootCmd := &cobra.Command{
+		Use:   "machines-emulator",
+		Short: "Serve an in-memory emulation of the Machines API",
+		Long: `Serve an in-memory emulation of the Machines API.
+
+Point flyctl at it by exporting the printed FLY_FLAPS_BASE_URL and
+FLY_API_BASE_URL. Nothing is persisted and no machine actually runs: machines,
+leases, volumes, snapshots, secrets and secret keys only live in memory until
+the emulator exits.
+
+Besides the Machines API, the part of the GraphQL API that 'fly deploy' and
+'fly scale count' need is emulated, so those work along with commands that use
+the Machines API alone, such as 'fly machine list', 'status', 'start', 'stop',
+'restart', 'update', 'clone', 'cordon' and 'leases', and 'fly status', 'fly
+releases' and 'fly deploy lock'. Other GraphQL queries are answered with an
+error, so commands like 'fly volumes' and 'fly secrets' fail. Drive those from
+tests with inmem.Client instead.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			server := inmem.NewServer()
+			for _, name := range apps {
+				server.CreateApp(&fly.App{Name: name, Organization: fly.Organization{Slug: org}})
+			}
+
+			return server.ListenAndServe(addr, func(a net.Addr) {
+				fmt.Fprintf(cmd.OutOrStdout(), "export FLY_FLAPS_BASE_URL=%s\n", inmem.URL(a))
+				fmt.Fprintf(cmd.OutOrStdout(), "export FLY_API_BASE_URL=%s\n", inmem.URL(a))
+			})
+		},
+	}
+
+	rootCmd.Flags().StringVar(&addr, "addr", "127.0.0.1:4280", "Address to listen on")
+	rootCmd.Flags().StringVar(&org, "org", "personal", "Organization of the apps created with --app")
+	rootCmd.Flags().StringArrayVar(&apps, "app", nil, "Create an app on startup, can be repeated")
+
+	if err := rootCmd.Execute(); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -1212,6 +1212,99 @@ type FlyctlConfigCurrentReleaseResponse struct {
 // GetApp returns FlyctlConfigCurrentReleaseResponse.App, and is useful for accessing the field via an interface.
 func (v *FlyctlConfigCurrentReleaseResponse) GetApp() FlyctlConfigCurrentReleaseApp { return v.App }
 
+// FlyctlReleasesUnprocessedApp includes the requested fields of the GraphQL type App.
+type FlyctlReleasesUnprocessedApp struct {
+	// Individual releases for this application, without any config processing
+	ReleasesUnprocessed FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnection `json:"releasesUnprocessed"`
+}
+
+// GetReleasesUnprocessed returns FlyctlReleasesUnprocessedApp.ReleasesUnprocessed, and is useful for accessing the field via an interface.
+func (v *FlyctlReleasesUnprocessedApp) GetReleasesUnprocessed() FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnection {
+	return v.ReleasesUnprocessed
+}
+
+// FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnection includes the requested fields of the GraphQL type ReleaseUnprocessedConnection.
+// The GraphQL type's documentation follows.
+//
+// The connection type for ReleaseUnprocessed.
+type FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnection struct {
+	// A list of nodes.
+	Nodes []FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionNodesReleaseUnprocessed `json:"nodes"`
+	// Information to aid in pagination.
+	PageInfo FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionPageInfo `json:"pageInfo"`
+}
+
+// GetNodes returns FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnection.Nodes, and is useful for accessing the field via an interface.
+func (v *FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnection) GetNodes() []FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionNodesReleaseUnprocessed {
+	return v.Nodes
+}
+
+// GetPageInfo returns FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnection.PageInfo, and is useful for accessing the field via an interface.
+func (v *FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnection) GetPageInfo() FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionPageInfo {
+	return v.PageInfo
+}
+
+// FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionNodesReleaseUnprocessed includes the requested fields of the GraphQL type ReleaseUnprocessed.
+type FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionNodesReleaseUnprocessed struct {
+	// The version of the release
+	Version int `json:"version"`
+	// The status of the release
+	Status string `json:"status"`
+	// Docker image URI
+	ImageRef         string      `json:"imageRef"`
+	ConfigDefinition interface{} `json:"configDefinition"`
+}
+
+// GetVersion returns FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionNodesReleaseUnprocessed.Version, and is useful for accessing the field via an interface.
+func (v *FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionNodesReleaseUnprocessed) GetVersion() int {
+	return v.Version
+}
+
+// GetStatus returns FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionNodesReleaseUnprocessed.Status, and is useful for accessing the field via an interface.
+func (v *FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionNodesReleaseUnprocessed) GetStatus() string {
+	return v.Status
+}
+
+// GetImageRef returns FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionNodesReleaseUnprocessed.ImageRef, and is useful for accessing the field via an interface.
+func (v *FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionNodesReleaseUnprocessed) GetImageRef() string {
+	return v.ImageRef
+}
+
+// GetConfigDefinition returns FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionNodesReleaseUnprocessed.ConfigDefinition, and is useful for accessing the field via an interface.
+func (v *FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionNodesReleaseUnprocessed) GetConfigDefinition() interface{} {
+	return v.ConfigDefinition
+}
+
+// FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionPageInfo includes the requested fields of the GraphQL type PageInfo.
+// The GraphQL type's documentation follows.
+//
+// Information about pagination in a connection.
+type FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionPageInfo struct {
+	// When paginating forwards, are there more items?
+	HasNextPage bool `json:"hasNextPage"`
+	// When paginating forwards, the cursor to continue.
+	EndCursor string `json:"endCursor"`
+}
+
+// GetHasNextPage returns FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionPageInfo.HasNextPage, and is useful for accessing the field via an interface.
+func (v *FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionPageInfo) GetHasNextPage() bool {
+	return v.HasNextPage
+}
+
+// GetEndCursor returns FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionPageInfo.EndCursor, and is useful for accessing the field via an interface.
+func (v *FlyctlReleasesUnprocessedAppReleasesUnprocessedReleaseUnprocessedConnectionPageInfo) GetEndCursor() string {
+	return v.EndCursor
+}
+
+// FlyctlReleasesUnprocessedResponse is returned by FlyctlReleasesUnprocessed on success.
+type FlyctlReleasesUnprocessedResponse struct {
+	// Find an app by name
+	App FlyctlReleasesUnprocessedApp `json:"app"`
+}
+
+// GetApp returns FlyctlReleasesUnprocessedResponse.App, and is useful for accessing the field via an interface.
+func (v *FlyctlReleasesUnprocessedResponse) GetApp() FlyctlReleasesUnprocessedApp { return v.App }
+
 // GetAddOnAddOn includes the requested fields of the GraphQL type AddOn.
 type GetAddOnAddOn struct {
 	AddOnData `json:"-"`
@@ -3056,6 +3149,18 @@ type __FlyctlConfigCurrentReleaseInput struct {
 // GetAppName returns __FlyctlConfigCurrentReleaseInput.AppName, and is useful for accessing the field via an interface.
 func (v *__FlyctlConfigCurrentReleaseInput) GetAppName() string { return v.AppName }
 
+// __FlyctlReleasesUnprocessedInput is used internally by genqlient
+type __FlyctlReleasesUnprocessedInput struct {
+	AppName string `json:"appName"`
+	After   string `json:"after,omitempty"`
+}
+
+// GetAppName returns __FlyctlReleasesUnprocessedInput.AppName, and is useful for accessing the field via an interface.
+func (v *__FlyctlReleasesUnprocessedInput) GetAppName() string { return v.AppName }
+
+// GetAfter returns __FlyctlReleasesUnprocessedInput.After, and is useful for accessing the field via an interface.
+func (v *__FlyctlReleasesUnprocessedInput) GetAfter() string { return v.After }
+
 // __GetAddOnInput is used internally by genqlient
 type __GetAddOnInput struct {
 	Name     string `json:"name"`
@@ -3621,6 +3726,53 @@ func FlyctlConfigCurrentRelease(
 	return data_, err_
 }
 
+// The query executed by FlyctlReleasesUnprocessed.
+const FlyctlReleasesUnprocessed_Operation = `
+query FlyctlReleasesUnprocessed ($appName: String!, $after: String) {
+	app(name: $appName) {
+		releasesUnprocessed(first: 50, after: $after) {
+			nodes {
+				version
+				status
+				imageRef
+				configDefinition
+			}
+			pageInfo {
+				hasNextPage
+				endCursor
+			}
+		}
+	}
+}
+`
+
+func FlyctlReleasesUnprocessed(
+	ctx_ context.Context,
+	client_ graphql.Client,
+	appName string,
+	after string,
+) (data_ *FlyctlReleasesUnprocessedResponse, err_ error) {
+	req_ := &graphql.Request{
+		OpName: "FlyctlReleasesUnprocessed",
+		Query:  FlyctlReleasesUnprocessed_Operation,
+		Variables: &__FlyctlReleasesUnprocessedInput{
+			AppName: appName,
+			After:   after,
+		},
+	}
+
+	data_ = &FlyctlReleasesUnprocessedResponse{}
+	resp_ := &graphql.Response{Data: data_}
+
+	err_ = client_.MakeRequest(
+		ctx_,
+		req_,
+		resp_,
+	)
+
+	return data_, err_
+}
+
 // The query executed by GetAddOn.
 const GetAddOn_Operation = `
 query GetAddOn ($name: String, $provider: String) {
@@ -4,14 +4,20 @@ package appconfig
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
 	"slices"
+	"strings"
+	"time"
 
+	"github.com/docker/go-units"
+	"github.com/robfig/cron/v3"
 	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/helpers"
 )
 
 const (
@@ -57,6 +63,7 @@ type Config struct {
 	Build        *Build            `toml:"build,omitempty" json:"build,omitempty"`
 	Deploy       *Deploy           `toml:"deploy,omitempty" json:"deploy,omitempty"`
 	Env          map[string]string `toml:"env,omitempty" json:"env,omitempty"`
+	Secrets      *Secrets          `toml:"secrets,omitempty" json:"secrets,omitempty"`
 
 	// Fields that are process group aware must come after Processes
 	Processes        map[string]string         `toml:"processes,omitempty" json:"processes,omitempty"`
@@ -82,6 +89,9 @@ type Config struct {
 
 	Compute []*Compute `toml:"vm,omitempty" json:"vm,omitempty"`
 
+	Autoscale []*Autoscale `toml:"autoscale,omitempty" json:"autoscale,omitempty"`
+	Scale     *Scale       `toml:"scale,omitempty" json:"scale,omitempty"`
+
 	// Others, less important.
 	Statics []Static   `toml:"statics,omitempty" json:"statics,omitempty"`
 	Metrics []*Metrics `toml:"metrics,omitempty" json:"metrics,omitempty"`
@@ -112,6 +122,105 @@ type Deploy struct {
 	ReleaseCommandTimeout *fly.Duration `toml:"release_command_timeout,omitempty" json:"release_command_timeout,omitempty"`
 	ReleaseCommandCompute *Compute      `toml:"release_command_vm,omitempty" json:"release_command_vm,omitempty"`
 	SeedCommand           string        `toml:"seed_command,omitempty" json:"seed_command,omitempty"`
+	Hooks                 []DeployHook  `toml:"hooks,omitempty" json:"hooks,omitempty"`
+	SmokeTest             *SmokeTest    `toml:"smoke_test,omitempty" json:"smoke_test,omitempty"`
+	Notify                *DeployNotify `toml:"notify,omitempty" json:"notify,omitempty"`
+
+	// RegionOrder rolls updates out one region at a time, in this order.
+	// "*" stands for every region not listed, updated together; regions
+	// left out are updated last.
+	RegionOrder         []string      `toml:"region_order,omitempty" json:"region_order,omitempty"`
+	RegionWaveBake      *fly.Duration `toml:"region_wave_bake,omitempty" json:"region_wave_bake,omitempty"`
+	RegionWaveApproval  bool          `toml:"region_wave_approval,omitempty" json:"region_wave_approval,omitempty"`
+	RegionWaveSmokeTest bool          `toml:"region_wave_smoke_test,omitempty" json:"region_wave_smoke_test,omitempty"`
+}
+
+// RegionWaveWildcard in Deploy.RegionOrder matches every region not listed.
+const RegionWaveWildcard = "*"
+
+// Deploy hook phases, in the order they happen.
+const (
+	DeployHookPreBuild   = "pre-build"
+	DeployHookPostBuild  = "post-build"
+	DeployHookPreRelease = "pre-release"
+	DeployHookPostDeploy = "post-deploy"
+)
+
+var DeployHookPhases = []string{DeployHookPreBuild, DeployHookPostBuild, DeployHookPreRelease, DeployHookPostDeploy}
+
+// DeployHook runs a command at a phase of deploys, either locally or on a
+// temporary machine running the image being deployed. A failing hook aborts
+// the deploy unless ContinueOnError is set.
+type DeployHook struct {
+	Phase           string        `toml:"phase,omitempty" json:"phase,omitempty"`
+	Command         string        `toml:"command,omitempty" json:"command,omitempty"`
+	MachineCommand  string        `toml:"machine_command,omitempty" json:"machine_command,omitempty"`
+	Timeout         *fly.Duration `toml:"timeout,omitempty" json:"timeout,omitempty"`
+	ContinueOnError bool          `toml:"continue_on_error,omitempty" json:"continue_on_error,omitempty"`
+}
+
+// SmokeTest checks a release once it's deployed, with HTTP probes and/or a
+// local command. Failures roll the release back when
+// experimental.auto_rollback is set.
+type SmokeTest struct {
+	Command  string           `toml:"command,omitempty" json:"command,omitempty"`
+	HTTP     []SmokeTestProbe `toml:"http,omitempty" json:"http,omitempty"`
+	Timeout  *fly.Duration    `toml:"timeout,omitempty" json:"timeout,omitempty"`
+	Interval *fly.Duration    `toml:"interval,omitempty" json:"interval,omitempty"`
+}
+
+// SmokeTestProbe is an HTTP request expected to succeed. URL is either
+// absolute or a path on the app's URL.
+type SmokeTestProbe struct {
+	URL      string            `toml:"url,omitempty" json:"url,omitempty"`
+	Method   string            `toml:"method,omitempty" json:"method,omitempty"`
+	Headers  map[string]string `toml:"headers,omitempty" json:"headers,omitempty"`
+	Status   int               `toml:"status,omitempty" json:"status,omitempty"`
+	Contains string            `toml:"contains,omitempty" json:"contains,omitempty"`
+}
+
+// Deploy notification events.
+const (
+	DeployEventStart    = "start"
+	DeployEventSuccess  = "success"
+	DeployEventFailure  = "failure"
+	DeployEventRollback = "rollback"
+)
+
+var DeployEvents = []string{DeployEventStart, DeployEventSuccess, DeployEventFailure, DeployEventRollback}
+
+// Deploy webhook payload formats.
+const (
+	WebhookFormatJSON    = "json"
+	WebhookFormatSlack   = "slack"
+	WebhookFormatDiscord = "discord"
+	WebhookFormatTeams   = "teams"
+)
+
+var WebhookFormats = []string{WebhookFormatJSON, WebhookFormatSlack, WebhookFormatDiscord, WebhookFormatTeams}
+
+// DeployNotify lists the webhooks told about deploys of the app.
+type DeployNotify struct {
+	// Events to send to every webhook that doesn't set its own. All events
+	// are sent when empty.
+	Events   []string        `toml:"events,omitempty" json:"events,omitempty"`
+	Webhooks []DeployWebhook `toml:"webhooks,omitempty" json:"webhooks,omitempty"`
+}
+
+// DeployWebhook is a webhook receiving deploy events. Environment variables
+// in URL and Headers are expanded when sending, so secrets like Slack
+// webhook URLs don't need to be committed.
+type DeployWebhook struct {
+	URL     string            `toml:"url,omitempty" json:"url,omitempty"`
+	Format  string            `toml:"format,omitempty" json:"format,omitempty"`
+	Events  []string          `toml:"events,omitempty" json:"events,omitempty"`
+	Headers map[string]string `toml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+// Secrets declares the secrets the app expects to be set. Deploys fail early
+// when any of them is missing instead of leaving machines to crash on boot.
+type Secrets struct {
+	Required []string `toml:"required,omitempty" json:"required,omitempty"`
 }
 
 type File struct {
@@ -159,6 +268,29 @@ type Mount struct {
 	AutoExtendSizeIncrement string   `toml:"auto_extend_size_increment,omitempty" json:"auto_extend_size_increment,omitempty"`
 	AutoExtendSizeLimit     string   `toml:"auto_extend_size_limit,omitempty" json:"auto_extend_size_limit,omitempty"`
 	Processes               []string `toml:"processes,omitempty" json:"processes,omitempty"`
+
+	SnapshotPolicy *SnapshotPolicy `toml:"snapshot_policy,omitempty" json:"snapshot_policy,omitempty"`
+}
+
+// SnapshotPolicy schedules snapshots of a mount's volumes beyond the platform's
+// daily snapshots. It is reconciled by `fly volumes snapshots policy apply`.
+type SnapshotPolicy struct {
+	// Frequency is the minimum time between two snapshots of a volume.
+	Frequency *fly.Duration `toml:"frequency,omitempty" json:"frequency,omitempty"`
+	// Retain is the number of snapshots to keep at the given frequency.
+	Retain int `toml:"retain,omitempty" json:"retain,omitempty"`
+	// CopyToRegions lists regions where the latest snapshot is restored into
+	// a standby volume, for disaster recovery.
+	CopyToRegions []string `toml:"copy_to_regions,omitempty" json:"copy_to_regions,omitempty"`
+}
+
+// RetentionDays returns the volume snapshot retention, in whole days, needed to
+// keep Retain snapshots taken at Frequency. The platform has no way to delete
+// individual snapshots, so the count is enforced through the retention period.
+func (p *SnapshotPolicy) RetentionDays() int {
+	const day = 24 * time.Hour
+	d := time.Duration(p.Retain) * p.Frequency.Duration
+	return int((d + day - 1) / day)
 }
 
 type BuildCompose struct {
@@ -211,6 +343,86 @@ type Restart struct {
 	Processes  []string      `json:"processes,omitempty" toml:"processes,omitempty"`
 }
 
+// Autoscale is a policy `fly autoscale run` keeps the machine count of
+// process groups within, following a metric.
+type Autoscale struct {
+	MinMachines int `toml:"min_machines,omitempty" json:"min_machines,omitempty"`
+	MaxMachines int `toml:"max_machines,omitempty" json:"max_machines,omitempty"`
+	// Metric is one of AutoscaleMetrics and Target its value per machine:
+	// open connections or requests for concurrency, percent busy for cpu,
+	// queued jobs for queue_depth.
+	Metric string  `toml:"metric,omitempty" json:"metric,omitempty"`
+	Target float64 `toml:"target,omitempty" json:"target,omitempty"`
+	// Query is the PromQL query returning the queue depth.
+	Query string `toml:"query,omitempty" json:"query,omitempty"`
+	// PrometheusURL is a Prometheus-compatible API to query instead of the
+	// organization's Fly.io metrics.
+	PrometheusURL     string        `toml:"prometheus_url,omitempty" json:"prometheus_url,omitempty"`
+	ScaleUpCooldown   *fly.Duration `toml:"scale_up_cooldown,omitempty" json:"scale_up_cooldown,omitempty"`
+	ScaleDownCooldown *fly.Duration `toml:"scale_down_cooldown,omitempty" json:"scale_down_cooldown,omitempty"`
+	// Regions machines are spread over, the regions the app runs in if empty.
+	Regions   []string `toml:"regions,omitempty" json:"regions,omitempty"`
+	Processes []string `toml:"processes,omitempty" json:"processes,omitempty"`
+}
+
+// Autoscale metrics.
+const (
+	AutoscaleMetricConcurrency = "concurrency"
+	AutoscaleMetricCPU         = "cpu"
+	AutoscaleMetricQueueDepth  = "queue_depth"
+)
+
+var AutoscaleMetrics = []string{AutoscaleMetricConcurrency, AutoscaleMetricCPU, AutoscaleMetricQueueDepth}
+
+type Scale struct {
+	Schedule []*ScaleSchedule `toml:"schedule,omitempty" json:"schedule,omitempty"`
+}
+
+// ScaleSchedule scales process groups at the times of a cron expression,
+// when `fly scale schedule run` finds it due.
+type ScaleSchedule struct {
+	Name string `toml:"name,omitempty" json:"name,omitempty"`
+	// Cron is a standard five field cron expression, or a descriptor like
+	// @daily, in Timezone. Timezone is an IANA time zone, UTC if empty.
+	Cron     string `toml:"cron,omitempty" json:"cron,omitempty"`
+	Timezone string `toml:"timezone,omitempty" json:"timezone,omitempty"`
+	// Count is the machine count spread over the regions the app runs in,
+	// Regions the machine count of each region listed.
+	Count   *int           `toml:"count,omitempty" json:"count,omitempty"`
+	Regions map[string]int `toml:"regions,omitempty" json:"regions,omitempty"`
+	// Size and Memory resize the machines, like `fly scale vm` does.
+	Size      string   `toml:"size,omitempty" json:"size,omitempty"`
+	Memory    string   `toml:"memory,omitempty" json:"memory,omitempty"`
+	Processes []string `toml:"processes,omitempty" json:"processes,omitempty"`
+}
+
+// CronSchedule parses the cron expression of s in its time zone.
+func (s *ScaleSchedule) CronSchedule() (cron.Schedule, error) {
+	if strings.HasPrefix(s.Cron, "@every") {
+		return nil, errors.New("@every isn't supported, schedules fire at fixed times")
+	}
+	spec := s.Cron
+	if s.Timezone != "" {
+		spec = "CRON_TZ=" + s.Timezone + " " + spec
+	}
+	return cron.ParseStandard(spec)
+}
+
+// MemoryMB is the memory s resizes machines to, 0 if it doesn't.
+func (s *ScaleSchedule) MemoryMB() (int, error) {
+	if s.Memory == "" {
+		return 0, nil
+	}
+	return helpers.ParseSize(s.Memory, units.RAMInBytes, units.MiB)
+}
+
+func (s *ScaleSchedule) String() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Cron
+}
+
 func (c *Config) ConfigFilePath() string {
 	return c.configFilePath
 }
@@ -404,6 +616,22 @@ func (c *Config) DeployStrategy() string {
 	return c.Deploy.Strategy
 }
 
+// RequiredSecrets returns the secrets the app needs to boot: those listed in
+// [secrets] required and those referenced by [[files]] secret_name.
+func (c *Config) RequiredSecrets() []string {
+	var names []string
+	if c.Secrets != nil {
+		names = append(names, c.Secrets.Required...)
+	}
+	for _, f := range c.Files {
+		if f.SecretName != "" {
+			names = append(names, f.SecretName)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
 // DetectComposeFile returns Build.Compose.File if set, otherwise looks for
 // well-known compose filenames in the directory containing the config file.
 // Returns the first found filename or empty string.
@@ -269,6 +269,9 @@ func TestToDefinition(t *testing.T) {
 		"env": map[string]any{
 			"FOO": "BAR",
 		},
+		"secrets": map[string]any{
+			"required": []any{"DATABASE_URL", "SUPER_SECRET"},
+		},
 		"metrics": []any{
 			map[string]any{
 				"port": int64(9999),
@@ -308,6 +311,11 @@ func TestToDefinition(t *testing.T) {
 			"destination":        "/data",
 			"initial_size":       "30gb",
 			"snapshot_retention": int64(17),
+			"snapshot_policy": map[string]any{
+				"frequency":       "6h0m0s",
+				"retain":          int64(8),
+				"copy_to_regions": []any{"iad"},
+			},
 		}},
 		"processes": map[string]any{
 			"web":  "run web",
@@ -50,7 +50,7 @@ func FromAppAndMachineSet(ctx context.Context, appName string, machines machine.
 			otherToml, err := other.appConfig.marshalTOML()
 			if err == nil {
 				warnings = append(warnings, warning("fly.toml", `Machine %s currently has a config that will change with the new fly.toml. This is what will change:
-%s`, other.machine.Machine().ID, prettyDiff(string(otherToml), report.mostCommon, colorize)))
+%s`, other.machine.Machine().ID, PrettyDiff(string(otherToml), report.mostCommon, colorize)))
 			}
 		}
 	}
@@ -61,7 +61,9 @@ func FromAppAndMachineSet(ctx context.Context, appName string, machines machine.
 	return mostCommonConfig, strings.Join(finalWarningMsgs, "\n"), nil
 }
 
-func prettyDiff(original, new string, colorize *iostreams.ColorScheme) string {
+// PrettyDiff returns a line diff of two multi-line strings, with additions in
+// green and deletions in red. It returns an empty string when they're equal.
+func PrettyDiff(original, new string, colorize *iostreams.ColorScheme) string {
 	diff := cmp.Diff(original, new)
 	diffSlice := strings.Split(diff, "\n")
 	var str string
@@ -209,6 +209,25 @@ func (c *Config) ComputeForGroup(groupName string) *Compute {
 	return compute
 }
 
+// AutoscaleForGroup finds the autoscale policy of a process group, the one
+// listing the group or else the one without processes, if any.
+func (c *Config) AutoscaleForGroup(groupName string) *Autoscale {
+	if groupName == "" {
+		groupName = c.DefaultProcessName()
+	}
+
+	var policy *Autoscale
+	for _, p := range c.Autoscale {
+		switch {
+		case slices.Contains(p.Processes, groupName):
+			return p
+		case len(p.Processes) == 0:
+			policy = p
+		}
+	}
+	return policy
+}
+
 func (c *Config) InitCmd(groupName string) ([]string, error) {
 	if groupName == "" {
 		groupName = c.DefaultProcessName()
@@ -487,6 +487,10 @@ func TestLoadTOMLAppConfigReferenceFormat(t *testing.T) {
 			"FOO": "BAR",
 		},
 
+		Secrets: &Secrets{
+			Required: []string{"DATABASE_URL", "SUPER_SECRET"},
+		},
+
 		Metrics: []*Metrics{
 			{
 				MachineMetrics: &fly.MachineMetrics{
@@ -596,6 +600,11 @@ func TestLoadTOMLAppConfigReferenceFormat(t *testing.T) {
 			Destination:       "/data",
 			InitialSize:       "30gb",
 			SnapshotRetention: fly.Pointer(17),
+			SnapshotPolicy: &SnapshotPolicy{
+				Frequency:     fly.MustParseDuration("6h"),
+				Retain:        8,
+				CopyToRegions: []string{"iad"},
+			},
 		}},
 
 		Processes: map[string]string{
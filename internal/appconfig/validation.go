@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
 	"slices"
 	"strings"
 	"time"
@@ -36,6 +37,8 @@ func (c *Config) Validate(ctx context.Context) (err error, extra_info string) {
 		c.validateConsoleCommand,
 		c.validateMounts,
 		c.validateRestartPolicy,
+		c.validateAutoscale,
+		c.validateScaleSchedule,
 	}
 
 	extra_info = fmt.Sprintf("Validating %s\n", c.ConfigFilePath())
@@ -115,6 +118,97 @@ func (c *Config) validateDeploySection() (extraInfo string, err error) {
 		}
 	}
 
+	for i, hook := range c.Deploy.Hooks {
+		switch {
+		case !slices.Contains(DeployHookPhases, hook.Phase):
+			extraInfo += fmt.Sprintf("deploy hook #%d has unsupported phase '%s'; supported phases are: %s\n", i+1, hook.Phase, strings.Join(DeployHookPhases, ", "))
+			err = ValidationError
+		case (hook.Command == "") == (hook.MachineCommand == ""):
+			extraInfo += fmt.Sprintf("deploy hook #%d must set exactly one of command or machine_command\n", i+1)
+			err = ValidationError
+		case hook.MachineCommand != "" && (hook.Phase == DeployHookPreBuild || hook.Phase == DeployHookPostBuild):
+			extraInfo += fmt.Sprintf("deploy hook #%d can't run machine_command at %s, there is no image to run yet; use pre-release or post-deploy\n", i+1, hook.Phase)
+			err = ValidationError
+		}
+		if _, vErr := shlex.Split(hook.MachineCommand); vErr != nil {
+			extraInfo += fmt.Sprintf("Can't shell split machine_command of deploy hook #%d: '%s'\n", i+1, hook.MachineCommand)
+			err = ValidationError
+		}
+	}
+
+	if st := c.Deploy.SmokeTest; st != nil {
+		if st.Command == "" && len(st.HTTP) == 0 {
+			extraInfo += "deploy.smoke_test must set a command or at least one http probe\n"
+			err = ValidationError
+		}
+		for i, probe := range st.HTTP {
+			if probe.URL == "" {
+				extraInfo += fmt.Sprintf("deploy.smoke_test http probe #%d must set url\n", i+1)
+				err = ValidationError
+			} else if u, pErr := url.Parse(probe.URL); pErr != nil {
+				extraInfo += fmt.Sprintf("deploy.smoke_test http probe #%d has invalid url '%s': %v\n", i+1, probe.URL, pErr)
+				err = ValidationError
+			} else if !u.IsAbs() && c.URL() == nil {
+				extraInfo += fmt.Sprintf("deploy.smoke_test http probe #%d has the relative url '%s', but the app has no public http service to resolve it against; use an absolute url\n", i+1, probe.URL)
+				err = ValidationError
+			}
+			if probe.Status != 0 && (probe.Status < 100 || probe.Status > 599) {
+				extraInfo += fmt.Sprintf("deploy.smoke_test http probe #%d has invalid status %d\n", i+1, probe.Status)
+				err = ValidationError
+			}
+		}
+	}
+
+	seenRegions := map[string]bool{}
+	for _, region := range c.Deploy.RegionOrder {
+		switch {
+		case region == "":
+			extraInfo += "deploy.region_order can't have empty regions\n"
+			err = ValidationError
+		case seenRegions[region]:
+			extraInfo += fmt.Sprintf("deploy.region_order lists '%s' more than once\n", region)
+			err = ValidationError
+		}
+		seenRegions[region] = true
+	}
+	if len(c.Deploy.RegionOrder) > 0 && (c.Deploy.Strategy == "bluegreen" || c.Deploy.Strategy == "immediate") {
+		extraInfo += fmt.Sprintf("deploy.region_order only applies to the rolling and canary strategies, not %s\n", c.Deploy.Strategy)
+		err = ValidationError
+	}
+	if len(c.Deploy.RegionOrder) == 0 && (c.Deploy.RegionWaveBake != nil || c.Deploy.RegionWaveApproval || c.Deploy.RegionWaveSmokeTest) {
+		extraInfo += "deploy.region_wave_bake, region_wave_approval and region_wave_smoke_test need deploy.region_order\n"
+		err = ValidationError
+	}
+	if c.Deploy.RegionWaveSmokeTest && c.Deploy.SmokeTest == nil {
+		extraInfo += "deploy.region_wave_smoke_test needs a [deploy.smoke_test]\n"
+		err = ValidationError
+	}
+
+	if n := c.Deploy.Notify; n != nil {
+		for _, event := range n.Events {
+			if !slices.Contains(DeployEvents, event) {
+				extraInfo += fmt.Sprintf("deploy.notify has unsupported event '%s'; supported events are: %s\n", event, strings.Join(DeployEvents, ", "))
+				err = ValidationError
+			}
+		}
+		for i, webhook := range n.Webhooks {
+			if webhook.URL == "" {
+				extraInfo += fmt.Sprintf("deploy.notify webhook #%d must set url\n", i+1)
+				err = ValidationError
+			}
+			if webhook.Format != "" && !slices.Contains(WebhookFormats, webhook.Format) {
+				extraInfo += fmt.Sprintf("deploy.notify webhook #%d has unsupported format '%s'; supported formats are: %s\n", i+1, webhook.Format, strings.Join(WebhookFormats, ", "))
+				err = ValidationError
+			}
+			for _, event := range webhook.Events {
+				if !slices.Contains(DeployEvents, event) {
+					extraInfo += fmt.Sprintf("deploy.notify webhook #%d has unsupported event '%s'; supported events are: %s\n", i+1, event, strings.Join(DeployEvents, ", "))
+					err = ValidationError
+				}
+			}
+		}
+	}
+
 	return
 }
 
@@ -280,6 +374,23 @@ func (c *Config) validateMounts() (extraInfo string, err error) {
 			err = ValidationError
 		}
 
+		if p := m.SnapshotPolicy; p != nil {
+			if p.Frequency == nil || p.Frequency.Duration < time.Hour {
+				extraInfo += fmt.Sprintf("mount '%s' snapshot_policy frequency must be at least 1h\n", m.Source)
+				err = ValidationError
+			}
+			if p.Retain < 1 {
+				extraInfo += fmt.Sprintf("mount '%s' snapshot_policy retain must be at least 1\n", m.Source)
+				err = ValidationError
+			}
+			if p.Frequency != nil && p.Retain > 0 {
+				if days := p.RetentionDays(); days > 60 {
+					extraInfo += fmt.Sprintf("mount '%s' snapshot_policy keeps snapshots for %d days, more than the maximum of 60 days\n", m.Source, days)
+					err = ValidationError
+				}
+			}
+		}
+
 		var autoExtendSizeIncrement, autoExtendSizeLimit int
 		var vErr error
 		if m.AutoExtendSizeIncrement != "" {
@@ -355,3 +466,103 @@ func (c *Config) validateRestartPolicy() (extraInfo string, err error) {
 
 	return
 }
+
+func (c *Config) validateAutoscale() (extraInfo string, err error) {
+	validGroupNames := c.ProcessNames()
+	seen := map[string]bool{}
+
+	for _, p := range c.Autoscale {
+		groups := p.Processes
+		if len(groups) == 0 {
+			groups = []string{"*"}
+		}
+		for _, name := range groups {
+			if name != "*" && !slices.Contains(validGroupNames, name) {
+				extraInfo += fmt.Sprintf("Autoscale policy specifies '%s' as one of its processes, but no processes are defined with that name\n", name)
+				err = ValidationError
+			}
+			if seen[name] {
+				extraInfo += fmt.Sprintf("More than one autoscale policy applies to '%s'\n", name)
+				err = ValidationError
+			}
+			seen[name] = true
+		}
+		what := strings.Join(groups, ", ")
+
+		if p.MaxMachines < 1 || p.MinMachines < 0 || p.MinMachines > p.MaxMachines {
+			extraInfo += fmt.Sprintf("Autoscale policy for %s needs 0 <= min_machines <= max_machines and max_machines >= 1\n", what)
+			err = ValidationError
+		}
+		if !slices.Contains(AutoscaleMetrics, p.Metric) {
+			extraInfo += fmt.Sprintf("Autoscale policy for %s has metric '%s', it must be one of: %s\n", what, p.Metric, strings.Join(AutoscaleMetrics, ", "))
+			err = ValidationError
+		}
+		if p.Target <= 0 {
+			extraInfo += fmt.Sprintf("Autoscale policy for %s needs a target greater than 0\n", what)
+			err = ValidationError
+		}
+		if (p.Metric == AutoscaleMetricConcurrency || p.Metric == AutoscaleMetricCPU) && p.MinMachines < 1 {
+			extraInfo += fmt.Sprintf("Autoscale policy for %s needs min_machines >= 1, groups without machines don't report %s\n", what, p.Metric)
+			err = ValidationError
+		}
+		switch {
+		case p.Metric == AutoscaleMetricQueueDepth && p.Query == "":
+			extraInfo += fmt.Sprintf("Autoscale policy for %s needs a query returning the queue depth\n", what)
+			err = ValidationError
+		case p.Metric != AutoscaleMetricQueueDepth && p.Query != "":
+			extraInfo += fmt.Sprintf("Autoscale policy for %s has a query, but only the queue_depth metric uses one\n", what)
+			err = ValidationError
+		}
+	}
+	return
+}
+
+func (c *Config) validateScaleSchedule() (extraInfo string, err error) {
+	if c.Scale == nil {
+		return
+	}
+
+	validGroupNames := c.ProcessNames()
+	for _, s := range c.Scale.Schedule {
+		if _, vErr := s.CronSchedule(); vErr != nil {
+			extraInfo += fmt.Sprintf("Scale schedule '%s' has an invalid cron expression or timezone: %s\n", s, vErr)
+			err = ValidationError
+		}
+		for _, name := range s.Processes {
+			if !slices.Contains(validGroupNames, name) {
+				extraInfo += fmt.Sprintf("Scale schedule '%s' specifies '%s' as one of its processes, but no processes are defined with that name\n", s, name)
+				err = ValidationError
+			}
+		}
+
+		switch {
+		case s.Count != nil && len(s.Regions) > 0:
+			extraInfo += fmt.Sprintf("Scale schedule '%s' sets both count and regions, set the count of each region in regions instead\n", s)
+			err = ValidationError
+		case s.Count == nil && len(s.Regions) == 0 && s.Size == "" && s.Memory == "":
+			extraInfo += fmt.Sprintf("Scale schedule '%s' doesn't change anything, set count, regions, size or memory\n", s)
+			err = ValidationError
+		case s.Count != nil && *s.Count < 0:
+			extraInfo += fmt.Sprintf("Scale schedule '%s' has a negative count\n", s)
+			err = ValidationError
+		}
+		for region, count := range s.Regions {
+			if count < 0 {
+				extraInfo += fmt.Sprintf("Scale schedule '%s' has a negative count for region '%s'\n", s, region)
+				err = ValidationError
+			}
+		}
+
+		if s.Size != "" {
+			if vErr := (&fly.MachineGuest{}).SetSize(s.Size); vErr != nil {
+				extraInfo += fmt.Sprintf("Scale schedule '%s' has an invalid size: %s\n", s, vErr)
+				err = ValidationError
+			}
+		}
+		if _, vErr := s.MemoryMB(); vErr != nil {
+			extraInfo += fmt.Sprintf("Scale schedule '%s' has an invalid memory '%s': %s\n", s, s.Memory, vErr)
+			err = ValidationError
+		}
+	}
+	return
+}
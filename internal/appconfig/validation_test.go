@@ -7,6 +7,7 @@ import (
 
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
 	"github.com/superfly/flyctl/internal/cmdutil/preparers"
 	"github.com/superfly/flyctl/internal/flag"
 	"github.com/superfly/flyctl/internal/logger"
@@ -51,6 +52,8 @@ func TestConfig_ValidateMounts(t *testing.T) {
 	err, x := cfg.Validate(ctx)
 	require.Error(t, err, x)
 	require.Contains(t, x, "has an initial_size '15Mb' value which is smaller than 1GB")
+	require.Contains(t, x, "mount 'foo' snapshot_policy frequency must be at least 1h")
+	require.Contains(t, x, "mount 'foo' snapshot_policy retain must be at least 1")
 
 	err, x = cfg.ValidateGroups(ctx, []string{"app"})
 	require.Error(t, err, x)
@@ -71,3 +74,138 @@ func TestConfig_ValidateServices(t *testing.T) {
 	err, x = cfg.ValidateGroups(ctx, []string{"success"})
 	require.NoErrorf(t, err, x)
 }
+
+func TestConfig_ValidateDeployHooks(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Deploy = &Deploy{
+		Hooks: []DeployHook{
+			{Phase: DeployHookPreBuild, Command: "make assets"},
+			{Phase: DeployHookPostDeploy, MachineCommand: "bin/warm-cache"},
+		},
+		SmokeTest: &SmokeTest{HTTP: []SmokeTestProbe{{URL: "/health", Status: 200}}},
+	}
+	cfg.HTTPService = &HTTPService{InternalPort: 8080}
+	x, err := cfg.validateDeploySection()
+	require.NoError(t, err, x)
+
+	cfg.HTTPService = nil
+	x, err = cfg.validateDeploySection()
+	require.Error(t, err)
+	require.Contains(t, x, "http probe #1 has the relative url '/health', but the app has no public http service")
+
+	cfg.Deploy.SmokeTest.HTTP[0].URL = "https://status.example.com/health"
+	x, err = cfg.validateDeploySection()
+	require.NoError(t, err, x)
+
+	cfg.Deploy.Hooks = []DeployHook{
+		{Phase: "during-build", Command: "true"},
+		{Phase: DeployHookPreRelease, Command: "true", MachineCommand: "true"},
+		{Phase: DeployHookPostBuild, MachineCommand: "true"},
+	}
+	cfg.Deploy.SmokeTest = &SmokeTest{HTTP: []SmokeTestProbe{{Status: 42}}}
+	x, err = cfg.validateDeploySection()
+	require.Error(t, err)
+	require.Contains(t, x, "deploy hook #1 has unsupported phase 'during-build'")
+	require.Contains(t, x, "deploy hook #2 must set exactly one of command or machine_command")
+	require.Contains(t, x, "deploy hook #3 can't run machine_command at post-build")
+	require.Contains(t, x, "http probe #1 must set url")
+	require.Contains(t, x, "http probe #1 has invalid status 42")
+}
+
+func TestConfig_ValidateDeployNotify(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Deploy = &Deploy{Notify: &DeployNotify{
+		Events: []string{DeployEventFailure, "paged"},
+		Webhooks: []DeployWebhook{
+			{URL: "$SLACK_WEBHOOK_URL", Format: WebhookFormatSlack},
+			{Format: "irc", Events: []string{DeployEventRollback}},
+		},
+	}}
+	x, err := cfg.validateDeploySection()
+	require.Error(t, err)
+	require.Contains(t, x, "deploy.notify has unsupported event 'paged'")
+	require.Contains(t, x, "deploy.notify webhook #2 must set url")
+	require.Contains(t, x, "deploy.notify webhook #2 has unsupported format 'irc'")
+	require.NotContains(t, x, "webhook #1")
+}
+
+func TestConfig_ValidateRegionOrder(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Deploy = &Deploy{RegionOrder: []string{"ams", "iad", RegionWaveWildcard}, RegionWaveApproval: true}
+	x, err := cfg.validateDeploySection()
+	require.NoError(t, err, x)
+
+	cfg.Deploy = &Deploy{
+		Strategy:            "bluegreen",
+		RegionOrder:         []string{"ams", "ams"},
+		RegionWaveSmokeTest: true,
+	}
+	x, err = cfg.validateDeploySection()
+	require.Error(t, err)
+	require.Contains(t, x, "deploy.region_order lists 'ams' more than once")
+	require.Contains(t, x, "only applies to the rolling and canary strategies, not bluegreen")
+	require.Contains(t, x, "deploy.region_wave_smoke_test needs a [deploy.smoke_test]")
+
+	cfg.Deploy = &Deploy{RegionWaveBake: fly.MustParseDuration("5m")}
+	x, err = cfg.validateDeploySection()
+	require.Error(t, err)
+	require.Contains(t, x, "need deploy.region_order")
+}
+
+func TestConfig_ValidateAutoscale(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Processes = map[string]string{"app": "", "worker": ""}
+	cfg.Autoscale = []*Autoscale{
+		{Processes: []string{"app"}, MinMachines: 1, MaxMachines: 10, Metric: AutoscaleMetricConcurrency, Target: 50},
+		{MinMachines: 1, MaxMachines: 3, Metric: AutoscaleMetricQueueDepth, Target: 100, Query: "sum(jobs_queued)"},
+	}
+	x, err := cfg.validateAutoscale()
+	require.NoError(t, err, x)
+	require.Equal(t, cfg.Autoscale[0], cfg.AutoscaleForGroup("app"))
+	require.Equal(t, cfg.Autoscale[1], cfg.AutoscaleForGroup("worker"))
+
+	cfg.Autoscale = []*Autoscale{
+		{Processes: []string{"app", "web"}, MinMachines: 4, MaxMachines: 2, Metric: "rps", Query: "up"},
+		{Processes: []string{"app"}, MaxMachines: 1, Metric: AutoscaleMetricQueueDepth, Target: 1},
+		{Processes: []string{"worker"}, MaxMachines: 1, Metric: AutoscaleMetricCPU, Target: 60},
+	}
+	x, err = cfg.validateAutoscale()
+	require.Error(t, err)
+	require.Contains(t, x, "'web' as one of its processes")
+	require.Contains(t, x, "More than one autoscale policy applies to 'app'")
+	require.Contains(t, x, "min_machines <= max_machines")
+	require.Contains(t, x, "metric 'rps'")
+	require.Contains(t, x, "needs a target greater than 0")
+	require.Contains(t, x, "only the queue_depth metric uses one")
+	require.Contains(t, x, "needs a query returning the queue depth")
+	require.Contains(t, x, "needs min_machines >= 1")
+}
+
+func TestConfig_ValidateScaleSchedule(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Processes = map[string]string{"app": "", "worker": ""}
+	cfg.Scale = &Scale{Schedule: []*ScaleSchedule{
+		{Name: "night", Cron: "0 22 * * *", Timezone: "Europe/Paris", Count: fly.Pointer(1)},
+		{Cron: "@weekly", Regions: map[string]int{"iad": 3, "ams": 2}, Size: "performance-2x", Memory: "8gb", Processes: []string{"worker"}},
+	}}
+	x, err := cfg.validateScaleSchedule()
+	require.NoError(t, err, x)
+
+	cfg.Scale = &Scale{Schedule: []*ScaleSchedule{
+		{Cron: "0 25 * * *", Count: fly.Pointer(-1), Processes: []string{"web"}},
+		{Cron: "0 8 * * 1-5", Timezone: "Mars/Olympus", Count: fly.Pointer(2), Regions: map[string]int{"iad": -1}},
+		{Cron: "@every 1h", Size: "shared-cpu-3x", Memory: "lots"},
+		{Name: "noop", Cron: "@daily"},
+	}}
+	x, err = cfg.validateScaleSchedule()
+	require.Error(t, err)
+	require.Contains(t, x, "Scale schedule '0 25 * * *' has an invalid cron expression")
+	require.Contains(t, x, "'web' as one of its processes")
+	require.Contains(t, x, "has a negative count\n")
+	require.Contains(t, x, "Scale schedule '0 8 * * 1-5' has an invalid cron expression or timezone")
+	require.Contains(t, x, "sets both count and regions")
+	require.Contains(t, x, "@every isn't supported")
+	require.Contains(t, x, "has an invalid size")
+	require.Contains(t, x, "has an invalid memory 'lots'")
+	require.Contains(t, x, "Scale schedule 'noop' doesn't change anything")
+}
@@ -0,0 +1,478 @@
+// Package chaos wraps a FlapsClient to inject failures into Machines API
+// calls, to check that deploys recover and roll back the way they should.
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/helpers"
+	"github.com/superfly/flyctl/internal/flapsutil"
+)
+
+var _ flapsutil.FlapsClient = (*Client)(nil)
+
+// Client is a FlapsClient injecting faults according to rules.
+type Client struct {
+	inner flapsutil.FlapsClient
+
+	mu      sync.Mutex
+	rand    *rand.Rand
+	rules   []Rule
+	matched []int // matching calls per rule
+	applied []int // injected faults per rule
+}
+
+// NewClient wraps inner to inject the faults described by cfg.
+func NewClient(inner flapsutil.FlapsClient, cfg *Config) *Client {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Client{
+		inner:   inner,
+		rand:    rand.New(rand.NewSource(seed)), // #nosec G404
+		rules:   cfg.Rules,
+		matched: make([]int, len(cfg.Rules)),
+		applied: make([]int, len(cfg.Rules)),
+	}
+}
+
+// trigger reports whether a call to method for machineID gets the fault of
+// rule i, counting the call.
+func (c *Client) trigger(i int, method, machineID string) bool {
+	r := c.rules[i]
+	if !r.matches(method, machineID) {
+		return false
+	}
+	c.matched[i]++
+	if c.matched[i] <= r.After || (r.Times > 0 && c.applied[i] >= r.Times) {
+		return false
+	}
+	if r.Probability > 0 && c.rand.Float64() >= r.Probability {
+		return false
+	}
+	c.applied[i]++
+	return true
+}
+
+// triggered returns whether any rule with fault f triggers.
+func (c *Client) triggered(f Fault, method, machineID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, r := range c.rules {
+		if r.Fault == f && c.trigger(i, method, machineID) {
+			return true
+		}
+	}
+	return false
+}
+
+// fail returns the error a call to method should fail with, if any.
+func (c *Client) fail(ctx context.Context, method, machineID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, r := range c.rules {
+		switch r.Fault {
+		case LeaseConflict, ServerError, Timeout:
+		default:
+			continue
+		}
+		if !c.trigger(i, method, machineID) {
+			continue
+		}
+
+		switch r.Fault {
+		case LeaseConflict:
+			return apiError(http.StatusConflict, "machine ID %s lease currently held by chaos@fly.io, expires at %s", machineID, time.Now().Add(time.Minute).UTC().Format(time.RFC3339))
+		case ServerError:
+			return apiError(http.StatusInternalServerError, "chaos: injected server error on %s", method)
+		case Timeout:
+			if method == "Wait" {
+				return waitTimeoutError(machineID, "started")
+			}
+			return fmt.Errorf("chaos: injected timeout on %s: %w", method, context.DeadlineExceeded)
+		}
+	}
+	return nil
+}
+
+// stuck reports whether machineID should never get started.
+func (c *Client) stuck(method, machineID string) bool {
+	return c.triggered(StuckStarting, method, machineID)
+}
+
+// mutate applies the faults changing how a machine is reported.
+func (c *Client) mutate(method string, machine *fly.Machine) *fly.Machine {
+	if machine == nil {
+		return nil
+	}
+
+	stuck := machine.State == fly.MachineStateStarted && c.triggered(StuckStarting, method, machine.ID)
+	failing := c.triggered(FailingChecks, method, machine.ID)
+	if !stuck && !failing {
+		return machine
+	}
+
+	machine = helpers.Clone(machine)
+	if stuck {
+		machine.State = "starting"
+	}
+	if failing {
+		now := time.Now()
+		if len(machine.Checks) == 0 {
+			machine.Checks = []*fly.MachineCheckStatus{{Name: "chaos"}}
+		}
+		for _, check := range machine.Checks {
+			check.Status = fly.Critical
+			check.Output = "chaos: injected health check failure"
+			check.UpdatedAt = &now
+		}
+	}
+	return machine
+}
+
+func (c *Client) mutateAll(method string, machines []*fly.Machine) []*fly.Machine {
+	for i, machine := range machines {
+		machines[i] = c.mutate(method, machine)
+	}
+	return machines
+}
+
+func apiError(statusCode int, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	body, _ := json.Marshal(map[string]string{"error": msg})
+	return &flaps.FlapsError{
+		OriginalError:      fmt.Errorf("%s", msg),
+		ResponseStatusCode: statusCode,
+		ResponseBody:       body,
+	}
+}
+
+func waitTimeoutError(machineID, state string) error {
+	return apiError(http.StatusRequestTimeout, "deadline_exceeded: machine %s did not reach state %s", machineID, state)
+}
+
+func (c *Client) NewRequest(ctx context.Context, method, path string, in interface{}, headers map[string][]string) (*http.Request, error) {
+	return c.inner.NewRequest(ctx, method, path, in, headers)
+}
+
+func (c *Client) AcquireLease(ctx context.Context, machineID string, ttl *int) (*fly.MachineLease, error) {
+	if err := c.fail(ctx, "AcquireLease", machineID); err != nil {
+		return nil, err
+	}
+	return c.inner.AcquireLease(ctx, machineID, ttl)
+}
+
+func (c *Client) Cordon(ctx context.Context, machineID string, nonce string) error {
+	if err := c.fail(ctx, "Cordon", machineID); err != nil {
+		return err
+	}
+	return c.inner.Cordon(ctx, machineID, nonce)
+}
+
+func (c *Client) CreateApp(ctx context.Context, name string, org string) error {
+	if err := c.fail(ctx, "CreateApp", ""); err != nil {
+		return err
+	}
+	return c.inner.CreateApp(ctx, name, org)
+}
+
+func (c *Client) CreateVolume(ctx context.Context, req fly.CreateVolumeRequest) (*fly.Volume, error) {
+	if err := c.fail(ctx, "CreateVolume", ""); err != nil {
+		return nil, err
+	}
+	return c.inner.CreateVolume(ctx, req)
+}
+
+func (c *Client) CreateVolumeSnapshot(ctx context.Context, volumeId string) error {
+	if err := c.fail(ctx, "CreateVolumeSnapshot", ""); err != nil {
+		return err
+	}
+	return c.inner.CreateVolumeSnapshot(ctx, volumeId)
+}
+
+func (c *Client) DeleteMetadata(ctx context.Context, machineID, key string) error {
+	if err := c.fail(ctx, "DeleteMetadata", machineID); err != nil {
+		return err
+	}
+	return c.inner.DeleteMetadata(ctx, machineID, key)
+}
+
+func (c *Client) DeleteAppSecret(ctx context.Context, name string) error {
+	if err := c.fail(ctx, "DeleteAppSecret", ""); err != nil {
+		return err
+	}
+	return c.inner.DeleteAppSecret(ctx, name)
+}
+
+func (c *Client) DeleteSecretKey(ctx context.Context, name string) error {
+	if err := c.fail(ctx, "DeleteSecretKey", ""); err != nil {
+		return err
+	}
+	return c.inner.DeleteSecretKey(ctx, name)
+}
+
+func (c *Client) DeleteVolume(ctx context.Context, volumeId string) (*fly.Volume, error) {
+	if err := c.fail(ctx, "DeleteVolume", ""); err != nil {
+		return nil, err
+	}
+	return c.inner.DeleteVolume(ctx, volumeId)
+}
+
+func (c *Client) Destroy(ctx context.Context, input fly.RemoveMachineInput, nonce string) error {
+	if err := c.fail(ctx, "Destroy", input.ID); err != nil {
+		return err
+	}
+	return c.inner.Destroy(ctx, input, nonce)
+}
+
+func (c *Client) Exec(ctx context.Context, machineID string, in *fly.MachineExecRequest) (*fly.MachineExecResponse, error) {
+	if err := c.fail(ctx, "Exec", machineID); err != nil {
+		return nil, err
+	}
+	return c.inner.Exec(ctx, machineID, in)
+}
+
+func (c *Client) ExtendVolume(ctx context.Context, volumeId string, size_gb int) (*fly.Volume, bool, error) {
+	if err := c.fail(ctx, "ExtendVolume", ""); err != nil {
+		return nil, false, err
+	}
+	return c.inner.ExtendVolume(ctx, volumeId, size_gb)
+}
+
+func (c *Client) FindLease(ctx context.Context, machineID string) (*fly.MachineLease, error) {
+	if err := c.fail(ctx, "FindLease", machineID); err != nil {
+		return nil, err
+	}
+	return c.inner.FindLease(ctx, machineID)
+}
+
+func (c *Client) GenerateSecretKey(ctx context.Context, name string, typ string) (*fly.SetSecretKeyResp, error) {
+	if err := c.fail(ctx, "GenerateSecretKey", ""); err != nil {
+		return nil, err
+	}
+	return c.inner.GenerateSecretKey(ctx, name, typ)
+}
+
+func (c *Client) Get(ctx context.Context, machineID string) (*fly.Machine, error) {
+	if err := c.fail(ctx, "Get", machineID); err != nil {
+		return nil, err
+	}
+	machine, err := c.inner.Get(ctx, machineID)
+	return c.mutate("Get", machine), err
+}
+
+func (c *Client) GetAllVolumes(ctx context.Context) ([]fly.Volume, error) {
+	if err := c.fail(ctx, "GetAllVolumes", ""); err != nil {
+		return nil, err
+	}
+	return c.inner.GetAllVolumes(ctx)
+}
+
+func (c *Client) GetMany(ctx context.Context, machineIDs []string) ([]*fly.Machine, error) {
+	if err := c.fail(ctx, "GetMany", ""); err != nil {
+		return nil, err
+	}
+	machines, err := c.inner.GetMany(ctx, machineIDs)
+	return c.mutateAll("GetMany", machines), err
+}
+
+func (c *Client) GetMetadata(ctx context.Context, machineID string) (map[string]string, error) {
+	if err := c.fail(ctx, "GetMetadata", machineID); err != nil {
+		return nil, err
+	}
+	return c.inner.GetMetadata(ctx, machineID)
+}
+
+func (c *Client) GetProcesses(ctx context.Context, machineID string) (fly.MachinePsResponse, error) {
+	if err := c.fail(ctx, "GetProcesses", machineID); err != nil {
+		return nil, err
+	}
+	return c.inner.GetProcesses(ctx, machineID)
+}
+
+func (c *Client) GetVolume(ctx context.Context, volumeId string) (*fly.Volume, error) {
+	if err := c.fail(ctx, "GetVolume", ""); err != nil {
+		return nil, err
+	}
+	return c.inner.GetVolume(ctx, volumeId)
+}
+
+func (c *Client) GetVolumeSnapshots(ctx context.Context, volumeId string) ([]fly.VolumeSnapshot, error) {
+	if err := c.fail(ctx, "GetVolumeSnapshots", ""); err != nil {
+		return nil, err
+	}
+	return c.inner.GetVolumeSnapshots(ctx, volumeId)
+}
+
+func (c *Client) GetVolumes(ctx context.Context) ([]fly.Volume, error) {
+	if err := c.fail(ctx, "GetVolumes", ""); err != nil {
+		return nil, err
+	}
+	return c.inner.GetVolumes(ctx)
+}
+
+func (c *Client) Kill(ctx context.Context, machineID string) error {
+	if err := c.fail(ctx, "Kill", machineID); err != nil {
+		return err
+	}
+	return c.inner.Kill(ctx, machineID)
+}
+
+func (c *Client) Launch(ctx context.Context, builder fly.LaunchMachineInput) (*fly.Machine, error) {
+	if err := c.fail(ctx, "Launch", ""); err != nil {
+		return nil, err
+	}
+	machine, err := c.inner.Launch(ctx, builder)
+	return c.mutate("Launch", machine), err
+}
+
+func (c *Client) List(ctx context.Context, state string) ([]*fly.Machine, error) {
+	if err := c.fail(ctx, "List", ""); err != nil {
+		return nil, err
+	}
+	machines, err := c.inner.List(ctx, state)
+	return c.mutateAll("List", machines), err
+}
+
+func (c *Client) ListActive(ctx context.Context) ([]*fly.Machine, error) {
+	if err := c.fail(ctx, "ListActive", ""); err != nil {
+		return nil, err
+	}
+	machines, err := c.inner.ListActive(ctx)
+	return c.mutateAll("ListActive", machines), err
+}
+
+func (c *Client) ListFlyAppsMachines(ctx context.Context) ([]*fly.Machine, *fly.Machine, error) {
+	if err := c.fail(ctx, "ListFlyAppsMachines", ""); err != nil {
+		return nil, nil, err
+	}
+	machines, releaseCmdMachine, err := c.inner.ListFlyAppsMachines(ctx)
+	return c.mutateAll("ListFlyAppsMachines", machines), releaseCmdMachine, err
+}
+
+func (c *Client) ListAppSecrets(ctx context.Context, version *uint64, showSecrets bool) ([]fly.AppSecret, error) {
+	if err := c.fail(ctx, "ListAppSecrets", ""); err != nil {
+		return nil, err
+	}
+	return c.inner.ListAppSecrets(ctx, version, showSecrets)
+}
+
+func (c *Client) ListSecretKeys(ctx context.Context, version *uint64) ([]fly.SecretKey, error) {
+	if err := c.fail(ctx, "ListSecretKeys", ""); err != nil {
+		return nil, err
+	}
+	return c.inner.ListSecretKeys(ctx, version)
+}
+
+func (c *Client) RefreshLease(ctx context.Context, machineID string, ttl *int, nonce string) (*fly.MachineLease, error) {
+	if err := c.fail(ctx, "RefreshLease", machineID); err != nil {
+		return nil, err
+	}
+	return c.inner.RefreshLease(ctx, machineID, ttl, nonce)
+}
+
+func (c *Client) ReleaseLease(ctx context.Context, machineID, nonce string) error {
+	if err := c.fail(ctx, "ReleaseLease", machineID); err != nil {
+		return err
+	}
+	return c.inner.ReleaseLease(ctx, machineID, nonce)
+}
+
+func (c *Client) Restart(ctx context.Context, in fly.RestartMachineInput, nonce string) error {
+	if err := c.fail(ctx, "Restart", in.ID); err != nil {
+		return err
+	}
+	return c.inner.Restart(ctx, in, nonce)
+}
+
+func (c *Client) SetAppSecret(ctx context.Context, name string, value string) (*fly.SetAppSecretResp, error) {
+	if err := c.fail(ctx, "SetAppSecret", ""); err != nil {
+		return nil, err
+	}
+	return c.inner.SetAppSecret(ctx, name, value)
+}
+
+func (c *Client) SetSecretKey(ctx context.Context, name string, typ string, value []byte) (*fly.SetSecretKeyResp, error) {
+	if err := c.fail(ctx, "SetSecretKey", ""); err != nil {
+		return nil, err
+	}
+	return c.inner.SetSecretKey(ctx, name, typ, value)
+}
+
+func (c *Client) SetMetadata(ctx context.Context, machineID, key, value string) error {
+	if err := c.fail(ctx, "SetMetadata", machineID); err != nil {
+		return err
+	}
+	return c.inner.SetMetadata(ctx, machineID, key, value)
+}
+
+func (c *Client) Start(ctx context.Context, machineID string, nonce string) (*fly.MachineStartResponse, error) {
+	if err := c.fail(ctx, "Start", machineID); err != nil {
+		return nil, err
+	}
+	return c.inner.Start(ctx, machineID, nonce)
+}
+
+func (c *Client) Stop(ctx context.Context, in fly.StopMachineInput, nonce string) error {
+	if err := c.fail(ctx, "Stop", in.ID); err != nil {
+		return err
+	}
+	return c.inner.Stop(ctx, in, nonce)
+}
+
+func (c *Client) Suspend(ctx context.Context, machineID, nonce string) error {
+	if err := c.fail(ctx, "Suspend", machineID); err != nil {
+		return err
+	}
+	return c.inner.Suspend(ctx, machineID, nonce)
+}
+
+func (c *Client) Uncordon(ctx context.Context, machineID string, nonce string) error {
+	if err := c.fail(ctx, "Uncordon", machineID); err != nil {
+		return err
+	}
+	return c.inner.Uncordon(ctx, machineID, nonce)
+}
+
+func (c *Client) Update(ctx context.Context, builder fly.LaunchMachineInput, nonce string) (*fly.Machine, error) {
+	if err := c.fail(ctx, "Update", builder.ID); err != nil {
+		return nil, err
+	}
+	machine, err := c.inner.Update(ctx, builder, nonce)
+	return c.mutate("Update", machine), err
+}
+
+func (c *Client) UpdateVolume(ctx context.Context, volumeId string, req fly.UpdateVolumeRequest) (*fly.Volume, error) {
+	if err := c.fail(ctx, "UpdateVolume", ""); err != nil {
+		return nil, err
+	}
+	return c.inner.UpdateVolume(ctx, volumeId, req)
+}
+
+func (c *Client) Wait(ctx context.Context, machine *fly.Machine, state string, timeout time.Duration) error {
+	if err := c.fail(ctx, "Wait", machine.ID); err != nil {
+		return err
+	}
+	if state == fly.MachineStateStarted && c.stuck("Wait", machine.ID) {
+		return waitTimeoutError(machine.ID, state)
+	}
+	return c.inner.Wait(ctx, machine, state, timeout)
+}
+
+func (c *Client) WaitForApp(ctx context.Context, name string) error {
+	if err := c.fail(ctx, "WaitForApp", ""); err != nil {
+		return err
+	}
+	return c.inner.WaitForApp(ctx, name)
+}
@@ -0,0 +1,105 @@
+package chaos
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/inmem"
+)
+
+func newTestClient(t *testing.T, spec string) (*Client, *fly.Machine) {
+	t.Helper()
+
+	server := inmem.NewServer()
+	server.CreateApp(&fly.App{Name: "myapp"})
+	inner := server.FlapsClient("myapp")
+
+	machine, err := inner.Launch(context.Background(), fly.LaunchMachineInput{Config: &fly.MachineConfig{Image: "nginx"}})
+	require.NoError(t, err)
+
+	cfg, err := Load(spec)
+	require.NoError(t, err)
+	return NewClient(inner, cfg), machine
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load("Update=server_error:0.5, lease_conflict")
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{
+		{Method: "Update", Fault: ServerError, Probability: 0.5},
+		{Fault: LeaseConflict},
+	}, cfg.Rules)
+
+	path := filepath.Join(t.TempDir(), "chaos.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+seed = 42
+
+[[rule]]
+fault = "timeout"
+method = "Wait"
+after = 1
+times = 2
+`), 0o644))
+	cfg, err = Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, &Config{Seed: 42, Rules: []Rule{{Fault: Timeout, Method: "Wait", After: 1, Times: 2}}}, cfg)
+
+	_, err = Load("Update=meteor")
+	assert.ErrorContains(t, err, `unknown fault "meteor"`)
+	_, err = Load("server_error:2")
+	assert.Error(t, err)
+}
+
+func TestErrorFaults(t *testing.T) {
+	ctx := context.Background()
+	client, machine := newTestClient(t, "AcquireLease=lease_conflict,Stop=server_error,Get=timeout")
+
+	_, err := client.AcquireLease(ctx, machine.ID, nil)
+	assert.ErrorIs(t, err, &flaps.FlapsError{ResponseStatusCode: http.StatusConflict})
+	assert.ErrorContains(t, err, "lease currently held by", "deploys treat this as unrecoverable")
+
+	err = client.Stop(ctx, fly.StopMachineInput{ID: machine.ID}, "")
+	assert.ErrorIs(t, err, &flaps.FlapsError{ResponseStatusCode: http.StatusInternalServerError})
+
+	_, err = client.Get(ctx, machine.ID)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = client.List(ctx, "")
+	assert.NoError(t, err, "other methods are left alone")
+}
+
+func TestAfterAndTimes(t *testing.T) {
+	ctx := context.Background()
+	client, machine := newTestClient(t, "server_error")
+
+	client.rules = []Rule{{Method: "Get", Fault: ServerError, After: 1, Times: 2}}
+	client.matched, client.applied = make([]int, 1), make([]int, 1)
+
+	var failures []bool
+	for range 5 {
+		_, err := client.Get(ctx, machine.ID)
+		failures = append(failures, err != nil)
+	}
+	assert.Equal(t, []bool{false, true, true, false, false}, failures)
+}
+
+func TestMachineFaults(t *testing.T) {
+	ctx := context.Background()
+	client, machine := newTestClient(t, "stuck_starting,failing_checks")
+
+	got, err := client.Get(ctx, machine.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "starting", got.State)
+	assert.Equal(t, 1, got.AllHealthChecks().Critical)
+
+	err = client.Wait(ctx, machine, fly.MachineStateStarted, time.Second)
+	assert.ErrorIs(t, err, &flaps.FlapsError{ResponseStatusCode: http.StatusRequestTimeout})
+}
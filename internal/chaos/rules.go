@@ -0,0 +1,129 @@
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Fault is a kind of failure injected into Machines API calls.
+type Fault string
+
+const (
+	// LeaseConflict fails calls with a 409, as if someone else held the
+	// machine's lease.
+	LeaseConflict Fault = "lease_conflict"
+	// ServerError fails calls with a 500.
+	ServerError Fault = "server_error"
+	// Timeout fails calls as if they timed out.
+	Timeout Fault = "timeout"
+	// StuckStarting reports started machines as starting, and makes waiting
+	// for them to start time out.
+	StuckStarting Fault = "stuck_starting"
+	// FailingChecks reports every health check of machines as critical.
+	FailingChecks Fault = "failing_checks"
+)
+
+var faults = []Fault{LeaseConflict, ServerError, Timeout, StuckStarting, FailingChecks}
+
+// Rule describes when to inject a fault.
+type Rule struct {
+	// Fault is the failure to inject.
+	Fault Fault `toml:"fault"`
+	// Method is the FlapsClient method to fail, e.g. "Update". Empty or "*"
+	// matches every method.
+	Method string `toml:"method,omitempty"`
+	// Machine restricts the rule to one machine ID.
+	Machine string `toml:"machine,omitempty"`
+	// Probability of injecting the fault on a matching call, 1 if unset.
+	Probability float64 `toml:"probability,omitempty"`
+	// After skips that many matching calls before injecting the fault.
+	After int `toml:"after,omitempty"`
+	// Times limits how many times the fault is injected, 0 for no limit.
+	Times int `toml:"times,omitempty"`
+}
+
+// Config is a set of rules, as read from a rules file.
+type Config struct {
+	// Seed makes the faults injected with a probability reproducible.
+	Seed  int64  `toml:"seed,omitempty"`
+	Rules []Rule `toml:"rule"`
+}
+
+func (r Rule) validate() error {
+	found := false
+	for _, f := range faults {
+		found = found || f == r.Fault
+	}
+	switch {
+	case !found:
+		return fmt.Errorf("unknown fault %q, expected one of %v", r.Fault, faults)
+	case r.Probability < 0 || r.Probability > 1:
+		return fmt.Errorf("probability of %s must be between 0 and 1", r.Fault)
+	case r.After < 0 || r.Times < 0:
+		return fmt.Errorf("after and times of %s can't be negative", r.Fault)
+	}
+	return nil
+}
+
+func (r Rule) matches(method, machineID string) bool {
+	if r.Method != "" && r.Method != "*" && r.Method != method {
+		return false
+	}
+	return r.Machine == "" || r.Machine == machineID
+}
+
+// Load reads rules from spec, which is either the path of a TOML rules file
+// or a comma separated list of [method=]fault[:probability], e.g.
+// "Update=server_error:0.5,Wait=timeout".
+func Load(spec string) (*Config, error) {
+	data, err := os.ReadFile(spec)
+	switch {
+	case err == nil:
+		var cfg Config
+		if err := toml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse chaos rules file %s: %w", spec, err)
+		}
+		return &cfg, cfg.validate()
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	cfg := &Config{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var rule Rule
+		if method, rest, ok := strings.Cut(part, "="); ok {
+			rule.Method, part = method, rest
+		}
+		fault, probability, ok := strings.Cut(part, ":")
+		rule.Fault = Fault(fault)
+		if ok {
+			if rule.Probability, err = strconv.ParseFloat(probability, 64); err != nil {
+				return nil, fmt.Errorf("invalid probability %q for %s", probability, fault)
+			}
+		}
+		cfg.Rules = append(cfg.Rules, rule)
+	}
+	if len(cfg.Rules) == 0 {
+		return nil, fmt.Errorf("no chaos rules in %q", spec)
+	}
+	return cfg, cfg.validate()
+}
+
+func (cfg *Config) validate() error {
+	for _, r := range cfg.Rules {
+		if err := r.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
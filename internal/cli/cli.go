@@ -59,7 +59,10 @@ func Run(ctx context.Context, io *iostreams.IOStreams, args ...string) int {
 	// initialize the background task runner early so command preparers can start running stuff immediately
 	ctx = task.NewWithContext(ctx)
 
-	httptracing.Init()
+	if err := httptracing.Init(); err != nil {
+		fmt.Fprint(io.ErrOut, io.ColorScheme().Red("Error: "), err.Error(), "\n")
+		return 1
+	}
 	defer httptracing.Finish()
 
 	cmd := root.New()
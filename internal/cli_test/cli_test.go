@@ -2,17 +2,35 @@ package cli_test
 
 import (
 	"context"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
 
 	"github.com/superfly/flyctl/iostreams"
 
 	"github.com/superfly/flyctl/internal/cli"
+	"github.com/superfly/flyctl/internal/inmem"
 )
 
+// flyctlEnv makes the test binary run as flyctl, so tests can run commands in
+// their own process: flyctl records metrics for one command per process.
+const flyctlEnv = "FLYCTL_CLI_TEST_RUN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(flyctlEnv) != "" {
+		os.Exit(cli.Run(context.Background(), iostreams.System(), os.Args[1:]...))
+	}
+	os.Exit(m.Run())
+}
+
 func TestVersion(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
@@ -25,6 +43,46 @@ func TestVersion(t *testing.T) {
 	assert.Empty(t, stderr)
 }
 
+// TestDeployAndScaleCount runs fly deploy and fly scale count against the
+// in-memory emulation of the Machines and GraphQL APIs.
+func TestDeployAndScaleCount(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	server := inmem.NewServer()
+	server.CreateApp(&fly.App{Name: "myapp"})
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+
+	t.Setenv("FLY_FLAPS_BASE_URL", ts.URL)
+	t.Setenv("FLY_API_BASE_URL", ts.URL)
+	t.Setenv("FLY_ACCESS_TOKEN", "test")
+	t.Setenv("FLY_NO_UPDATE_CHECK", "1")
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fly.toml"), []byte("app = \"myapp\"\nprimary_region = \"ord\"\n"), 0o644))
+	t.Chdir(dir)
+
+	flyctl(ctx, t, "deploy", "--image", "nginx:latest", "--ha=false", "-y")
+
+	machines, err := server.ListMachines(ctx, "myapp", "")
+	require.NoError(t, err)
+	require.Len(t, machines, 1)
+	assert.Equal(t, "nginx:latest", machines[0].Config.Image)
+
+	releases, err := server.ListReleases(ctx, "myapp", "", 0)
+	require.NoError(t, err)
+	require.Len(t, releases, 1)
+	assert.Equal(t, "complete", releases[0].Status)
+
+	flyctl(ctx, t, "scale", "count", "3", "-y")
+
+	machines, err = server.ListMachines(ctx, "myapp", "")
+	require.NoError(t, err)
+	assert.Len(t, machines, 3)
+}
+
 func capture(ctx context.Context, t *testing.T, args ...string) (stdout, stderr string, code int) {
 	t.Helper()
 
@@ -42,3 +100,14 @@ func capture(ctx context.Context, t *testing.T, args ...string) (stdout, stderr
 
 	return
 }
+
+// flyctl runs a flyctl command in a process of its own and fails the test if
+// it fails.
+func flyctl(ctx context.Context, t *testing.T, args ...string) {
+	t.Helper()
+
+	cmd := exec.CommandContext(ctx, os.Args[0], args...)
+	cmd.Env = append(os.Environ(), flyctlEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
@@ -0,0 +1,187 @@
+// Package autoscale implements a controller keeping the machine count of
+// process groups within the [[autoscale]] policies of fly.toml.
+package autoscale
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/terminal"
+)
+
+func New() *cobra.Command {
+	const (
+		short = "Scale machine counts on metrics"
+		long  = `Scale the machine count of process groups on metrics, following the
+[[autoscale]] policies of fly.toml:
+
+  [[autoscale]]
+    processes = ["app"]
+    min_machines = 2
+    max_machines = 10
+    metric = "concurrency"    # or "cpu", or "queue_depth" with a query
+    target = 50               # per machine
+    scale_up_cooldown = "1m"
+    scale_down_cooldown = "5m"
+
+Metrics come from the organization's Fly.io metrics, or the Prometheus-compatible
+API at prometheus_url. Machines are created and destroyed like ` + "`fly scale count`" + ` does.`
+	)
+	cmd := command.New("autoscale", short, long, nil)
+	cmd.AddCommand(
+		newRun(),
+		newStatus(),
+	)
+	return cmd
+}
+
+func newRun() *cobra.Command {
+	const (
+		short = "Run the autoscaler"
+		long  = `Evaluate the autoscale policies of the app every interval and scale process
+groups to the machine count their metric asks for, until interrupted.
+
+Policies are read from fly.toml when it has any, from the deployed config
+otherwise, which picks up changes made by deploys. When a group was last
+scaled is recorded on its machines, so restarting the autoscaler doesn't skip
+cooldowns.`
+	)
+	cmd := command.New("run", short, long, runRun,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.NoArgs
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Duration{
+			Name:        "interval",
+			Description: "Time between evaluations of the policies",
+			Default:     30 * time.Second,
+		},
+		flag.Bool{
+			Name:        "dry-run",
+			Description: "Log what would be scaled without scaling",
+		},
+		flag.Bool{
+			Name:        "once",
+			Description: "Evaluate the policies once and exit",
+		},
+	)
+	return cmd
+}
+
+func newStatus() *cobra.Command {
+	const (
+		short = "Show what the autoscaler would do"
+		long  = `Evaluate the autoscale policies of the app once and show the metric values and
+the machine count each process group would be scaled to.`
+	)
+	cmd := command.New("status", short, long, runStatus,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.NoArgs
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.JSONOutput(),
+	)
+	return cmd
+}
+
+func withFlapsClient(ctx context.Context) (context.Context, error) {
+	flapsClient, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+		AppName: appconfig.NameFromContext(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return flapsutil.NewContextWithClient(ctx, flapsClient), nil
+}
+
+func runRun(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+	ctx, err := withFlapsClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	interval := flag.GetDuration(ctx, "interval")
+	if interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+
+	c := newController(appconfig.NameFromContext(ctx), io.Out)
+	c.dryRun = flag.GetBool(ctx, "dry-run")
+
+	fmt.Fprintf(io.ErrOut, "Autoscaling %s every %s, press Ctrl+C to stop\n", c.appName, interval)
+	for {
+		if err := c.tick(ctx); err != nil {
+			if flag.GetBool(ctx, "once") {
+				return err
+			}
+			terminal.Warnf("Autoscaling %s failed: %v\n", c.appName, err)
+		}
+		if flag.GetBool(ctx, "once") {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func runStatus(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+	ctx, err := withFlapsClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	c := newController(appconfig.NameFromContext(ctx), io.Out)
+	appConfig, err := c.loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	decisions, err := c.evaluate(ctx, appConfig, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if flag.GetBool(ctx, "json") {
+		return render.JSON(io.Out, decisions)
+	}
+
+	rows := make([][]string, 0, len(decisions))
+	for _, d := range decisions {
+		value := "-"
+		if d.Error == "" {
+			value = strconv.FormatFloat(d.Value, 'f', 2, 64)
+		}
+		rows = append(rows, []string{
+			d.Group,
+			d.Metric,
+			value,
+			strconv.FormatFloat(d.Target, 'f', -1, 64),
+			fmt.Sprintf("%d-%d", d.Min, d.Max),
+			strconv.Itoa(d.Current),
+			strconv.Itoa(d.Desired),
+			d.note(),
+		})
+	}
+	return render.Table(io.Out, "", rows, "Process Group", "Metric", "Value", "Target", "Range", "Machines", "Desired", "Note")
+}
@@ -0,0 +1,308 @@
+package autoscale
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command/scale"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/terminal"
+)
+
+const (
+	defaultScaleUpCooldown   = time.Minute
+	defaultScaleDownCooldown = 5 * time.Minute
+
+	// Groups whose metric is within tolerance of the target are left alone,
+	// so that noise doesn't make them flap.
+	tolerance = 0.1
+
+	// scaledAtMetadataKey records on a group's machines when the group was
+	// last scaled, so cooldowns hold across restarts and several autoscalers.
+	scaledAtMetadataKey = "fly_autoscaled_at"
+
+	flyPrometheusURL = "https://api.fly.io/prometheus/"
+	queryTimeout     = 30 * time.Second
+)
+
+// decision is what evaluating the policy of a process group came to.
+type decision struct {
+	Group   string  `json:"process_group"`
+	Metric  string  `json:"metric"`
+	Value   float64 `json:"value"`
+	Target  float64 `json:"target"`
+	Min     int     `json:"min_machines"`
+	Max     int     `json:"max_machines"`
+	Current int     `json:"machines"`
+	Desired int     `json:"desired"`
+	// Cooldown is how long until the group may be scaled to Desired.
+	Cooldown time.Duration `json:"cooldown,omitempty"`
+	Error    string        `json:"error,omitempty"`
+
+	regions []string
+}
+
+// scale reports whether the group is to be scaled now.
+func (d *decision) scale() bool {
+	return d.Error == "" && d.Cooldown == 0 && d.Desired != d.Current
+}
+
+func (d *decision) note() string {
+	switch {
+	case d.Error != "":
+		return d.Error
+	case d.Desired == d.Current:
+		return ""
+	case d.Cooldown > 0:
+		return fmt.Sprintf("cooling down for %s", d.Cooldown.Round(time.Second))
+	case d.Desired > d.Current:
+		return "scale up"
+	default:
+		return "scale down"
+	}
+}
+
+func (d *decision) String() string {
+	if d.Error != "" {
+		return fmt.Sprintf("%d machines, %s: %s", d.Current, d.Metric, d.Error)
+	}
+	s := fmt.Sprintf("%d machines, %s %.2f for a target of %g", d.Current, d.Metric, d.Value, d.Target)
+	if d.Desired != d.Current {
+		s += fmt.Sprintf(", %s to %d", d.note(), d.Desired)
+	}
+	return s
+}
+
+// controller evaluates the autoscale policies of an app and scales its
+// process groups.
+type controller struct {
+	appName string
+	out     io.Writer
+	dryRun  bool
+	client  *http.Client
+
+	// lastScaled is when each process group was last scaled, for cooldowns.
+	// It's also read from the group's machines, see scaledAtMetadataKey.
+	lastScaled map[string]time.Time
+	orgSlug    string
+}
+
+func newController(appName string, out io.Writer) *controller {
+	return &controller{
+		appName:    appName,
+		out:        out,
+		client:     &http.Client{Timeout: queryTimeout},
+		lastScaled: map[string]time.Time{},
+	}
+}
+
+// loadConfig returns the app config from fly.toml if it has autoscale
+// policies, the deployed config otherwise.
+func (c *controller) loadConfig(ctx context.Context) (*appconfig.Config, error) {
+	if cfg := appconfig.ConfigFromContext(ctx); cfg != nil && len(cfg.Autoscale) > 0 {
+		if err, extraInfo := cfg.Validate(ctx); err != nil {
+			fmt.Fprintln(iostreams.FromContext(ctx).ErrOut, extraInfo)
+			return nil, err
+		}
+		return cfg, nil
+	}
+
+	cfg, err := appconfig.FromRemoteApp(ctx, c.appName)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Autoscale) == 0 {
+		return nil, fmt.Errorf("%s has no [[autoscale]] policies, add some to fly.toml", c.appName)
+	}
+	return cfg, nil
+}
+
+// tick evaluates the policies once and scales the process groups that need
+// it. A group failing to scale doesn't keep the others from scaling.
+func (c *controller) tick(ctx context.Context) error {
+	appConfig, err := c.loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	decisions, err := c.evaluate(ctx, appConfig, now)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range decisions {
+		fmt.Fprintf(c.out, "%s %s: %s\n", now.Format(time.RFC3339), d.Group, d)
+		if !d.scale() || c.dryRun {
+			continue
+		}
+		if err := scale.ScaleCounts(ctx, c.appName, appConfig, map[string]int{d.Group: d.Desired}, d.regions); err != nil {
+			terminal.Warnf("Scaling %s to %d machines failed: %v\n", d.Group, d.Desired, err)
+			continue
+		}
+		c.recordScaled(ctx, d.Group, time.Now())
+	}
+	return nil
+}
+
+// recordScaled notes that group was scaled at, on the controller and on the
+// group's machines.
+func (c *controller) recordScaled(ctx context.Context, group string, at time.Time) {
+	c.lastScaled[group] = at
+
+	flapsClient := flapsutil.ClientFromContext(ctx)
+	machines, _, err := flapsClient.ListFlyAppsMachines(ctx)
+	if err != nil {
+		terminal.Warnf("Recording when %s was scaled failed, other autoscalers may scale it again before the cooldown: %v\n", group, err)
+		return
+	}
+	for _, m := range machines {
+		if m.Config == nil || m.ProcessGroup() != group {
+			continue
+		}
+		if err := flapsClient.SetMetadata(ctx, m.ID, scaledAtMetadataKey, at.UTC().Format(time.RFC3339)); err != nil {
+			terminal.Warnf("Recording when %s was scaled on machine %s failed: %v\n", group, m.ID, err)
+		}
+	}
+}
+
+// observeScaled picks up when group was last scaled from its machines, which
+// may have been by another autoscaler.
+func (c *controller) observeScaled(group string, machines []*fly.Machine) {
+	for _, m := range machines {
+		at, err := time.Parse(time.RFC3339, m.Config.Metadata[scaledAtMetadataKey])
+		if err == nil && at.After(c.lastScaled[group]) {
+			c.lastScaled[group] = at
+		}
+	}
+}
+
+// evaluate works out the machine count of each process group with a policy.
+func (c *controller) evaluate(ctx context.Context, appConfig *appconfig.Config, now time.Time) ([]*decision, error) {
+	machines, _, err := flapsutil.ClientFromContext(ctx).ListFlyAppsMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups := lo.GroupBy(
+		lo.Filter(machines, func(m *fly.Machine, _ int) bool { return m.Config != nil }),
+		func(m *fly.Machine) string { return m.ProcessGroup() },
+	)
+
+	var decisions []*decision
+	for _, name := range appConfig.ProcessNames() {
+		policy := appConfig.AutoscaleForGroup(name)
+		if policy == nil {
+			continue
+		}
+
+		d := &decision{
+			Group:   name,
+			Metric:  policy.Metric,
+			Target:  policy.Target,
+			Min:     policy.MinMachines,
+			Max:     policy.MaxMachines,
+			Current: len(groups[name]),
+			Desired: len(groups[name]),
+			regions: policy.Regions,
+		}
+		decisions = append(decisions, d)
+
+		value, err := c.metric(ctx, policy, groups[name])
+		if err != nil {
+			d.Error = err.Error()
+			continue
+		}
+		d.Value = value
+		d.Desired = desiredCount(policy, d.Current, value)
+		c.observeScaled(name, groups[name])
+		d.Cooldown = c.cooldown(policy, name, d.Current, d.Desired, now)
+	}
+	return decisions, nil
+}
+
+// desiredCount is the machine count bringing a group's metric to the target
+// per machine, within the range of the policy. CPU is an average over the
+// group's machines, the other metrics are totals.
+func desiredCount(policy *appconfig.Autoscale, current int, value float64) int {
+	desired := value / policy.Target
+	if policy.Metric == appconfig.AutoscaleMetricCPU {
+		desired *= float64(current)
+	}
+
+	count := current
+	if current == 0 || math.Abs(desired/float64(current)-1) > tolerance {
+		count = int(math.Ceil(desired - 1e-9))
+	}
+	return min(max(count, policy.MinMachines), policy.MaxMachines)
+}
+
+// cooldown is how long a group must wait before being scaled from current
+// to desired machines.
+func (c *controller) cooldown(policy *appconfig.Autoscale, group string, current, desired int, now time.Time) time.Duration {
+	last, ok := c.lastScaled[group]
+	if !ok || desired == current {
+		return 0
+	}
+
+	wait := defaultScaleDownCooldown
+	if policy.ScaleDownCooldown != nil {
+		wait = policy.ScaleDownCooldown.Duration
+	}
+	if desired > current {
+		wait = defaultScaleUpCooldown
+		if policy.ScaleUpCooldown != nil {
+			wait = policy.ScaleUpCooldown.Duration
+		}
+	}
+	return max(last.Add(wait).Sub(now), 0)
+}
+
+// metric queries the value of a policy's metric for the machines of a group.
+func (c *controller) metric(ctx context.Context, policy *appconfig.Autoscale, machines []*fly.Machine) (float64, error) {
+	query := policy.Query
+	if policy.Metric != appconfig.AutoscaleMetricQueueDepth {
+		if len(machines) == 0 {
+			return 0, nil
+		}
+		query = metricQuery(policy.Metric, c.appName, machines)
+	}
+
+	api := &promAPI{url: os.ExpandEnv(policy.PrometheusURL), client: c.client}
+	if policy.PrometheusURL == "" {
+		if c.orgSlug == "" {
+			app, err := flyutil.ClientFromContext(ctx).GetAppCompact(ctx, c.appName)
+			if err != nil {
+				return 0, err
+			}
+			c.orgSlug = app.Organization.Slug
+		}
+		api.url = flyPrometheusURL + c.orgSlug
+		api.authorization = fly.AuthorizationHeader(config.Tokens(ctx).GraphQL())
+	}
+	return api.query(ctx, query)
+}
+
+// metricQuery is the query of a built-in metric over the given machines of
+// an app, from the metrics Fly.io collects.
+func metricQuery(metric, appName string, machines []*fly.Machine) string {
+	ids := lo.Map(machines, func(m *fly.Machine, _ int) string { return m.ID })
+	selector := fmt.Sprintf(`app=%q,instance=~%q`, appName, strings.Join(ids, "|"))
+
+	switch metric {
+	case appconfig.AutoscaleMetricCPU:
+		return fmt.Sprintf(`100 * (1 - sum(rate(fly_instance_cpu{%s,mode="idle"}[1m])) / sum(rate(fly_instance_cpu{%s}[1m])))`, selector, selector)
+	default:
+		return fmt.Sprintf(`sum(fly_app_concurrency{%s})`, selector)
+	}
+}
@@ -0,0 +1,140 @@
+package autoscale
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/inmem"
+)
+
+func TestDesiredCount(t *testing.T) {
+	concurrency := &appconfig.Autoscale{MinMachines: 1, MaxMachines: 10, Metric: appconfig.AutoscaleMetricConcurrency, Target: 50}
+	cpu := &appconfig.Autoscale{MinMachines: 2, MaxMachines: 8, Metric: appconfig.AutoscaleMetricCPU, Target: 60}
+
+	tests := []struct {
+		name    string
+		policy  *appconfig.Autoscale
+		current int
+		value   float64
+		want    int
+	}{
+		{"concurrency up", concurrency, 2, 230, 5},
+		{"concurrency down", concurrency, 5, 60, 2},
+		{"concurrency within tolerance", concurrency, 4, 210, 4},
+		{"concurrency clamped to max", concurrency, 4, 5000, 10},
+		{"concurrency clamped to min", concurrency, 3, 0, 1},
+		{"cpu up", cpu, 4, 90, 6},
+		{"cpu down", cpu, 6, 20, 2},
+		{"cpu within tolerance", cpu, 4, 63, 4},
+		{"no machines", concurrency, 0, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, desiredCount(tt.policy, tt.current, tt.value))
+		})
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	now := time.Now()
+	c := newController("app", io.Discard)
+	policy := &appconfig.Autoscale{ScaleUpCooldown: fly.MustParseDuration("30s")}
+
+	assert.Zero(t, c.cooldown(policy, "web", 2, 4, now))
+
+	c.lastScaled["web"] = now.Add(-10 * time.Second)
+	assert.Equal(t, 20*time.Second, c.cooldown(policy, "web", 2, 4, now))
+	assert.Equal(t, defaultScaleDownCooldown-10*time.Second, c.cooldown(policy, "web", 4, 2, now))
+	assert.Zero(t, c.cooldown(policy, "web", 2, 2, now))
+	assert.Zero(t, c.cooldown(policy, "web", 2, 4, now.Add(time.Minute)))
+}
+
+func TestPromAPIQuery(t *testing.T) {
+	responses := map[string]string{
+		"vector": `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"instance":"a"},"value":[1700000000,"12.5"]},{"metric":{"instance":"b"},"value":[1700000000,"7.5"]}]}}`,
+		"scalar": `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"42"]}}`,
+		"empty":  `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+		"nan":    `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"NaN"]}]}}`,
+		"error":  `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/prometheus/my-org/api/v1/query", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(responses[r.URL.Query().Get("query")]))
+	}))
+	defer server.Close()
+
+	api := &promAPI{url: server.URL + "/prometheus/my-org", authorization: "Bearer token", client: server.Client()}
+	ctx := context.Background()
+
+	v, err := api.query(ctx, "vector")
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, v)
+
+	v, err = api.query(ctx, "scalar")
+	require.NoError(t, err)
+	assert.Equal(t, 42.0, v)
+
+	_, err = api.query(ctx, "empty")
+	assert.ErrorContains(t, err, "no data")
+	_, err = api.query(ctx, "nan")
+	assert.ErrorContains(t, err, "no data")
+	_, err = api.query(ctx, "error")
+	assert.ErrorContains(t, err, "parse error")
+}
+
+func TestMetricQuery(t *testing.T) {
+	machines := []*fly.Machine{{ID: "1781973f3d2e89"}, {ID: "9080e6f3a12387"}}
+	assert.Equal(t,
+		`sum(fly_app_concurrency{app="my-app",instance=~"1781973f3d2e89|9080e6f3a12387"})`,
+		metricQuery(appconfig.AutoscaleMetricConcurrency, "my-app", machines),
+	)
+	assert.Contains(t,
+		metricQuery(appconfig.AutoscaleMetricCPU, "my-app", machines),
+		`rate(fly_instance_cpu{app="my-app",instance=~"1781973f3d2e89|9080e6f3a12387",mode="idle"}[1m])`,
+	)
+}
+
+func TestCooldownFromMachines(t *testing.T) {
+	ctx := context.Background()
+	server := inmem.NewServer()
+	server.CreateApp(&fly.App{Name: "app"})
+	client := server.FlapsClient("app")
+	ctx = flapsutil.NewContextWithClient(ctx, client)
+	for _, group := range []string{"web", "web", "worker"} {
+		_, err := client.Launch(ctx, fly.LaunchMachineInput{Region: "ord", Config: &fly.MachineConfig{
+			Image:    "app:v1",
+			Metadata: map[string]string{
+				fly.MachineConfigMetadataKeyFlyPlatformVersion: fly.MachineFlyPlatformVersion2,
+				fly.MachineConfigMetadataKeyFlyProcessGroup:    group,
+			},
+		}})
+		require.NoError(t, err)
+	}
+
+	scaledAt := time.Now().Add(-10 * time.Second).Truncate(time.Second)
+	newController("app", io.Discard).recordScaled(ctx, "web", scaledAt)
+
+	// Another autoscaler, or this one restarted, sees when web was scaled.
+	machines, err := client.List(ctx, "")
+	require.NoError(t, err)
+	c := newController("app", io.Discard)
+	c.observeScaled("web", lo.Filter(machines, func(m *fly.Machine, _ int) bool { return m.ProcessGroup() == "web" }))
+	c.observeScaled("worker", lo.Filter(machines, func(m *fly.Machine, _ int) bool { return m.ProcessGroup() == "worker" }))
+
+	policy := &appconfig.Autoscale{ScaleUpCooldown: fly.MustParseDuration("30s")}
+	assert.True(t, scaledAt.Equal(c.lastScaled["web"]))
+	assert.Positive(t, c.cooldown(policy, "web", 2, 4, time.Now()))
+	assert.Zero(t, c.cooldown(policy, "worker", 1, 2, time.Now()))
+}
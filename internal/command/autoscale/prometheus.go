@@ -0,0 +1,101 @@
+package autoscale
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/superfly/flyctl/internal/buildinfo"
+)
+
+// promAPI is a Prometheus-compatible HTTP API.
+type promAPI struct {
+	url           string
+	authorization string
+	client        *http.Client
+}
+
+// query runs an instant query and adds up the values of the series it
+// returns.
+func (p *promAPI) query(ctx context.Context, query string) (float64, error) {
+	u := strings.TrimSuffix(p.url, "/") + "/api/v1/query?" + url.Values{"query": {query}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", buildinfo.UserAgent())
+	if p.authorization != "" {
+		req.Header.Set("Authorization", p.authorization)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return 0, fmt.Errorf("metrics query returned %s", resp.Status)
+	}
+	if body.Status != "success" {
+		return 0, fmt.Errorf("metrics query failed: %s", body.Error)
+	}
+
+	var samples [][2]json.RawMessage
+	switch body.Data.ResultType {
+	case "scalar":
+		var sample [2]json.RawMessage
+		if err := json.Unmarshal(body.Data.Result, &sample); err != nil {
+			return 0, err
+		}
+		samples = append(samples, sample)
+	case "vector":
+		var vector []struct {
+			Value [2]json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(body.Data.Result, &vector); err != nil {
+			return 0, err
+		}
+		for _, series := range vector {
+			samples = append(samples, series.Value)
+		}
+	default:
+		return 0, fmt.Errorf("metrics query returned a %s, expected a vector or a scalar", body.Data.ResultType)
+	}
+
+	total, values := 0.0, 0
+	for _, sample := range samples {
+		var s string
+		if err := json.Unmarshal(sample[1], &s); err != nil {
+			return 0, err
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, err
+		}
+		if math.IsNaN(v) {
+			continue
+		}
+		total += v
+		values++
+	}
+	if values == 0 {
+		return 0, errors.New("metrics query returned no data")
+	}
+	return total, nil
+}
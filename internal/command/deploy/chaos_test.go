@@ -0,0 +1,135 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/chaos"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/inmem"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+// chaosDeployment returns a deployment of an app with two machines running
+// image v1, whose Machines API calls get the faults of rules.
+func chaosDeployment(t *testing.T, rules ...chaos.Rule) (context.Context, *machineDeployment, *inmem.FlapsClient) {
+	t.Helper()
+
+	ctx := withQuietIOStreams(context.Background())
+
+	server := inmem.NewServer()
+	server.CreateApp(&fly.App{Name: "myapp"})
+	inner := server.FlapsClient("myapp")
+	for range 2 {
+		_, err := inner.Launch(ctx, fly.LaunchMachineInput{Region: "ord", Config: &fly.MachineConfig{Image: "myapp:v1"}})
+		require.NoError(t, err)
+	}
+
+	flapsClient := chaos.NewClient(inner, &chaos.Config{Seed: 1, Rules: rules})
+	ctx = flapsutil.NewContextWithClient(ctx, flapsClient)
+
+	md := &machineDeployment{
+		flapsClient:      flapsClient,
+		io:               iostreams.FromContext(ctx),
+		app:              &fly.AppCompact{Name: "myapp"},
+		appConfig:        &appconfig.Config{AppName: "myapp"},
+		waitTimeout:      5 * time.Second,
+		deployRetries:    3,
+		maxUnavailable:   1,
+		skipHealthChecks: true,
+		skipSmokeChecks:  true,
+	}
+	return ctx, md, inner
+}
+
+// deployImage updates every machine of md to image.
+func deployImage(ctx context.Context, t *testing.T, md *machineDeployment, image string) (prior *AppState, err error) {
+	t.Helper()
+
+	prior, err = md.appState(ctx, nil)
+	require.NoError(t, err)
+
+	target := &AppState{}
+	for _, m := range prior.Machines {
+		config := *m.Config
+		config.Image = image
+		target.Machines = append(target.Machines, &fly.Machine{ID: m.ID, Region: m.Region, State: m.State, Config: &config})
+	}
+
+	return prior, md.updateMachinesWRecovery(ctx, prior, target, nil, updateMachineSettings{
+		pushForward:      true,
+		skipHealthChecks: true,
+		skipSmokeChecks:  true,
+	})
+}
+
+func assertImages(t *testing.T, client *inmem.FlapsClient, image string) {
+	t.Helper()
+
+	machines, err := client.List(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, machines, 2)
+	for _, m := range machines {
+		assert.Equal(t, image, m.Config.Image, "machine %s", m.ID)
+		assert.Equal(t, fly.MachineStateStarted, m.State, "machine %s", m.ID)
+
+		lease, err := client.FindLease(context.Background(), m.ID)
+		assert.True(t, err != nil || lease == nil || lease.Data == nil, "lease on machine %s is released", m.ID)
+	}
+}
+
+func TestDeployUnderChaos(t *testing.T) {
+	t.Run("retries failed updates", func(t *testing.T) {
+		ctx, md, inner := chaosDeployment(t, chaos.Rule{Fault: chaos.ServerError, Method: "Update", Times: 2})
+		_, err := deployImage(ctx, t, md, "myapp:v2")
+		require.NoError(t, err)
+		assertImages(t, inner, "myapp:v2")
+	})
+
+	t.Run("retries machines that time out starting", func(t *testing.T) {
+		ctx, md, inner := chaosDeployment(t, chaos.Rule{Fault: chaos.Timeout, Method: "Wait", Times: 1})
+		_, err := deployImage(ctx, t, md, "myapp:v2")
+		require.NoError(t, err)
+		assertImages(t, inner, "myapp:v2")
+	})
+
+	t.Run("gives up on lease conflicts", func(t *testing.T) {
+		ctx, md, inner := chaosDeployment(t, chaos.Rule{Fault: chaos.LeaseConflict, Method: "AcquireLease"})
+		_, err := deployImage(ctx, t, md, "myapp:v2")
+		assert.ErrorContains(t, err, "lease currently held by")
+		assertImages(t, inner, "myapp:v1")
+	})
+
+	t.Run("gives up once retries are exhausted", func(t *testing.T) {
+		ctx, md, inner := chaosDeployment(t, chaos.Rule{Fault: chaos.ServerError, Method: "Update"})
+		_, err := deployImage(ctx, t, md, "myapp:v2")
+		assert.ErrorContains(t, err, "injected server error")
+		assertImages(t, inner, "myapp:v1")
+	})
+
+	t.Run("rolls back despite failed updates", func(t *testing.T) {
+		ctx, md, inner := chaosDeployment(t, chaos.Rule{Fault: chaos.ServerError, Method: "Update", After: 2, Times: 2})
+		prior, err := deployImage(ctx, t, md, "myapp:v2")
+		require.NoError(t, err)
+		assertImages(t, inner, "myapp:v2")
+
+		require.NoError(t, md.rollback(ctx, prior))
+		assertImages(t, inner, "myapp:v1")
+	})
+
+	t.Run("rolls back machines the deploy created", func(t *testing.T) {
+		ctx, md, inner := chaosDeployment(t)
+		prior, err := deployImage(ctx, t, md, "myapp:v2")
+		require.NoError(t, err)
+		_, err = inner.Launch(ctx, fly.LaunchMachineInput{Region: "ord", Config: &fly.MachineConfig{Image: "myapp:v2"}})
+		require.NoError(t, err)
+
+		require.NoError(t, md.rollback(ctx, prior))
+		assertImages(t, inner, "myapp:v1")
+	})
+}
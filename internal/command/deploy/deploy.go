@@ -1,8 +1,10 @@
 package deploy
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +16,7 @@ import (
 	"github.com/superfly/flyctl/internal/appconfig"
 	"github.com/superfly/flyctl/internal/build/imgsrc"
 	"github.com/superfly/flyctl/internal/buildinfo"
+	"github.com/superfly/flyctl/internal/chaos"
 	"github.com/superfly/flyctl/internal/cmdutil"
 	"github.com/superfly/flyctl/internal/command"
 	"github.com/superfly/flyctl/internal/config"
@@ -237,6 +240,25 @@ func New() *Command {
 			Description: "Path to a deploy manifest file to use for deployment.",
 			Hidden:      true,
 		},
+		flag.String{
+			Name:        "chaos",
+			Description: "Inject failures into Machines API calls to exercise recovery and rollbacks. Takes a rules file or a list of [method=]fault[:probability], e.g. 'Update=server_error:0.5'. Defaults to $FLYCTL_CHAOS",
+			Hidden:      true,
+		},
+		flag.StringSlice{
+			Name:        "region-wave",
+			Description: "Update machines one region at a time, in this order. '*' stands for every other region. Overrides deploy.region_order in fly.toml",
+		},
+		flag.Duration{
+			Name:        "wait-for-lock",
+			Description: "How long to wait for the deploy lock if someone else holds it. Fails right away by default",
+		},
+	)
+
+	cmd.AddCommand(
+		newLock(),
+		newUnlock(),
+		newLockStatus(),
 	)
 
 	return cmd
@@ -284,6 +306,15 @@ func (cmd *Command) run(ctx context.Context) (err error) {
 		ctx = flapsutil.NewContextWithClient(ctx, flapsClient)
 	}
 
+	if spec := cmp.Or(flag.GetString(ctx, "chaos"), os.Getenv("FLYCTL_CHAOS")); spec != "" {
+		rules, err := chaos.Load(spec)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(io.ErrOut, "Injecting failures into Machines API calls (%d rules)\n", len(rules.Rules))
+		ctx = flapsutil.NewContextWithClient(ctx, chaos.NewClient(flapsutil.ClientFromContext(ctx), rules))
+	}
+
 	client := flyutil.ClientFromContext(ctx)
 
 	user, err := client.GetCurrentUser(ctx)
@@ -344,6 +375,16 @@ func DeployWithConfig(ctx context.Context, appConfig *appconfig.Config, userID i
 		return err
 	}
 
+	// Wait for other deploys of the app to be done before running hooks or
+	// building. Builds alone and exporting a manifest don't touch machines.
+	if !flag.GetBuildOnly(ctx) && flag.GetString(ctx, "export-manifest") == "" {
+		unlock, err := lockDeploy(ctx, appCompact)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
 	// Start the feature flag client, if we haven't already
 	if launchdarkly.ClientFromContext(ctx) == nil {
 		ffClient, err := launchdarkly.NewClient(ctx, launchdarkly.UserInfo{
@@ -363,10 +404,31 @@ func DeployWithConfig(ctx context.Context, appConfig *appconfig.Config, userID i
 		}
 	}
 
+	// The machine deployment sends start once the release and image are
+	// known. Report failures from here too, so builds, hooks and creating
+	// the release failing before then aren't missed.
+	if notifier := newDeployNotifier(appConfig); notifier != nil && !flag.GetBuildOnly(ctx) {
+		ctx = withDeployNotifier(ctx, notifier)
+		defer func() {
+			if err != nil {
+				notifier.send(ctx, notifier.newEvent(ctx, apiClient, appCompact, appconfig.DeployEventFailure, err))
+			}
+		}()
+	}
+
+	if err := checkRequiredSecrets(ctx, appConfig, appName); err != nil {
+		return err
+	}
+
 	httpFailover := flag.GetHTTPSFailover(ctx)
 	usingWireguard := flag.GetWireguard(ctx)
 	recreateBuilder := flag.GetRecreateBuilder(ctx)
 
+	hookEnv := map[string]string{"FLY_APP_NAME": appName}
+	if err := runLocalHooks(ctx, appConfig, appconfig.DeployHookPreBuild, hookEnv); err != nil {
+		return err
+	}
+
 	// Fetch an image ref or build from source to get the final image reference to deploy
 	img, err := determineImage(ctx, appConfig, usingWireguard, recreateBuilder)
 	if err != nil {
@@ -383,6 +445,11 @@ func DeployWithConfig(ctx context.Context, appConfig *appconfig.Config, userID i
 		return fmt.Errorf("failed to fetch an image or build from source: %w", err)
 	}
 
+	hookEnv["FLY_IMAGE_REF"] = img.Tag
+	if err := runLocalHooks(ctx, appConfig, appconfig.DeployHookPostBuild, hookEnv); err != nil {
+		return err
+	}
+
 	if flag.GetBuildOnly(ctx) {
 		return nil
 	}
@@ -611,6 +678,7 @@ func deployToMachines(
 		DeployRetries:         deployRetries,
 		BuildID:               img.BuildID,
 		BuilderID:             img.BuilderID,
+		RegionWaves:           flag.GetNonEmptyStringSlice(ctx, "region-wave"),
 	}
 
 	var path = flag.GetString(ctx, "export-manifest")
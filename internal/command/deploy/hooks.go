@@ -0,0 +1,346 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/terminal"
+)
+
+const (
+	defaultHookTimeout          = 10 * time.Minute
+	defaultSmokeTestTimeout     = time.Minute
+	defaultSmokeTestInterval    = 5 * time.Second
+	defaultSmokeTestHTTPTimeout = 10 * time.Second
+)
+
+// deployHooks returns the hooks of cfg that run at phase, in the order they
+// are declared.
+func deployHooks(cfg *appconfig.Config, phase string) []appconfig.DeployHook {
+	if cfg == nil || cfg.Deploy == nil {
+		return nil
+	}
+	var hooks []appconfig.DeployHook
+	for _, hook := range cfg.Deploy.Hooks {
+		if hook.Phase == phase {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks
+}
+
+// runLocalHooks runs the local command hooks of a phase. Machine command
+// hooks are run by the machine deployment instead.
+func runLocalHooks(ctx context.Context, cfg *appconfig.Config, phase string, env map[string]string) error {
+	for _, hook := range deployHooks(cfg, phase) {
+		if hook.Command == "" {
+			continue
+		}
+		if err := runLocalHook(ctx, hook, phase, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runLocalHook(ctx context.Context, hook appconfig.DeployHook, phase string, env map[string]string) error {
+	io := iostreams.FromContext(ctx)
+	fmt.Fprintf(io.ErrOut, "Running %s hook: %s\n", phase, hook.Command)
+
+	timeout := defaultHookTimeout
+	if hook.Timeout != nil {
+		timeout = hook.Timeout.Duration
+	}
+
+	err := runShellCommand(ctx, hook.Command, timeout, withPhase(env, phase), io.ErrOut)
+	if err == nil {
+		return nil
+	}
+	if hook.ContinueOnError {
+		terminal.Warnf("%s hook %q failed, continuing: %v\n", phase, hook.Command, err)
+		return nil
+	}
+	return fmt.Errorf("%s hook %q failed: %w", phase, hook.Command, err)
+}
+
+// runShellCommand runs command through the user's shell with env added to
+// the environment, sending its output to out.
+func runShellCommand(ctx context.Context, command string, timeout time.Duration, env map[string]string, out io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+func withPhase(env map[string]string, phase string) map[string]string {
+	merged := make(map[string]string, len(env)+1)
+	for k, v := range env {
+		merged[k] = v
+	}
+	merged["FLY_DEPLOY_PHASE"] = phase
+	return merged
+}
+
+// hookEnv is the environment hooks and smoke test commands run with.
+// FLY_APP_URL is only set for apps with a public http service.
+func (md *machineDeployment) hookEnv() map[string]string {
+	env := map[string]string{
+		"FLY_APP_NAME":        md.app.Name,
+		"FLY_IMAGE_REF":       md.img,
+		"FLY_RELEASE_VERSION": strconv.Itoa(md.releaseVersion),
+	}
+	if u := md.appConfig.URL(); u != nil {
+		env["FLY_APP_URL"] = u.String()
+	}
+	return env
+}
+
+// runHooks runs the hooks of a phase, local commands as well as commands on
+// temporary machines.
+func (md *machineDeployment) runHooks(ctx context.Context, phase string) error {
+	for _, hook := range deployHooks(md.appConfig, phase) {
+		var err error
+		if hook.MachineCommand != "" {
+			err = md.runMachineHook(ctx, hook, phase)
+		} else {
+			err = runLocalHook(ctx, hook, phase, md.hookEnv())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runMachineHook runs a hook on a temporary machine, the same way release
+// commands run.
+func (md *machineDeployment) runMachineHook(ctx context.Context, hook appconfig.DeployHook, phase string) error {
+	releaseCommand, releaseCmdTimeout := md.appConfig.Deploy.ReleaseCommand, md.releaseCmdTimeout
+	defer func() {
+		md.appConfig.Deploy.ReleaseCommand, md.releaseCmdTimeout = releaseCommand, releaseCmdTimeout
+	}()
+
+	md.appConfig.Deploy.ReleaseCommand = hook.MachineCommand
+	if hook.Timeout != nil {
+		md.releaseCmdTimeout = hook.Timeout.Duration
+	}
+
+	err := md.runReleaseCommand(ctx, strings.ReplaceAll(phase, "-", "_")+"_hook")
+	if err == nil {
+		return nil
+	}
+	if hook.ContinueOnError {
+		terminal.Warnf("%s hook %q failed, continuing: %v\n", phase, hook.MachineCommand, err)
+		return nil
+	}
+	return fmt.Errorf("%s hook %q failed: %w", phase, hook.MachineCommand, err)
+}
+
+// postDeploy runs the post-deploy hooks and the smoke test once the new
+// release is deployed. If either fails and experimental.auto_rollback is
+// set, the machines are rolled back to prior.
+func (md *machineDeployment) postDeploy(ctx context.Context, prior *AppState) error {
+	err := md.runHooks(ctx, appconfig.DeployHookPostDeploy)
+	if err == nil {
+		err = md.runSmokeTest(ctx)
+	}
+	if err == nil || prior == nil {
+		return err
+	}
+
+	fmt.Fprintf(md.io.ErrOut, "Rolling back %s to the previous release\n", md.colorize.Bold(md.app.Name))
+	if rollbackErr := md.rollback(ctx, prior); rollbackErr != nil {
+		return fmt.Errorf("%w; rollback failed: %v", err, rollbackErr)
+	}
+	md.notify(ctx, appconfig.DeployEventRollback, err)
+	return fmt.Errorf("%w; rolled back to the previous release", err)
+}
+
+// autoRollback reports whether a failed post-deploy step rolls the release
+// back.
+func (md *machineDeployment) autoRollback() bool {
+	if md.appConfig.Experimental == nil || !md.appConfig.Experimental.AutoRollback {
+		return false
+	}
+	return len(deployHooks(md.appConfig, appconfig.DeployHookPostDeploy)) > 0 ||
+		(md.appConfig.Deploy != nil && md.appConfig.Deploy.SmokeTest != nil)
+}
+
+// rollback updates the machines that existed before the deploy back to
+// their prior config and destroys the machines the deploy created. Machines
+// it destroyed can't be brought back.
+func (md *machineDeployment) rollback(ctx context.Context, prior *AppState) error {
+	current, err := md.appState(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	target := &AppState{}
+	var created []*fly.Machine
+	for _, machine := range current.Machines {
+		old, ok := lo.Find(prior.Machines, func(m *fly.Machine) bool { return m.ID == machine.ID })
+		if !ok {
+			created = append(created, machine)
+			continue
+		}
+		target.Machines = append(target.Machines, old)
+	}
+
+	err = md.updateMachinesWRecovery(ctx, current, target, nil, updateMachineSettings{
+		pushForward:      true,
+		skipHealthChecks: md.skipHealthChecks,
+		skipSmokeChecks:  true,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, machine := range created {
+		fmt.Fprintf(md.io.ErrOut, "  Destroying machine %s created by the deploy\n", machine.ID)
+		if err := md.destroyMachine(ctx, machine.ID, ""); err != nil {
+			return fmt.Errorf("failed to destroy machine %s: %w", machine.ID, err)
+		}
+	}
+	return nil
+}
+
+// runSmokeTest runs the smoke test of the app against the new release,
+// retrying until every probe and the command pass or the timeout expires.
+func (md *machineDeployment) runSmokeTest(ctx context.Context) error {
+	if md.appConfig.Deploy == nil || md.appConfig.Deploy.SmokeTest == nil {
+		return nil
+	}
+	st := md.appConfig.Deploy.SmokeTest
+
+	fmt.Fprintf(md.io.ErrOut, "Running smoke test for %s\n", md.colorize.Bold(md.app.Name))
+	if err := smokeTest(ctx, st, md.appConfig.URL(), md.hookEnv(), md.io.ErrOut); err != nil {
+		return fmt.Errorf("smoke test failed: %w", err)
+	}
+	fmt.Fprintf(md.io.ErrOut, "Smoke test passed\n")
+	return nil
+}
+
+func smokeTest(ctx context.Context, st *appconfig.SmokeTest, base *url.URL, env map[string]string, out io.Writer) error {
+	timeout, interval := defaultSmokeTestTimeout, defaultSmokeTestInterval
+	if st.Timeout != nil {
+		timeout = st.Timeout.Duration
+	}
+	if st.Interval != nil {
+		interval = st.Interval.Duration
+	}
+
+	deadline := time.Now().Add(timeout)
+	client := &http.Client{Timeout: defaultSmokeTestHTTPTimeout}
+	for {
+		err := smokeTestOnce(ctx, st, client, base, env, deadline, out)
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return err
+		}
+		fmt.Fprintf(out, "  %v, retrying in %s\n", err, interval)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func smokeTestOnce(ctx context.Context, st *appconfig.SmokeTest, client *http.Client, base *url.URL, env map[string]string, deadline time.Time, out io.Writer) error {
+	for _, probe := range st.HTTP {
+		if err := runProbe(ctx, client, base, probe); err != nil {
+			return err
+		}
+	}
+	if st.Command != "" {
+		if err := runShellCommand(ctx, st.Command, max(time.Until(deadline), time.Second), env, out); err != nil {
+			return fmt.Errorf("command %q failed: %w", st.Command, err)
+		}
+	}
+	return nil
+}
+
+// runProbe requests probe, resolving its url against base, which is nil for
+// apps without a public http service.
+func runProbe(ctx context.Context, client *http.Client, base *url.URL, probe appconfig.SmokeTestProbe) error {
+	target, err := url.Parse(probe.URL)
+	if err != nil {
+		return fmt.Errorf("invalid probe url %q: %w", probe.URL, err)
+	}
+	if !target.IsAbs() {
+		if base == nil {
+			return fmt.Errorf("probe url %q is relative, but the app has no public http service; use an absolute url", probe.URL)
+		}
+		target = base.ResolveReference(target)
+	}
+
+	method := probe.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range probe.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, target, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case probe.Status != 0 && resp.StatusCode != probe.Status:
+		return fmt.Errorf("%s %s returned %d, expected %d", method, target, resp.StatusCode, probe.Status)
+	case probe.Status == 0 && resp.StatusCode >= 400:
+		return fmt.Errorf("%s %s returned %d", method, target, resp.StatusCode)
+	}
+
+	if probe.Contains != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", method, target, err)
+		}
+		if !strings.Contains(string(body), probe.Contains) {
+			return fmt.Errorf("%s %s response doesn't contain %q", method, target, probe.Contains)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func TestRunLocalHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks use sh in this test")
+	}
+
+	ios, _, _, _ := iostreams.Test()
+	ctx := iostreams.NewContext(context.Background(), ios)
+
+	out := filepath.Join(t.TempDir(), "out")
+	cfg := &appconfig.Config{Deploy: &appconfig.Deploy{Hooks: []appconfig.DeployHook{
+		{Phase: appconfig.DeployHookPreBuild, Command: `echo "$FLY_DEPLOY_PHASE $FLY_APP_NAME" >> ` + out},
+		{Phase: appconfig.DeployHookPostBuild, Command: `echo "$FLY_IMAGE_REF" >> ` + out},
+		{Phase: appconfig.DeployHookPreBuild, Command: "exit 3", ContinueOnError: true},
+		{Phase: appconfig.DeployHookPreRelease, MachineCommand: "not run locally"},
+	}}}
+
+	require.NoError(t, runLocalHooks(ctx, cfg, appconfig.DeployHookPreBuild, map[string]string{"FLY_APP_NAME": "app"}))
+	require.NoError(t, runLocalHooks(ctx, cfg, appconfig.DeployHookPostBuild, map[string]string{"FLY_IMAGE_REF": "registry.fly.io/app:v1"}))
+	require.NoError(t, runLocalHooks(ctx, cfg, appconfig.DeployHookPreRelease, nil))
+
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "pre-build app\nregistry.fly.io/app:v1\n", string(b))
+
+	cfg.Deploy.Hooks[2].ContinueOnError = false
+	err = runLocalHooks(ctx, cfg, appconfig.DeployHookPreBuild, nil)
+	assert.ErrorContains(t, err, `pre-build hook "exit 3" failed`)
+
+	cfg.Deploy.Hooks = []appconfig.DeployHook{{Phase: appconfig.DeployHookPreBuild, Command: "sleep 5", Timeout: fly.MustParseDuration("100ms")}}
+	err = runLocalHooks(ctx, cfg, appconfig.DeployHookPreBuild, nil)
+	assert.ErrorContains(t, err, "timed out after 100ms")
+}
+
+func TestSmokeTest(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/health" || r.Header.Get("X-Probe") != "yes" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("status: ok"))
+	}))
+	defer srv.Close()
+
+	base, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	st := &appconfig.SmokeTest{
+		HTTP: []appconfig.SmokeTestProbe{
+			{URL: "/health", Headers: map[string]string{"X-Probe": "yes"}, Status: http.StatusOK, Contains: "ok"},
+		},
+		Timeout:  fly.MustParseDuration("5s"),
+		Interval: fly.MustParseDuration("10ms"),
+	}
+	var out bytes.Buffer
+	require.NoError(t, smokeTest(context.Background(), st, base, nil, &out))
+	assert.Equal(t, 3, requests)
+
+	st.HTTP[0].Contains = "healthy"
+	st.Timeout = &fly.Duration{Duration: 50 * time.Millisecond}
+	err = smokeTest(context.Background(), st, base, nil, &out)
+	assert.ErrorContains(t, err, `response doesn't contain "healthy"`)
+
+	// Apps without a public http service can only probe absolute urls.
+	err = smokeTest(context.Background(), st, nil, nil, &out)
+	assert.ErrorContains(t, err, "the app has no public http service")
+
+	st.HTTP[0].URL = srv.URL + "/health"
+	st.HTTP[0].Contains = "ok"
+	require.NoError(t, smokeTest(context.Background(), st, nil, nil, &out))
+}
+
+func TestHookEnv(t *testing.T) {
+	cfg := appconfig.NewConfig()
+	cfg.AppName = "myapp"
+	md := &machineDeployment{app: &fly.AppCompact{Name: "myapp"}, appConfig: cfg, img: "registry.fly.io/myapp:v2", releaseVersion: 3}
+
+	env := md.hookEnv()
+	assert.Equal(t, "3", env["FLY_RELEASE_VERSION"])
+	assert.NotContains(t, env, "FLY_APP_URL", "the app has no public http service")
+
+	cfg.HTTPService = &appconfig.HTTPService{InternalPort: 8080}
+	assert.Equal(t, "https://myapp.fly.dev/", md.hookEnv()["FLY_APP_URL"])
+}
@@ -0,0 +1,320 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/terminal"
+)
+
+// The deploy lock is the platform's app lock. Taking it is atomic, so only
+// one flyctl holds it at a time, until it's released or the platform
+// expires it. Its ID is what proves who holds it: fly deploy lock prints
+// it, and deploys run with it in deployLockIDEnv go ahead while it's held.
+const (
+	deployLockIDEnv = "FLY_DEPLOY_LOCK_ID"
+
+	deployLockPoll = 5 * time.Second
+)
+
+// errDeployLocked is returned when the deploy lock is held by someone else.
+var errDeployLocked = errors.New("deploys are locked")
+
+// deployLockInfo is the current lock of an app.
+type deployLockInfo struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (i *deployLockInfo) String() string {
+	return fmt.Sprintf("lock %s expires in %s", i.ID, time.Until(i.ExpiresAt).Round(time.Second))
+}
+
+// deployLock is a held deploy lock.
+type deployLock struct {
+	client flyutil.Client
+	appID  string
+	info   deployLockInfo
+	// owned is false when the lock was taken with fly deploy lock
+	// beforehand, in which case it isn't released.
+	owned bool
+}
+
+// readDeployLock returns the current lock of appName, or nil if it isn't
+// locked.
+func readDeployLock(ctx context.Context, client flyutil.Client, appName string) (*deployLockInfo, error) {
+	app, err := client.GetAppLock(ctx, appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading deploy lock: %w", err)
+	}
+	if app.CurrentLock == nil || app.CurrentLock.LockID == "" {
+		return nil, nil
+	}
+
+	info := &deployLockInfo{ID: app.CurrentLock.LockID}
+	if info.ExpiresAt, err = time.Parse(time.RFC3339, app.CurrentLock.Expiration); err != nil {
+		return nil, fmt.Errorf("failed reading deploy lock: invalid expiration %q", app.CurrentLock.Expiration)
+	}
+	if time.Now().After(info.ExpiresAt) {
+		return nil, nil
+	}
+	return info, nil
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// acquireDeployLock takes the deploy lock of app, waiting up to wait for
+// whoever holds it to let go. A lock whose ID is heldID is reused rather
+// than taken.
+func acquireDeployLock(ctx context.Context, client flyutil.Client, app *fly.AppCompact, heldID string, wait time.Duration) (*deployLock, error) {
+	deadline := time.Now().Add(wait)
+	announced := false
+
+	for {
+		info, err := readDeployLock(ctx, client, app.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case info == nil:
+			lock, err := client.LockApp(ctx, fly.LockAppInput{AppID: app.ID})
+			if err == nil {
+				return &deployLock{
+					client: client,
+					appID:  app.ID,
+					owned:  true,
+					info:   deployLockInfo{ID: lock.LockID, ExpiresAt: lock.Expiration},
+				}, nil
+			}
+			// Someone else may have taken it since it was read.
+			var rerr error
+			if info, rerr = readDeployLock(ctx, client, app.Name); rerr != nil || info == nil {
+				return nil, fmt.Errorf("failed acquiring deploy lock: %w", err)
+			}
+		case heldID != "" && info.ID == heldID:
+			return &deployLock{client: client, appID: app.ID, info: *info}, nil
+		}
+
+		if time.Now().Add(deployLockPoll).After(deadline) {
+			return nil, fmt.Errorf("%w: %s; wait with --wait-for-lock or release it with fly deploy unlock --force", errDeployLocked, info)
+		}
+		if !announced {
+			fmt.Fprintf(iostreams.FromContext(ctx).ErrOut, "Waiting for the deploy lock, %s\n", info)
+			announced = true
+		}
+		if err := sleepCtx(ctx, deployLockPoll); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// release lets go of the lock, unless it was taken before this deploy.
+func (l *deployLock) release(ctx context.Context) error {
+	if !l.owned {
+		return nil
+	}
+	_, err := l.client.UnlockApp(ctx, fly.UnlockAppInput{AppID: l.appID, LockID: l.info.ID})
+	return err
+}
+
+// lockDeploy takes the deploy lock for a deploy of app. The returned func
+// releases it.
+func lockDeploy(ctx context.Context, app *fly.AppCompact) (func(), error) {
+	client := flyutil.ClientFromContext(ctx)
+
+	lock, err := acquireDeployLock(ctx, client, app, os.Getenv(deployLockIDEnv), flag.GetDuration(ctx, "wait-for-lock"))
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if time.Now().After(lock.info.ExpiresAt) {
+			terminal.Warnf("the deploy lock expired during the deploy, someone else may have deployed meanwhile\n")
+		}
+		if err := lock.release(context.WithoutCancel(ctx)); err != nil {
+			terminal.Warnf("failed to release the deploy lock: %v\n", err)
+		}
+	}, nil
+}
+
+func newLock() *cobra.Command {
+	const (
+		short = "Lock deploys of an app"
+		long  = short + `. Deploys fail, or wait with --wait-for-lock, until the
+lock is released with fly deploy unlock or the platform expires it.
+
+The ID of the lock is printed. Deploys run with $FLY_DEPLOY_LOCK_ID set to it
+go ahead and leave the lock in place, as does unlock.`
+		usage = "lock"
+	)
+
+	cmd := command.New(usage, short, long, runLock,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Duration{
+			Name:        "wait-for-lock",
+			Description: "How long to wait for the lock if someone else holds it",
+		},
+	)
+
+	return cmd
+}
+
+func runLock(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+		client  = flyutil.ClientFromContext(ctx)
+	)
+
+	app, err := client.GetAppCompact(ctx, appName)
+	if err != nil {
+		return err
+	}
+	lock, err := acquireDeployLock(ctx, client, app, os.Getenv(deployLockIDEnv), flag.GetDuration(ctx, "wait-for-lock"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(io.Out, "Deploys of %s locked until %s\n", appName, lock.info.ExpiresAt.Local().Format(time.DateTime))
+	fmt.Fprintf(io.Out, "Deploy while it's held with %s=%s\n", deployLockIDEnv, lock.info.ID)
+	return nil
+}
+
+func newUnlock() *cobra.Command {
+	const (
+		short = "Unlock deploys of an app"
+		long  = short + `. Only the holder of the lock, who has its ID in
+$FLY_DEPLOY_LOCK_ID, can release it, unless --force is given.`
+		usage = "unlock"
+	)
+
+	cmd := command.New(usage, short, long, runUnlock,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Bool{
+			Name:        "force",
+			Description: "Release the lock even if someone else holds it",
+		},
+	)
+
+	return cmd
+}
+
+func runUnlock(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+		client  = flyutil.ClientFromContext(ctx)
+	)
+
+	app, err := client.GetAppCompact(ctx, appName)
+	if err != nil {
+		return err
+	}
+	info, err := readDeployLock(ctx, client, appName)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		fmt.Fprintf(io.Out, "Deploys of %s aren't locked\n", appName)
+		return nil
+	}
+
+	if info.ID != os.Getenv(deployLockIDEnv) && !flag.GetBool(ctx, "force") {
+		return fmt.Errorf("deploys are locked by someone else, %s; use --force to release it anyway", info)
+	}
+
+	if _, err := client.UnlockApp(ctx, fly.UnlockAppInput{AppID: app.ID, LockID: info.ID}); err != nil {
+		return fmt.Errorf("failed releasing deploy lock: %w", err)
+	}
+	fmt.Fprintf(io.Out, "Deploys of %s unlocked\n", appName)
+	return nil
+}
+
+func newLockStatus() *cobra.Command {
+	const (
+		short = "Show whether deploys of an app are locked"
+		long  = short + ", and until when."
+		usage = "status"
+	)
+
+	cmd := command.New(usage, short, long, runLockStatus,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.JSONOutput(),
+	)
+
+	return cmd
+}
+
+func runLockStatus(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+		client  = flyutil.ClientFromContext(ctx)
+	)
+
+	info, err := readDeployLock(ctx, client, appName)
+	if err != nil {
+		return err
+	}
+	held := info != nil && info.ID == os.Getenv(deployLockIDEnv)
+
+	if flag.GetBool(ctx, "json") {
+		out := map[string]any{"locked": info != nil}
+		if info != nil {
+			out["lock_id"] = info.ID
+			out["expires_at"] = info.ExpiresAt
+			out["held"] = held
+		}
+		return render.JSON(io.Out, out)
+	}
+
+	switch {
+	case info == nil:
+		fmt.Fprintf(io.Out, "Deploys of %s aren't locked\n", appName)
+	case held:
+		fmt.Fprintf(io.Out, "Deploys of %s are locked by you, %s\n", appName, info)
+	default:
+		fmt.Fprintf(io.Out, "Deploys of %s are locked, %s\n", appName, info)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/inmem"
+)
+
+func TestDeployLock(t *testing.T) {
+	ctx := context.Background()
+	server := inmem.NewServer()
+	server.CreateApp(&fly.App{Name: "app"})
+	client := server.Client()
+	app, err := client.GetAppCompact(ctx, "app")
+	require.NoError(t, err)
+
+	alice, err := acquireDeployLock(ctx, client, app, "", 0)
+	require.NoError(t, err)
+	assert.True(t, alice.owned)
+
+	_, err = acquireDeployLock(ctx, client, app, "", 0)
+	assert.ErrorIs(t, err, errDeployLocked)
+	assert.ErrorContains(t, err, "lock "+alice.info.ID)
+
+	// Deploys with the ID of the lock go ahead and leave it alone.
+	again, err := acquireDeployLock(ctx, client, app, alice.info.ID, 0)
+	require.NoError(t, err)
+	assert.False(t, again.owned)
+	require.NoError(t, again.release(ctx))
+
+	info, err := readDeployLock(ctx, client, "app")
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, alice.info.ID, info.ID)
+
+	require.NoError(t, alice.release(ctx))
+	info, err = readDeployLock(ctx, client, "app")
+	require.NoError(t, err)
+	assert.Nil(t, info)
+
+	// Expired locks are free.
+	ttl := inmem.AppLockTTL
+	inmem.AppLockTTL = -time.Minute
+	t.Cleanup(func() { inmem.AppLockTTL = ttl })
+	_, err = acquireDeployLock(ctx, client, app, "", 0)
+	require.NoError(t, err)
+	info, err = readDeployLock(ctx, client, "app")
+	require.NoError(t, err)
+	assert.Nil(t, info)
+}
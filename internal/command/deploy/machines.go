@@ -1,6 +1,7 @@
 package deploy
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -77,6 +78,7 @@ type MachineDeploymentArgs struct {
 	DeployRetries         int
 	BuildID               string
 	BuilderID             string
+	RegionWaves           []string
 }
 
 func argsFromManifest(manifest *DeployManifest, app *fly.AppCompact) MachineDeploymentArgs {
@@ -110,6 +112,7 @@ func argsFromManifest(manifest *DeployManifest, app *fly.AppCompact) MachineDepl
 		RestartPolicy:         manifest.RestartPolicy,
 		RestartMaxRetries:     manifest.RestartMaxRetries,
 		DeployRetries:         manifest.DeployRetries,
+		RegionWaves:           manifest.RegionWaves,
 	}
 }
 
@@ -157,6 +160,7 @@ type machineDeployment struct {
 	deployRetries         int
 	buildID               string
 	builderID             string
+	notifier              *deployNotifier
 }
 
 func NewMachineDeployment(ctx context.Context, args MachineDeploymentArgs) (_ MachineDeployment, err error) {
@@ -177,6 +181,11 @@ func NewMachineDeployment(ctx context.Context, args MachineDeploymentArgs) (_ Ma
 		return nil, err
 	}
 
+	if err := applyRegionWaves(appConfig, args.RegionWaves); err != nil {
+		tracing.RecordError(span, err, "failed to apply region waves")
+		return nil, err
+	}
+
 	// TODO: Blend extraInfo into ValidationError and remove this hack
 	if err, extraInfo := appConfig.ValidateGroups(ctx, lo.Keys(args.ProcessGroups)); err != nil {
 		fmt.Fprint(io.ErrOut, extraInfo)
@@ -258,6 +267,7 @@ func NewMachineDeployment(ctx context.Context, args MachineDeploymentArgs) (_ Ma
 		flapsClient:           flapsClient,
 		io:                    io,
 		colorize:              io.ColorScheme(),
+		notifier:              cmp.Or(deployNotifierFromContext(ctx), newDeployNotifier(appConfig)),
 		app:                   args.AppCompact,
 		appConfig:             appConfig,
 		img:                   args.DeploymentImage,
@@ -635,6 +645,26 @@ func (md *machineDeployment) logClearLinesAbove(count int) {
 	}
 }
 
+// applyRegionWaves overrides deploy.region_order with --region-wave. fly.toml
+// was validated before the flags were applied, so the strategy is checked
+// again here.
+func applyRegionWaves(appConfig *appconfig.Config, waves []string) error {
+	if len(waves) > 0 {
+		if appConfig.Deploy == nil {
+			appConfig.Deploy = &appconfig.Deploy{}
+		}
+		appConfig.Deploy.RegionOrder = waves
+	}
+	if appConfig.Deploy == nil || len(appConfig.Deploy.RegionOrder) == 0 {
+		return nil
+	}
+	switch strategy := appConfig.Deploy.Strategy; strategy {
+	case "bluegreen", "immediate":
+		return fmt.Errorf("region waves only apply to the rolling and canary strategies, not %s", strategy)
+	}
+	return nil
+}
+
 func determineAppConfigForMachines(ctx context.Context, envFromFlags []string, primaryRegion, strategy string, maxUnavailable *float64, files []*fly.File) (*appconfig.Config, error) {
 	appConfig := appconfig.ConfigFromContext(ctx)
 	if appConfig == nil {
@@ -74,6 +74,10 @@ func (md *machineDeployment) DeployMachinesApp(ctx context.Context) error {
 		return fmt.Errorf("failed to set release status to 'running': %w", err)
 	}
 
+	if !md.restartOnly {
+		md.notify(ctx, appconfig.DeployEventStart, nil)
+	}
+
 	if md.tigrisStatics != nil && !md.restartOnly {
 		if err := md.tigrisStatics.Push(ctx); err != nil {
 			return err
@@ -84,7 +88,18 @@ func (md *machineDeployment) DeployMachinesApp(ctx context.Context) error {
 	if md.restartOnly {
 		err = md.restartMachinesApp(ctx)
 	} else {
-		err = md.deployMachinesApp(ctx)
+		// Remember the machines as they were, to roll back to if the
+		// post-deploy hooks or the smoke test fail.
+		var prior *AppState
+		if md.autoRollback() {
+			prior, err = md.appState(ctx, nil)
+		}
+		if err == nil {
+			err = md.deployMachinesApp(ctx)
+		}
+		if err == nil {
+			err = md.postDeploy(ctx, prior)
+		}
 	}
 
 	var status string
@@ -124,6 +139,14 @@ func (md *machineDeployment) DeployMachinesApp(ctx context.Context) error {
 		}
 	}
 
+	if !md.restartOnly {
+		if err == nil {
+			md.notify(ctx, appconfig.DeployEventSuccess, nil)
+		} else {
+			md.notify(ctx, appconfig.DeployEventFailure, err)
+		}
+	}
+
 	// no need to run dns checks if the deployment failed
 	if !md.skipDNSChecks && err == nil {
 		if err := md.checkDNS(ctx); err != nil {
@@ -424,6 +447,10 @@ func (md *machineDeployment) deployMachinesApp(ctx context.Context) error {
 	ctx, span := tracing.GetTracer().Start(ctx, "deploy_new_machines")
 	defer span.End()
 
+	if err := md.runHooks(ctx, appconfig.DeployHookPreRelease); err != nil {
+		return fmt.Errorf("pre-release hook failed - aborting deployment. %w", err)
+	}
+
 	if !md.skipReleaseCommand {
 		if err := md.runReleaseCommands(ctx); err != nil {
 			return fmt.Errorf("release command failed - aborting deployment. %w", err)
@@ -672,7 +699,7 @@ func (md *machineDeployment) updateExistingMachinesWRecovery(ctx context.Context
 			return err
 		}
 
-		return md.updateMachinesWRecovery(ctx, oldAppState, &newAppState, nil, updateMachineSettings{
+		return md.updateMachinesInWaves(ctx, oldAppState, &newAppState, updateMachineSettings{
 			pushForward:          true,
 			skipHealthChecks:     md.skipHealthChecks,
 			skipSmokeChecks:      md.skipSmokeChecks,
@@ -681,7 +708,7 @@ func (md *machineDeployment) updateExistingMachinesWRecovery(ctx context.Context
 	case "rolling":
 		fallthrough
 	default:
-		return md.updateMachinesWRecovery(ctx, oldAppState, &newAppState, nil, updateMachineSettings{
+		return md.updateMachinesInWaves(ctx, oldAppState, &newAppState, updateMachineSettings{
 			pushForward:          true,
 			skipHealthChecks:     md.skipHealthChecks,
 			skipSmokeChecks:      md.skipSmokeChecks,
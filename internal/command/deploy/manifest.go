@@ -49,6 +49,7 @@ type DeployManifest struct {
 	RestartPolicy         *fly.MachineRestartPolicy `json:"restart_policy,omitempty"`
 	RestartMaxRetries     int                       `json:"restart_max_retrie,omitempty"`
 	DeployRetries         int                       `json:"deploy_retries,omitempty"`
+	RegionWaves           []string                  `json:"region_waves,omitempty"`
 }
 
 func NewManifest(AppName string, config *appconfig.Config, args MachineDeploymentArgs) *DeployManifest {
@@ -83,6 +84,7 @@ func NewManifest(AppName string, config *appconfig.Config, args MachineDeploymen
 		RestartPolicy:         args.RestartPolicy,
 		RestartMaxRetries:     args.RestartMaxRetries,
 		DeployRetries:         args.DeployRetries,
+		RegionWaves:           args.RegionWaves,
 	}
 }
 
@@ -149,6 +151,17 @@ func deployFromManifest(ctx context.Context, manifest *DeployManifest) error {
 
 	ctx = appconfig.WithConfig(ctx, manifest.Config)
 
+	unlock, err := lockDeploy(ctx, app)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Secrets may have been unset since the manifest was written.
+	if err := checkRequiredSecrets(ctx, manifest.Config, app.Name); err != nil {
+		return err
+	}
+
 	args := argsFromManifest(manifest, app)
 
 	md, err := NewMachineDeployment(ctx, args)
@@ -0,0 +1,324 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/buildinfo"
+	"github.com/superfly/flyctl/terminal"
+)
+
+const (
+	notifyAttempts = 3
+	notifyTimeout  = 10 * time.Second
+)
+
+// deployEvent is what webhooks are told about a deploy. It's sent as is to
+// webhooks using the json format.
+type deployEvent struct {
+	Event          string    `json:"event"`
+	App            string    `json:"app"`
+	Org            string    `json:"org"`
+	ReleaseVersion int       `json:"release_version"`
+	Image          string    `json:"image"`
+	Strategy       string    `json:"strategy"`
+	User           string    `json:"user"`
+	Duration       float64   `json:"duration_seconds"`
+	Error          string    `json:"error,omitempty"`
+	MonitoringURL  string    `json:"monitoring_url"`
+	FlyctlVersion  string    `json:"flyctl_version"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// deployNotifier sends deploy events to the webhooks of [deploy.notify].
+// The start, success and failure events are sent at most once, so both
+// DeployWithConfig, which reports failures before the machine deployment
+// starts, and the machine deployment can send failure.
+type deployNotifier struct {
+	config  *appconfig.DeployNotify
+	client  *http.Client
+	started time.Time
+
+	userOnce sync.Once
+	user     string
+
+	mu   sync.Mutex
+	sent map[string]bool
+}
+
+type deployNotifierKey struct{}
+
+func withDeployNotifier(ctx context.Context, n *deployNotifier) context.Context {
+	return context.WithValue(ctx, deployNotifierKey{}, n)
+}
+
+// deployNotifierFromContext returns the notifier of the deploy in progress,
+// if DeployWithConfig set one up.
+func deployNotifierFromContext(ctx context.Context) *deployNotifier {
+	n, _ := ctx.Value(deployNotifierKey{}).(*deployNotifier)
+	return n
+}
+
+// newDeployNotifier returns a notifier for cfg, or nil if the app doesn't
+// have any webhook.
+func newDeployNotifier(cfg *appconfig.Config) *deployNotifier {
+	if cfg.Deploy == nil || cfg.Deploy.Notify == nil || len(cfg.Deploy.Notify.Webhooks) == 0 {
+		return nil
+	}
+	return &deployNotifier{
+		config:  cfg.Deploy.Notify,
+		client:  &http.Client{Timeout: notifyTimeout},
+		started: time.Now(),
+		sent:    map[string]bool{},
+	}
+}
+
+// notify tells the app's webhooks about a deploy event. Webhooks failing
+// only warn, deploys go on regardless.
+func (md *machineDeployment) notify(ctx context.Context, event string, deployErr error) {
+	n := md.notifier
+	if n == nil {
+		return
+	}
+
+	ev := n.newEvent(ctx, md.apiClient, md.app, event, deployErr)
+	ev.ReleaseVersion = md.releaseVersion
+	ev.Image = md.img
+	ev.Strategy = md.strategy
+
+	n.send(ctx, ev)
+}
+
+// newEvent returns event for app, with what's known before a release is
+// created.
+func (n *deployNotifier) newEvent(ctx context.Context, client webClient, app *fly.AppCompact, event string, deployErr error) deployEvent {
+	ev := deployEvent{
+		Event:         event,
+		App:           app.Name,
+		User:          n.deployUser(ctx, client),
+		MonitoringURL: fmt.Sprintf("https://fly.io/apps/%s/monitoring", app.Name),
+		FlyctlVersion: buildinfo.Info().Version.String(),
+		Timestamp:     time.Now().UTC(),
+	}
+	if app.Organization != nil {
+		ev.Org = app.Organization.Slug
+	}
+	if event != appconfig.DeployEventStart {
+		ev.Duration = time.Since(n.started).Round(time.Second).Seconds()
+	}
+	if deployErr != nil {
+		ev.Error = deployErr.Error()
+	}
+	return ev
+}
+
+// once reports whether ev should be sent, recording that it was if it's
+// one of the events only sent once. Success and failure end the deploy, so
+// only the first of them is sent.
+func (n *deployNotifier) once(ev deployEvent) bool {
+	key := ev.Event
+	switch ev.Event {
+	case appconfig.DeployEventStart:
+	case appconfig.DeployEventSuccess, appconfig.DeployEventFailure:
+		key = "end"
+	default:
+		return true
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.sent[key] {
+		return false
+	}
+	n.sent[key] = true
+	return true
+}
+
+// deployUser is who runs the deploy: the user the token belongs to, or what
+// FLY_DEPLOY_USER says for CI jobs using deploy tokens.
+func (n *deployNotifier) deployUser(ctx context.Context, client webClient) string {
+	n.userOnce.Do(func() {
+		if n.user = os.Getenv("FLY_DEPLOY_USER"); n.user != "" {
+			return
+		}
+		if user, err := client.GetCurrentUser(ctx); err == nil && user != nil {
+			n.user = user.Email
+		}
+		if n.user == "" {
+			n.user = "unknown"
+		}
+	})
+	return n.user
+}
+
+func (n *deployNotifier) send(ctx context.Context, ev deployEvent) {
+	if !n.once(ev) {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, webhook := range n.config.Webhooks {
+		events := webhook.Events
+		if len(events) == 0 {
+			events = n.config.Events
+		}
+		if len(events) > 0 && !slices.Contains(events, ev.Event) {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := n.post(ctx, webhook, ev); err != nil {
+				terminal.Warnf("failed to send deploy %s notification: %v\n", ev.Event, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// post sends an event to a webhook, retrying on network errors, rate
+// limits and server errors.
+func (n *deployNotifier) post(ctx context.Context, webhook appconfig.DeployWebhook, ev deployEvent) error {
+	body, err := json.Marshal(webhookPayload(webhook.Format, ev))
+	if err != nil {
+		return err
+	}
+	url := os.ExpandEnv(webhook.URL)
+
+	return retry.Do(
+		func() error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+			if err != nil {
+				return retry.Unrecoverable(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", "flyctl/"+ev.FlyctlVersion)
+			for k, v := range webhook.Headers {
+				req.Header.Set(k, os.ExpandEnv(v))
+			}
+
+			resp, err := n.client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+			switch {
+			case resp.StatusCode < 300:
+				return nil
+			case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+				return fmt.Errorf("webhook returned %s", resp.Status)
+			default:
+				return retry.Unrecoverable(fmt.Errorf("webhook returned %s", resp.Status))
+			}
+		},
+		retry.Context(ctx),
+		retry.Attempts(notifyAttempts),
+		retry.Delay(time.Second),
+		retry.LastErrorOnly(true),
+	)
+}
+
+// webhookPayload formats an event for a webhook format.
+func webhookPayload(format string, ev deployEvent) any {
+	title := eventTitle(ev)
+	facts := eventFacts(ev)
+
+	switch format {
+	case appconfig.WebhookFormatSlack:
+		fields := make([]map[string]any, 0, len(facts))
+		for _, f := range facts {
+			fields = append(fields, map[string]any{"title": f[0], "value": f[1], "short": f[0] != "Image" && f[0] != "Error"})
+		}
+		return map[string]any{
+			"text": fmt.Sprintf("%s (<%s|monitoring>)", title, ev.MonitoringURL),
+			"attachments": []map[string]any{
+				{"color": "#" + eventColor(ev.Event), "fields": fields},
+			},
+		}
+	case appconfig.WebhookFormatDiscord:
+		fields := make([]map[string]any, 0, len(facts))
+		for _, f := range facts {
+			fields = append(fields, map[string]any{"name": f[0], "value": f[1], "inline": f[0] != "Image" && f[0] != "Error"})
+		}
+		color, _ := strconv.ParseInt(eventColor(ev.Event), 16, 64)
+		return map[string]any{
+			"embeds": []map[string]any{
+				{"title": title, "url": ev.MonitoringURL, "color": color, "fields": fields, "timestamp": ev.Timestamp},
+			},
+		}
+	case appconfig.WebhookFormatTeams:
+		teamsFacts := make([]map[string]string, 0, len(facts))
+		for _, f := range facts {
+			teamsFacts = append(teamsFacts, map[string]string{"name": f[0], "value": f[1]})
+		}
+		return map[string]any{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    title,
+			"title":      title,
+			"themeColor": eventColor(ev.Event),
+			"sections":   []map[string]any{{"facts": teamsFacts}},
+			"potentialAction": []map[string]any{
+				{"@type": "OpenUri", "name": "Monitoring", "targets": []map[string]string{{"os": "default", "uri": ev.MonitoringURL}}},
+			},
+		}
+	default:
+		return ev
+	}
+}
+
+func eventTitle(ev deployEvent) string {
+	what := map[string]string{
+		appconfig.DeployEventStart:    "started",
+		appconfig.DeployEventSuccess:  "succeeded",
+		appconfig.DeployEventFailure:  "failed",
+		appconfig.DeployEventRollback: "rolled back",
+	}[ev.Event]
+	return fmt.Sprintf("Deploy of %s v%d %s", ev.App, ev.ReleaseVersion, what)
+}
+
+func eventFacts(ev deployEvent) [][2]string {
+	facts := [][2]string{
+		{"App", ev.App},
+		{"Release", "v" + strconv.Itoa(ev.ReleaseVersion)},
+		{"Strategy", ev.Strategy},
+		{"User", ev.User},
+	}
+	if ev.Event != appconfig.DeployEventStart {
+		facts = append(facts, [2]string{"Duration", (time.Duration(ev.Duration) * time.Second).String()})
+	}
+	facts = append(facts, [2]string{"Image", ev.Image})
+	if ev.Error != "" {
+		facts = append(facts, [2]string{"Error", strings.TrimSpace(ev.Error)})
+	}
+	return facts
+}
+
+func eventColor(event string) string {
+	switch event {
+	case appconfig.DeployEventSuccess:
+		return "22c55e"
+	case appconfig.DeployEventFailure:
+		return "ef4444"
+	case appconfig.DeployEventRollback:
+		return "f59e0b"
+	default:
+		return "3b82f6"
+	}
+}
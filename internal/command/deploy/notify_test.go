@@ -0,0 +1,153 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/flag/flagctx"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/launchdarkly"
+	"github.com/superfly/flyctl/internal/mock"
+)
+
+func TestNotify(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+		received = map[string][]map[string]any{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.URL.Path == "/flaky" {
+			if attempts++; attempts == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		}
+		var payload map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.Equal(t, "secret", r.Header.Get("Authorization"))
+		received[r.URL.Path] = append(received[r.URL.Path], payload)
+	}))
+	defer srv.Close()
+
+	t.Setenv("NOTIFY_URL", srv.URL)
+	t.Setenv("NOTIFY_TOKEN", "secret")
+	headers := map[string]string{"Authorization": "$NOTIFY_TOKEN"}
+
+	cfg := &appconfig.Config{Deploy: &appconfig.Deploy{Notify: &appconfig.DeployNotify{
+		Events: []string{appconfig.DeployEventFailure, appconfig.DeployEventRollback},
+		Webhooks: []appconfig.DeployWebhook{
+			{URL: "${NOTIFY_URL}/json", Headers: headers, Events: appconfig.DeployEvents},
+			{URL: "${NOTIFY_URL}/flaky", Headers: headers, Format: appconfig.WebhookFormatSlack},
+		},
+	}}}
+
+	md := &machineDeployment{
+		app:            &fly.AppCompact{Name: "app", Organization: &fly.OrganizationBasic{Slug: "org"}},
+		apiClient:      &mock.Client{GetCurrentUserFunc: func(context.Context) (*fly.User, error) { return &fly.User{Email: "dev@example.com"}, nil }},
+		img:            "registry.fly.io/app:deployment-1",
+		strategy:       "rolling",
+		releaseVersion: 7,
+		notifier:       newDeployNotifier(cfg),
+	}
+
+	ctx := context.Background()
+	md.notify(ctx, appconfig.DeployEventStart, nil)
+	md.notify(ctx, appconfig.DeployEventFailure, assert.AnError)
+
+	require.Len(t, received["/json"], 2)
+	assert.Equal(t, "start", received["/json"][0]["event"])
+	assert.Equal(t, "dev@example.com", received["/json"][0]["user"])
+	assert.Equal(t, float64(7), received["/json"][0]["release_version"])
+	assert.Equal(t, "org", received["/json"][1]["org"])
+	assert.Equal(t, assert.AnError.Error(), received["/json"][1]["error"])
+
+	require.Len(t, received["/flaky"], 1)
+	assert.Equal(t, 2, attempts)
+	assert.Contains(t, received["/flaky"][0]["text"], "Deploy of app v7 failed")
+
+	// A deploy starts and ends once, however many times it's reported.
+	md.notify(ctx, appconfig.DeployEventStart, nil)
+	md.notify(ctx, appconfig.DeployEventSuccess, nil)
+	md.notify(ctx, appconfig.DeployEventRollback, assert.AnError)
+	require.Len(t, received["/json"], 3)
+	assert.Equal(t, "rollback", received["/json"][2]["event"])
+}
+
+func TestDeployWithConfigNotifiesEarlyFailures(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, payload["event"].(string))
+	}))
+	defer srv.Close()
+
+	cfg := appconfig.NewConfig()
+	cfg.AppName = "app"
+	cfg.Secrets = &appconfig.Secrets{Required: []string{"DATABASE_URL"}}
+	cfg.Deploy = &appconfig.Deploy{Notify: &appconfig.DeployNotify{
+		Webhooks: []appconfig.DeployWebhook{{URL: srv.URL}},
+	}}
+
+	client := &mock.Client{
+		GetAppCompactFunc: func(ctx context.Context, appName string) (*fly.AppCompact, error) {
+			return &fly.AppCompact{Name: appName, Organization: &fly.OrganizationBasic{Slug: "org"}}, nil
+		},
+		GetAppSecretsFunc: func(ctx context.Context, appName string) ([]fly.Secret, error) {
+			return nil, nil
+		},
+		GetAppLockFunc: func(ctx context.Context, name string) (*fly.App, error) {
+			return &fly.App{}, nil
+		},
+		LockAppFunc: func(ctx context.Context, input fly.LockAppInput) (*fly.LockApp, error) {
+			return &fly.LockApp{LockID: "lock", Expiration: time.Now().Add(time.Hour)}, nil
+		},
+		UnlockAppFunc: func(ctx context.Context, input fly.UnlockAppInput) (*fly.App, error) {
+			return &fly.App{}, nil
+		},
+	}
+	t.Setenv("FLY_DEPLOY_USER", "ci")
+
+	ctx := withQuietIOStreams(context.Background())
+	ctx = flagctx.NewContext(ctx, New().Flags())
+	ctx = appconfig.WithName(ctx, "app")
+	ctx = flyutil.NewContextWithClient(ctx, client)
+	ctx = launchdarkly.NewContextWithClient(ctx, &launchdarkly.Client{})
+
+	err := DeployWithConfig(ctx, cfg, 0, true)
+	assert.ErrorContains(t, err, "DATABASE_URL")
+	assert.Equal(t, []string{"failure"}, events)
+}
+
+func TestWebhookPayload(t *testing.T) {
+	ev := deployEvent{Event: appconfig.DeployEventRollback, App: "app", ReleaseVersion: 3, Duration: 90}
+
+	discord := webhookPayload(appconfig.WebhookFormatDiscord, ev).(map[string]any)
+	embed := discord["embeds"].([]map[string]any)[0]
+	assert.Equal(t, "Deploy of app v3 rolled back", embed["title"])
+	assert.Equal(t, int64(0xf59e0b), embed["color"])
+
+	teams := webhookPayload(appconfig.WebhookFormatTeams, ev).(map[string]any)
+	assert.Equal(t, "MessageCard", teams["@type"])
+	assert.Contains(t, teams["sections"].([]map[string]any)[0]["facts"], map[string]string{"name": "Duration", "value": "1m30s"})
+
+	assert.Equal(t, ev, webhookPayload(appconfig.WebhookFormatJSON, ev))
+}
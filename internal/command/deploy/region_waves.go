@@ -0,0 +1,168 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/prompt"
+)
+
+// regionWave is a set of machines updated together during a region by
+// region rollout.
+type regionWave struct {
+	regions  []string
+	machines []*fly.Machine
+}
+
+func (w regionWave) String() string {
+	return strings.Join(w.regions, ", ")
+}
+
+// regionWaves splits machines into waves following order. Each region of
+// order is a wave, "*" is a wave of every region not in order, and regions
+// not matched by order make up a last wave. Empty waves are dropped.
+func regionWaves(order []string, machines []*fly.Machine) []regionWave {
+	byRegion := make(map[string][]*fly.Machine)
+	for _, m := range machines {
+		byRegion[m.Region] = append(byRegion[m.Region], m)
+	}
+
+	rest := func() regionWave {
+		var wave regionWave
+		for region := range byRegion {
+			if !slices.Contains(order, region) {
+				wave.regions = append(wave.regions, region)
+			}
+		}
+		sort.Strings(wave.regions)
+		for _, region := range wave.regions {
+			wave.machines = append(wave.machines, byRegion[region]...)
+		}
+		return wave
+	}
+
+	var waves []regionWave
+	for _, region := range order {
+		wave := regionWave{regions: []string{region}, machines: byRegion[region]}
+		if region == appconfig.RegionWaveWildcard {
+			wave = rest()
+		}
+		if len(wave.machines) > 0 {
+			waves = append(waves, wave)
+		}
+	}
+	if !slices.Contains(order, appconfig.RegionWaveWildcard) {
+		if wave := rest(); len(wave.machines) > 0 {
+			waves = append(waves, wave)
+		}
+	}
+	return waves
+}
+
+// regionOrder is the region order of rollouts, if any.
+func (md *machineDeployment) regionOrder() []string {
+	if md.appConfig.Deploy == nil {
+		return nil
+	}
+	return md.appConfig.Deploy.RegionOrder
+}
+
+// updateMachinesInWaves updates machines like updateMachinesWRecovery, one
+// region wave at a time when the app has a region order. Each wave passes
+// its health checks, and the smoke test if asked, before the next one
+// starts, so a bad release doesn't go further than the wave it broke.
+func (md *machineDeployment) updateMachinesInWaves(ctx context.Context, oldAppState, newAppState *AppState, settings updateMachineSettings) error {
+	waves := regionWaves(md.regionOrder(), newAppState.Machines)
+	if len(waves) <= 1 {
+		return md.updateMachinesWRecovery(ctx, oldAppState, newAppState, nil, settings)
+	}
+
+	deploy := md.appConfig.Deploy
+	if deploy.RegionWaveApproval && !md.io.CanPrompt() {
+		return errors.New("deploy.region_wave_approval needs an interactive terminal to approve waves, use region_wave_bake to pause between waves instead")
+	}
+
+	for i, wave := range waves {
+		fmt.Fprintf(md.io.Out, "Updating machines in %s (wave %d of %d)\n", md.colorize.Bold(wave.String()), i+1, len(waves))
+
+		if err := md.updateMachinesWRecovery(ctx, oldAppState, &AppState{Machines: wave.machines}, nil, settings); err != nil {
+			return fmt.Errorf("wave %d (%s) failed%s: %w", i+1, wave, remainingWaves(waves[i+1:]), err)
+		}
+		if deploy.RegionWaveSmokeTest {
+			if err := md.runRegionSmokeTest(ctx, wave); err != nil {
+				return fmt.Errorf("wave %d (%s) failed%s: %w", i+1, wave, remainingWaves(waves[i+1:]), err)
+			}
+		}
+
+		if i == len(waves)-1 {
+			break
+		}
+		if deploy.RegionWaveBake != nil && deploy.RegionWaveBake.Duration > 0 {
+			fmt.Fprintf(md.io.Out, "Waiting %s before updating %s\n", deploy.RegionWaveBake.Duration, waves[i+1])
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(deploy.RegionWaveBake.Duration):
+			}
+		}
+		if deploy.RegionWaveApproval {
+			confirmed, err := prompt.Confirmf(ctx, "Machines in %s are updated. Continue with %s?", wave, waves[i+1])
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				return fmt.Errorf("deploy stopped after wave %d (%s)%s", i+1, wave, remainingWaves(waves[i+1:]))
+			}
+		}
+	}
+	return nil
+}
+
+// remainingWaves tells which regions a failed rollout didn't get to.
+func remainingWaves(waves []regionWave) string {
+	if len(waves) == 0 {
+		return ""
+	}
+	regions := make([]string, 0, len(waves))
+	for _, wave := range waves {
+		regions = append(regions, wave.String())
+	}
+	return fmt.Sprintf(", %s still run the previous release", strings.Join(regions, ", "))
+}
+
+// runRegionSmokeTest runs the smoke test after a wave, asking Fly Proxy to
+// route probes to the wave's region when it has a single one. Apps without a
+// public http service can only use absolute probe urls.
+func (md *machineDeployment) runRegionSmokeTest(ctx context.Context, wave regionWave) error {
+	if md.appConfig.Deploy == nil || md.appConfig.Deploy.SmokeTest == nil {
+		return nil
+	}
+	st := *md.appConfig.Deploy.SmokeTest
+	env := md.hookEnv()
+	if len(wave.regions) == 1 {
+		region := wave.regions[0]
+		env["FLY_DEPLOY_REGION"] = region
+		st.HTTP = make([]appconfig.SmokeTestProbe, len(md.appConfig.Deploy.SmokeTest.HTTP))
+		for i, probe := range md.appConfig.Deploy.SmokeTest.HTTP {
+			headers := map[string]string{"Fly-Prefer-Region": region}
+			for k, v := range probe.Headers {
+				headers[k] = v
+			}
+			probe.Headers = headers
+			st.HTTP[i] = probe
+		}
+	}
+
+	fmt.Fprintf(md.io.ErrOut, "Running smoke test for %s in %s\n", md.colorize.Bold(md.app.Name), wave)
+	if err := smokeTest(ctx, &st, md.appConfig.URL(), env, md.io.ErrOut); err != nil {
+		return fmt.Errorf("smoke test failed: %w", err)
+	}
+	return nil
+}
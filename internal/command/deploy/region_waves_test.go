@@ -0,0 +1,139 @@
+package deploy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func TestRegionWaves(t *testing.T) {
+	machines := []*fly.Machine{
+		{ID: "m1", Region: "iad"},
+		{ID: "m2", Region: "ams"},
+		{ID: "m3", Region: "fra"},
+		{ID: "m4", Region: "iad"},
+		{ID: "m5", Region: "syd"},
+	}
+	ids := func(waves []regionWave) [][]string {
+		var out [][]string
+		for _, wave := range waves {
+			var wids []string
+			for _, m := range wave.machines {
+				wids = append(wids, m.ID)
+			}
+			out = append(out, append([]string{wave.String()}, wids...))
+		}
+		return out
+	}
+
+	assert.Equal(t, [][]string{
+		{"ams", "m2"},
+		{"iad", "m1", "m4"},
+		{"fra, syd", "m3", "m5"},
+	}, ids(regionWaves([]string{"ams", "iad", "*"}, machines)))
+
+	// "*" can come first, and regions without machines are skipped.
+	assert.Equal(t, [][]string{
+		{"ams, fra, iad", "m2", "m3", "m1", "m4"},
+		{"syd", "m5"},
+	}, ids(regionWaves([]string{"*", "lhr", "syd"}, machines)))
+
+	// Regions left out go last.
+	assert.Equal(t, [][]string{
+		{"syd", "m5"},
+		{"ams, fra, iad", "m2", "m3", "m1", "m4"},
+	}, ids(regionWaves([]string{"syd"}, machines)))
+
+	assert.Len(t, regionWaves(nil, machines), 1)
+}
+
+func TestUpdateMachinesInWaves(t *testing.T) {
+	ios, _, stdout, _ := iostreams.Test()
+	client := &mockFlapsClient{}
+	for _, m := range []*fly.Machine{{ID: "m1", Region: "iad"}, {ID: "m2", Region: "ams"}} {
+		m.LeaseNonce = m.ID + "-lease"
+		m.Config = &fly.MachineConfig{Metadata: map[string]string{fly.MachineConfigMetadataKeyFlyProcessGroup: "app"}}
+		client.machines = append(client.machines, m)
+	}
+
+	md := &machineDeployment{
+		app:         &fly.AppCompact{Name: "app"},
+		io:          ios,
+		colorize:    ios.ColorScheme(),
+		flapsClient: client,
+		strategy:    "rolling",
+		appConfig: &appconfig.Config{Deploy: &appconfig.Deploy{
+			RegionOrder:    []string{"ams", "*"},
+			RegionWaveBake: &fly.Duration{Duration: time.Millisecond},
+		}},
+		waitTimeout:    time.Second,
+		maxUnavailable: 1,
+		maxConcurrent:  1,
+	}
+
+	ctx := iostreams.NewContext(context.Background(), ios)
+	ctx = flapsutil.NewContextWithClient(ctx, client)
+	state := &AppState{Machines: client.machines}
+	err := md.updateMachinesInWaves(ctx, state, state, updateMachineSettings{skipHealthChecks: true, skipSmokeChecks: true})
+	require.NoError(t, err)
+
+	out := stdout.String()
+	assert.Contains(t, out, "Updating machines in ams (wave 1 of 2)")
+	assert.Contains(t, out, "Waiting 1ms before updating iad")
+	assert.Contains(t, out, "Updating machines in iad (wave 2 of 2)")
+
+	md.appConfig.Deploy.RegionWaveApproval = true
+	err = md.updateMachinesInWaves(ctx, state, state, updateMachineSettings{skipHealthChecks: true, skipSmokeChecks: true})
+	assert.ErrorContains(t, err, "needs an interactive terminal")
+}
+
+func TestApplyRegionWaves(t *testing.T) {
+	cfg := &appconfig.Config{}
+	require.NoError(t, applyRegionWaves(cfg, []string{"ams", "*"}))
+	assert.Equal(t, []string{"ams", "*"}, cfg.Deploy.RegionOrder)
+
+	cfg = &appconfig.Config{Deploy: &appconfig.Deploy{Strategy: "bluegreen"}}
+	assert.ErrorContains(t, applyRegionWaves(cfg, []string{"ams"}), "not bluegreen")
+
+	// --strategy may turn a fly.toml region_order invalid too.
+	cfg = &appconfig.Config{Deploy: &appconfig.Deploy{Strategy: "immediate", RegionOrder: []string{"ams"}}}
+	assert.ErrorContains(t, applyRegionWaves(cfg, nil), "not immediate")
+
+	cfg = &appconfig.Config{Deploy: &appconfig.Deploy{Strategy: "immediate"}}
+	assert.NoError(t, applyRegionWaves(cfg, nil))
+}
+
+func TestRunRegionSmokeTestWithoutURL(t *testing.T) {
+	var region string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		region = r.Header.Get("Fly-Prefer-Region")
+	}))
+	defer server.Close()
+
+	ios, _, _, _ := iostreams.Test()
+	md := &machineDeployment{
+		app:      &fly.AppCompact{Name: "app"},
+		io:       ios,
+		colorize: ios.ColorScheme(),
+		appConfig: &appconfig.Config{Deploy: &appconfig.Deploy{SmokeTest: &appconfig.SmokeTest{
+			HTTP:    []appconfig.SmokeTestProbe{{URL: server.URL + "/health"}},
+			Timeout: &fly.Duration{Duration: time.Second},
+		}}},
+	}
+	wave := regionWave{regions: []string{"ams"}}
+
+	require.NoError(t, md.runRegionSmokeTest(context.Background(), wave))
+	assert.Equal(t, "ams", region)
+
+	md.appConfig.Deploy.SmokeTest.HTTP[0].URL = "/health"
+	assert.ErrorContains(t, md.runRegionSmokeTest(context.Background(), wave), "no public http service")
+}
@@ -0,0 +1,59 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/flyerr"
+	"github.com/superfly/flyctl/internal/flyutil"
+)
+
+// checkRequiredSecrets fails the deploy before anything is built when a secret
+// the app config depends on hasn't been set, rather than letting the new
+// machines crash on boot.
+func checkRequiredSecrets(ctx context.Context, appConfig *appconfig.Config, appName string) error {
+	required := appConfig.RequiredSecrets()
+	if len(required) == 0 {
+		return nil
+	}
+
+	secrets, err := flyutil.ClientFromContext(ctx).GetAppSecrets(ctx, appName)
+	if err != nil {
+		return fmt.Errorf("failed checking required secrets: %w", err)
+	}
+
+	missing := missingSecrets(required, secrets)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	pairs := make([]string, 0, len(missing))
+	for _, name := range missing {
+		pairs = append(pairs, name+"=...")
+	}
+
+	return flyerr.GenericErr{
+		Err:      fmt.Sprintf("app %s is missing required secrets: %s", appName, strings.Join(missing, ", ")),
+		Descript: "These secrets are listed in [secrets] required or referenced by [[files]] secret_name in your app config.",
+		Suggest:  fmt.Sprintf("Set them with: fly secrets set --stage -a %s %s", appName, strings.Join(pairs, " ")),
+		DocUrl:   "https://fly.io/docs/apps/secrets/",
+	}
+}
+
+func missingSecrets(required []string, secrets []fly.Secret) []string {
+	set := make(map[string]bool, len(secrets))
+	for _, s := range secrets {
+		set[s.Name] = true
+	}
+
+	var missing []string
+	for _, name := range required {
+		if !set[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
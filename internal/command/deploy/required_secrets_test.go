@@ -0,0 +1,28 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+)
+
+func TestMissingSecrets(t *testing.T) {
+	cfg := appconfig.NewConfig()
+	cfg.Secrets = &appconfig.Secrets{Required: []string{"DATABASE_URL", "API_KEY"}}
+	cfg.Files = []appconfig.File{
+		{GuestPath: "/etc/cert.pem", SecretName: "TLS_CERT"},
+		{GuestPath: "/etc/key.pem", SecretName: "API_KEY"},
+		{GuestPath: "/etc/motd", RawValue: "hello"},
+	}
+
+	required := cfg.RequiredSecrets()
+	assert.Equal(t, []string{"API_KEY", "DATABASE_URL", "TLS_CERT"}, required)
+
+	secrets := []fly.Secret{{Name: "API_KEY"}, {Name: "UNRELATED"}}
+	assert.Equal(t, []string{"DATABASE_URL", "TLS_CERT"}, missingSecrets(required, secrets))
+
+	secrets = append(secrets, fly.Secret{Name: "DATABASE_URL"}, fly.Secret{Name: "TLS_CERT"})
+	assert.Empty(t, missingSecrets(required, secrets))
+}
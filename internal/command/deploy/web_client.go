@@ -22,6 +22,7 @@ type webClient interface {
 
 	GetApp(ctx context.Context, appName string) (*fly.App, error)
 	GetOrganizationBySlug(ctx context.Context, slug string) (*fly.Organization, error)
+	GetCurrentUser(ctx context.Context) (*fly.User, error)
 
 	logs.WebClient
 	blueGreenWebClient
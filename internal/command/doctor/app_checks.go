@@ -92,6 +92,7 @@ func (ac *AppChecker) checkAll() map[string]string {
 
 	ipAddresses := ac.checkIpsAllocated()
 	ac.checkDnsRecords(ipAddresses)
+	ac.checkRuntime()
 
 	relPath, err := filepath.Rel(ac.workDir, ac.appConfig.ConfigFilePath())
 	if err == nil && relPath == appconfig.DefaultConfigFileName {
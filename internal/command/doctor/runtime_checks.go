@@ -0,0 +1,372 @@
+package doctor
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/machine"
+)
+
+const (
+	severityCritical = "critical"
+	severityWarning  = "warning"
+
+	// A machine that exited abnormally this many times within
+	// restartLoopWindow, or that is on its restartLoopCount'th restart, is
+	// considered to be in a restart loop.
+	restartLoopCount  = 3
+	restartLoopWindow = time.Hour
+
+	oomWindow = 24 * time.Hour
+
+	volumeFullPercent = 90
+)
+
+// runtimeFinding is a problem found with the running app, along with a
+// command that fixes it or helps figuring out what's wrong.
+type runtimeFinding struct {
+	Severity string
+	Problem  string
+	Fix      string
+}
+
+func (f runtimeFinding) String() string {
+	return fmt.Sprintf("%s: %s (run: %s)", f.Severity, f.Problem, f.Fix)
+}
+
+func (ac *AppChecker) checkRuntime() {
+	ac.lprint(nil, "\nRuntime checks for %s:\n", ac.app.Name)
+
+	flapsClient := flapsutil.ClientFromContext(ac.ctx)
+	machines, err := flapsClient.List(ac.ctx, "")
+	if err != nil {
+		ac.lprint(nil, "API error listing machines for app %s: %v\n", ac.app.Name, err)
+		return
+	}
+	machines = slices.DeleteFunc(machines, func(m *fly.Machine) bool { return !m.IsActive() || !isAppMachine(m) })
+
+	now := time.Now()
+	appName := ac.app.Name
+
+	ac.reportFindings("appRestartLoops", "Checking for machines in restart loops... ",
+		findRestartLoops(appName, machines, now))
+	ac.reportFindings("appOOMKills", "Checking for recent out of memory kills... ",
+		findOOMKills(appName, machines, now))
+	ac.reportFindings("appFailingChecks", "Checking health checks... ",
+		findFailingChecks(appName, machines))
+	ac.reportFindings("appVolumeUsage", "Checking volume usage... ",
+		ac.findFullVolumes(machines))
+	ac.reportFindings("appProcessGroupsRunning", "Checking that every process group has running machines... ",
+		findIdleProcessGroups(appName, ac.appConfig.ProcessNames(), machines))
+	ac.reportFindings("appImagesMatch", "Checking that machines run the same image... ",
+		findMismatchedImages(appName, machines))
+}
+
+func (ac *AppChecker) reportFindings(checkKey, msg string, findings []runtimeFinding) {
+	ac.lprint(nil, "%s", msg)
+
+	if len(findings) == 0 {
+		ac.checks[checkKey] = "ok"
+		ac.lprint(ac.color.Green, "PASSED\n")
+		return
+	}
+
+	ac.lprint(nil, "Nope\n")
+	details := make([]string, 0, len(findings))
+	for _, f := range findings {
+		color := ac.color.Yellow
+		if f.Severity == severityCritical {
+			color = ac.color.Red
+		}
+		ac.lprint(color, "\t[%s] ", f.Severity)
+		ac.lprint(nil, "%s\n\t  Run: %s\n", f.Problem, f.Fix)
+		details = append(details, f.String())
+	}
+	ac.checks[checkKey] = strings.Join(details, "; ")
+}
+
+// isAppMachine reports whether m runs one of the app's process groups, as
+// opposed to a console, release command or flyctl tool machine.
+func isAppMachine(m *fly.Machine) bool {
+	return m.IsAppsV2() && m.ProcessGroup() != "" && !m.IsReleaseCommandMachine() && !m.IsFlyAppsConsole() && !machine.IsTool(m)
+}
+
+// machineExit returns the exit described by e, if any.
+func machineExit(e *fly.MachineEvent) *fly.MachineExitEvent {
+	switch {
+	case e.Request == nil:
+		return nil
+	case e.Request.MonitorEvent != nil && e.Request.MonitorEvent.ExitEvent != nil:
+		return e.Request.MonitorEvent.ExitEvent
+	default:
+		return e.Request.ExitEvent
+	}
+}
+
+func findRestartLoops(appName string, machines []*fly.Machine, now time.Time) []runtimeFinding {
+	var findings []runtimeFinding
+	for _, m := range machines {
+		var (
+			crashes  int
+			restarts int
+			lastExit *fly.MachineExitEvent
+		)
+		for _, e := range m.Events {
+			exit := machineExit(e)
+			if exit == nil || exit.RequestedStop || (exit.ExitCode == 0 && !exit.OOMKilled) {
+				continue
+			}
+			if now.Sub(e.Time()) <= restartLoopWindow {
+				crashes++
+			}
+			restarts = max(restarts, e.Request.RestartCount)
+			if lastExit == nil {
+				lastExit = exit
+			}
+		}
+		if crashes < restartLoopCount && restarts < restartLoopCount {
+			continue
+		}
+
+		problem := fmt.Sprintf("machine %s (%s) crashed %d time(s) in the last hour", m.ID, m.ProcessGroup(), crashes)
+		if restarts > 0 {
+			problem += fmt.Sprintf(" and is on restart #%d", restarts)
+		}
+		if lastExit != nil {
+			problem += fmt.Sprintf("; last exit code %d", lastExit.ExitCode)
+		}
+		findings = append(findings, runtimeFinding{
+			Severity: severityCritical,
+			Problem:  problem,
+			Fix:      fmt.Sprintf("fly logs -a %s --machine %s", appName, m.ID),
+		})
+	}
+	return findings
+}
+
+func findOOMKills(appName string, machines []*fly.Machine, now time.Time) []runtimeFinding {
+	type groupOOMs struct {
+		kills    int
+		memoryMB int
+		machines []string
+	}
+	groups := map[string]*groupOOMs{}
+
+	for _, m := range machines {
+		kills := 0
+		for _, e := range m.Events {
+			if exit := machineExit(e); exit != nil && exit.OOMKilled && now.Sub(e.Time()) <= oomWindow {
+				kills++
+			}
+		}
+		if kills == 0 {
+			continue
+		}
+
+		g := groups[m.ProcessGroup()]
+		if g == nil {
+			g = &groupOOMs{}
+			groups[m.ProcessGroup()] = g
+		}
+		g.kills += kills
+		g.machines = append(g.machines, m.ID)
+		if guest := m.GetConfig().Guest; guest != nil {
+			g.memoryMB = max(g.memoryMB, guest.MemoryMB)
+		}
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	findings := make([]runtimeFinding, 0, len(names))
+	for _, name := range names {
+		g := groups[name]
+		finding := runtimeFinding{
+			Severity: severityCritical,
+			Problem: fmt.Sprintf("%d out of memory kill(s) in the last 24 hours in process group %s (machines %s) with %dMB of memory",
+				g.kills, name, strings.Join(g.machines, ", "), g.memoryMB),
+			Fix: fmt.Sprintf("fly scale memory %d --process-group %s -a %s", max(g.memoryMB*2, 512), name, appName),
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+func findFailingChecks(appName string, machines []*fly.Machine) []runtimeFinding {
+	var findings []runtimeFinding
+	for _, m := range machines {
+		if m.State != fly.MachineStateStarted {
+			continue
+		}
+		for _, check := range m.Checks {
+			var severity string
+			switch check.Status {
+			case fly.Critical:
+				severity = severityCritical
+			case fly.Warning:
+				severity = severityWarning
+			default:
+				continue
+			}
+
+			problem := fmt.Sprintf("health check %s on machine %s is %s", check.Name, m.ID, check.Status)
+			if output, _, _ := strings.Cut(strings.TrimSpace(check.Output), "\n"); output != "" {
+				problem += fmt.Sprintf(": %s", output)
+			}
+			findings = append(findings, runtimeFinding{
+				Severity: severity,
+				Problem:  problem,
+				Fix:      fmt.Sprintf("fly logs -a %s --machine %s", appName, m.ID),
+			})
+		}
+	}
+	return findings
+}
+
+// findFullVolumes runs df on started machines to find out how full their
+// volumes are, since the API doesn't report volume usage.
+func (ac *AppChecker) findFullVolumes(machines []*fly.Machine) []runtimeFinding {
+	flapsClient := flapsutil.ClientFromContext(ac.ctx)
+
+	var findings []runtimeFinding
+	for _, m := range machines {
+		if m.State != fly.MachineStateStarted {
+			continue
+		}
+		for _, mount := range m.GetConfig().Mounts {
+			out, err := flapsClient.Exec(ac.ctx, m.ID, &fly.MachineExecRequest{
+				Cmd:     "df -Pk " + mount.Path,
+				Timeout: 10,
+			})
+			if err != nil || out.ExitCode != 0 {
+				continue
+			}
+			used, ok := parseDfUsage(out.StdOut)
+			if !ok || used < volumeFullPercent {
+				continue
+			}
+
+			severity := severityWarning
+			if used >= 98 {
+				severity = severityCritical
+			}
+			// Mounts usually don't carry the volume size, so ask for it.
+			fix := fmt.Sprintf("fly volumes show %s -a %s", mount.Volume, ac.app.Name)
+			if vol, err := flapsClient.GetVolume(ac.ctx, mount.Volume); err == nil && vol.SizeGb > 0 {
+				fix = fmt.Sprintf("fly volumes extend %s -s %d -a %s", mount.Volume, vol.SizeGb*2, ac.app.Name)
+			}
+			findings = append(findings, runtimeFinding{
+				Severity: severity,
+				Problem:  fmt.Sprintf("volume %s mounted at %s on machine %s is %d%% full", mount.Volume, mount.Path, m.ID, used),
+				Fix:      fix,
+			})
+		}
+	}
+	return findings
+}
+
+// parseDfUsage returns the percentage of used space from the output of
+// `df -Pk <path>`.
+func parseDfUsage(out string) (int, bool) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return 0, false
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return 0, false
+	}
+	used, err1 := strconv.ParseInt(fields[2], 10, 64)
+	avail, err2 := strconv.ParseInt(fields[3], 10, 64)
+	if err1 != nil || err2 != nil || used+avail == 0 {
+		return 0, false
+	}
+	return int(used * 100 / (used + avail)), true
+}
+
+func findIdleProcessGroups(appName string, processGroups []string, machines []*fly.Machine) []runtimeFinding {
+	var findings []runtimeFinding
+	for _, group := range processGroups {
+		var (
+			stopped   []string
+			running   int
+			autostart bool
+		)
+		for _, m := range machines {
+			if m.ProcessGroup() != group {
+				continue
+			}
+			if m.State == fly.MachineStateStarted {
+				running++
+				continue
+			}
+			stopped = append(stopped, m.ID)
+			for _, s := range m.GetConfig().Services {
+				if s.Autostart != nil && *s.Autostart {
+					autostart = true
+				}
+			}
+		}
+
+		switch {
+		case running > 0:
+		case len(stopped) == 0:
+			findings = append(findings, runtimeFinding{
+				Severity: severityCritical,
+				Problem:  fmt.Sprintf("process group %s has no machines", group),
+				Fix:      fmt.Sprintf("fly scale count 1 --process-group %s -a %s", group, appName),
+			})
+		case !autostart:
+			// Machines stopped by the proxy are started again on demand,
+			// so only report groups nothing is going to start.
+			findings = append(findings, runtimeFinding{
+				Severity: severityWarning,
+				Problem:  fmt.Sprintf("process group %s has no running machines and none are started on demand", group),
+				Fix:      fmt.Sprintf("fly machine start %s -a %s", strings.Join(stopped, " "), appName),
+			})
+		}
+	}
+	return findings
+}
+
+func findMismatchedImages(appName string, machines []*fly.Machine) []runtimeFinding {
+	var (
+		images = map[string][]string{}
+		latest *fly.Machine
+	)
+	for _, m := range machines {
+		ref := m.FullImageRef()
+		images[ref] = append(images[ref], m.ID)
+		if latest == nil || m.UpdatedAt > latest.UpdatedAt {
+			latest = m
+		}
+	}
+	if len(images) < 2 {
+		return nil
+	}
+
+	refs := make([]string, 0, len(images))
+	for ref := range images {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	details := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		details = append(details, fmt.Sprintf("%s on %d machine(s)", ref, len(images[ref])))
+	}
+	return []runtimeFinding{{
+		Severity: severityWarning,
+		Problem:  fmt.Sprintf("machines run %d different images: %s", len(refs), strings.Join(details, ", ")),
+		Fix:      fmt.Sprintf("fly deploy -a %s --image %s", appName, latest.FullImageRef()),
+	}}
+}
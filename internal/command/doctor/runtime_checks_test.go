@@ -0,0 +1,143 @@
+package doctor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/machine"
+)
+
+func exitEvent(at time.Time, exit fly.MachineExitEvent, restarts int) *fly.MachineEvent {
+	return &fly.MachineEvent{
+		Type:      "exit",
+		Timestamp: at.UnixMilli(),
+		Request: &fly.MachineRequest{
+			ExitEvent:    &exit,
+			RestartCount: restarts,
+		},
+	}
+}
+
+func appMetadata(group string) map[string]string {
+	return map[string]string{
+		fly.MachineConfigMetadataKeyFlyPlatformVersion: fly.MachineFlyPlatformVersion2,
+		fly.MachineConfigMetadataKeyFlyProcessGroup:    group,
+	}
+}
+
+func TestFindRestartLoopsAndOOMs(t *testing.T) {
+	now := time.Now()
+	crashing := &fly.Machine{
+		ID:    "m1",
+		State: fly.MachineStateStarted,
+		Config: &fly.MachineConfig{
+			Metadata: appMetadata("web"),
+			Guest:    &fly.MachineGuest{MemoryMB: 256},
+		},
+		Events: []*fly.MachineEvent{
+			exitEvent(now.Add(-time.Minute), fly.MachineExitEvent{ExitCode: 137, OOMKilled: true}, 2),
+			exitEvent(now.Add(-10*time.Minute), fly.MachineExitEvent{ExitCode: 1}, 1),
+			exitEvent(now.Add(-20*time.Minute), fly.MachineExitEvent{ExitCode: 1}, 0),
+		},
+	}
+	healthy := &fly.Machine{
+		ID:    "m2",
+		State: fly.MachineStateStarted,
+		Config: &fly.MachineConfig{
+			Metadata: appMetadata("web"),
+		},
+		Events: []*fly.MachineEvent{
+			exitEvent(now.Add(-time.Minute), fly.MachineExitEvent{RequestedStop: true}, 0),
+		},
+	}
+	machines := []*fly.Machine{crashing, healthy}
+
+	loops := findRestartLoops("shop", machines, now)
+	require.Len(t, loops, 1)
+	assert.Equal(t, severityCritical, loops[0].Severity)
+	assert.Contains(t, loops[0].Problem, "machine m1 (web) crashed 3 time(s)")
+	assert.Contains(t, loops[0].Problem, "last exit code 137")
+	assert.Equal(t, "fly logs -a shop --machine m1", loops[0].Fix)
+
+	ooms := findOOMKills("shop", machines, now)
+	require.Len(t, ooms, 1)
+	assert.Contains(t, ooms[0].Problem, "with 256MB of memory")
+	assert.Equal(t, "fly scale memory 512 --process-group web -a shop", ooms[0].Fix)
+}
+
+func TestFindIdleProcessGroups(t *testing.T) {
+	machine := func(id, group, state string, autostart bool) *fly.Machine {
+		return &fly.Machine{
+			ID:    id,
+			State: state,
+			Config: &fly.MachineConfig{
+				Metadata: map[string]string{fly.MachineConfigMetadataKeyFlyProcessGroup: group},
+				Services: []fly.MachineService{{Autostart: fly.Pointer(autostart)}},
+			},
+		}
+	}
+	machines := []*fly.Machine{
+		machine("m1", "web", fly.MachineStateStopped, true),
+		machine("m2", "worker", fly.MachineStateStopped, false),
+		machine("m3", "cron", fly.MachineStateStarted, false),
+	}
+
+	findings := findIdleProcessGroups("shop", []string{"web", "worker", "cron", "mailer"}, machines)
+	require.Len(t, findings, 2)
+	assert.Equal(t, severityWarning, findings[0].Severity)
+	assert.Equal(t, "fly machine start m2 -a shop", findings[0].Fix)
+	assert.Equal(t, severityCritical, findings[1].Severity)
+	assert.Equal(t, "fly scale count 1 --process-group mailer -a shop", findings[1].Fix)
+}
+
+func TestFindMismatchedImages(t *testing.T) {
+	machine := func(id, tag, updated string) *fly.Machine {
+		return &fly.Machine{
+			ID:        id,
+			UpdatedAt: updated,
+			ImageRef:  fly.MachineImageRef{Registry: "registry.fly.io", Repository: "shop", Tag: tag},
+			Config:    &fly.MachineConfig{Metadata: appMetadata("app")},
+		}
+	}
+
+	assert.Empty(t, findMismatchedImages("shop", []*fly.Machine{
+		machine("m1", "v2", "2024-01-02T00:00:00Z"),
+		machine("m2", "v2", "2024-01-01T00:00:00Z"),
+	}))
+
+	findings := findMismatchedImages("shop", []*fly.Machine{
+		machine("m1", "v2", "2024-01-02T00:00:00Z"),
+		machine("m2", "v1", "2024-01-01T00:00:00Z"),
+	})
+	require.Len(t, findings, 1)
+	assert.Equal(t, "fly deploy -a shop --image registry.fly.io/shop:v2", findings[0].Fix)
+}
+
+func TestIsAppMachine(t *testing.T) {
+	withMetadata := func(metadata map[string]string) *fly.Machine {
+		return &fly.Machine{Config: &fly.MachineConfig{Metadata: metadata}}
+	}
+
+	assert.True(t, isAppMachine(withMetadata(appMetadata("web"))))
+	assert.False(t, isAppMachine(withMetadata(appMetadata(""))))
+	assert.False(t, isAppMachine(withMetadata(appMetadata(fly.MachineProcessGroupFlyAppConsole))))
+	assert.False(t, isAppMachine(withMetadata(map[string]string{fly.MachineConfigMetadataKeyFlyProcessGroup: "web"})))
+
+	tool := appMetadata("web")
+	tool[machine.ToolMetadataKey] = "snapshot-policy"
+	assert.False(t, isAppMachine(withMetadata(tool)))
+}
+
+func TestParseDfUsage(t *testing.T) {
+	used, ok := parseDfUsage(`Filesystem     1024-blocks    Used Available Capacity Mounted on
+/dev/vdc           1011672  950000     61672      94% /data
+`)
+	require.True(t, ok)
+	assert.Equal(t, 93, used)
+
+	_, ok = parseDfUsage("df: /data: No such file or directory")
+	assert.False(t, ok)
+}
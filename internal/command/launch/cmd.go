@@ -28,6 +28,7 @@ import (
 	"github.com/superfly/flyctl/internal/state"
 	"github.com/superfly/flyctl/internal/tracing"
 	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/scanner"
 	"go.opentelemetry.io/otel/attribute"
 )
 
@@ -40,7 +41,18 @@ func New() (cmd *cobra.Command) {
 	cmd = command.New("launch", short, long, run, command.RequireSession, command.RequireUiex, command.LoadAppConfigIfPresent)
 	cmd.Args = cobra.NoArgs
 
-	flags := []flag.Flag{
+	flag.Add(cmd, launchFlags()...)
+
+	cmd.AddCommand(NewPlan())
+	cmd.AddCommand(newApply())
+
+	return
+}
+
+// launchFlags returns the flags of launch, which are also needed by commands
+// that build a launch plan.
+func launchFlags() []flag.Flag {
+	return []flag.Flag{
 		// Since launch can perform a deployment, we offer the full set of deployment flags for those using
 		// the launch command in CI environments. We may want to rescind this decision down the line, because
 		// the list of flags is long, but it follows from the precedent of already offering some deployment flags.
@@ -133,6 +145,14 @@ func New() (cmd *cobra.Command) {
 			Description: "Skip automatically provisioning a GitHub fly deploy workflow",
 			Default:     false,
 		},
+		flag.String{
+			Name:        "ci",
+			Description: "Generate a deploy pipeline for a CI provider: " + strings.Join(scanner.CIProviders, ", "),
+		},
+		flag.Bool{
+			Name:        "ci-review-apps",
+			Description: "Add review apps for merge and pull requests to the generated pipeline",
+		},
 		flag.Bool{
 			Name:        "json",
 			Description: "Generate configuration in JSON format",
@@ -168,13 +188,15 @@ func New() (cmd *cobra.Command) {
 			Description: "Provision a Postgres database. Options: mpg (managed postgres), upg/legacy (unmanaged postgres), or true (default type)",
 			NoOptDefVal: "true",
 		},
+		flag.Bool{
+			Name:        "monorepo",
+			Description: "Detect the services in subdirectories of --path and launch them all",
+		},
+		flag.String{
+			Name:        "monorepo-layout",
+			Description: "How --monorepo launches services: 'apps' launches each as its own app, 'groups' as process groups of one app built from the Dockerfile at --path",
+		},
 	}
-
-	flag.Add(cmd, flags...)
-
-	cmd.AddCommand(NewPlan())
-
-	return
 }
 
 func getManifestArgument(ctx context.Context) (*LaunchManifest, error) {
@@ -269,6 +291,10 @@ func setupFromTemplate(ctx context.Context) (context.Context, *appconfig.Config,
 func run(ctx context.Context) (err error) {
 	io := iostreams.FromContext(ctx)
 
+	if flag.GetBool(ctx, "monorepo") {
+		return runMonorepo(ctx)
+	}
+
 	tp, err := tracing.InitTraceProviderWithoutApp(ctx)
 	if err != nil {
 		fmt.Fprintf(io.ErrOut, "failed to initialize tracing library: =%v", err)
@@ -67,7 +67,13 @@ func (state *launchState) Launch(ctx context.Context) error {
 
 	// TODO: ideally this would be passed as a part of the plan to the Launch UI
 	// and allow choices of what actions are desired to be make there.
-	if state.sourceInfo != nil && state.sourceInfo.GitHubActions.Deploy {
+	if state.sourceInfo != nil && state.Plan.CI.Provider != "" {
+		if planStep == "" || planStep == "generate" {
+			if err = state.setupCI(ctx); err != nil {
+				return err
+			}
+		}
+	} else if state.sourceInfo != nil && state.sourceInfo.GitHubActions.Deploy {
 		if planStep == "" || planStep == "generate" {
 			if err = state.setupGitHubActions(ctx, state.Plan.AppName); err != nil {
 				return err
@@ -2,6 +2,7 @@ package launch
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -22,7 +23,17 @@ import (
 )
 
 // createDatabases creates databases requested by the plan
+// createDatabases provisions the databases and object storage of the plan.
+// Failing to create one is reported, but doesn't stop the launch.
 func (state *launchState) createDatabases(ctx context.Context) error {
+	_, err := state.provisionResources(ctx)
+	return err
+}
+
+// provisionResources provisions the databases and object storage of the
+// plan, returning the ones that failed to be created along with any error
+// that stopped provisioning.
+func (state *launchState) provisionResources(ctx context.Context) (failed []error, err error) {
 	planStep := plan.GetPlanStep(ctx)
 
 	if state.Plan.Postgres.FlyPostgres != nil && (planStep == "" || planStep == "postgres") {
@@ -30,6 +41,7 @@ func (state *launchState) createDatabases(ctx context.Context) error {
 		if err != nil {
 			// TODO(Ali): Make error printing here better.
 			fmt.Fprintf(iostreams.FromContext(ctx).ErrOut, "Error creating Postgres cluster: %s\n", err)
+			failed = append(failed, fmt.Errorf("creating Postgres cluster: %w", err))
 		}
 	}
 
@@ -38,11 +50,13 @@ func (state *launchState) createDatabases(ctx context.Context) error {
 		if err != nil {
 			// TODO(Ali): Make error printing here better.
 			fmt.Fprintf(iostreams.FromContext(ctx).ErrOut, "Error creating Managed Postgres cluster: %s\n", err)
+			failed = append(failed, fmt.Errorf("creating Managed Postgres cluster: %w", err))
 		}
 	}
 
 	if state.Plan.Postgres.SupabasePostgres != nil && (planStep == "" || planStep == "postgres") {
 		fmt.Fprintf(iostreams.FromContext(ctx).ErrOut, "Supabase Postgres is no longer supported.\n")
+		failed = append(failed, errors.New("creating Supabase Postgres: no longer supported"))
 	}
 
 	if state.Plan.Redis.UpstashRedis != nil && (planStep == "" || planStep == "redis") {
@@ -50,6 +64,7 @@ func (state *launchState) createDatabases(ctx context.Context) error {
 		if err != nil {
 			// TODO(Ali): Make error printing here better.
 			fmt.Fprintf(iostreams.FromContext(ctx).ErrOut, "Error provisioning Upstash Redis: %s\n", err)
+			failed = append(failed, fmt.Errorf("provisioning Upstash Redis: %w", err))
 		}
 	}
 
@@ -58,6 +73,7 @@ func (state *launchState) createDatabases(ctx context.Context) error {
 		if err != nil {
 			// TODO(Ali): Make error printing here better.
 			fmt.Fprintf(iostreams.FromContext(ctx).ErrOut, "Error creating Tigris object storage: %s\n", err)
+			failed = append(failed, fmt.Errorf("creating Tigris object storage: %w", err))
 		}
 	}
 
@@ -66,13 +82,13 @@ func (state *launchState) createDatabases(ctx context.Context) error {
 		for _, cmd := range state.sourceInfo.PostgresInitCommands {
 			if cmd.Condition {
 				if err := execInitCommand(ctx, cmd); err != nil {
-					return err
+					return failed, err
 				}
 			}
 		}
 	}
 
-	return nil
+	return failed, nil
 }
 
 func (state *launchState) createFlyPostgres(ctx context.Context) error {
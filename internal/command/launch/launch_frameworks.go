@@ -36,31 +36,10 @@ func (state *launchState) setupGitHubActions(ctx context.Context, appName string
 			fmt.Println("Run `fly tokens create deploy -x 999999h` to create a token and set it as the FLY_API_TOKEN secret in your GitHub repository settings")
 			fmt.Println("See https://docs.github.com/en/actions/security-guides/using-secrets-in-github-actions")
 		} else {
-			apiClient := flyutil.ClientFromContext(ctx)
-
-			expiry := "999999h"
-
-			app, err := apiClient.GetAppCompact(ctx, appName)
+			token, err := createCIToken(ctx, appName, false)
 			if err != nil {
-				return fmt.Errorf("failed retrieving app %s: %w", appName, err)
-			}
-
-			resp, err := gql.CreateLimitedAccessToken(
-				ctx,
-				apiClient.GenqClient(),
-				appName,
-				app.Organization.ID,
-				"deploy",
-				&gql.LimitedAccessTokenOptions{
-					"app_id": app.ID,
-				},
-				expiry,
-			)
-			if err != nil {
-				return fmt.Errorf("failed creating token: %w", err)
+				return err
 			} else {
-				token := resp.CreateLimitedAccessToken.LimitedAccessToken.TokenHeader
-
 				fmt.Println("Setting FLY_API_TOKEN secret in GitHub repository settings")
 				cmd := exec.Command(gh, "secret", "set", "FLY_API_TOKEN")
 				cmd.Stdin = strings.NewReader(token)
@@ -76,6 +55,126 @@ func (state *launchState) setupGitHubActions(ctx context.Context, appName string
 	return nil
 }
 
+const (
+	// ciTokenExpiry is how long the deploy token set up for a pipeline lasts.
+	ciTokenExpiry = "999999h"
+	// ciReviewTokenExpiry is how long the org token review jobs use lasts.
+	// It can create and destroy any app in the org, so it has to be rotated.
+	ciReviewTokenExpiry = "720h"
+
+	ciTokenName       = "FLY_API_TOKEN"
+	ciReviewTokenName = "FLY_REVIEW_API_TOKEN"
+)
+
+// createCIToken creates a token for CI to deploy appName with. Review apps
+// create and destroy apps, which needs a token for the whole org; that one
+// expires after ciReviewTokenExpiry.
+func createCIToken(ctx context.Context, appName string, review bool) (string, error) {
+	apiClient := flyutil.ClientFromContext(ctx)
+
+	app, err := apiClient.GetAppCompact(ctx, appName)
+	if err != nil {
+		return "", fmt.Errorf("failed retrieving app %s: %w", appName, err)
+	}
+
+	profile, options, expiry := "deploy", &gql.LimitedAccessTokenOptions{"app_id": app.ID}, ciTokenExpiry
+	if review {
+		profile, options, expiry = "deploy_organization", &gql.LimitedAccessTokenOptions{}, ciReviewTokenExpiry
+	}
+
+	resp, err := gql.CreateLimitedAccessToken(
+		ctx,
+		apiClient.GenqClient(),
+		appName,
+		app.Organization.ID,
+		profile,
+		options,
+		expiry,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed creating token: %w", err)
+	}
+
+	return resp.CreateLimitedAccessToken.LimitedAccessToken.TokenHeader, nil
+}
+
+// setupCI adds the deploy pipeline for the CI provider chosen in the plan and,
+// where the provider has a CLI for it, stores the tokens for the pipeline.
+// Deploys get a token for the app only; review jobs get a separate org token
+// with a finite expiry.
+func (state *launchState) setupCI(ctx context.Context) error {
+	if flag.GetString(ctx, "from") != "" {
+		return nil
+	}
+
+	ci := state.Plan.CI
+
+	files, err := scanner.CIFiles(ci.Provider, scanner.CIVars{
+		AppName: state.Plan.AppName,
+		OrgSlug: state.Plan.OrgSlug,
+		Region:  state.Plan.RegionCode,
+		Review:  ci.Review,
+	})
+	if err != nil {
+		return err
+	}
+	state.sourceInfo.Files = append(state.sourceInfo.Files, files...)
+
+	if err := state.setCIToken(ctx, ciTokenName, "fly tokens create deploy -x "+ciTokenExpiry, false); err != nil {
+		return err
+	}
+	if ci.Review {
+		tokenCmd := fmt.Sprintf("fly tokens create org %s -x %s", state.Plan.OrgSlug, ciReviewTokenExpiry)
+		if err := state.setCIToken(ctx, ciReviewTokenName, tokenCmd, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setCIToken creates a token and stores it as name with the CI provider's CLI,
+// or tells the user to run tokenCmd and store it themselves when there's no
+// CLI to do it with.
+func (state *launchState) setCIToken(ctx context.Context, name, tokenCmd string, review bool) error {
+	var (
+		io   = iostreams.FromContext(ctx)
+		ci   = state.Plan.CI
+		cli  string
+		args []string
+	)
+	switch ci.Provider {
+	case "github":
+		cli, args = "gh", []string{"secret", "set", name}
+	case "gitlab":
+		cli, args = "glab", []string{"variable", "set", name, "--masked"}
+	}
+
+	path := ""
+	if cli != "" {
+		path, _ = exec.LookPath(cli)
+	}
+	if path == "" || flag.GetBool(ctx, "no-create") {
+		fmt.Fprintf(io.Out, "Run `%s` to create a token and set it as %s in your %s pipeline settings\n", tokenCmd, name, ci.Provider)
+		return nil
+	}
+
+	token, err := createCIToken(ctx, state.Plan.AppName, review)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(io.Out, "Setting %s with %s\n", name, cli)
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = strings.NewReader(token)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		fmt.Fprintf(io.ErrOut, "Failed setting %s with %s: %v: %s\n", name, cli, err, strings.TrimSpace(string(out)))
+		fmt.Fprintf(io.Out, "Run `%s` to create a token and set it as %s in your %s pipeline settings\n", tokenCmd, name, ci.Provider)
+	}
+
+	return nil
+}
+
 // satisfyScannerBeforeDb performs operations that the scanner requests that must be done before databases are created
 func (state *launchState) satisfyScannerBeforeDb(ctx context.Context) error {
 	if err := state.scannerCreateFiles(ctx); err != nil {
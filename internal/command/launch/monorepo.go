@@ -0,0 +1,587 @@
+package launch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/pflag"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command/deploy"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flyerr"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/prompt"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/scanner"
+)
+
+const (
+	monorepoLayoutApps   = "apps"
+	monorepoLayoutGroups = "groups"
+)
+
+// monorepoMaxDepth is how deep below the repository root services are looked
+// for, which covers both api/ and services/api/ layouts.
+const monorepoMaxDepth = 2
+
+// monorepoMarkers are files that make a directory worth scanning. Running
+// every scanner on every directory would be slow and some scanners match
+// loosely on files that are common in non-service directories.
+var monorepoMarkers = []string{
+	"Dockerfile", "package.json", "go.mod", "Gemfile", "requirements.txt",
+	"pyproject.toml", "Pipfile", "Cargo.toml", "pom.xml", "build.gradle",
+	"build.gradle.kts", "mix.exs", "composer.json", "deno.json", "deno.jsonc",
+}
+
+var monorepoSkipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"target":       true,
+	"build":        true,
+	"dist":         true,
+	"tmp":          true,
+	"deps":         true,
+	"_build":       true,
+}
+
+type monorepoService struct {
+	Name       string
+	Dir        string
+	RelDir     string
+	AppName    string
+	SourceInfo *scanner.SourceInfo
+}
+
+// detectMonorepoServices walks root looking for directories the scanner
+// recognizes. Directories below a detected service are not scanned, so a
+// service's own subpackages aren't launched separately.
+func detectMonorepoServices(root string, scannerConfig *scanner.ScannerConfig) ([]*monorepoService, error) {
+	var services []*monorepoService
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || path == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") || monorepoSkipDirs[d.Name()] {
+			return filepath.SkipDir
+		}
+		depth := strings.Count(rel, string(filepath.Separator)) + 1
+		if depth > monorepoMaxDepth {
+			return filepath.SkipDir
+		}
+
+		if !hasMonorepoMarker(path) {
+			return nil
+		}
+
+		si, err := scanMonorepoDir(path, scannerConfig)
+		if err != nil {
+			return fmt.Errorf("failed scanning %s: %w", rel, err)
+		}
+		if si == nil {
+			return nil
+		}
+
+		services = append(services, &monorepoService{
+			Name:       filepath.Base(path),
+			Dir:        path,
+			RelDir:     filepath.ToSlash(rel),
+			SourceInfo: si,
+		})
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(services, func(i, j int) bool { return services[i].RelDir < services[j].RelDir })
+	return services, nil
+}
+
+// scanMonorepoDir scans a candidate service directory. Scanners look their
+// files up relative to the working directory rather than the directory they
+// are given, so the working directory is switched to dir for the scan only.
+func scanMonorepoDir(dir string, scannerConfig *scanner.ScannerConfig) (*scanner.SourceInfo, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return nil, err
+	}
+	defer os.Chdir(wd) // skipcq: GO-S2307
+
+	return scanner.Scan(dir, scannerConfig)
+}
+
+func hasMonorepoMarker(dir string) bool {
+	for _, name := range monorepoMarkers {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.csproj"))
+	return len(matches) > 0
+}
+
+// monorepoEnvName returns the name of the variable holding a service's URL,
+// e.g. API_URL for a service in api/.
+func monorepoEnvName(service string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsNumber(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, service)
+	return strings.Trim(name, "_") + "_URL"
+}
+
+// monorepoServiceEnv returns the variables pointing svc at every other
+// service that serves HTTP. URLs use flycast so that requests reach stopped
+// machines too.
+func monorepoServiceEnv(svc *monorepoService, services []*monorepoService) map[string]string {
+	env := map[string]string{}
+	for _, other := range services {
+		if other == svc || other.SourceInfo.Port == 0 || other.AppName == "" {
+			continue
+		}
+		env[monorepoEnvName(other.Name)] = "http://" + other.AppName + ".flycast"
+	}
+	return env
+}
+
+// monorepoGroupName returns the process group a service runs as in the
+// groups layout, e.g. web_app for a service in web-app/.
+func monorepoGroupName(service string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsNumber(r)) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, service)
+	return strings.Trim(name, "_")
+}
+
+// monorepoGroupCommand returns the command of a service's process group, if
+// its scanner knows how the service is started.
+func monorepoGroupCommand(svc *monorepoService) string {
+	if cmd := svc.SourceInfo.Processes["app"]; cmd != "" {
+		return cmd
+	}
+	return svc.SourceInfo.DockerCommand
+}
+
+// configureMonorepoGroups turns the config launch generated for the
+// repository root into one with a process group per service. The first
+// service that serves HTTP gets the app's http_service, and every service is
+// pointed at the others with <SERVICE>_URL variables using the
+// <group>.process.<app>.internal addresses of their machines. It returns the
+// groups whose command couldn't be detected.
+func configureMonorepoGroups(cfg *appconfig.Config, services []*monorepoService) (missingCommands []string) {
+	cfg.Processes = map[string]string{}
+	env := map[string]string{}
+	var web *monorepoService
+	for _, svc := range services {
+		group := monorepoGroupName(svc.Name)
+		cfg.Processes[group] = monorepoGroupCommand(svc)
+		if cfg.Processes[group] == "" {
+			missingCommands = append(missingCommands, group)
+		}
+
+		if svc.SourceInfo.Port == 0 {
+			continue
+		}
+		if web == nil {
+			web = svc
+		}
+		env[monorepoEnvName(svc.Name)] = fmt.Sprintf("http://%s.process.%s.internal:%d", group, cfg.AppName, svc.SourceInfo.Port)
+	}
+	cfg.SetEnvVariables(env)
+
+	// Sections launch generated were for the single default group; without
+	// processes they apply to every group.
+	for _, compute := range cfg.Compute {
+		compute.Processes = nil
+	}
+	for i := range cfg.Mounts {
+		cfg.Mounts[i].Processes = nil
+	}
+	for _, check := range cfg.Checks {
+		check.Processes = nil
+	}
+	// Services come from the ports the root Dockerfile exposes, which say
+	// nothing about the ports of each service.
+	cfg.Services = nil
+
+	if web == nil {
+		cfg.HTTPService = nil
+		return missingCommands
+	}
+	if cfg.HTTPService == nil {
+		cfg.HTTPService = &appconfig.HTTPService{ForceHTTPS: true}
+	}
+	cfg.HTTPService.InternalPort = web.SourceInfo.Port
+	cfg.HTTPService.Processes = []string{monorepoGroupName(web.Name)}
+
+	return missingCommands
+}
+
+// runMonorepo launches every service found below the launch path, each as
+// its own app or as process groups of one app.
+func runMonorepo(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+
+	if flag.GetString(ctx, "from") != "" || flag.GetString(ctx, "from-manifest") != "" || flag.GetBool(ctx, "manifest") {
+		return errors.New("--monorepo can't be combined with --from, --manifest or --from-manifest")
+	}
+	if flag.GetString(ctx, "image") != "" || flag.GetString(ctx, "dockerfile") != "" {
+		return errors.New("--monorepo can't be combined with --image or --dockerfile; each service is built from its own directory")
+	}
+	if flag.GetBool(ctx, "json") || flag.GetBool(ctx, "yaml") {
+		return errors.New("--monorepo can't be combined with --json or --yaml; services are configured with fly.toml files")
+	}
+
+	root, err := filepath.Abs(flag.GetString(ctx, "path"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(io.Out, "Scanning monorepo for services")
+	services, err := detectMonorepoServices(root, &scanner.ScannerConfig{
+		Mode:      "launch",
+		Colorize:  io.ColorScheme(),
+		PluginDir: scannerPluginDir(ctx),
+	})
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		return fmt.Errorf("no services were found below %s", root)
+	}
+
+	prefix := flag.GetString(ctx, "name")
+	if prefix == "" {
+		prefix = sanitizeAppName(filepath.Base(root))
+	}
+
+	layout, err := monorepoLayout(ctx)
+	if err != nil {
+		return err
+	}
+	if layout == monorepoLayoutGroups {
+		return runMonorepoGroups(ctx, root, prefix, services)
+	}
+	return runMonorepoApps(ctx, prefix, services)
+}
+
+// monorepoLayout returns the --monorepo-layout, asking for it when it isn't
+// set and the plan is being reviewed.
+func monorepoLayout(ctx context.Context) (string, error) {
+	switch layout := flag.GetString(ctx, "monorepo-layout"); layout {
+	case monorepoLayoutApps, monorepoLayoutGroups:
+		return layout, nil
+	case "":
+	default:
+		return "", fmt.Errorf("invalid --monorepo-layout %q, expected %s or %s", layout, monorepoLayoutApps, monorepoLayoutGroups)
+	}
+	if flag.GetYes(ctx) {
+		return monorepoLayoutApps, nil
+	}
+
+	var index int
+	err := prompt.Select(ctx, &index, "How should the services be launched?", "",
+		"One app per service",
+		"Process groups in one app, built from the Dockerfile at the repository root",
+	)
+	switch {
+	case prompt.IsNonInteractive(err):
+		return monorepoLayoutApps, nil
+	case err != nil:
+		return "", err
+	case index == 1:
+		return monorepoLayoutGroups, nil
+	default:
+		return monorepoLayoutApps, nil
+	}
+}
+
+// confirmMonorepoLaunch asks for the combined plan to be confirmed, unless
+// --yes was given.
+func confirmMonorepoLaunch(ctx context.Context) (bool, error) {
+	if flag.GetYes(ctx) {
+		return true, nil
+	}
+	switch confirmed, err := prompt.Confirm(ctx, "Launch these services?"); {
+	case err == nil:
+		return confirmed, nil
+	case prompt.IsNonInteractive(err):
+		return false, prompt.NonInteractiveError("yes flag must be specified when not running interactively")
+	default:
+		return false, err
+	}
+}
+
+func monorepoPort(svc *monorepoService) string {
+	if svc.SourceInfo.Port > 0 {
+		return strconv.Itoa(svc.SourceInfo.Port)
+	}
+	return "-"
+}
+
+// runMonorepoApps launches each service as its own app. Apps are created and
+// configured first, then wired to each other with environment variables, and
+// only then deployed, so the first deploy of each service already knows where
+// the others are.
+func runMonorepoApps(ctx context.Context, prefix string, services []*monorepoService) error {
+	var (
+		io       = iostreams.FromContext(ctx)
+		noCreate = flag.GetBool(ctx, "no-create")
+		noDeploy = flag.GetBool(ctx, "no-deploy")
+	)
+
+	for _, svc := range services {
+		svc.AppName = sanitizeAppName(prefix + "-" + svc.Name)
+	}
+
+	rows := make([][]string, 0, len(services))
+	for _, svc := range services {
+		rows = append(rows, []string{svc.Name, svc.RelDir, svc.SourceInfo.Family, monorepoPort(svc), svc.AppName})
+	}
+	fmt.Fprintf(io.Out, "We're about to launch %d services, each as its own app:\n\n", len(services))
+	if err := render.Table(io.Out, "", rows, "Service", "Path", "Detected", "Port", "App"); err != nil {
+		return err
+	}
+	fmt.Fprintln(io.Out, "Services that serve HTTP are reachable from the others at http://<app>.flycast, passed in <SERVICE>_URL variables.")
+	fmt.Fprintln(io.Out)
+
+	if confirmed, err := confirmMonorepoLaunch(ctx); err != nil || !confirmed {
+		return err
+	}
+
+	// Deploys are held back until every app exists and is wired up.
+	for _, svc := range services {
+		fmt.Fprintf(io.Out, "\n==> Launching %s from %s\n", svc.Name, svc.RelDir)
+
+		if err := launchMonorepoDir(ctx, svc.Dir, svc.AppName); err != nil {
+			return fmt.Errorf("failed launching %s: %w", svc.Name, err)
+		}
+
+		// The app name may have been given a suffix if it was taken.
+		cfg, err := appconfig.LoadConfig(filepath.Join(svc.Dir, appconfig.DefaultConfigFileName))
+		if err != nil {
+			return err
+		}
+		svc.AppName = cfg.AppName
+	}
+
+	for _, svc := range services {
+		env := monorepoServiceEnv(svc, services)
+		if len(env) == 0 {
+			continue
+		}
+		path := filepath.Join(svc.Dir, appconfig.DefaultConfigFileName)
+		cfg, err := appconfig.LoadConfig(path)
+		if err != nil {
+			return err
+		}
+		cfg.SetEnvVariables(env)
+		if err := cfg.WriteToFile(path); err != nil {
+			return err
+		}
+	}
+
+	if noCreate {
+		fmt.Fprintln(io.Out, "\nGenerated configuration for all services")
+		return nil
+	}
+
+	client := flyutil.ClientFromContext(ctx)
+	for _, svc := range services {
+		if svc.SourceInfo.Port == 0 {
+			continue
+		}
+		addr, err := client.AllocateIPAddress(ctx, svc.AppName, "private_v6", "", nil, "")
+		if err != nil {
+			return fmt.Errorf("failed allocating a flycast address for %s: %w", svc.AppName, err)
+		}
+		fmt.Fprintf(io.Out, "Allocated flycast address %s for %s\n", addr.Address, svc.AppName)
+	}
+
+	if noDeploy {
+		fmt.Fprintln(io.Out, "\nAll services are ready! Deploy each with `fly deploy` from its directory")
+		return nil
+	}
+
+	for _, svc := range services {
+		if svc.SourceInfo.SkipDeploy {
+			fmt.Fprintf(io.Out, "\nSkipping deploy of %s; check %s before deploying it\n", svc.Name, svc.RelDir)
+			continue
+		}
+		fmt.Fprintf(io.Out, "\n==> Deploying %s\n", svc.Name)
+		if err := deployMonorepoDir(ctx, svc.Dir); err != nil {
+			return fmt.Errorf("failed deploying %s: %w", svc.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// runMonorepoGroups launches the services as process groups of one app. The
+// groups of an app share one image, built from the Dockerfile at the root of
+// the repository, and each runs its service's command.
+func runMonorepoGroups(ctx context.Context, root, prefix string, services []*monorepoService) error {
+	var (
+		io       = iostreams.FromContext(ctx)
+		noCreate = flag.GetBool(ctx, "no-create")
+		noDeploy = flag.GetBool(ctx, "no-deploy")
+	)
+
+	if _, err := os.Stat(filepath.Join(root, "Dockerfile")); err != nil {
+		return flyerr.GenericErr{
+			Err:     "process groups share one image, but there is no Dockerfile at the root of the repository",
+			Suggest: "Add a Dockerfile building every service at " + root + ", or use --monorepo-layout " + monorepoLayoutApps,
+		}
+	}
+
+	groups := map[string]string{}
+	rows := make([][]string, 0, len(services))
+	for _, svc := range services {
+		group := monorepoGroupName(svc.Name)
+		if other, ok := groups[group]; ok {
+			return fmt.Errorf("services in %s and %s would both run as process group %s", other, svc.RelDir, group)
+		}
+		groups[group] = svc.RelDir
+
+		command := monorepoGroupCommand(svc)
+		if command == "" {
+			command = "-"
+		}
+		rows = append(rows, []string{svc.Name, svc.RelDir, svc.SourceInfo.Family, monorepoPort(svc), group, command})
+	}
+	fmt.Fprintf(io.Out, "We're about to launch %d services as process groups of app %s:\n\n", len(services), prefix)
+	if err := render.Table(io.Out, "", rows, "Service", "Path", "Detected", "Port", "Process Group", "Command"); err != nil {
+		return err
+	}
+	fmt.Fprintln(io.Out, "Services that serve HTTP are reachable from the others at http://<group>.process.<app>.internal:<port>, passed in <SERVICE>_URL variables.")
+	fmt.Fprintln(io.Out)
+
+	if confirmed, err := confirmMonorepoLaunch(ctx); err != nil || !confirmed {
+		return err
+	}
+
+	fmt.Fprintf(io.Out, "\n==> Launching %s from %s\n", prefix, root)
+	if err := launchMonorepoDir(ctx, root, prefix); err != nil {
+		return fmt.Errorf("failed launching %s: %w", prefix, err)
+	}
+
+	path := filepath.Join(root, appconfig.DefaultConfigFileName)
+	cfg, err := appconfig.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	missingCommands := configureMonorepoGroups(cfg, services)
+	if err := cfg.WriteToFile(path); err != nil {
+		return err
+	}
+
+	switch {
+	case noCreate:
+		fmt.Fprintln(io.Out, "\nGenerated configuration for all services")
+	case len(missingCommands) > 0:
+		fmt.Fprintf(io.Out, "\nSet the command of the %s process groups in [processes] of %s, then deploy with `fly deploy`\n", strings.Join(missingCommands, ", "), path)
+	case noDeploy:
+		fmt.Fprintln(io.Out, "\nAll services are ready! Deploy them with `fly deploy`")
+	default:
+		fmt.Fprintf(io.Out, "\n==> Deploying %s\n", cfg.AppName)
+		if err := deployMonorepoDir(ctx, root); err != nil {
+			return fmt.Errorf("failed deploying %s: %w", cfg.AppName, err)
+		}
+	}
+	return nil
+}
+
+// monorepoFlags are the launch flags runMonorepo sets itself for every
+// launch and deploy it runs. Every other flag given is passed on.
+var monorepoFlags = map[string]bool{
+	"monorepo":        true,
+	"monorepo-layout": true,
+	"path":            true,
+	"name":            true,
+	"generate-name":   true,
+	"config":          true,
+	"internal-port":   true,
+	"yes":             true,
+	"no-deploy":       true,
+	"access-token":    true,
+}
+
+// passedFlags returns the flags given on the command line as arguments,
+// leaving out monorepoFlags and, if known is set, flags it doesn't know.
+func passedFlags(ctx context.Context, known *pflag.FlagSet) []string {
+	var args []string
+	flag.FromContext(ctx).Visit(func(f *pflag.Flag) {
+		if monorepoFlags[f.Name] || (known != nil && known.Lookup(f.Name) == nil) {
+			return
+		}
+		if values, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range values.GetSlice() {
+				args = append(args, "--"+f.Name+"="+v)
+			}
+			return
+		}
+		args = append(args, "--"+f.Name+"="+f.Value.String())
+	})
+	return args
+}
+
+// launchMonorepoDir runs launch for the source in dir, without deploying.
+func launchMonorepoDir(ctx context.Context, dir, appName string) error {
+	args := []string{"launch", "--path", dir, "--name", appName, "--yes", "--no-deploy"}
+	return runFlyctl(ctx, dir, append(args, passedFlags(ctx, nil)...)...)
+}
+
+// deployMonorepoDir deploys the app configured in dir, with the deploy flags
+// given to launch.
+func deployMonorepoDir(ctx context.Context, dir string) error {
+	args := []string{"deploy", "--config", filepath.Join(dir, appconfig.DefaultConfigFileName), "--yes"}
+	return runFlyctl(ctx, dir, append(args, passedFlags(ctx, deploy.New().Flags())...)...)
+}
+
+// runFlyctl runs flyctl with args from dir, as the current user. Running each
+// service's launch in its own process keeps the working directory and flags
+// of one from leaking into the next.
+func runFlyctl(ctx context.Context, dir string, args ...string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	io := iostreams.FromContext(ctx)
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Dir = dir
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = io.In, io.Out, io.ErrOut
+	cmd.Env = os.Environ()
+	if token := config.Tokens(ctx).All(); token != "" {
+		cmd.Env = append(cmd.Env, config.AccessTokenEnvKey+"="+token)
+	}
+
+	return cmd.Run()
+}
@@ -0,0 +1,111 @@
+package launch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/flag/flagctx"
+	"github.com/superfly/flyctl/scanner"
+)
+
+func TestDetectMonorepoServices(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"api/go.mod":                    "module example.com/api\n\ngo 1.22\n",
+		"api/go.sum":                    "",
+		"api/internal/tools/go.mod":     "module example.com/tools\n",
+		"services/worker/Cargo.toml":    "[package]\nname = \"worker\"\n",
+		"services/worker/Cargo.lock":    "",
+		"web/node_modules/x/go.mod":     "module x\n",
+		"docs/README.md":                "docs",
+		"deep/nested/service/Gemfile":   "",
+		".github/workflows/Dockerfile":  "FROM scratch",
+		"services/empty/.gitkeep":       "",
+		"services/worker/src/main.rs":   "fn main() {}",
+		"services/worker/target/go.mod": "module y\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	}
+
+	services, err := detectMonorepoServices(root, &scanner.ScannerConfig{})
+	require.NoError(t, err)
+
+	var dirs []string
+	for _, svc := range services {
+		dirs = append(dirs, svc.RelDir)
+	}
+	assert.Equal(t, []string{"api", "services/worker"}, dirs)
+	assert.Equal(t, "Go", services[0].SourceInfo.Family)
+	assert.Equal(t, "worker", services[1].Name)
+}
+
+func TestMonorepoServiceEnv(t *testing.T) {
+	api := &monorepoService{Name: "api", AppName: "shop-api", SourceInfo: &scanner.SourceInfo{Port: 8080}}
+	web := &monorepoService{Name: "web-app", AppName: "shop-web-app", SourceInfo: &scanner.SourceInfo{Port: 3000}}
+	worker := &monorepoService{Name: "worker", AppName: "shop-worker", SourceInfo: &scanner.SourceInfo{}}
+	services := []*monorepoService{api, web, worker}
+
+	assert.Equal(t, map[string]string{
+		"WEB_APP_URL": "http://shop-web-app.flycast",
+	}, monorepoServiceEnv(api, services))
+	assert.Equal(t, map[string]string{
+		"API_URL":     "http://shop-api.flycast",
+		"WEB_APP_URL": "http://shop-web-app.flycast",
+	}, monorepoServiceEnv(worker, services))
+}
+
+func TestConfigureMonorepoGroups(t *testing.T) {
+	api := &monorepoService{Name: "api", SourceInfo: &scanner.SourceInfo{Port: 8080, Processes: map[string]string{"app": "bin/api"}}}
+	web := &monorepoService{Name: "web-app", SourceInfo: &scanner.SourceInfo{Port: 3000, DockerCommand: "npm start"}}
+	worker := &monorepoService{Name: "worker", SourceInfo: &scanner.SourceInfo{}}
+
+	cfg := &appconfig.Config{
+		AppName:     "shop",
+		HTTPService: &appconfig.HTTPService{InternalPort: 80, ForceHTTPS: true, Processes: []string{"app"}},
+		Compute:     []*appconfig.Compute{{Size: "shared-cpu-1x", Processes: []string{"app"}}},
+		Services:    []appconfig.Service{{InternalPort: 9000, Processes: []string{"app"}}},
+	}
+	missing := configureMonorepoGroups(cfg, []*monorepoService{api, web, worker})
+
+	assert.Equal(t, []string{"worker"}, missing)
+	assert.Equal(t, map[string]string{"api": "bin/api", "web_app": "npm start", "worker": ""}, cfg.Processes)
+	assert.Equal(t, 8080, cfg.HTTPService.InternalPort)
+	assert.Equal(t, []string{"api"}, cfg.HTTPService.Processes)
+	assert.Nil(t, cfg.Compute[0].Processes)
+	assert.Empty(t, cfg.Services)
+	assert.Equal(t, map[string]string{
+		"API_URL":     "http://api.process.shop.internal:8080",
+		"WEB_APP_URL": "http://web_app.process.shop.internal:3000",
+	}, cfg.Env)
+
+	cfg = &appconfig.Config{AppName: "jobs", HTTPService: &appconfig.HTTPService{InternalPort: 80}}
+	assert.Equal(t, []string{"worker"}, configureMonorepoGroups(cfg, []*monorepoService{worker}))
+	assert.Nil(t, cfg.HTTPService)
+}
+
+func TestPassedFlags(t *testing.T) {
+	fs := pflag.NewFlagSet("launch", pflag.ContinueOnError)
+	fs.String("org", "", "")
+	fs.String("name", "", "")
+	fs.Bool("no-db", false, "")
+	fs.Bool("monorepo", false, "")
+	fs.StringSlice("volume", nil, "")
+	fs.String("region", "", "")
+	require.NoError(t, fs.Parse([]string{"--org", "acme", "--name", "shop", "--no-db", "--monorepo", "--volume=a:/a,b:/b"}))
+	ctx := flagctx.NewContext(context.Background(), fs)
+
+	assert.Equal(t, []string{"--no-db=true", "--org=acme", "--volume=a:/a", "--volume=b:/b"}, passedFlags(ctx, nil))
+
+	known := pflag.NewFlagSet("deploy", pflag.ContinueOnError)
+	known.String("org", "", "")
+	assert.Equal(t, []string{"--org=acme"}, passedFlags(ctx, known))
+}
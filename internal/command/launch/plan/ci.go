@@ -0,0 +1,9 @@
+package plan
+
+// CIPlan selects the CI system launch generates a deploy pipeline for. When
+// Provider is empty, a GitHub Actions workflow is still added for repositories
+// hosted on GitHub.
+type CIPlan struct {
+	Provider string `json:"provider,omitempty"`
+	Review   bool   `json:"review,omitempty"`
+}
@@ -32,6 +32,7 @@ type LaunchPlan struct {
 	Postgres      PostgresPlan      `json:"postgres"`
 	Redis         RedisPlan         `json:"redis"`
 	GitHubActions GitHubActionsPlan `json:"github_actions"`
+	CI            CIPlan            `json:"ci"`
 	Sentry        bool              `json:"sentry"`
 	ObjectStorage ObjectStoragePlan `json:"object_storage"`
 
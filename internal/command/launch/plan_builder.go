@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"slices"
 	"strings"
 	"unicode"
 
@@ -202,6 +203,18 @@ func buildManifest(ctx context.Context, parentConfig *appconfig.Config, recovera
 		FlyctlVersion:    buildinfo.Info().Version,
 	}
 
+	if provider := flag.GetString(ctx, "ci"); provider != "" {
+		if !slices.Contains(scanner.CIProviders, provider) {
+			return nil, nil, flyerr.GenericErr{
+				Err:     fmt.Sprintf("unsupported CI provider %q", provider),
+				Suggest: "Supported providers are " + strings.Join(scanner.CIProviders, ", "),
+			}
+		}
+		lp.CI = plan.CIPlan{Provider: provider, Review: flag.GetBool(ctx, "ci-review-apps")}
+	} else if flag.GetBool(ctx, "ci-review-apps") {
+		return nil, nil, errors.New("--ci-review-apps requires --ci")
+	}
+
 	planSource := &launchPlanSource{
 		appNameSource:  appNameExplanation,
 		regionSource:   regionExplanation,
@@ -787,6 +800,10 @@ func determineCompute(ctx context.Context, config *appconfig.Config, srcInfo *sc
 	def := helpers.Clone(fly.MachinePresets["shared-cpu-1x"])
 	def.MemoryMB = 1024
 	reason := "most apps need about 1GB of RAM"
+	if srcInfo != nil && srcInfo.MemoryMB > def.MemoryMB {
+		def.MemoryMB = srcInfo.MemoryMB
+		reason = fmt.Sprintf("%s apps need about %dGB of RAM", srcInfo.Family, srcInfo.MemoryMB/1024)
+	}
 
 	guest, err := flag.GetMachineGuest(ctx, helpers.Clone(def))
 	if err != nil {
@@ -13,20 +13,36 @@ import (
 )
 
 func NewPlan() *cobra.Command {
-	const desc = `[experimental] Granular subcommands for creating and configuring apps`
+	const (
+		short = "Write a launch plan file for the app in the current directory"
+		long  = short + `. The plan is what 'fly launch' would
+create: the app, its region and machine size, and any databases, Redis, object
+storage or Sentry detected from the source. It can be reviewed, committed and
+applied with 'fly launch apply'.`
+	)
 
-	cmd := command.New("plan", desc, desc, nil, command.RequireSession, command.LoadAppConfigIfPresent)
+	cmd := command.New("plan", short, long, runPlanWrite, command.RequireSession, command.RequireUiex, command.LoadAppConfigIfPresent)
 	cmd.Args = cobra.NoArgs
 
-	cmd.AddCommand(newPropose())
-	cmd.AddCommand(newCreate())
-	cmd.AddCommand(newPostgres())
-	cmd.AddCommand(newRedis())
-	cmd.AddCommand(newTigris())
-	cmd.AddCommand(newGenerate())
-
-	// Don't advertise this command yet
-	cmd.Hidden = true
+	flag.Add(cmd, launchFlags()...)
+	flag.Add(cmd, flag.String{
+		Name:        "out",
+		Description: "Path to write the plan file to",
+		Default:     defaultPlanFile,
+	})
+
+	// The granular subcommands are experimental, don't advertise them yet
+	for _, sub := range []*cobra.Command{
+		newPropose(),
+		newCreate(),
+		newPostgres(),
+		newRedis(),
+		newTigris(),
+		newGenerate(),
+	} {
+		sub.Hidden = true
+		cmd.AddCommand(sub)
+	}
 
 	return cmd
 }
@@ -0,0 +1,304 @@
+package launch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/command/launch/plan"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/prompt"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/scanner"
+)
+
+const (
+	// planFileVersion is bumped whenever a plan file written by this version
+	// of flyctl can't be applied correctly by older ones.
+	planFileVersion = 1
+	defaultPlanFile = "fly-launch-plan.json"
+)
+
+// planFile is a launch plan written to disk, so it can be reviewed, committed
+// and applied repeatedly.
+type planFile struct {
+	Version     int              `json:"version"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Plan        *plan.LaunchPlan `json:"plan"`
+}
+
+func readPlanFile(path string) (*planFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pf planFile
+	if err := json.Unmarshal(data, &pf); err != nil {
+		return nil, fmt.Errorf("failed parsing launch plan %s: %w", path, err)
+	}
+
+	switch {
+	case pf.Version == 0 || pf.Plan == nil:
+		return nil, fmt.Errorf("%s is not a launch plan file; create one with 'fly launch plan'", path)
+	case pf.Version > planFileVersion:
+		return nil, fmt.Errorf("launch plan %s has version %d, but this flyctl only supports up to version %d; upgrade flyctl", path, pf.Version, planFileVersion)
+	case pf.Plan.AppName == "":
+		return nil, fmt.Errorf("launch plan %s has no app name", path)
+	}
+
+	return &pf, nil
+}
+
+func writePlanFile(path string, p *plan.LaunchPlan) error {
+	data, err := json.MarshalIndent(planFile{
+		Version:     planFileVersion,
+		GeneratedAt: time.Now().UTC(),
+		Plan:        p,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+const (
+	resourceCreate = "create"
+	resourceExists = "exists"
+)
+
+// planResource is a resource described by a launch plan along with what
+// applying the plan would do with it.
+type planResource struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+// diffPlan compares the resources in p to what exists. Attached databases and
+// extensions are recognized by the secrets they set on the app, since that's
+// what the app depends on.
+func diffPlan(p *plan.LaunchPlan, appExists bool, secrets map[string]bool) []planResource {
+	action := func(exists bool) string {
+		if exists {
+			return resourceExists
+		}
+		return resourceCreate
+	}
+
+	resources := []planResource{{Kind: "app", Name: p.AppName, Action: action(appExists)}}
+
+	switch {
+	case p.Postgres.FlyPostgres != nil:
+		resources = append(resources, planResource{"postgres", p.Postgres.FlyPostgres.AppName, action(secrets["DATABASE_URL"])})
+	case p.Postgres.ManagedPostgres != nil:
+		resources = append(resources, planResource{"managed_postgres", p.Postgres.ManagedPostgres.DbName, action(secrets["DATABASE_URL"])})
+	}
+	if p.Redis.UpstashRedis != nil {
+		resources = append(resources, planResource{"redis", p.AppName + "-redis", action(secrets["REDIS_URL"])})
+	}
+	if p.ObjectStorage.TigrisObjectStorage != nil {
+		resources = append(resources, planResource{"object_storage", p.ObjectStorage.TigrisObjectStorage.Name, action(secrets["BUCKET_NAME"])})
+	}
+	if p.Sentry {
+		resources = append(resources, planResource{"sentry", p.AppName, action(secrets["SENTRY_DSN"])})
+	}
+
+	return resources
+}
+
+func newApply() *cobra.Command {
+	const (
+		short = "Apply a launch plan file to an app"
+		long  = short + `. Resources in the plan that don't exist yet, such as the
+app itself, Postgres, Redis, object storage and Sentry, are created. Resources
+that already exist are left alone, so applying a plan is idempotent. Use
+--diff to only show what would be created.
+
+Applying a plan does not generate fly.toml or deploy; use 'fly deploy' for that.`
+		usage = "apply [plan file]"
+	)
+
+	cmd := command.New(usage, short, long, runApply, command.RequireSession, command.RequireUiex)
+	cmd.Args = cobra.MaximumNArgs(1)
+
+	flag.Add(cmd,
+		flag.Yes(),
+		flag.JSONOutput(),
+		flag.Bool{
+			Name:        "diff",
+			Description: "Show the resources that would be created without creating them",
+		},
+	)
+
+	return cmd
+}
+
+func runApply(ctx context.Context) error {
+	var (
+		io     = iostreams.FromContext(ctx)
+		client = flyutil.ClientFromContext(ctx)
+		path   = flag.FirstArg(ctx)
+	)
+
+	if path == "" {
+		path = defaultPlanFile
+	}
+
+	pf, err := readPlanFile(path)
+	if err != nil {
+		return err
+	}
+	p := pf.Plan
+
+	appExists := true
+	if _, err := client.GetAppCompact(ctx, p.AppName); err != nil {
+		if !fly.IsNotFoundError(err) {
+			return err
+		}
+		appExists = false
+	}
+
+	secretNames := map[string]bool{}
+	if appExists {
+		secrets, err := client.GetAppSecrets(ctx, p.AppName)
+		if err != nil {
+			return err
+		}
+		for _, s := range secrets {
+			secretNames[s.Name] = true
+		}
+	}
+
+	resources := diffPlan(p, appExists, secretNames)
+
+	if config.FromContext(ctx).JSONOutput {
+		if err := render.JSON(io.Out, resources); err != nil {
+			return err
+		}
+	} else {
+		printPlanDiff(io, resources)
+	}
+
+	pending := 0
+	for _, r := range resources {
+		if r.Action == resourceCreate {
+			pending++
+		}
+	}
+	if pending == 0 || flag.GetBool(ctx, "diff") {
+		return nil
+	}
+
+	if !flag.GetYes(ctx) {
+		switch confirmed, err := prompt.Confirmf(ctx, "Create %d resource(s)?", pending); {
+		case err == nil:
+			if !confirmed {
+				return nil
+			}
+		case prompt.IsNonInteractive(err):
+			return prompt.NonInteractiveError("yes flag must be specified when not running interactively")
+		default:
+			return err
+		}
+	}
+
+	// Only create what's missing; the creation helpers act on every resource
+	// present in the plan.
+	toApply := *p
+	for _, r := range resources {
+		if r.Action != resourceExists {
+			continue
+		}
+		switch r.Kind {
+		case "postgres", "managed_postgres":
+			toApply.Postgres = plan.PostgresPlan{}
+		case "redis":
+			toApply.Redis = plan.RedisPlan{}
+		case "object_storage":
+			toApply.ObjectStorage = plan.ObjectStoragePlan{}
+		case "sentry":
+			toApply.Sentry = false
+		}
+	}
+
+	appConfig := appconfig.NewConfig()
+	appConfig.AppName = p.AppName
+	state := &launchState{
+		LaunchManifest: LaunchManifest{Plan: &toApply, PlanSource: &launchPlanSource{}},
+		planBuildCache: planBuildCache{
+			appConfig:  appConfig,
+			sourceInfo: &scanner.SourceInfo{},
+		},
+		cache: map[string]interface{}{},
+	}
+
+	if !appExists {
+		app, err := state.createApp(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(io.Out, "Created app '%s' in organization '%s'\n", app.Name, app.Organization.Slug)
+	}
+
+	// Unlike fly launch, which carries on without the resources that failed,
+	// apply fails so that CI notices.
+	failed, err := state.provisionResources(ctx)
+	if err != nil {
+		return err
+	}
+	if err := state.launchSentry(ctx, p.AppName); err != nil {
+		failed = append(failed, fmt.Errorf("creating Sentry project: %w", err))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed applying launch plan %s to %s: %w", path, p.AppName, errors.Join(failed...))
+	}
+
+	fmt.Fprintf(io.Out, "Applied launch plan %s to %s\n", path, p.AppName)
+	return nil
+}
+
+func printPlanDiff(io *iostreams.IOStreams, resources []planResource) {
+	cs := io.ColorScheme()
+	for _, r := range resources {
+		line := fmt.Sprintf("%s %s", r.Kind, r.Name)
+		if r.Action == resourceCreate {
+			fmt.Fprintln(io.Out, cs.Green("  + "+line))
+		} else {
+			fmt.Fprintln(io.Out, "    "+line+" (exists)")
+		}
+	}
+}
+
+func runPlanWrite(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+	out := flag.GetString(ctx, "out")
+
+	if flag.GetBool(ctx, "monorepo") {
+		return errors.New("--monorepo is not supported when writing a launch plan")
+	}
+
+	ctx = context.WithValue(ctx, plan.PlanStepKey, "propose")
+	manifest, _, err := buildManifest(ctx, nil, &recoverableErrorBuilder{})
+	if err != nil {
+		return err
+	}
+
+	if err := writePlanFile(out, manifest.Plan); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(io.Out, "Wrote launch plan for %s to %s\n", manifest.Plan.AppName, out)
+	fmt.Fprintf(io.Out, "Review it, then create its resources with 'fly launch apply %s'\n", out)
+	return nil
+}
@@ -0,0 +1,75 @@
+package launch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superfly/flyctl/internal/command/launch/plan"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func TestPlanFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), defaultPlanFile)
+	p := &plan.LaunchPlan{AppName: "shop", OrgSlug: "personal", RegionCode: "iad"}
+
+	require.NoError(t, writePlanFile(path, p))
+
+	pf, err := readPlanFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, planFileVersion, pf.Version)
+	assert.Equal(t, "shop", pf.Plan.AppName)
+	assert.Equal(t, "iad", pf.Plan.RegionCode)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 99, "plan": {"name": "shop"}}`), 0o644))
+	_, err = readPlanFile(path)
+	assert.ErrorContains(t, err, "upgrade flyctl")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"name": "shop"}`), 0o644))
+	_, err = readPlanFile(path)
+	assert.ErrorContains(t, err, "not a launch plan file")
+}
+
+func TestDiffPlan(t *testing.T) {
+	p := &plan.LaunchPlan{
+		AppName:       "shop",
+		Postgres:      plan.PostgresPlan{FlyPostgres: &plan.FlyPostgresPlan{AppName: "shop-db"}},
+		Redis:         plan.RedisPlan{UpstashRedis: &plan.UpstashRedisPlan{}},
+		ObjectStorage: plan.ObjectStoragePlan{TigrisObjectStorage: &plan.TigrisObjectStoragePlan{Name: "shop-bucket"}},
+	}
+
+	assert.Equal(t, []planResource{
+		{"app", "shop", resourceCreate},
+		{"postgres", "shop-db", resourceCreate},
+		{"redis", "shop-redis", resourceCreate},
+		{"object_storage", "shop-bucket", resourceCreate},
+	}, diffPlan(p, false, nil))
+
+	assert.Equal(t, []planResource{
+		{"app", "shop", resourceExists},
+		{"postgres", "shop-db", resourceExists},
+		{"redis", "shop-redis", resourceCreate},
+		{"object_storage", "shop-bucket", resourceExists},
+	}, diffPlan(p, true, map[string]bool{"DATABASE_URL": true, "BUCKET_NAME": true}))
+}
+
+func TestProvisionResourcesReportsFailures(t *testing.T) {
+	ios, _, _, _ := iostreams.Test()
+	ctx := iostreams.NewContext(context.Background(), ios)
+
+	state := &launchState{LaunchManifest: LaunchManifest{Plan: &plan.LaunchPlan{
+		AppName:  "shop",
+		Postgres: plan.PostgresPlan{SupabasePostgres: &plan.SupabasePostgresPlan{}},
+	}}}
+
+	failed, err := state.provisionResources(ctx)
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.ErrorContains(t, failed[0], "Supabase Postgres")
+
+	// fly launch carries on regardless.
+	assert.NoError(t, state.createDatabases(ctx))
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/superfly/flyctl/internal/appconfig"
 	"github.com/superfly/flyctl/internal/command/launch/plan"
 	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/state"
 	"github.com/superfly/flyctl/iostreams"
 	"github.com/superfly/flyctl/scanner"
 )
@@ -26,6 +29,7 @@ func determineSourceInfo(ctx context.Context, appConfig *appconfig.Config, copyC
 		ExistingPort: appConfig.InternalPort(),
 		Mode:         "launch",
 		Colorize:     io.ColorScheme(),
+		PluginDir:    scannerPluginDir(ctx),
 	}
 	// Detect if --copy-config and --now flags are set. If so, limited set of
 	// fly.toml file updates. Helpful for deploying PRs when the project is
@@ -125,3 +129,12 @@ func articleFor(w string) string {
 	}
 	return article
 }
+
+// scannerPluginDir returns the directory scanner plugins are loaded from,
+// FLY_SCANNER_PLUGINS_DIR or scanners/ in the config directory.
+func scannerPluginDir(ctx context.Context) string {
+	if dir := os.Getenv("FLY_SCANNER_PLUGINS_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(state.ConfigDirectory(ctx), "scanners")
+}
@@ -0,0 +1,326 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func newEvents() *cobra.Command {
+	const (
+		short = "Show a timeline of machine events across an app"
+		long  = `Show the recent events of all machines of an app, or of the given machines,
+as one timeline. Each event is matched with the release that was current when
+it happened, and exits are explained: OOM kills, non-zero exit codes and
+signals. Failing health checks are included as well.
+
+Use --crashes to answer questions like "which machines restarted in the last
+hour and why":
+
+  fly machine events --since 1h --crashes`
+		usage = "events [<id>...]"
+	)
+
+	cmd := command.New(usage, short, long, runMachineEvents,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.ArbitraryArgs
+
+	flag.Add(
+		cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.JSONOutput(),
+		flag.Duration{
+			Name:        "since",
+			Description: "Only show events from this long ago, for example 1h or 30m",
+		},
+		flag.StringSlice{
+			Name:        "type",
+			Description: "Only show events of these types, for example exit,start,launch or check",
+		},
+		flag.String{
+			Name:        "process-group",
+			Description: "Only show events of machines in this process group",
+		},
+		flag.Bool{
+			Name:        "crashes",
+			Description: "Only show abnormal exits and failing health checks",
+		},
+	)
+
+	return cmd
+}
+
+// timelineEvent is a machine event along with the context needed to make
+// sense of it.
+type timelineEvent struct {
+	Time         time.Time `json:"time"`
+	MachineID    string    `json:"machine_id"`
+	ProcessGroup string    `json:"process_group"`
+	Region       string    `json:"region"`
+	Release      int       `json:"release,omitempty"`
+	Type         string    `json:"type"`
+	Status       string    `json:"status"`
+	Source       string    `json:"source,omitempty"`
+	Info         string    `json:"info,omitempty"`
+	// Abnormal is set for exits that weren't requested or clean, and for
+	// failing health checks.
+	Abnormal bool `json:"abnormal"`
+}
+
+type timelineFilter struct {
+	Since        time.Time
+	Types        []string
+	ProcessGroup string
+	Machines     []string
+	Crashes      bool
+}
+
+func (f *timelineFilter) match(e *timelineEvent) bool {
+	switch {
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case len(f.Types) > 0 && !slices.Contains(f.Types, e.Type):
+		return false
+	case f.ProcessGroup != "" && e.ProcessGroup != f.ProcessGroup:
+		return false
+	case len(f.Machines) > 0 && !slices.Contains(f.Machines, e.MachineID):
+		return false
+	case f.Crashes && !e.Abnormal:
+		return false
+	}
+	return true
+}
+
+func runMachineEvents(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		client  = flyutil.ClientFromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+	)
+
+	flapsClient, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+		AppName: appName,
+	})
+	if err != nil {
+		return err
+	}
+
+	machines, err := flapsClient.List(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed listing machines: %w", err)
+	}
+
+	releases, err := client.GetAppReleasesMachines(ctx, appName, "", 50)
+	if err != nil {
+		return fmt.Errorf("failed retrieving releases: %w", err)
+	}
+
+	filter := &timelineFilter{
+		Types:        flag.GetStringSlice(ctx, "type"),
+		ProcessGroup: flag.GetString(ctx, "process-group"),
+		Machines:     flag.Args(ctx),
+		Crashes:      flag.GetBool(ctx, "crashes"),
+	}
+	if since := flag.GetDuration(ctx, "since"); since > 0 {
+		filter.Since = time.Now().Add(-since)
+	}
+
+	events := buildTimeline(machines, releases, filter)
+
+	if config.FromContext(ctx).JSONOutput {
+		return render.JSON(io.Out, events)
+	}
+
+	if len(events) == 0 {
+		fmt.Fprintln(io.Out, "No matching machine events")
+		return nil
+	}
+
+	colorize := io.ColorScheme()
+	rows := make([][]string, 0, len(events))
+	for _, e := range events {
+		release := ""
+		if e.Release > 0 {
+			release = fmt.Sprintf("v%d", e.Release)
+		}
+		info := e.Info
+		if e.Abnormal {
+			info = colorize.Red(info)
+		}
+		rows = append(rows, []string{
+			e.Time.UTC().Format(time.RFC3339),
+			e.MachineID,
+			e.ProcessGroup,
+			e.Region,
+			release,
+			e.Type,
+			e.Status,
+			e.Source,
+			info,
+		})
+	}
+	if err := render.Table(io.Out, "", rows, "Time", "Machine", "Process", "Region", "Release", "Event", "Status", "Source", "Info"); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(io.Out, summarizeTimeline(events))
+	return nil
+}
+
+// buildTimeline flattens the events of machines into a timeline, oldest
+// first, keeping those that match filter.
+func buildTimeline(machines []*fly.Machine, releases []fly.Release, filter *timelineFilter) []*timelineEvent {
+	releases = slices.Clone(releases)
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].CreatedAt.Before(releases[j].CreatedAt)
+	})
+
+	var events []*timelineEvent
+	add := func(e *timelineEvent) {
+		e.Release = releaseAt(releases, e.Time)
+		if filter.match(e) {
+			events = append(events, e)
+		}
+	}
+
+	for _, m := range machines {
+		for _, me := range m.Events {
+			e := &timelineEvent{
+				Time:         me.Time(),
+				MachineID:    m.ID,
+				ProcessGroup: m.ProcessGroup(),
+				Region:       m.Region,
+				Type:         me.Type,
+				Status:       me.Status,
+				Source:       me.Source,
+			}
+			e.Info, e.Abnormal = explainEvent(me)
+			add(e)
+		}
+
+		for _, check := range m.Checks {
+			if check.Status == fly.Passing || check.UpdatedAt == nil {
+				continue
+			}
+			add(&timelineEvent{
+				Time:         *check.UpdatedAt,
+				MachineID:    m.ID,
+				ProcessGroup: m.ProcessGroup(),
+				Region:       m.Region,
+				Type:         "check",
+				Status:       string(check.Status),
+				Info:         strings.TrimSpace(check.Name + ": " + firstLine(check.Output)),
+				Abnormal:     check.Status == fly.Critical,
+			})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events
+}
+
+// releaseAt returns the version of the release that was current at t.
+// releases must be sorted oldest first.
+func releaseAt(releases []fly.Release, t time.Time) int {
+	version := 0
+	for _, r := range releases {
+		if r.CreatedAt.After(t) {
+			break
+		}
+		version = r.Version
+	}
+	return version
+}
+
+// explainEvent describes why a machine exited, and whether that was
+// abnormal: anything but a requested stop or a clean exit.
+func explainEvent(e *fly.MachineEvent) (string, bool) {
+	if e.Request == nil {
+		return "", false
+	}
+
+	var exit *fly.MachineExitEvent
+	switch {
+	case e.Request.MonitorEvent != nil && e.Request.MonitorEvent.ExitEvent != nil:
+		exit = e.Request.MonitorEvent.ExitEvent
+	case e.Request.ExitEvent != nil:
+		exit = e.Request.ExitEvent
+	default:
+		return "", false
+	}
+
+	var info string
+	abnormal := false
+	switch {
+	case exit.OOMKilled:
+		info, abnormal = "out of memory, killed", true
+	case exit.RequestedStop:
+		info = "stop requested"
+	case exit.ExitCode != 0:
+		info, abnormal = fmt.Sprintf("exited with code %d", exit.ExitCode), true
+		if exit.Signal != 0 {
+			info += fmt.Sprintf(" (signal %d)", exit.Signal)
+		}
+	default:
+		info = "exited cleanly"
+	}
+	if exit.Restarting {
+		info += ", restarting"
+	}
+	if e.Request.RestartCount > 0 {
+		info += fmt.Sprintf(", restart #%d", e.Request.RestartCount)
+	}
+	return info, abnormal
+}
+
+func summarizeTimeline(events []*timelineEvent) string {
+	var (
+		ooms, exits, checks int
+		machines            = map[string]bool{}
+	)
+	for _, e := range events {
+		if !e.Abnormal {
+			continue
+		}
+		machines[e.MachineID] = true
+		switch {
+		case e.Type == "check":
+			checks++
+		case strings.HasPrefix(e.Info, "out of memory"):
+			ooms++
+		default:
+			exits++
+		}
+	}
+	if len(machines) == 0 {
+		return "No crashes or failing health checks"
+	}
+	return fmt.Sprintf("%d machine(s) with problems: %d OOM kill(s), %d abnormal exit(s), %d failing health check(s)",
+		len(machines), ooms, exits, checks)
+}
+
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	return s
+}
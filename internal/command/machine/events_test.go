@@ -0,0 +1,73 @@
+package machine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) int64 { return base.Add(d).UnixMilli() }
+	checkTime := base.Add(50 * time.Minute)
+
+	machines := []*fly.Machine{
+		{
+			ID:     "m1",
+			Region: "iad",
+			Config: &fly.MachineConfig{Metadata: map[string]string{fly.MachineConfigMetadataKeyFlyProcessGroup: "app"}},
+			Events: []*fly.MachineEvent{
+				{Type: "start", Status: "started", Source: "flyd", Timestamp: at(10 * time.Minute)},
+				{Type: "exit", Status: "stopped", Source: "flyd", Timestamp: at(40 * time.Minute), Request: &fly.MachineRequest{
+					MonitorEvent: &fly.MachineMonitorEvent{ExitEvent: &fly.MachineExitEvent{OOMKilled: true, ExitCode: 137, Restarting: true}},
+				}},
+			},
+			Checks: []*fly.MachineCheckStatus{
+				{Name: "http", Status: fly.Critical, Output: "connection refused\nmore", UpdatedAt: &checkTime},
+				{Name: "tcp", Status: fly.Passing, UpdatedAt: &checkTime},
+			},
+		},
+		{
+			ID:     "m2",
+			Region: "ams",
+			Config: &fly.MachineConfig{Metadata: map[string]string{fly.MachineConfigMetadataKeyFlyProcessGroup: "worker"}},
+			Events: []*fly.MachineEvent{
+				{Type: "exit", Status: "stopped", Source: "user", Timestamp: at(20 * time.Minute), Request: &fly.MachineRequest{
+					ExitEvent: &fly.MachineExitEvent{RequestedStop: true},
+				}},
+				{Type: "exit", Status: "stopped", Source: "flyd", Timestamp: at(30 * time.Minute), Request: &fly.MachineRequest{
+					ExitEvent: &fly.MachineExitEvent{ExitCode: 1}, RestartCount: 2,
+				}},
+			},
+		},
+	}
+	releases := []fly.Release{
+		{Version: 2, CreatedAt: base.Add(25 * time.Minute)},
+		{Version: 1, CreatedAt: base},
+	}
+
+	events := buildTimeline(machines, releases, &timelineFilter{})
+	require.Len(t, events, 5)
+	assert.Equal(t, "m1", events[0].MachineID)
+	assert.Equal(t, 1, events[0].Release)
+	assert.Equal(t, "stop requested", events[1].Info)
+	assert.False(t, events[1].Abnormal)
+	assert.Equal(t, "exited with code 1, restart #2", events[2].Info)
+	assert.Equal(t, 2, events[2].Release)
+	assert.Equal(t, "out of memory, killed, restarting", events[3].Info)
+	assert.Equal(t, "check", events[4].Type)
+	assert.Equal(t, "http: connection refused", events[4].Info)
+
+	crashes := buildTimeline(machines, releases, &timelineFilter{Crashes: true, Since: base.Add(35 * time.Minute)})
+	require.Len(t, crashes, 2)
+	assert.Equal(t, "2 machine(s) with problems: 1 OOM kill(s), 1 abnormal exit(s), 1 failing health check(s)",
+		summarizeTimeline(buildTimeline(machines, releases, &timelineFilter{Crashes: true})))
+	assert.Equal(t, "1 machine(s) with problems: 1 OOM kill(s), 0 abnormal exit(s), 1 failing health check(s)", summarizeTimeline(crashes))
+
+	worker := buildTimeline(machines, releases, &timelineFilter{ProcessGroup: "worker", Types: []string{"exit"}})
+	assert.Len(t, worker, 2)
+	assert.Len(t, buildTimeline(machines, releases, &timelineFilter{Machines: []string{"m2"}}), 2)
+}
@@ -40,6 +40,7 @@ Machines REST fly.`
 		newSuspend(),
 		newEgressIp(),
 		newPlace(),
+		newEvents(),
 	)
 
 	return cmd
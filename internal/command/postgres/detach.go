@@ -62,10 +62,32 @@ func runDetach(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	return runMachineDetach(ctx, app, pgApp)
+	return runMachineDetach(ctx, app, pgApp, false)
 }
 
-func runMachineDetach(ctx context.Context, app *fly.AppCompact, pgApp *fly.AppCompact) error {
+// DetachCluster removes every attachment between an app and a postgres
+// cluster without prompting. Like detach, it leaves the databases intact.
+func DetachCluster(ctx context.Context, appName, pgAppName string) error {
+	client := flyutil.ClientFromContext(ctx)
+
+	pgApp, err := client.GetAppCompact(ctx, pgAppName)
+	if err != nil {
+		return fmt.Errorf("get postgres app: %w", err)
+	}
+
+	app, err := client.GetAppCompact(ctx, appName)
+	if err != nil {
+		return fmt.Errorf("get app: %w", err)
+	}
+
+	ctx, err = apps.BuildContext(ctx, pgApp)
+	if err != nil {
+		return err
+	}
+	return runMachineDetach(ctx, app, pgApp, true)
+}
+
+func runMachineDetach(ctx context.Context, app *fly.AppCompact, pgApp *fly.AppCompact, all bool) error {
 	var (
 		MinPostgresHaVersion         = "0.0.19"
 		MinPostgresFlexVersion       = "0.0.3"
@@ -90,15 +112,14 @@ func runMachineDetach(ctx context.Context, app *fly.AppCompact, pgApp *fly.AppCo
 		return err
 	}
 
-	return detachAppFromPostgres(ctx, leader.PrivateIP, app, pgApp)
+	return detachAppFromPostgres(ctx, leader.PrivateIP, app, pgApp, all)
 }
 
 // TODO - This process needs to be re-written to suppport non-interactive terminals.
-func detachAppFromPostgres(ctx context.Context, leaderIP string, app *fly.AppCompact, pgApp *fly.AppCompact) error {
+func detachAppFromPostgres(ctx context.Context, leaderIP string, app *fly.AppCompact, pgApp *fly.AppCompact, all bool) error {
 	var (
 		client = flyutil.ClientFromContext(ctx)
 		dialer = agent.DialerFromContext(ctx)
-		io     = iostreams.FromContext(ctx)
 	)
 
 	attachments, err := client.ListPostgresClusterAttachments(ctx, app.ID, pgApp.ID)
@@ -110,6 +131,17 @@ func detachAppFromPostgres(ctx context.Context, leaderIP string, app *fly.AppCom
 		return fmt.Errorf("no attachments found")
 	}
 
+	pgclient := flypg.NewFromInstance(leaderIP, dialer)
+
+	if all {
+		for _, attachment := range attachments {
+			if err := detachAttachment(ctx, pgclient, app, pgApp, attachment); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	selected := 0
 	msg := "Select the attachment that you would like to detach (Database will remain intact): "
 	options := []string{}
@@ -125,9 +157,14 @@ func detachAppFromPostgres(ctx context.Context, leaderIP string, app *fly.AppCom
 		return err
 	}
 
-	targetAttachment := attachments[selected]
+	return detachAttachment(ctx, pgclient, app, pgApp, attachments[selected])
+}
 
-	pgclient := flypg.NewFromInstance(leaderIP, dialer)
+func detachAttachment(ctx context.Context, pgclient *flypg.Client, app *fly.AppCompact, pgApp *fly.AppCompact, targetAttachment *fly.PostgresClusterAttachment) error {
+	var (
+		client = flyutil.ClientFromContext(ctx)
+		io     = iostreams.FromContext(ctx)
+	)
 
 	// Remove user if exists
 	exists, err := pgclient.UserExists(ctx, targetAttachment.DatabaseUser)
@@ -0,0 +1,117 @@
+package releases
+
+import (
+	"context"
+	"fmt"
+
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/gql"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/flyutil"
+)
+
+// releaseSnapshot is what a release deployed: its image and configuration.
+type releaseSnapshot struct {
+	Version  int
+	Status   string
+	ImageRef string
+	Config   *appconfig.Config
+}
+
+// fetchReleases looks up the given release versions of an app, paging back
+// through its release history until all of them are found.
+func fetchReleases(ctx context.Context, appName string, versions ...int) (map[int]*releaseSnapshot, error) {
+	_ = `# @genqlient
+	query FlyctlReleasesUnprocessed(
+		$appName: String!,
+		# @genqlient(omitempty: true)
+		$after: String
+	) {
+		app(name:$appName) {
+			releasesUnprocessed(first: 50, after: $after) {
+				nodes {
+					version
+					status
+					imageRef
+					configDefinition
+				}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+			}
+		}
+	}
+	`
+	client := flyutil.ClientFromContext(ctx)
+
+	wanted := map[int]bool{}
+	for _, v := range versions {
+		wanted[v] = true
+	}
+
+	found := map[int]*releaseSnapshot{}
+	var after string
+	for len(found) < len(wanted) {
+		resp, err := gql.FlyctlReleasesUnprocessed(ctx, client.GenqClient(), appName, after)
+		if err != nil {
+			return nil, fmt.Errorf("failed retrieving releases of %s: %w", appName, err)
+		}
+		releases := resp.App.ReleasesUnprocessed
+
+		for _, node := range releases.Nodes {
+			if !wanted[node.Version] {
+				continue
+			}
+			snapshot, err := newReleaseSnapshot(appName, node.Version, node.Status, node.ImageRef, node.ConfigDefinition)
+			if err != nil {
+				return nil, err
+			}
+			found[node.Version] = snapshot
+		}
+
+		if !releases.PageInfo.HasNextPage {
+			break
+		}
+		after = releases.PageInfo.EndCursor
+	}
+
+	for _, v := range versions {
+		if found[v] == nil {
+			return nil, fmt.Errorf("release v%d of %s not found", v, appName)
+		}
+	}
+	return found, nil
+}
+
+func newReleaseSnapshot(appName string, version int, status, imageRef string, definition any) (*releaseSnapshot, error) {
+	definitionMap, ok := definition.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("release v%d has no configuration to restore; it predates flyctl storing it", version)
+	}
+
+	cfg, err := appconfig.FromDefinition(fly.DefinitionPtr(definitionMap))
+	if err != nil {
+		return nil, fmt.Errorf("failed reading the configuration of release v%d: %w", version, err)
+	}
+	cfg.AppName = appName
+
+	return &releaseSnapshot{
+		Version:  version,
+		Status:   status,
+		ImageRef: imageRef,
+		Config:   cfg,
+	}, nil
+}
+
+// parseVersion accepts release versions as either 12 or v12.
+func parseVersion(s string) (int, error) {
+	var v int
+	if _, err := fmt.Sscanf(s, "v%d", &v); err == nil && v > 0 {
+		return v, nil
+	}
+	if _, err := fmt.Sscanf(s, "%d", &v); err == nil && v > 0 {
+		return v, nil
+	}
+	return 0, fmt.Errorf("invalid release version %q", s)
+}
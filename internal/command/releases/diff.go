@@ -0,0 +1,162 @@
+package releases
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func newDiff() *cobra.Command {
+	const (
+		short = "Show what changed between two releases"
+		long  = `Show the differences between two releases of an app: the image, the
+fly.toml configuration and the resulting machine configuration of each
+process group. Secrets are not part of releases and aren't compared.`
+		usage = "diff <from version> <to version>"
+	)
+
+	cmd := command.New(usage, short, long, runDiff,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.ExactArgs(2)
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+	)
+
+	return cmd
+}
+
+func runDiff(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+		args    = flag.Args(ctx)
+	)
+
+	from, err := parseVersion(args[0])
+	if err != nil {
+		return err
+	}
+	to, err := parseVersion(args[1])
+	if err != nil {
+		return err
+	}
+
+	releases, err := fetchReleases(ctx, appName, from, to)
+	if err != nil {
+		return err
+	}
+
+	diff, err := diffReleases(releases[from], releases[to], io.ColorScheme())
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		fmt.Fprintf(io.Out, "Releases v%d and v%d are identical\n", from, to)
+		return nil
+	}
+
+	fmt.Fprint(io.Out, diff)
+	return nil
+}
+
+// diffReleases renders the image, fly.toml and per process group machine
+// config changes from a to b. It returns an empty string when nothing
+// changed.
+func diffReleases(a, b *releaseSnapshot, colorize *iostreams.ColorScheme) (string, error) {
+	var out bytes.Buffer
+
+	if a.ImageRef != b.ImageRef {
+		fmt.Fprintf(&out, "%s\n", colorize.Bold("Image"))
+		fmt.Fprintf(&out, "%s\n%s\n\n", colorize.Red("- "+a.ImageRef), colorize.Green("+ "+b.ImageRef))
+	}
+
+	aToml, err := configTOML(a.Config)
+	if err != nil {
+		return "", err
+	}
+	bToml, err := configTOML(b.Config)
+	if err != nil {
+		return "", err
+	}
+	if diff := appconfig.PrettyDiff(aToml, bToml, colorize); diff != "" {
+		fmt.Fprintf(&out, "%s\n%s\n\n", colorize.Bold("fly.toml"), diff)
+	}
+
+	aMachines, err := machineConfigs(a)
+	if err != nil {
+		return "", err
+	}
+	bMachines, err := machineConfigs(b)
+	if err != nil {
+		return "", err
+	}
+
+	groups := map[string]bool{}
+	for g := range aMachines {
+		groups[g] = true
+	}
+	for g := range bMachines {
+		groups[g] = true
+	}
+	names := make([]string, 0, len(groups))
+	for g := range groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+
+	for _, g := range names {
+		if diff := appconfig.PrettyDiff(aMachines[g], bMachines[g], colorize); diff != "" {
+			fmt.Fprintf(&out, "%s\n%s\n\n", colorize.Bold(fmt.Sprintf("Machine config (%s)", g)), diff)
+		}
+	}
+
+	return out.String(), nil
+}
+
+func configTOML(cfg *appconfig.Config) (string, error) {
+	var buf bytes.Buffer
+	if _, err := cfg.WriteTo(&buf, "toml"); err != nil {
+		return "", err
+	}
+
+	// Drop the generated header, it carries the current time.
+	lines := strings.Split(buf.String(), "\n")
+	for len(lines) > 0 && strings.HasPrefix(lines[0], "#") {
+		lines = lines[1:]
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// machineConfigs renders the machine config a release results in for each
+// of its process groups.
+func machineConfigs(r *releaseSnapshot) (map[string]string, error) {
+	configs := map[string]string{}
+	for _, group := range r.Config.ProcessNames() {
+		mConfig, err := r.Config.ToMachineConfig(group, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed building the machine config of release v%d: %w", r.Version, err)
+		}
+		mConfig.Image = r.ImageRef
+
+		data, err := json.MarshalIndent(mConfig, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		configs[group] = string(data)
+	}
+	return configs, nil
+}
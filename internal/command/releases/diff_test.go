@@ -0,0 +1,57 @@
+package releases
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func TestParseVersion(t *testing.T) {
+	for in, want := range map[string]int{"12": 12, "v7": 7} {
+		got, err := parseVersion(in)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	for _, in := range []string{"", "v", "0", "latest"} {
+		_, err := parseVersion(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestDiffReleases(t *testing.T) {
+	colorize := iostreams.System().ColorScheme()
+
+	snapshot := func(version int, image string, definition map[string]any) *releaseSnapshot {
+		t.Helper()
+		r, err := newReleaseSnapshot("shop", version, "complete", image, definition)
+		require.NoError(t, err)
+		require.NoError(t, r.Config.SetMachinesPlatform())
+		return r
+	}
+
+	v1 := snapshot(1, "registry.fly.io/shop:deployment-1", map[string]any{
+		"primary_region": "iad",
+		"env":            map[string]any{"LOG_LEVEL": "info"},
+	})
+	v2 := snapshot(2, "registry.fly.io/shop:deployment-2", map[string]any{
+		"primary_region": "iad",
+		"env":            map[string]any{"LOG_LEVEL": "debug"},
+	})
+
+	diff, err := diffReleases(v1, v2, colorize)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "- registry.fly.io/shop:deployment-1")
+	assert.Contains(t, diff, "+ registry.fly.io/shop:deployment-2")
+	assert.Contains(t, diff, "fly.toml")
+	assert.Contains(t, diff, "debug")
+	assert.Contains(t, diff, "Machine config (app)")
+
+	diff, err = diffReleases(v1, v1, colorize)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	_, err = newReleaseSnapshot("shop", 3, "complete", "", nil)
+	assert.ErrorContains(t, err, "no configuration")
+}
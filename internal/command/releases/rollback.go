@@ -0,0 +1,106 @@
+package releases
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/command/deploy"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/prompt"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func newRollback() *cobra.Command {
+	const (
+		short = "Redeploy the image and configuration of an earlier release"
+		long  = `Roll an app back to an earlier release by deploying that release's image
+and fly.toml configuration again. The rollback is a regular deploy, so it
+creates a new release and respects the deployment strategy, health checks and
+the other deploy flags.
+
+Secrets are not part of releases; they keep their current values.`
+		usage = "rollback <version>"
+	)
+
+	cmd := command.New(usage, short, long, runRollback,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.ExactArgs(1)
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		deploy.CommonFlags,
+	)
+
+	return cmd
+}
+
+func runRollback(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		client  = flyutil.ClientFromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+	)
+
+	version, err := parseVersion(flag.FirstArg(ctx))
+	if err != nil {
+		return err
+	}
+
+	current, err := client.GetAppCurrentReleaseMachines(ctx, appName)
+	if err != nil {
+		return fmt.Errorf("failed retrieving the current release of %s: %w", appName, err)
+	}
+	if current.Version == version {
+		return fmt.Errorf("release v%d is already the current release", version)
+	}
+
+	releases, err := fetchReleases(ctx, appName, version, current.Version)
+	if err != nil {
+		return err
+	}
+	target := releases[version]
+	if target.ImageRef == "" {
+		return fmt.Errorf("release v%d has no image to roll back to", version)
+	}
+
+	diff, err := diffReleases(releases[current.Version], target, io.ColorScheme())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(io.Out, "Rolling %s back from v%d to v%d:\n\n%s", appName, current.Version, version, diff)
+
+	if !flag.GetYes(ctx) {
+		switch confirmed, err := prompt.Confirmf(ctx, "Deploy the image and configuration of v%d?", version); {
+		case err == nil:
+			if !confirmed {
+				return nil
+			}
+		case prompt.IsNonInteractive(err):
+			return prompt.NonInteractiveError("yes flag must be specified when not running interactively")
+		default:
+			return err
+		}
+	}
+
+	cfg := target.Config
+	cfg.Build = &appconfig.Build{Image: target.ImageRef}
+	if err := cfg.SetMachinesPlatform(); err != nil {
+		return err
+	}
+
+	// The image comes from the release, not from --image or a build.
+	if err := flag.SetString(ctx, "image", target.ImageRef); err != nil {
+		return err
+	}
+
+	ctx = appconfig.WithConfig(ctx, cfg)
+	return deploy.DeployWithConfig(ctx, cfg, 0, true)
+}
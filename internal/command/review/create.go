@@ -0,0 +1,367 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/command/deploy"
+	"github.com/superfly/flyctl/internal/command/postgres"
+	"github.com/superfly/flyctl/internal/command/secrets"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func newCreate() *cobra.Command {
+	const (
+		short = "Create or update the review app for a branch"
+		long  = `Create a review app for a branch and deploy the working directory to it.
+The review app is named <app>-<branch> and runs the app's configuration,
+without spare machines. Running create again for the same branch redeploys
+it and extends its TTL.
+
+Secret values can't be read back from the app, so review apps get only the
+secrets passed with --secret or --secrets-file. Use --postgres to attach a
+fresh database on a Fly Postgres cluster, and --fork-volumes to start from
+copies of the app's volumes.
+
+The branch defaults to the one being built by GitHub Actions, GitLab CI,
+Buildkite or CircleCI, or else the branch checked out locally.`
+		usage = "create [branch]"
+	)
+
+	cmd := command.New(usage, short, long, runCreate,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.MaximumNArgs(1)
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		deploy.CommonFlags,
+		flag.StringArray{
+			Name:        "secret",
+			Description: "Set a secret on the review app, as NAME=VALUE. Can be specified multiple times",
+		},
+		flag.String{
+			Name:        "secrets-file",
+			Description: "Read secrets for the review app from a file of NAME=VALUE lines",
+		},
+		flag.String{
+			Name:        "postgres",
+			Description: "Attach a new database on this Fly Postgres cluster as DATABASE_URL",
+		},
+		flag.Bool{
+			Name:        "fork-volumes",
+			Description: "Fork the app's volumes instead of creating empty ones",
+		},
+		flag.Duration{
+			Name:        "ttl",
+			Description: "Destroy the review app with 'fly review prune' once it hasn't been updated for this long. 0 keeps it until destroyed",
+			Default:     72 * time.Hour,
+		},
+	)
+
+	return cmd
+}
+
+func runCreate(ctx context.Context) error {
+	var (
+		io     = iostreams.FromContext(ctx)
+		client = flyutil.ClientFromContext(ctx)
+		parent = appconfig.NameFromContext(ctx)
+		pgApp  = flag.GetString(ctx, "postgres")
+	)
+
+	branch := flag.FirstArg(ctx)
+	if branch == "" {
+		var err error
+		if branch, err = currentBranch(ctx); err != nil {
+			return err
+		}
+	}
+	name, err := reviewAppName(parent, branch)
+	if err != nil {
+		return err
+	}
+
+	overrides, err := secretOverrides(ctx)
+	if err != nil {
+		return err
+	}
+
+	cfg := appconfig.ConfigFromContext(ctx)
+	if cfg == nil {
+		if cfg, err = appconfig.FromRemoteApp(ctx, parent); err != nil {
+			return fmt.Errorf("failed loading the configuration of %s: %w", parent, err)
+		}
+	}
+	cfg.AppName = name
+
+	parentApp, err := client.GetAppCompact(ctx, parent)
+	if err != nil {
+		return err
+	}
+
+	app, err := client.GetAppCompact(ctx, name)
+	switch {
+	case err == nil:
+		// Don't take over an app that merely happens to have the name, or
+		// one whose config can't be read to tell.
+		if app.Organization.Slug != parentApp.Organization.Slug {
+			return fmt.Errorf("app %s already exists and is not a review app of %s", name, parent)
+		}
+		switch existing, err := lookupReviewApp(ctx, parent, name); {
+		case err != nil:
+			return fmt.Errorf("app %s already exists and its config can't be read to confirm it's a review app of %s: %w; if it is, remove it with 'fly review destroy %s --force' and try again", name, parent, err, branch)
+		case existing == nil:
+			return fmt.Errorf("app %s already exists and is not a review app of %s", name, parent)
+		}
+		fmt.Fprintf(io.Out, "Updating review app %s for branch %s\n", name, branch)
+	case fly.IsNotFoundError(err):
+		org, err := client.GetOrganizationBySlug(ctx, parentApp.Organization.Slug)
+		if err != nil {
+			return err
+		}
+		input := fly.CreateAppInput{
+			OrganizationID: org.ID,
+			Name:           name,
+			Machines:       true,
+		}
+		if cfg.PrimaryRegion != "" {
+			input.PreferredRegion = fly.StringPointer(cfg.PrimaryRegion)
+		}
+		if _, err := client.CreateApp(ctx, input); err != nil {
+			return fmt.Errorf("failed creating review app %s: %w", name, err)
+		}
+		fmt.Fprintf(io.Out, "Created review app %s for branch %s\n", name, branch)
+	default:
+		return err
+	}
+
+	// Recorded before anything can fail, so prune can find the review app
+	// even if it never deploys.
+	rec := &reviewRecord{Parent: parent, Branch: branch, Postgres: pgApp}
+	if ttl := flag.GetDuration(ctx, "ttl"); ttl > 0 {
+		rec.ExpiresAt = time.Now().Add(ttl).UTC().Truncate(time.Second)
+	}
+	reviewFlaps, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{AppName: name})
+	if err != nil {
+		return err
+	}
+	if err := writeReviewRecord(ctx, reviewFlaps, rec); err != nil {
+		return err
+	}
+
+	if len(overrides) > 0 {
+		if _, err := client.SetSecrets(ctx, name, overrides); err != nil {
+			return fmt.Errorf("failed setting secrets on %s: %w", name, err)
+		}
+	}
+	if err := warnMissingSecrets(ctx, parent, overrides, pgApp != ""); err != nil {
+		return err
+	}
+
+	if pgApp != "" {
+		if err := attachDatabase(ctx, name, pgApp); err != nil {
+			return err
+		}
+	}
+
+	if flag.GetBool(ctx, "fork-volumes") {
+		if err := forkVolumes(ctx, parent, name, cfg); err != nil {
+			return err
+		}
+	}
+
+	env := map[string]string{
+		envParent: parent,
+		envBranch: branch,
+	}
+	if !rec.ExpiresAt.IsZero() {
+		env[envExpiresAt] = rec.ExpiresAt.Format(time.RFC3339)
+	}
+	if pgApp != "" {
+		env[envPostgres] = pgApp
+	}
+	cfg.SetEnvVariables(env)
+
+	if err := cfg.SetMachinesPlatform(); err != nil {
+		return err
+	}
+
+	// Review apps don't need to survive host failures.
+	if !flag.IsSpecified(ctx, "ha") {
+		if err := flag.SetString(ctx, "ha", "false"); err != nil {
+			return err
+		}
+	}
+
+	ctx = appconfig.WithName(ctx, name)
+	ctx = appconfig.WithConfig(ctx, cfg)
+	if err := deploy.DeployWithConfig(ctx, cfg, 0, flag.GetYes(ctx)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(io.Out, "\nReview app for %s is live at https://%s.fly.dev\n", branch, name)
+	return nil
+}
+
+// secretOverrides collects the secrets given with --secrets-file and
+// --secret, the latter taking precedence.
+func secretOverrides(ctx context.Context) (map[string]string, error) {
+	overrides := map[string]string{}
+
+	if path := flag.GetString(ctx, "secrets-file"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close() // skipcq: GO-S2307
+		if overrides, err = secrets.ParseSecrets(f); err != nil {
+			return nil, fmt.Errorf("failed reading %s: %w", path, err)
+		}
+	}
+
+	for _, s := range flag.GetStringArray(ctx, "secret") {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("secrets must be given as NAME=VALUE (%s is invalid)", s)
+		}
+		overrides[k] = v
+	}
+
+	return overrides, nil
+}
+
+// warnMissingSecrets lists the secrets of the parent app that the review
+// app won't have.
+func warnMissingSecrets(ctx context.Context, parent string, overrides map[string]string, database bool) error {
+	var (
+		io     = iostreams.FromContext(ctx)
+		client = flyutil.ClientFromContext(ctx)
+	)
+
+	parentSecrets, err := client.GetAppSecrets(ctx, parent)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, s := range parentSecrets {
+		if _, ok := overrides[s.Name]; ok || (database && s.Name == "DATABASE_URL") {
+			continue
+		}
+		missing = append(missing, s.Name)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	fmt.Fprintf(io.ErrOut, "Warning: the review app won't have these secrets of %s: %s\n", parent, strings.Join(missing, ", "))
+	fmt.Fprintln(io.ErrOut, "Pass them with --secret or --secrets-file if the app needs them.")
+	return nil
+}
+
+// attachDatabase attaches a new database on the pgApp cluster, unless the
+// review app has one from an earlier run.
+func attachDatabase(ctx context.Context, name, pgApp string) error {
+	var (
+		io     = iostreams.FromContext(ctx)
+		client = flyutil.ClientFromContext(ctx)
+	)
+
+	current, err := client.GetAppSecrets(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, s := range current {
+		if s.Name == "DATABASE_URL" {
+			return nil
+		}
+	}
+
+	fmt.Fprintf(io.Out, "Attaching a new database on %s\n", pgApp)
+	if err := postgres.AttachCluster(ctx, postgres.AttachParams{
+		AppName:   name,
+		PgAppName: pgApp,
+		Force:     true,
+	}); err != nil {
+		return fmt.Errorf("failed attaching %s: %w", pgApp, err)
+	}
+	return nil
+}
+
+// forkVolumes forks one volume of the parent app for each mount the review
+// app has no volume for yet, preferring volumes in the primary region. Deploy attaches the forks instead of
+// creating empty volumes.
+func forkVolumes(ctx context.Context, parent, name string, cfg *appconfig.Config) error {
+	io := iostreams.FromContext(ctx)
+
+	parentFlaps, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{AppName: parent})
+	if err != nil {
+		return err
+	}
+	reviewFlaps, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{AppName: name})
+	if err != nil {
+		return err
+	}
+
+	volumes, err := parentFlaps.GetVolumes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed listing volumes of %s: %w", parent, err)
+	}
+	existing, err := reviewFlaps.GetVolumes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed listing volumes of %s: %w", name, err)
+	}
+
+	for _, m := range cfg.Mounts {
+		if pickVolume(existing, m.Source, "") != nil {
+			continue
+		}
+		source := pickVolume(volumes, m.Source, cfg.PrimaryRegion)
+		if source == nil {
+			fmt.Fprintf(io.ErrOut, "Warning: %s has no volume named %s to fork; an empty one will be created\n", parent, m.Source)
+			continue
+		}
+
+		vol, err := reviewFlaps.CreateVolume(ctx, fly.CreateVolumeRequest{
+			Name:           source.Name,
+			Region:         source.Region,
+			SizeGb:         fly.Pointer(source.SizeGb),
+			SourceVolumeID: fly.StringPointer(source.ID),
+		})
+		if err != nil {
+			return fmt.Errorf("failed forking volume %s: %w", source.ID, err)
+		}
+		fmt.Fprintf(io.Out, "Forked volume %s into %s in %s\n", source.ID, vol.ID, vol.Region)
+	}
+
+	return nil
+}
+
+func pickVolume(volumes []fly.Volume, name, region string) *fly.Volume {
+	var picked *fly.Volume
+	for i, v := range volumes {
+		if v.Name != name || v.State == "destroyed" || v.State == "pending_destroy" {
+			continue
+		}
+		if picked == nil || (v.Region == region && picked.Region != region) {
+			picked = &volumes[i]
+		}
+	}
+	return picked
+}
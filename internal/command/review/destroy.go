@@ -0,0 +1,117 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/command/postgres"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/prompt"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func newDestroy() *cobra.Command {
+	const (
+		short = "Destroy the review app for a branch"
+		long  = `Destroy the review app for a branch, along with its machines, volumes and
+secrets. A database attached with --postgres is detached from its cluster.
+
+The branch defaults to the same one 'fly review create' would use. An app whose
+config can't be read, such as a review app whose first deploy failed, is only
+destroyed with --force.`
+		usage = "destroy [branch]"
+	)
+
+	cmd := command.New(usage, short, long, runDestroy,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.MaximumNArgs(1)
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Yes(),
+		flag.Bool{
+			Name:        "force",
+			Description: "Destroy the app even if its config can't be read to confirm it's a review app",
+		},
+	)
+
+	return cmd
+}
+
+func runDestroy(ctx context.Context) error {
+	var (
+		io     = iostreams.FromContext(ctx)
+		parent = appconfig.NameFromContext(ctx)
+	)
+
+	branch := flag.FirstArg(ctx)
+	if branch == "" {
+		var err error
+		if branch, err = currentBranch(ctx); err != nil {
+			return err
+		}
+	}
+	name, err := reviewAppName(parent, branch)
+	if err != nil {
+		return err
+	}
+
+	r, err := lookupReviewApp(ctx, parent, name)
+	switch {
+	case err != nil && !flag.GetBool(ctx, "force"):
+		return fmt.Errorf("failed reading the config of %s to confirm it's a review app of %s: %w; use --force to destroy it anyway", name, parent, err)
+	case err != nil:
+		// Without a config there's nothing more to clean up than the app.
+		fmt.Fprintf(io.ErrOut, "Warning: failed reading the config of %s: %v\n", name, err)
+		r = &reviewApp{Name: name, Branch: branch}
+	case r == nil:
+		return fmt.Errorf("app %s is not a review app of %s", name, parent)
+	}
+
+	if !flag.GetYes(ctx) {
+		switch confirmed, err := prompt.Confirmf(ctx, "Destroy review app %s for branch %s?", r.Name, branch); {
+		case err == nil:
+			if !confirmed {
+				return nil
+			}
+		case prompt.IsNonInteractive(err):
+			return prompt.NonInteractiveError("yes flag must be specified when not running interactively")
+		default:
+			return err
+		}
+	}
+
+	return destroyReviewApp(ctx, r)
+}
+
+func destroyReviewApp(ctx context.Context, r *reviewApp) error {
+	var (
+		io     = iostreams.FromContext(ctx)
+		client = flyutil.ClientFromContext(ctx)
+	)
+
+	if r.Postgres != "" {
+		// Detaching drops the database user but not the database, which
+		// flyctl can't remove.
+		if err := postgres.DetachCluster(ctx, r.Name, r.Postgres); err != nil {
+			fmt.Fprintf(io.ErrOut, "Warning: failed detaching %s from %s: %v\n", r.Name, r.Postgres, err)
+		}
+		fmt.Fprintf(io.ErrOut, "Database %s remains on %s; drop it with 'fly postgres connect -a %s' when no longer needed\n",
+			strings.ReplaceAll(r.Name, "-", "_"), r.Postgres, r.Postgres)
+	}
+
+	if err := client.DeleteApp(ctx, r.Name); err != nil {
+		return fmt.Errorf("failed destroying %s: %w", r.Name, err)
+	}
+
+	fmt.Fprintf(io.Out, "Destroyed review app %s\n", r.Name)
+	return nil
+}
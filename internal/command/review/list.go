@@ -0,0 +1,68 @@
+package review
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/format"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func newList() *cobra.Command {
+	const (
+		short = "List the review apps of an app"
+		long  = short + "\n"
+		usage = "list"
+	)
+
+	cmd := command.New(usage, short, long, runList,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Aliases = []string{"ls"}
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.JSONOutput(),
+	)
+
+	return cmd
+}
+
+func runList(ctx context.Context) error {
+	var (
+		io     = iostreams.FromContext(ctx)
+		parent = appconfig.NameFromContext(ctx)
+	)
+
+	apps, err := listReviewApps(ctx, parent)
+	if err != nil {
+		return err
+	}
+
+	if config.FromContext(ctx).JSONOutput {
+		return render.JSON(io.Out, apps)
+	}
+
+	now := time.Now()
+	rows := make([][]string, 0, len(apps))
+	for _, r := range apps {
+		expires := "never"
+		switch {
+		case r.expired(now):
+			expires = "expired"
+		case !r.ExpiresAt.IsZero():
+			expires = format.RelativeTime(r.ExpiresAt)
+		}
+		rows = append(rows, []string{r.Name, r.Branch, r.Hostname, r.Postgres, expires})
+	}
+
+	return render.Table(io.Out, "", rows, "Name", "Branch", "Hostname", "Postgres", "Expires")
+}
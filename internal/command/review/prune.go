@@ -0,0 +1,103 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/prompt"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func newPrune() *cobra.Command {
+	const (
+		short = "Destroy review apps whose TTL has run out"
+		long  = `Destroy the review apps of an app that haven't been updated within the TTL
+they were created with. Run it on a schedule, for example from CI, to clean up
+after branches that were merged or abandoned.`
+		usage = "prune"
+	)
+
+	cmd := command.New(usage, short, long, runPrune,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Yes(),
+		flag.Bool{
+			Name:        "dry-run",
+			Description: "Only list the review apps that would be destroyed",
+		},
+	)
+
+	return cmd
+}
+
+func runPrune(ctx context.Context) error {
+	var (
+		io     = iostreams.FromContext(ctx)
+		parent = appconfig.NameFromContext(ctx)
+	)
+
+	apps, err := listReviewApps(ctx, parent)
+	if err != nil {
+		return err
+	}
+
+	expired := expiredReviewApps(apps, time.Now())
+	if len(expired) == 0 {
+		fmt.Fprintf(io.Out, "No expired review apps of %s\n", parent)
+		return nil
+	}
+
+	fmt.Fprintf(io.Out, "Expired review apps of %s:\n", parent)
+	for _, r := range expired {
+		fmt.Fprintf(io.Out, "  %s (branch %s, expired %s)\n", r.Name, r.Branch, r.ExpiresAt.Format(time.RFC3339))
+	}
+	if flag.GetBool(ctx, "dry-run") {
+		return nil
+	}
+
+	if !flag.GetYes(ctx) {
+		switch confirmed, err := prompt.Confirmf(ctx, "Destroy %d review app(s)?", len(expired)); {
+		case err == nil:
+			if !confirmed {
+				return nil
+			}
+		case prompt.IsNonInteractive(err):
+			return prompt.NonInteractiveError("yes flag must be specified when not running interactively")
+		default:
+			return err
+		}
+	}
+
+	var failed int
+	for _, r := range expired {
+		if err := destroyReviewApp(ctx, r); err != nil {
+			fmt.Fprintf(io.ErrOut, "%v\n", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed destroying %d of %d review apps", failed, len(expired))
+	}
+	return nil
+}
+
+func expiredReviewApps(apps []*reviewApp, now time.Time) []*reviewApp {
+	var expired []*reviewApp
+	for _, r := range apps {
+		if r.expired(now) {
+			expired = append(expired, r)
+		}
+	}
+	return expired
+}
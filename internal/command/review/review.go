@@ -0,0 +1,290 @@
+// Package review implements review apps: short-lived copies of an app,
+// deployed per branch so changes can be tried out before they're merged.
+package review
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/terminal"
+)
+
+// Review apps are plain apps. What makes them review apps is their name,
+// <parent>-<branch>, and these environment variables set in their config,
+// which is how list and prune find them again.
+const (
+	envParent    = "FLY_REVIEW_PARENT"
+	envBranch    = "FLY_REVIEW_BRANCH"
+	envExpiresAt = "FLY_REVIEW_EXPIRES_AT"
+	envPostgres  = "FLY_REVIEW_POSTGRES"
+
+	// recordSecret holds the same details as a JSON reviewRecord. It's set
+	// before the first deploy, so review apps that never deployed
+	// successfully can still be found, updated and pruned.
+	recordSecret = "FLY_REVIEW_APP"
+
+	// maxAppNameLength keeps <app>.fly.dev a valid DNS label.
+	maxAppNameLength = 63
+)
+
+func New() *cobra.Command {
+	const (
+		short = "Manage review apps"
+		long  = `Review apps are short-lived copies of an app, one per branch, for trying
+out changes before they're merged. A review app is created from the app's
+configuration, gets its own secrets, volumes and database, and is destroyed
+with all of them once the branch is done or its TTL runs out.`
+		usage = "review"
+	)
+
+	cmd := command.New(usage, short, long, nil)
+	cmd.AddCommand(
+		newCreate(),
+		newDestroy(),
+		newList(),
+		newPrune(),
+	)
+	return cmd
+}
+
+// reviewApp is a review app as found by listReviewApps.
+type reviewApp struct {
+	Name      string    `json:"name"`
+	Branch    string    `json:"branch"`
+	Postgres  string    `json:"postgres,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	Hostname  string    `json:"hostname"`
+}
+
+func (r *reviewApp) expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && now.After(r.ExpiresAt)
+}
+
+// reviewAppName derives the review app name for a branch of parent. Names
+// that would be too long are truncated and made unique with a hash of the
+// branch.
+func reviewAppName(parent, branch string) (string, error) {
+	slug := slugify(branch)
+	if slug == "" {
+		return "", fmt.Errorf("can't derive a review app name from branch %q", branch)
+	}
+
+	name := parent + "-" + slug
+	if len(name) <= maxAppNameLength {
+		return name, nil
+	}
+
+	sum := sha1.Sum([]byte(branch))
+	hash := hex.EncodeToString(sum[:])[:6]
+	room := maxAppNameLength - len(parent) - len(hash) - 2
+	if room < 1 {
+		return "", fmt.Errorf("app name %s is too long to derive review app names from", parent)
+	}
+	return parent + "-" + strings.Trim(slug[:room], "-") + "-" + hash, nil
+}
+
+// slugify lowercases s and replaces everything but letters and digits with
+// single dashes.
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(s) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+			dash = false
+		} else if !dash {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+// ciBranchVariables hold the branch being built in common CI systems, where
+// the checkout is usually a detached HEAD.
+var ciBranchVariables = []string{"GITHUB_HEAD_REF", "CI_MERGE_REQUEST_SOURCE_BRANCH_NAME", "CI_COMMIT_REF_NAME", "BUILDKITE_BRANCH", "CIRCLE_BRANCH"}
+
+// currentBranch returns the branch being built in CI, or else the branch
+// checked out in the working directory.
+func currentBranch(ctx context.Context) (string, error) {
+	for _, name := range ciBranchVariables {
+		if branch := os.Getenv(name); branch != "" {
+			return branch, nil
+		}
+	}
+
+	out, err := exec.CommandContext(ctx, "git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return "", errors.New("couldn't determine the current git branch; pass the branch name as an argument")
+	}
+	branch := strings.TrimSpace(string(out))
+	if branch == "HEAD" {
+		return "", errors.New("HEAD is detached; pass the branch name as an argument")
+	}
+	return branch, nil
+}
+
+// reviewRecord is what recordSecret holds.
+type reviewRecord struct {
+	Parent    string    `json:"parent"`
+	Branch    string    `json:"branch"`
+	Postgres  string    `json:"postgres,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+func writeReviewRecord(ctx context.Context, flapsClient flapsutil.FlapsClient, rec *reviewRecord) error {
+	encoded, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := flapsClient.SetAppSecret(ctx, recordSecret, string(encoded)); err != nil {
+		return fmt.Errorf("failed recording review app details in the %s secret: %w", recordSecret, err)
+	}
+	return nil
+}
+
+// readReviewRecord returns the details stored in recordSecret of appName,
+// or nil if the app has none. fly-go's flaps client panics when asked for
+// secret values, so the request is built with it but sent here.
+func readReviewRecord(ctx context.Context, flapsClient flapsutil.FlapsClient, appName string) (*reviewRecord, error) {
+	path := fmt.Sprintf("/apps/%s/secrets/%s?show_secrets=true", url.PathEscape(appName), recordSecret)
+	req, err := flapsClient.NewRequest(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // skipcq: GO-S2307
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("failed reading the %s secret: %s: %s", recordSecret, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var secret fly.AppSecret
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("failed reading the %s secret: %w", recordSecret, err)
+	}
+	if secret.Value == nil {
+		return nil, nil
+	}
+
+	var rec reviewRecord
+	if err := json.Unmarshal([]byte(*secret.Value), &rec); err != nil {
+		return nil, fmt.Errorf("invalid %s secret: %w", recordSecret, err)
+	}
+	return &rec, nil
+}
+
+// lookupReviewApp reads the review app details from an app's recordSecret,
+// or its deployed config for review apps made by older flyctl versions. It
+// returns nil for apps that aren't review apps of parent.
+func lookupReviewApp(ctx context.Context, parent, appName string) (*reviewApp, error) {
+	flapsClient, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{AppName: appName})
+	if err != nil {
+		return nil, err
+	}
+	ctx = flapsutil.NewContextWithClient(ctx, flapsClient)
+
+	switch rec, err := readReviewRecord(ctx, flapsClient, appName); {
+	case err != nil:
+		terminal.Debugf("failed reading the %s secret of %s, falling back to its config: %v\n", recordSecret, appName, err)
+	case rec != nil:
+		return rec.reviewApp(parent, appName), nil
+	}
+
+	cfg, err := appconfig.FromRemoteApp(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Env[envParent] != parent {
+		return nil, nil
+	}
+
+	r := &reviewApp{
+		Name:     appName,
+		Branch:   cfg.Env[envBranch],
+		Postgres: cfg.Env[envPostgres],
+		Hostname: appName + ".fly.dev",
+	}
+	if v := cfg.Env[envExpiresAt]; v != "" {
+		if r.ExpiresAt, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("review app %s has an invalid %s: %w", appName, envExpiresAt, err)
+		}
+	}
+	return r, nil
+}
+
+// reviewApp returns the review app rec describes, or nil if it isn't a
+// review app of parent.
+func (rec *reviewRecord) reviewApp(parent, appName string) *reviewApp {
+	if rec.Parent != parent {
+		return nil
+	}
+	return &reviewApp{
+		Name:      appName,
+		Branch:    rec.Branch,
+		Postgres:  rec.Postgres,
+		ExpiresAt: rec.ExpiresAt,
+		Hostname:  appName + ".fly.dev",
+	}
+}
+
+// listReviewApps finds the review apps of parent in its organization.
+func listReviewApps(ctx context.Context, parent string) ([]*reviewApp, error) {
+	var (
+		io     = iostreams.FromContext(ctx)
+		client = flyutil.ClientFromContext(ctx)
+	)
+
+	app, err := client.GetAppCompact(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+	apps, err := client.GetAppsForOrganization(ctx, app.Organization.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*reviewApp
+	for _, a := range apps {
+		if !strings.HasPrefix(a.Name, parent+"-") {
+			continue
+		}
+		r, err := lookupReviewApp(ctx, parent, a.Name)
+		if err != nil {
+			// Apps of older flyctl versions that never deployed have no
+			// config to tell whether they're review apps.
+			fmt.Fprintf(io.ErrOut, "Skipping %s: failed reading its config: %v\n", a.Name, err)
+			continue
+		}
+		if r != nil {
+			found = append(found, r)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,83 @@
+package review
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/fly-go/tokens"
+	"github.com/superfly/flyctl/internal/inmem"
+)
+
+func TestReviewAppName(t *testing.T) {
+	name, err := reviewAppName("shop", "feature/Add-Cart_v2")
+	require.NoError(t, err)
+	assert.Equal(t, "shop-feature-add-cart-v2", name)
+
+	long := "feature/" + strings.Repeat("very-long-branch-name-", 5)
+	name, err = reviewAppName("shop", long)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(name), maxAppNameLength)
+	assert.True(t, strings.HasPrefix(name, "shop-feature-very-long"))
+
+	other, err := reviewAppName("shop", long+"x")
+	require.NoError(t, err)
+	assert.NotEqual(t, name, other, "truncated names stay unique")
+
+	_, err = reviewAppName("shop", "///")
+	assert.Error(t, err)
+	_, err = reviewAppName(strings.Repeat("a", 60), "main")
+	assert.Error(t, err)
+}
+
+func TestExpiredReviewApps(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	apps := []*reviewApp{
+		{Name: "shop-old", ExpiresAt: now.Add(-time.Hour)},
+		{Name: "shop-new", ExpiresAt: now.Add(time.Hour)},
+		{Name: "shop-forever"},
+	}
+
+	expired := expiredReviewApps(apps, now)
+	require.Len(t, expired, 1)
+	assert.Equal(t, "shop-old", expired[0].Name)
+}
+
+func TestReviewRecord(t *testing.T) {
+	ctx := context.Background()
+	server := inmem.NewServer()
+	server.CreateApp(&fly.App{Name: "shop-cart"})
+
+	// A real flaps client, as fly-go panics listing secrets with values.
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	t.Setenv("FLY_FLAPS_BASE_URL", ts.URL)
+	client, err := flaps.NewWithOptions(ctx, flaps.NewClientOpts{AppName: "shop-cart", Tokens: tokens.Parse("test")})
+	require.NoError(t, err)
+
+	rec, err := readReviewRecord(ctx, client, "shop-cart")
+	require.NoError(t, err)
+	assert.Nil(t, rec)
+
+	expiresAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, writeReviewRecord(ctx, client, &reviewRecord{Parent: "shop", Branch: "cart", ExpiresAt: expiresAt}))
+
+	// Readable without the app ever deploying.
+	rec, err = readReviewRecord(ctx, client, "shop-cart")
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+
+	r := rec.reviewApp("shop", "shop-cart")
+	require.NotNil(t, r)
+	assert.Equal(t, "cart", r.Branch)
+	assert.True(t, expiresAt.Equal(r.ExpiresAt))
+	assert.True(t, r.expired(expiresAt.Add(time.Minute)))
+
+	assert.Nil(t, rec.reviewApp("other", "shop-cart"))
+}
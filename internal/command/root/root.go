@@ -16,6 +16,7 @@ import (
 	"github.com/superfly/flyctl/internal/command/agent"
 	"github.com/superfly/flyctl/internal/command/apps"
 	"github.com/superfly/flyctl/internal/command/auth"
+	"github.com/superfly/flyctl/internal/command/autoscale"
 	"github.com/superfly/flyctl/internal/command/certificates"
 	"github.com/superfly/flyctl/internal/command/checks"
 	"github.com/superfly/flyctl/internal/command/config"
@@ -58,6 +59,7 @@ import (
 	"github.com/superfly/flyctl/internal/command/registry"
 	"github.com/superfly/flyctl/internal/command/releases"
 	"github.com/superfly/flyctl/internal/command/resume"
+	"github.com/superfly/flyctl/internal/command/review"
 	"github.com/superfly/flyctl/internal/command/scale"
 	"github.com/superfly/flyctl/internal/command/secrets"
 	"github.com/superfly/flyctl/internal/command/services"
@@ -113,6 +115,7 @@ func New() *cobra.Command {
 		group(platform.New(), "more_help"),
 		group(docs.New(), "more_help"),
 		group(releases.New(), "upkeep"),
+		group(review.New(), "deploy"),
 		group(deploy.New().Command, "deploy"),
 		group(history.New(), "upkeep"),
 		group(status.New(), "deploy"),
@@ -143,6 +146,7 @@ func New() *cobra.Command {
 		group(services.New(), "upkeep"),
 		group(config.New(), "configuring"),
 		group(scale.New(), "configuring"),
+		group(autoscale.New(), "configuring"),
 		group(tokens.New(), "acl"),
 		group(extensions.New(), "dbs_and_extensions"),
 		group(consul.New(), "dbs_and_extensions"),
@@ -22,70 +22,49 @@ import (
 
 const maxConcurrentActions = 5
 
+// countPlanOptions tell how to plan a scale count.
+type countPlanOptions struct {
+	// Regions to spread machines over, the regions the app runs in if nil.
+	regions        []string
+	maxPerRegion   int
+	fromSnapshot   string
+	withNewVolumes bool
+	// Guest of new machines of a group without any.
+	guest *fly.MachineGuest
+	// Env added to new machines.
+	env map[string]string
+}
+
 func runMachinesScaleCount(ctx context.Context, appName string, appConfig *appconfig.Config, expectedGroupCounts groupCounts, maxPerRegion int) error {
 	io := iostreams.FromContext(ctx)
-	flapsClient := flapsutil.ClientFromContext(ctx)
 	ctx = appconfig.WithConfig(ctx, appConfig)
-	apiClient := flyutil.ClientFromContext(ctx)
 
-	machines, _, err := flapsClient.ListFlyAppsMachines(ctx)
-	if err != nil {
-		return err
+	opts := countPlanOptions{
+		maxPerRegion:   maxPerRegion,
+		fromSnapshot:   flag.GetString(ctx, "from-snapshot"),
+		withNewVolumes: flag.GetBool(ctx, "with-new-volumes"),
 	}
-
-	machines = lo.Filter(machines, func(m *fly.Machine, _ int) bool {
-		return m.Config != nil
-	})
-
-	var latestCompleteRelease fly.Release
-	switch releases, err := apiClient.GetAppReleasesMachines(ctx, appName, "complete", 1); {
-	case err != nil:
-		return err
-	case len(releases) == 0:
-		return fmt.Errorf("this app has no complete releases. Run `fly deploy` to create one and rerun this command")
-	default:
-		latestCompleteRelease = releases[0]
-	}
-
-	var regions []string
 	if v := flag.GetRegion(ctx); v != "" {
-		regions = strings.Split(v, ",")
+		opts.regions = strings.Split(v, ",")
 	}
-	if len(regions) == 0 {
-		regions = lo.Uniq(lo.Map(machines, func(m *fly.Machine, _ int) string { return m.Region }))
-		if len(regions) == 0 {
-			regions = []string{appConfig.PrimaryRegion}
-		}
-	}
-
-	volumes, err := flapsClient.GetVolumes(ctx)
-	if err != nil {
-		return err
-	}
-
-	defaultGuest, err := flag.GetMachineGuest(ctx, nil)
-	if err != nil {
-		return err
-	}
-
-	defaults := newDefaults(appConfig, latestCompleteRelease, machines, volumes,
-		flag.GetString(ctx, "from-snapshot"), flag.GetBool(ctx, "with-new-volumes"), defaultGuest)
 
-	actions, err := computeActions(machines, expectedGroupCounts, regions, maxPerRegion, defaults)
+	var err error
+	opts.guest, err = flag.GetMachineGuest(ctx, nil)
 	if err != nil {
 		return err
 	}
 
 	// Add env variable overrides to launch configs
 	if env := flag.GetStringArray(ctx, "env"); len(env) > 0 {
-		parsedEnv, err := cmdutil.ParseKVStringsToMap(env)
+		opts.env, err = cmdutil.ParseKVStringsToMap(env)
 		if err != nil {
 			return fmt.Errorf("failed parsing environment: %w", err)
 		}
-		lo.ForEach(actions, func(plan *planItem, _ int) {
-			c := plan.LaunchMachineInput.Config
-			c.Env = lo.Assign(c.Env, parsedEnv)
-		})
+	}
+
+	machines, actions, err := planMachinesScaleCount(ctx, appName, appConfig, expectedGroupCounts, opts)
+	if err != nil {
+		return err
 	}
 
 	if len(actions) == 0 {
@@ -124,6 +103,86 @@ func runMachinesScaleCount(ctx context.Context, appName string, appConfig *appco
 		}
 	}
 
+	return executeScaleCount(ctx, machines, actions)
+}
+
+// ScaleCounts converges process groups to machine counts without asking
+// first, spreading machines over regions, or the regions the app runs in if
+// nil. It's what `fly scale count --yes` does, for callers like the
+// autoscaler. The flaps client must be in ctx.
+func ScaleCounts(ctx context.Context, appName string, appConfig *appconfig.Config, counts map[string]int, regions []string) error {
+	ctx = appconfig.WithConfig(ctx, appConfig)
+	expected := lo.MapValues(counts, func(count int, _ string) groupCount {
+		return groupCount{absolute: count}
+	})
+
+	machines, actions, err := planMachinesScaleCount(ctx, appName, appConfig, expected, countPlanOptions{regions: regions, maxPerRegion: -1})
+	if err != nil || len(actions) == 0 {
+		return err
+	}
+	return executeScaleCount(ctx, machines, actions)
+}
+
+// planMachinesScaleCount computes the actions converging an app to group
+// counts, along with the app's machines.
+func planMachinesScaleCount(ctx context.Context, appName string, appConfig *appconfig.Config, expectedGroupCounts groupCounts, opts countPlanOptions) ([]*fly.Machine, []*planItem, error) {
+	flapsClient := flapsutil.ClientFromContext(ctx)
+	apiClient := flyutil.ClientFromContext(ctx)
+
+	machines, _, err := flapsClient.ListFlyAppsMachines(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	machines = lo.Filter(machines, func(m *fly.Machine, _ int) bool {
+		return m.Config != nil
+	})
+
+	var latestCompleteRelease fly.Release
+	switch releases, err := apiClient.GetAppReleasesMachines(ctx, appName, "complete", 1); {
+	case err != nil:
+		return nil, nil, err
+	case len(releases) == 0:
+		return nil, nil, fmt.Errorf("this app has no complete releases. Run `fly deploy` to create one and rerun this command")
+	default:
+		latestCompleteRelease = releases[0]
+	}
+
+	regions := opts.regions
+	if len(regions) == 0 {
+		regions = lo.Uniq(lo.Map(machines, func(m *fly.Machine, _ int) string { return m.Region }))
+		if len(regions) == 0 {
+			regions = []string{appConfig.PrimaryRegion}
+		}
+	}
+
+	volumes, err := flapsClient.GetVolumes(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defaults := newDefaults(appConfig, latestCompleteRelease, machines, volumes,
+		opts.fromSnapshot, opts.withNewVolumes, opts.guest)
+
+	actions, err := computeActions(machines, expectedGroupCounts, regions, opts.maxPerRegion, defaults)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(opts.env) > 0 {
+		lo.ForEach(actions, func(plan *planItem, _ int) {
+			c := plan.LaunchMachineInput.Config
+			c.Env = lo.Assign(c.Env, opts.env)
+		})
+	}
+
+	return machines, actions, nil
+}
+
+// executeScaleCount carries out the actions of a scale count plan.
+func executeScaleCount(ctx context.Context, machines []*fly.Machine, actions []*planItem) error {
+	io := iostreams.FromContext(ctx)
+
 	// XXX: Don't acquire the leases until the user confirms it wants to execute any action
 	//      The downside is that AcquireLeases has the side effect of fetching an updated copy of machine config
 	//      that we don't use here, but it also updates the `LeaseNonce` field of the original machine which we rely on
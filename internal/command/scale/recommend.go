@@ -0,0 +1,474 @@
+package scale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sourcegraph/conc/pool"
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	mach "github.com/superfly/flyctl/internal/machine"
+	"github.com/superfly/flyctl/internal/prompt"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/terminal"
+)
+
+const (
+	sampleCmd     = "cat /proc/meminfo /proc/stat"
+	sampleTimeout = 10 // seconds
+
+	// Headroom kept over the peak memory and the 95th percentile of CPU
+	// usage seen while sampling.
+	memoryHeadroom = 1.3
+	cpuHeadroom    = 1.5
+
+	oomWindow = 24 * time.Hour
+)
+
+// Approximate list prices in USD per 30 days, see
+// https://fly.io/docs/about/pricing/. They're only good for estimating how
+// much a size change saves or costs.
+const (
+	sharedCPUMonthlyPrice      = 0.69
+	performanceCPUMonthlyPrice = 22.19
+	memoryGBMonthlyPrice       = 5.00
+)
+
+func newScaleRecommend() *cobra.Command {
+	const (
+		short = "Recommend VM sizes from observed usage"
+		long  = `Sample the memory and CPU usage of the app's started machines for a while,
+look for out of memory kills in the last day, and recommend a VM size for each
+process group.
+
+Usage is read from /proc on each machine through ` + "`fly machine exec`" + `, so the
+image needs cat. Recommendations keep headroom over the peak memory and the
+95th percentile of CPU usage seen while sampling, so sample under typical load.
+
+With --apply, machines are scaled to the recommended sizes like ` + "`fly scale vm`" + `
+does. If fly.toml has [[vm]] sections, update them with the printed ones, or
+the next deploy brings the old sizes back.
+
+Cost estimates use list prices. For pricing, see https://fly.io/docs/about/pricing/`
+	)
+	cmd := command.New("recommend", short, long, runScaleRecommend,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.NoArgs
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.ProcessGroup("Only recommend a VM size for this process group"),
+		flag.Duration{
+			Name:        "duration",
+			Description: "How long to sample usage for",
+			Default:     time.Minute,
+		},
+		flag.Duration{
+			Name:        "interval",
+			Description: "Time between samples",
+			Default:     10 * time.Second,
+		},
+		flag.Bool{
+			Name:        "apply",
+			Description: "Scale machines to the recommended sizes",
+		},
+		flag.Yes(),
+		flag.JSONOutput(),
+	)
+	return cmd
+}
+
+// recommendation is the VM size recommended for a process group.
+type recommendation struct {
+	Group             string            `json:"process_group"`
+	Machines          int               `json:"machines"`
+	Sampled           int               `json:"sampled_machines"`
+	Current           *fly.MachineGuest `json:"current"`
+	Recommended       *fly.MachineGuest `json:"recommended"`
+	PeakMemoryMB      int               `json:"peak_memory_mb"`
+	CPUsP95           float64           `json:"cpus_p95"`
+	OOMKills          int               `json:"oom_kills_24h"`
+	MonthlyCostChange float64           `json:"monthly_cost_change"`
+}
+
+func (r *recommendation) changed() bool {
+	return r.Current.CPUKind != r.Recommended.CPUKind ||
+		r.Current.CPUs != r.Recommended.CPUs ||
+		r.Current.MemoryMB != r.Recommended.MemoryMB
+}
+
+func runScaleRecommend(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+	appName := appconfig.NameFromContext(ctx)
+	duration, interval := flag.GetDuration(ctx, "duration"), flag.GetDuration(ctx, "interval")
+	if interval <= 0 || duration < interval {
+		return errors.New("--interval must be positive and no longer than --duration")
+	}
+
+	flapsClient, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+		AppName: appName,
+	})
+	if err != nil {
+		return err
+	}
+	ctx = flapsutil.NewContextWithClient(ctx, flapsClient)
+
+	machines, err := mach.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	group := flag.GetProcessGroup(ctx)
+	machines = lo.Filter(machines, func(m *fly.Machine, _ int) bool {
+		return m.Config.Guest != nil && (group == "" || m.ProcessGroup() == group)
+	})
+	started := lo.Filter(machines, func(m *fly.Machine, _ int) bool {
+		return m.State == fly.MachineStateStarted
+	})
+	if len(started) == 0 {
+		return fmt.Errorf("no started machines to sample, start some with `fly machine start` or send traffic to %s first", appName)
+	}
+
+	fmt.Fprintf(io.ErrOut, "Sampling %d machines every %s for %s\n", len(started), interval, duration)
+	usage, err := sampleUsage(ctx, flapsClient, started, duration, interval)
+	if err != nil {
+		return err
+	}
+	for _, m := range started {
+		if err := usage[m.ID].err; err != nil {
+			terminal.Warnf("Couldn't sample machine %s: %v\n", m.ID, err)
+		}
+	}
+
+	recs := recommendSizes(machines, usage, time.Now())
+	if len(recs) == 0 {
+		return errors.New("not enough samples to recommend a VM size, try a longer --duration")
+	}
+
+	if flag.GetBool(ctx, "json") {
+		return render.JSON(io.Out, recs)
+	}
+
+	rows := make([][]string, 0, len(recs))
+	for _, r := range recs {
+		recommended := "keep"
+		if r.changed() {
+			recommended = formatGuest(r.Recommended)
+		}
+		rows = append(rows, []string{
+			r.Group,
+			fmt.Sprintf("%d/%d", r.Sampled, r.Machines),
+			formatGuest(r.Current),
+			fmt.Sprintf("%d MB", r.PeakMemoryMB),
+			fmt.Sprintf("%.2f", r.CPUsP95),
+			strconv.Itoa(r.OOMKills),
+			recommended,
+			formatCostChange(r.MonthlyCostChange),
+		})
+	}
+	fmt.Fprintf(io.Out, "VM size recommendations for app: %s\n\n", appName)
+	render.Table(io.Out, "", rows, "Process Group", "Sampled", "Current", "Peak Memory", "CPU p95", "OOM Kills (24h)", "Recommended", "Est. Monthly Change")
+
+	changed := lo.Filter(recs, func(r *recommendation, _ int) bool { return r.changed() })
+	if len(changed) == 0 {
+		fmt.Fprintln(io.Out, "Machines are sized right for the usage seen.")
+		return nil
+	}
+
+	fmt.Fprintf(io.Out, "\nTo keep these sizes across deploys, use these [[vm]] sections in fly.toml:\n\n")
+	for _, r := range changed {
+		fmt.Fprintf(io.Out, "[[vm]]\n  size = %q\n  memory = \"%dmb\"\n  processes = [%q]\n\n", r.Recommended.ToSize(), r.Recommended.MemoryMB, r.Group)
+	}
+
+	if !flag.GetBool(ctx, "apply") {
+		fmt.Fprintln(io.Out, "Run with --apply to scale the machines to these sizes.")
+		return nil
+	}
+
+	if !flag.GetYes(ctx) {
+		switch confirmed, err := prompt.Confirmf(ctx, "Scale %d process groups of %s to the recommended sizes?", len(changed), appName); {
+		case err == nil:
+			if !confirmed {
+				return nil
+			}
+		case prompt.IsNonInteractive(err):
+			return prompt.NonInteractiveError("--yes flag must be specified when not running interactively")
+		default:
+			return err
+		}
+	}
+
+	for _, r := range changed {
+		size, err := v2ScaleVM(ctx, appName, r.Group, r.Recommended.ToSize(), r.Recommended.MemoryMB)
+		if err != nil {
+			return fmt.Errorf("failed to scale '%s': %w", r.Group, err)
+		}
+		fmt.Fprintf(io.Out, "Scaled VM Type for '%s' to '%s' with %s\n", r.Group, size.Name, formatMemory(*size))
+	}
+	return nil
+}
+
+// machineUsage is the usage sampled from a machine.
+type machineUsage struct {
+	memoryMB []int
+	cpus     []float64
+	last     *procSample
+	err      error
+}
+
+// sampleUsage samples machines every interval for duration. Machines that
+// fail to be sampled once aren't sampled again, their error is kept instead.
+func sampleUsage(ctx context.Context, client flapsutil.FlapsClient, machines []*fly.Machine, duration, interval time.Duration) (map[string]*machineUsage, error) {
+	usage := make(map[string]*machineUsage, len(machines))
+	for _, m := range machines {
+		usage[m.ID] = &machineUsage{}
+	}
+
+	samples := int(duration/interval) + 1
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+
+		p := pool.New().WithMaxGoroutines(maxConcurrentActions)
+		for _, m := range machines {
+			u := usage[m.ID]
+			if u.err != nil {
+				continue
+			}
+			p.Go(func() { u.sample(ctx, client, m) })
+		}
+		p.Wait()
+	}
+	return usage, nil
+}
+
+func (u *machineUsage) sample(ctx context.Context, client flapsutil.FlapsClient, m *fly.Machine) {
+	out, err := client.Exec(ctx, m.ID, &fly.MachineExecRequest{Cmd: sampleCmd, Timeout: sampleTimeout})
+	if err == nil && out.ExitCode != 0 {
+		err = fmt.Errorf("%q exited with code %d: %s", sampleCmd, out.ExitCode, strings.TrimSpace(out.StdErr))
+	}
+	var s *procSample
+	if err == nil {
+		s, err = parseProcSample(out.StdOut)
+	}
+	if err != nil {
+		u.err = err
+		return
+	}
+
+	u.memoryMB = append(u.memoryMB, s.memoryUsedMB)
+	if u.last != nil {
+		if cpus, ok := s.cpusBusySince(u.last); ok {
+			u.cpus = append(u.cpus, cpus)
+		}
+	}
+	u.last = s
+}
+
+// procSample is a reading of /proc/meminfo and /proc/stat.
+type procSample struct {
+	memoryUsedMB int
+	// Jiffies spent busy and in total by all CPUs since boot.
+	cpuBusy, cpuTotal uint64
+	cpus              int
+}
+
+func parseProcSample(out string) (*procSample, error) {
+	var (
+		s                      procSample
+		memTotal, memAvailable = -1, -1
+	)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch {
+		case fields[0] == "MemTotal:":
+			memTotal, _ = strconv.Atoi(fields[1])
+		case fields[0] == "MemAvailable:":
+			memAvailable, _ = strconv.Atoi(fields[1])
+		case fields[0] == "cpu":
+			// user nice system idle iowait irq softirq steal, guest time
+			// is already counted in user and nice.
+			for i, field := range fields[1:min(len(fields), 9)] {
+				jiffies, err := strconv.ParseUint(field, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("unexpected /proc/stat line %q", line)
+				}
+				s.cpuTotal += jiffies
+				if i != 3 && i != 4 {
+					s.cpuBusy += jiffies
+				}
+			}
+		case strings.HasPrefix(fields[0], "cpu"):
+			s.cpus++
+		}
+	}
+
+	switch {
+	case memTotal < 0 || memAvailable < 0:
+		return nil, errors.New("no MemTotal or MemAvailable in /proc/meminfo")
+	case s.cpus == 0 || s.cpuTotal == 0:
+		return nil, errors.New("no CPU times in /proc/stat")
+	}
+	s.memoryUsedMB = (memTotal - memAvailable) / 1024
+	return &s, nil
+}
+
+// cpusBusySince is how many CPUs were busy on average since prev.
+func (s *procSample) cpusBusySince(prev *procSample) (float64, bool) {
+	if s.cpuTotal <= prev.cpuTotal || s.cpuBusy < prev.cpuBusy {
+		return 0, false
+	}
+	busy := float64(s.cpuBusy-prev.cpuBusy) / float64(s.cpuTotal-prev.cpuTotal)
+	return busy * float64(s.cpus), true
+}
+
+// recommendSizes recommends a VM size for each process group with sampled
+// machines. GPU machines are left alone.
+func recommendSizes(machines []*fly.Machine, usage map[string]*machineUsage, now time.Time) []*recommendation {
+	groups := lo.GroupBy(machines, func(m *fly.Machine) string {
+		return m.ProcessGroup()
+	})
+	groupNames := lo.Keys(groups)
+	slices.Sort(groupNames)
+
+	var recs []*recommendation
+	for _, name := range groupNames {
+		machines := groups[name]
+		// Like scale show, the first machine stands for the group.
+		current := machines[0].Config.Guest
+		if current.GPUKind != "" {
+			continue
+		}
+
+		r := &recommendation{Group: name, Machines: len(machines), Current: current}
+		var cpus []float64
+		for _, m := range machines {
+			r.OOMKills += oomKills(m, now)
+			u := usage[m.ID]
+			if u == nil || len(u.cpus) == 0 {
+				continue
+			}
+			r.Sampled++
+			r.PeakMemoryMB = max(r.PeakMemoryMB, slices.Max(u.memoryMB))
+			cpus = append(cpus, u.cpus...)
+		}
+		if r.Sampled == 0 {
+			continue
+		}
+		r.CPUsP95 = percentile(cpus, 0.95)
+
+		recommended := recommendGuest(*current, r.PeakMemoryMB, r.CPUsP95, r.OOMKills)
+		r.Recommended = &recommended
+		for _, m := range machines {
+			r.MonthlyCostChange += monthlyPrice(r.Recommended) - monthlyPrice(m.Config.Guest)
+		}
+		recs = append(recs, r)
+	}
+	return recs
+}
+
+// recommendGuest sizes a guest of the same CPU kind as current for the
+// usage seen. The CPU count is a power of two that keeps the 95th percentile
+// of usage under two thirds of the CPUs, and memory keeps 30% over the peak,
+// or at least doubles if the group was OOM killed.
+func recommendGuest(current fly.MachineGuest, peakMemoryMB int, cpusP95 float64, oomKills int) fly.MachineGuest {
+	guest := current
+
+	maxCPUs, memoryStep := 8, 256
+	minMemoryPerCPU, maxMemoryPerCPU := fly.MIN_MEMORY_MB_PER_SHARED_CPU, fly.MAX_MEMORY_MB_PER_SHARED_CPU
+	if guest.CPUKind == "performance" {
+		maxCPUs, memoryStep = 16, 1024
+		minMemoryPerCPU, maxMemoryPerCPU = fly.MIN_MEMORY_MB_PER_CPU, fly.MAX_MEMORY_MB_PER_CPU
+	}
+
+	guest.CPUs = 1
+	for guest.CPUs < maxCPUs && float64(guest.CPUs) < cpusP95*cpuHeadroom {
+		guest.CPUs *= 2
+	}
+
+	memoryMB := int(math.Ceil(float64(peakMemoryMB) * memoryHeadroom))
+	if oomKills > 0 {
+		memoryMB = max(memoryMB, current.MemoryMB*2)
+	}
+	memoryMB = (memoryMB + memoryStep - 1) / memoryStep * memoryStep
+
+	// More memory than the CPUs allow needs more CPUs.
+	for guest.CPUs < maxCPUs && memoryMB > guest.CPUs*maxMemoryPerCPU {
+		guest.CPUs *= 2
+	}
+	guest.MemoryMB = min(max(memoryMB, guest.CPUs*minMemoryPerCPU), guest.CPUs*maxMemoryPerCPU)
+	return guest
+}
+
+func oomKills(m *fly.Machine, now time.Time) int {
+	kills := 0
+	for _, e := range m.Events {
+		if e.Request == nil || now.Sub(e.Time()) > oomWindow {
+			continue
+		}
+		exit := e.Request.ExitEvent
+		if e.Request.MonitorEvent != nil && e.Request.MonitorEvent.ExitEvent != nil {
+			exit = e.Request.MonitorEvent.ExitEvent
+		}
+		if exit != nil && exit.OOMKilled {
+			kills++
+		}
+	}
+	return kills
+}
+
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// monthlyPrice estimates what a machine of guest costs running for 30 days.
+func monthlyPrice(guest *fly.MachineGuest) float64 {
+	cpuPrice := sharedCPUMonthlyPrice
+	if guest.CPUKind == "performance" {
+		cpuPrice = performanceCPUMonthlyPrice
+	}
+	return float64(guest.CPUs)*cpuPrice + float64(guest.MemoryMB)/1024*memoryGBMonthlyPrice
+}
+
+func formatGuest(guest *fly.MachineGuest) string {
+	return fmt.Sprintf("%s, %d MB", guest.ToSize(), guest.MemoryMB)
+}
+
+func formatCostChange(change float64) string {
+	switch {
+	case change > 0.005:
+		return fmt.Sprintf("+$%.2f", change)
+	case change < -0.005:
+		return fmt.Sprintf("-$%.2f", -change)
+	default:
+		return "$0.00"
+	}
+}
@@ -0,0 +1,95 @@
+package scale
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+)
+
+const procOutput = `MemTotal:         985364 kB
+MemFree:          407016 kB
+MemAvailable:     678740 kB
+Buffers:           21592 kB
+cpu  1000 0 500 8000 100 0 0 0 0 0
+cpu0 500 0 250 4000 50 0 0 0 0 0
+cpu1 500 0 250 4000 50 0 0 0 0 0
+intr 123456 0 0
+ctxt 654321
+`
+
+func Test_parseProcSample(t *testing.T) {
+	s, err := parseProcSample(procOutput)
+	require.NoError(t, err)
+	assert.Equal(t, (985364-678740)/1024, s.memoryUsedMB)
+	assert.Equal(t, 2, s.cpus)
+	assert.Equal(t, uint64(1500), s.cpuBusy)
+	assert.Equal(t, uint64(9600), s.cpuTotal)
+
+	next := *s
+	next.cpuBusy += 300
+	next.cpuTotal += 400
+	cpus, ok := next.cpusBusySince(s)
+	assert.True(t, ok)
+	assert.InDelta(t, 1.5, cpus, 0.001)
+
+	_, err = parseProcSample("sh: cat: not found")
+	assert.Error(t, err)
+}
+
+func Test_recommendGuest(t *testing.T) {
+	shared := fly.MachineGuest{CPUKind: "shared", CPUs: 2, MemoryMB: 2048}
+
+	// Mostly idle machines shrink.
+	g := recommendGuest(shared, 300, 0.1, 0)
+	assert.Equal(t, 1, g.CPUs)
+	assert.Equal(t, 512, g.MemoryMB)
+
+	// Busy CPUs grow to the next power of two.
+	g = recommendGuest(shared, 300, 2.5, 0)
+	assert.Equal(t, 4, g.CPUs)
+	assert.Equal(t, 1024, g.MemoryMB)
+
+	// OOM kills at least double memory, adding CPUs to allow it.
+	g = recommendGuest(shared, 1500, 0.1, 3)
+	assert.Equal(t, 4096, g.MemoryMB)
+	assert.Equal(t, 2, g.CPUs)
+
+	performance := fly.MachineGuest{CPUKind: "performance", CPUs: 4, MemoryMB: 8192}
+	g = recommendGuest(performance, 1000, 0.5, 0)
+	assert.Equal(t, "performance", g.CPUKind)
+	assert.Equal(t, 1, g.CPUs)
+	assert.Equal(t, 2048, g.MemoryMB)
+}
+
+func Test_recommendSizes(t *testing.T) {
+	now := time.Now()
+	oom := &fly.MachineEvent{
+		Type:      "exit",
+		Timestamp: now.Add(-time.Hour).UnixMilli(),
+		Request:   &fly.MachineRequest{ExitEvent: &fly.MachineExitEvent{OOMKilled: true, ExitCode: 137}},
+	}
+	guest := &fly.MachineGuest{CPUKind: "shared", CPUs: 1, MemoryMB: 256}
+	machines := []*fly.Machine{
+		{ID: "m1", Config: &fly.MachineConfig{Guest: guest, Metadata: map[string]string{"fly_process_group": "app"}}, Events: []*fly.MachineEvent{oom}},
+		{ID: "m2", Config: &fly.MachineConfig{Guest: guest, Metadata: map[string]string{"fly_process_group": "app"}}},
+		{ID: "m3", Config: &fly.MachineConfig{Guest: guest, Metadata: map[string]string{"fly_process_group": "worker"}}},
+	}
+	usage := map[string]*machineUsage{
+		"m1": {memoryMB: []int{200, 240}, cpus: []float64{0.2}},
+		"m2": {memoryMB: []int{180}, cpus: []float64{0.3}},
+	}
+
+	recs := recommendSizes(machines, usage, now)
+	require.Len(t, recs, 1)
+	r := recs[0]
+	assert.Equal(t, "app", r.Group)
+	assert.Equal(t, 2, r.Sampled)
+	assert.Equal(t, 240, r.PeakMemoryMB)
+	assert.Equal(t, 1, r.OOMKills)
+	assert.Equal(t, 512, r.Recommended.MemoryMB)
+	assert.True(t, r.changed())
+	assert.InDelta(t, 2*0.25*memoryGBMonthlyPrice, r.MonthlyCostChange, 0.001)
+}
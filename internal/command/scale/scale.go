@@ -17,6 +17,8 @@ func New() *cobra.Command {
 		newScaleMemory(),
 		newScaleShow(),
 		newScaleCount(),
+		newScaleRecommend(),
+		newScaleSchedule(),
 	)
 	return cmd
 }
@@ -0,0 +1,452 @@
+package scale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func newScaleSchedule() *cobra.Command {
+	const (
+		short = "Scale machines on a schedule"
+		long  = `Scale machine counts and sizes at set times, following the [[scale.schedule]]
+entries of fly.toml:
+
+  [[scale.schedule]]
+    name = "night"
+    cron = "0 22 * * *"
+    timezone = "Europe/Paris"
+    processes = ["app"]
+    count = 1
+
+  [[scale.schedule]]
+    name = "weekday peak"
+    cron = "0 8 * * 1-5"
+    timezone = "Europe/Paris"
+    processes = ["app"]
+    regions = { cdg = 4, ams = 2 }
+    size = "performance-2x"
+
+count spreads machines over the regions the app runs in, regions sets the
+count of each region listed. Entries without processes apply to every process
+group.
+
+'fly scale schedule run' scales the groups whose entries fired recently. Run it
+from a scheduled machine of a separate app, with a deploy token kept in a
+secret of that app rather than on the command line:
+
+  fly apps create <app>-scheduler
+  echo "FLY_API_TOKEN=$(fly tokens create deploy -a <app>)" | \
+    fly secrets import -a <app>-scheduler
+  fly machine run flyio/flyctl:latest --schedule hourly -a <app>-scheduler \
+    --env FLY_APP=<app> -- scale schedule run`
+	)
+	cmd := command.New("schedule", short, long, nil)
+	cmd.AddCommand(
+		newScaleScheduleValidate(),
+		newScaleSchedulePreview(),
+		newScaleScheduleRun(),
+	)
+	return cmd
+}
+
+func newScaleScheduleValidate() *cobra.Command {
+	const (
+		short = "Validate the scale schedule"
+		long  = `Check the [[scale.schedule]] entries of fly.toml and show when each fires next`
+	)
+	cmd := command.New("validate", short, long, runScaleScheduleValidate,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.NoArgs
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+	)
+	return cmd
+}
+
+func newScaleSchedulePreview() *cobra.Command {
+	const (
+		short = "Show the next scale schedule transitions"
+		long  = `Show the next times the scale schedule scales process groups, and to what`
+	)
+	cmd := command.New("preview", short, long, runScaleSchedulePreview,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.NoArgs
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Int{
+			Name:        "count",
+			Description: "Number of transitions to show",
+			Default:     10,
+		},
+		flag.JSONOutput(),
+	)
+	return cmd
+}
+
+func newScaleScheduleRun() *cobra.Command {
+	const (
+		short = "Run due scale schedule transitions"
+		long  = `Scale the process groups whose [[scale.schedule]] entries fired within
+--window, oldest first. The default window of 3 hours covers hourly runs
+that start late or are skipped. The last transition applied to a group is
+recorded on its machines and transitions up to it are skipped, so the window
+can be wider than how often this runs, but not narrower.
+
+The schedule is read from fly.toml when it has one, from the deployed config
+otherwise.`
+	)
+	cmd := command.New("run", short, long, runScaleScheduleRun,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.NoArgs
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Duration{
+			Name:        "window",
+			Description: "How far back to look for transitions",
+			Default:     3 * time.Hour,
+		},
+		flag.Bool{
+			Name:        "dry-run",
+			Description: "Show the due transitions without scaling",
+		},
+	)
+	return cmd
+}
+
+// appliedMetadataKey records on a group's machines when the last scale
+// schedule transition applied to the group fired, so later runs skip it.
+const appliedMetadataKey = "fly_scale_schedule_applied"
+
+// transition is a scale schedule entry firing for a process group.
+type transition struct {
+	At       time.Time                `json:"at"`
+	Group    string                   `json:"process_group"`
+	Schedule *appconfig.ScaleSchedule `json:"schedule"`
+}
+
+// loadScheduleConfig returns the app config from fly.toml if it has a scale
+// schedule, the deployed config otherwise.
+func loadScheduleConfig(ctx context.Context) (*appconfig.Config, error) {
+	io := iostreams.FromContext(ctx)
+	appName := appconfig.NameFromContext(ctx)
+
+	cfg := appconfig.ConfigFromContext(ctx)
+	if cfg == nil || cfg.Scale == nil || len(cfg.Scale.Schedule) == 0 {
+		var err error
+		if cfg, err = appconfig.FromRemoteApp(ctx, appName); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Scale == nil || len(cfg.Scale.Schedule) == 0 {
+		return nil, fmt.Errorf("%s has no [[scale.schedule]] entries, add some to fly.toml", appName)
+	}
+
+	if err, extraInfo := cfg.Validate(ctx); err != nil {
+		fmt.Fprint(io.ErrOut, extraInfo)
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// scheduleGroups are the process groups a schedule entry scales.
+func scheduleGroups(cfg *appconfig.Config, s *appconfig.ScaleSchedule) []string {
+	if len(s.Processes) > 0 {
+		return s.Processes
+	}
+	return cfg.ProcessNames()
+}
+
+// upcomingTransitions lists the first n transitions after now.
+func upcomingTransitions(cfg *appconfig.Config, now time.Time, n int) ([]transition, error) {
+	var transitions []transition
+	for _, s := range cfg.Scale.Schedule {
+		schedule, err := s.CronSchedule()
+		if err != nil {
+			return nil, err
+		}
+		at := now
+		for i := 0; i < n; i++ {
+			if at = schedule.Next(at); at.IsZero() {
+				break
+			}
+			for _, group := range scheduleGroups(cfg, s) {
+				transitions = append(transitions, transition{At: at, Group: group, Schedule: s})
+			}
+		}
+	}
+
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].At.Before(transitions[j].At)
+	})
+	return transitions[:min(n, len(transitions))], nil
+}
+
+// dueTransitions lists the transitions that fired within window before now,
+// oldest first. Entries firing more than once within window only count once.
+func dueTransitions(cfg *appconfig.Config, now time.Time, window time.Duration) ([]transition, error) {
+	var transitions []transition
+	for _, s := range cfg.Scale.Schedule {
+		schedule, err := s.CronSchedule()
+		if err != nil {
+			return nil, err
+		}
+		at := lastFired(schedule, now.Add(-window), now)
+		if at.IsZero() {
+			continue
+		}
+		for _, group := range scheduleGroups(cfg, s) {
+			transitions = append(transitions, transition{At: at, Group: group, Schedule: s})
+		}
+	}
+
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].At.Before(transitions[j].At)
+	})
+	return transitions, nil
+}
+
+// lastFired is the last time schedule fired after from and up to to, if it
+// did.
+func lastFired(schedule cron.Schedule, from, to time.Time) time.Time {
+	var last time.Time
+	for at := schedule.Next(from); !at.IsZero() && !at.After(to); at = schedule.Next(at) {
+		last = at
+	}
+	return last
+}
+
+// describeSchedule tells what a schedule entry scales groups to.
+func describeSchedule(s *appconfig.ScaleSchedule) string {
+	var changes []string
+	if s.Count != nil {
+		changes = append(changes, fmt.Sprintf("%d machines", *s.Count))
+	}
+	if len(s.Regions) > 0 {
+		regions := make([]string, 0, len(s.Regions))
+		for region, count := range s.Regions {
+			regions = append(regions, fmt.Sprintf("%s=%d", region, count))
+		}
+		slices.Sort(regions)
+		changes = append(changes, strings.Join(regions, " "))
+	}
+	if s.Size != "" {
+		changes = append(changes, s.Size)
+	}
+	if s.Memory != "" {
+		changes = append(changes, s.Memory+" memory")
+	}
+	return strings.Join(changes, ", ")
+}
+
+// scheduleTime formats a transition time in the time zone of its entry.
+func scheduleTime(t time.Time, s *appconfig.ScaleSchedule) string {
+	if loc, err := time.LoadLocation(s.Timezone); err == nil {
+		t = t.In(loc)
+	}
+	return t.Format("Mon 2006-01-02 15:04 MST")
+}
+
+func runScaleScheduleValidate(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+	cfg, err := loadScheduleConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, s := range cfg.Scale.Schedule {
+		schedule, err := s.CronSchedule()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(io.Out, "  %s: %s for %s, next at %s\n",
+			s, describeSchedule(s), strings.Join(scheduleGroups(cfg, s), ", "), scheduleTime(schedule.Next(now), s))
+	}
+	fmt.Fprintf(io.Out, "Scale schedule of %s is valid\n", cfg.AppName)
+	return nil
+}
+
+func runScaleSchedulePreview(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+	cfg, err := loadScheduleConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	transitions, err := upcomingTransitions(cfg, time.Now(), flag.GetInt(ctx, "count"))
+	if err != nil {
+		return err
+	}
+
+	if flag.GetBool(ctx, "json") {
+		return render.JSON(io.Out, transitions)
+	}
+
+	rows := make([][]string, 0, len(transitions))
+	for _, t := range transitions {
+		rows = append(rows, []string{scheduleTime(t.At, t.Schedule), t.Group, t.Schedule.String(), describeSchedule(t.Schedule)})
+	}
+	return render.Table(io.Out, "", rows, "Time", "Process Group", "Schedule", "Scale To")
+}
+
+func runScaleScheduleRun(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+	appName := appconfig.NameFromContext(ctx)
+
+	cfg, err := loadScheduleConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	transitions, err := dueTransitions(cfg, time.Now(), flag.GetDuration(ctx, "window"))
+	if err != nil {
+		return err
+	}
+	if len(transitions) == 0 {
+		fmt.Fprintf(io.Out, "No scale schedule transitions are due within the last %s\n", flag.GetDuration(ctx, "window"))
+		return nil
+	}
+
+	flapsClient, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+		AppName: appName,
+	})
+	if err != nil {
+		return err
+	}
+	ctx = flapsutil.NewContextWithClient(ctx, flapsClient)
+
+	dryRun := flag.GetBool(ctx, "dry-run")
+	verb := "Scaling"
+	if dryRun {
+		verb = "Would scale"
+	}
+
+	var errs []error
+	for _, t := range transitions {
+		machines, err := listMachinesWithGroup(ctx, t.Group)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t.Group, err))
+			continue
+		}
+		if !t.At.After(lastApplied(machines)) {
+			fmt.Fprintf(io.Out, "Skipping '%s' to %s (%s, due at %s), already applied\n", t.Group, describeSchedule(t.Schedule), t.Schedule, scheduleTime(t.At, t.Schedule))
+			continue
+		}
+
+		fmt.Fprintf(io.Out, "%s '%s' to %s (%s, due at %s)\n", verb, t.Group, describeSchedule(t.Schedule), t.Schedule, scheduleTime(t.At, t.Schedule))
+		if dryRun {
+			continue
+		}
+		if err := applyTransition(ctx, appName, cfg, t); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t.Group, err))
+			continue
+		}
+		if err := recordApplied(ctx, t); err != nil {
+			errs = append(errs, fmt.Errorf("%s: recording the transition: %w", t.Group, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lastApplied is when the last transition applied to the group of machines
+// fired, or the zero time if none was.
+func lastApplied(machines []*fly.Machine) time.Time {
+	var last time.Time
+	for _, m := range machines {
+		at, err := time.Parse(time.RFC3339, m.Config.Metadata[appliedMetadataKey])
+		if err == nil && at.After(last) {
+			last = at
+		}
+	}
+	return last
+}
+
+// recordApplied notes on the machines of its group that t was applied. A
+// group scaled to no machines can't record it, applying it again then
+// changes nothing.
+func recordApplied(ctx context.Context, t transition) error {
+	machines, err := listMachinesWithGroup(ctx, t.Group)
+	if err != nil {
+		return err
+	}
+	flapsClient := flapsutil.ClientFromContext(ctx)
+	for _, m := range machines {
+		if err := flapsClient.SetMetadata(ctx, m.ID, appliedMetadataKey, t.At.UTC().Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyTransition scales a process group as its schedule entry says. Machines
+// are resized before the count changes so that new machines get the new
+// size, unless the group has no machines to resize yet.
+func applyTransition(ctx context.Context, appName string, cfg *appconfig.Config, t transition) error {
+	s := t.Schedule
+	memoryMB, err := s.MemoryMB()
+	if err != nil {
+		return err
+	}
+
+	machines, err := listMachinesWithGroup(ctx, t.Group)
+	if err != nil {
+		return err
+	}
+	resize := s.Size != "" || memoryMB > 0
+	if resize && len(machines) > 0 {
+		if _, err := v2ScaleVM(ctx, appName, t.Group, s.Size, memoryMB); err != nil {
+			return err
+		}
+		resize = false
+	}
+
+	if s.Count != nil {
+		if err := ScaleCounts(ctx, appName, cfg, map[string]int{t.Group: *s.Count}, nil); err != nil {
+			return err
+		}
+	}
+	regions := make([]string, 0, len(s.Regions))
+	for region := range s.Regions {
+		regions = append(regions, region)
+	}
+	slices.Sort(regions)
+	for _, region := range regions {
+		if err := ScaleCounts(ctx, appName, cfg, map[string]int{t.Group: s.Regions[region]}, []string{region}); err != nil {
+			return err
+		}
+	}
+
+	if !resize {
+		return nil
+	}
+	if machines, err := listMachinesWithGroup(ctx, t.Group); err != nil || len(machines) == 0 {
+		return err
+	}
+	_, err = v2ScaleVM(ctx, appName, t.Group, s.Size, memoryMB)
+	return err
+}
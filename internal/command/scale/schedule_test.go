@@ -0,0 +1,106 @@
+package scale
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/inmem"
+)
+
+func testScheduleConfig() *appconfig.Config {
+	cfg := appconfig.NewConfig()
+	cfg.Processes = map[string]string{"app": "", "worker": ""}
+	cfg.Scale = &appconfig.Scale{Schedule: []*appconfig.ScaleSchedule{
+		{Name: "night", Cron: "0 22 * * *", Timezone: "Europe/Paris", Count: fly.Pointer(1), Processes: []string{"app"}},
+		{Name: "peak", Cron: "0 8 * * 1-5", Timezone: "Europe/Paris", Regions: map[string]int{"cdg": 4, "ams": 2}, Size: "performance-2x", Processes: []string{"app"}},
+		{Name: "weekly", Cron: "@weekly", Count: fly.Pointer(0)},
+	}}
+	return cfg
+}
+
+func Test_upcomingTransitions(t *testing.T) {
+	cfg := testScheduleConfig()
+	// Friday 2024-03-01 12:00 in Paris
+	now := time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)
+
+	transitions, err := upcomingTransitions(cfg, now, 5)
+	require.NoError(t, err)
+	require.Len(t, transitions, 5)
+
+	var got []string
+	for _, tr := range transitions {
+		got = append(got, scheduleTime(tr.At, tr.Schedule)+" "+tr.Group+" "+tr.Schedule.String())
+	}
+	assert.Equal(t, []string{
+		"Fri 2024-03-01 22:00 CET app night",
+		"Sat 2024-03-02 22:00 CET app night",
+		"Sun 2024-03-03 00:00 UTC app weekly",
+		"Sun 2024-03-03 00:00 UTC worker weekly",
+		"Sun 2024-03-03 22:00 CET app night",
+	}, got)
+}
+
+func Test_dueTransitions(t *testing.T) {
+	cfg := testScheduleConfig()
+	// Monday 2024-03-04 08:20 in Paris
+	now := time.Date(2024, 3, 4, 7, 20, 0, 0, time.UTC)
+
+	transitions, err := dueTransitions(cfg, now, time.Hour)
+	require.NoError(t, err)
+	require.Len(t, transitions, 1)
+	assert.Equal(t, "peak", transitions[0].Schedule.Name)
+	assert.Equal(t, "app", transitions[0].Group)
+	assert.Equal(t, "ams=2 cdg=4, performance-2x", describeSchedule(transitions[0].Schedule))
+
+	// Each entry fires once at most, however many times it fired within
+	// the window.
+	transitions, err = dueTransitions(cfg, now, 50*time.Hour)
+	require.NoError(t, err)
+	require.Len(t, transitions, 4)
+	assert.Equal(t, "weekly", transitions[0].Schedule.Name)
+	assert.Equal(t, "worker", transitions[1].Group)
+	assert.Equal(t, "night", transitions[2].Schedule.Name)
+	assert.Equal(t, time.Date(2024, 3, 3, 21, 0, 0, 0, time.UTC), transitions[2].At.UTC())
+	assert.Equal(t, "peak", transitions[3].Schedule.Name)
+
+	transitions, err = dueTransitions(cfg, now, 10*time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, transitions)
+}
+
+func TestAppliedTransitions(t *testing.T) {
+	ctx := context.Background()
+	server := inmem.NewServer()
+	server.CreateApp(&fly.App{Name: "app"})
+	client := server.FlapsClient("app")
+	ctx = flapsutil.NewContextWithClient(ctx, client)
+	for _, group := range []string{"app", "app", "worker"} {
+		_, err := client.Launch(ctx, fly.LaunchMachineInput{Region: "ord", Config: &fly.MachineConfig{
+			Image: "app:v1",
+			Metadata: map[string]string{
+				fly.MachineConfigMetadataKeyFlyPlatformVersion: fly.MachineFlyPlatformVersion2,
+				fly.MachineConfigMetadataKeyFlyProcessGroup:    group,
+			},
+		}})
+		require.NoError(t, err)
+	}
+
+	cfg := testScheduleConfig()
+	at := time.Date(2024, 3, 3, 21, 0, 0, 0, time.UTC)
+	require.NoError(t, recordApplied(ctx, transition{At: at, Group: "app", Schedule: cfg.Scale.Schedule[0]}))
+
+	machines, err := listMachinesWithGroup(ctx, "app")
+	require.NoError(t, err)
+	require.Len(t, machines, 2)
+	assert.True(t, at.Equal(lastApplied(machines)))
+
+	machines, err = listMachinesWithGroup(ctx, "worker")
+	require.NoError(t, err)
+	assert.True(t, lastApplied(machines).IsZero())
+}
@@ -63,5 +63,10 @@ func runDeploy(ctx context.Context) (err error) {
 		}
 	}
 
-	return DeploySecrets(ctx, app, false, flag.GetBool(ctx, "detach"))
+	if err := DeploySecrets(ctx, app, false, flag.GetBool(ctx, "detach")); err != nil {
+		return err
+	}
+	recordHistory(ctx, app.Name, "deploy", nil, nil)
+
+	return nil
 }
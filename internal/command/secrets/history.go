@@ -0,0 +1,192 @@
+package secrets
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/format"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/internal/state"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/terminal"
+)
+
+// historyDir is the directory, relative to the config directory, that holds
+// one JSON lines file per app recording the secret digests after each change.
+const historyDir = "secrets-history"
+
+// HistoryEntry records which secret digests were active after a change made
+// from this host.
+type HistoryEntry struct {
+	Time    time.Time         `json:"time"`
+	Action  string            `json:"action"`
+	Release int               `json:"release,omitempty"`
+	Names   []string          `json:"names"`
+	Digests map[string]string `json:"digests"`
+}
+
+func historyPath(ctx context.Context, appName string) string {
+	return filepath.Join(state.ConfigDirectory(ctx), historyDir, appName+".jsonl")
+}
+
+// recordHistory appends the app's current secret digests to its local history.
+// Failures are only logged, as the history is a convenience and must never
+// fail the change that was just made.
+func recordHistory(ctx context.Context, appName, action string, names []string, release *fly.Release) {
+	if err := appendHistory(ctx, appName, action, names, release); err != nil {
+		terminal.Debugf("failed recording secrets history for %s: %v\n", appName, err)
+	}
+}
+
+func appendHistory(ctx context.Context, appName, action string, names []string, release *fly.Release) error {
+	client := flyutil.ClientFromContext(ctx)
+
+	secrets, err := client.GetAppSecrets(ctx, appName)
+	if err != nil {
+		return err
+	}
+
+	entry := HistoryEntry{
+		Time:    time.Now().UTC(),
+		Action:  action,
+		Names:   slices.Sorted(slices.Values(names)),
+		Digests: make(map[string]string, len(secrets)),
+	}
+	if release != nil {
+		entry.Release = release.Version
+	}
+	for _, secret := range secrets {
+		entry.Digests[secret.Name] = secret.Digest
+	}
+
+	path := historyPath(ctx, appName)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // skipcq: GO-S2307
+
+	return json.NewEncoder(f).Encode(entry)
+}
+
+// loadHistory returns the recorded history for an app, oldest first.
+func loadHistory(ctx context.Context, appName string) ([]HistoryEntry, error) {
+	f, err := os.Open(historyPath(ctx, appName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close() // skipcq: GO-S2307
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed parsing %s: %w", f.Name(), err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+func newHistory() (cmd *cobra.Command) {
+	const (
+		long = `Show the secret changes made to the application from this machine. Each
+entry lists the release created by the change and the digest of every secret
+that was active afterwards, so you can tell which value a release was using.
+Secret values are never recorded.`
+		short = `Show the local history of secret digests per release`
+		usage = "history [flags]"
+	)
+
+	cmd = command.New(usage, short, long, runHistory, command.RequireSession, command.RequireAppName)
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.JSONOutput(),
+		flag.String{
+			Name:        "name",
+			Description: "Only show changes affecting this secret",
+		},
+	)
+
+	return cmd
+}
+
+func runHistory(ctx context.Context) error {
+	appName := appconfig.NameFromContext(ctx)
+	out := iostreams.FromContext(ctx).Out
+
+	entries, err := loadHistory(ctx, appName)
+	if err != nil {
+		return err
+	}
+
+	if name := flag.GetString(ctx, "name"); name != "" {
+		filtered := entries[:0]
+		for _, entry := range entries {
+			if slices.Contains(entry.Names, name) {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+
+	if config.FromContext(ctx).JSONOutput {
+		return render.JSON(out, entries)
+	}
+
+	var rows [][]string
+	for _, entry := range entries {
+		var changed []string
+		for _, name := range entry.Names {
+			digest := entry.Digests[name]
+			if digest == "" {
+				digest = "unset"
+			}
+			changed = append(changed, fmt.Sprintf("%s=%s", name, digest))
+		}
+
+		release := ""
+		if entry.Release > 0 {
+			release = "v" + strconv.Itoa(entry.Release)
+		}
+
+		rows = append(rows, []string{
+			format.RelativeTime(entry.Time),
+			release,
+			entry.Action,
+			strings.Join(changed, ", "),
+		})
+	}
+
+	return render.Table(out, "", rows, "When", "Release", "Action", "Changed")
+}
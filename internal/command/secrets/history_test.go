@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/mock"
+	"github.com/superfly/flyctl/internal/state"
+)
+
+func Test_history(t *testing.T) {
+	digests := map[string]string{"DATABASE_URL": "d1"}
+	client := &mock.Client{
+		GetAppSecretsFunc: func(ctx context.Context, appName string) ([]fly.Secret, error) {
+			assert.Equal(t, "my-app", appName)
+			var secrets []fly.Secret
+			for name, digest := range digests {
+				secrets = append(secrets, fly.Secret{Name: name, Digest: digest})
+			}
+			return secrets, nil
+		},
+	}
+	ctx := flyutil.NewContextWithClient(context.Background(), client)
+	ctx = state.WithConfigDirectory(ctx, t.TempDir())
+
+	entries, err := loadHistory(ctx, "my-app")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	require.NoError(t, appendHistory(ctx, "my-app", "set", []string{"DATABASE_URL"}, &fly.Release{Version: 3}))
+	digests["API_KEY"] = "d2"
+	require.NoError(t, appendHistory(ctx, "my-app", "set", []string{"DATABASE_URL", "API_KEY"}, &fly.Release{Version: 4}))
+	require.NoError(t, appendHistory(ctx, "my-app", "deploy", nil, nil))
+
+	entries, err = loadHistory(ctx, "my-app")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, "set", entries[0].Action)
+	assert.Equal(t, 3, entries[0].Release)
+	assert.Equal(t, []string{"DATABASE_URL"}, entries[0].Names)
+	assert.Equal(t, map[string]string{"DATABASE_URL": "d1"}, entries[0].Digests)
+
+	assert.Equal(t, 4, entries[1].Release)
+	assert.Equal(t, []string{"API_KEY", "DATABASE_URL"}, entries[1].Names)
+	assert.Equal(t, map[string]string{"DATABASE_URL": "d1", "API_KEY": "d2"}, entries[1].Digests)
+
+	assert.Equal(t, "deploy", entries[2].Action)
+	assert.Zero(t, entries[2].Release)
+	assert.Empty(t, entries[2].Names)
+
+	info, err := os.Stat(historyPath(ctx, "my-app"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	entries, err = loadHistory(ctx, "other-app")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func Test_loadHistory_invalid(t *testing.T) {
+	ctx := state.WithConfigDirectory(context.Background(), t.TempDir())
+	path := historyPath(ctx, "my-app")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte("{\"action\":\"set\"}\n\nnot json\n"), 0o600))
+
+	_, err := loadHistory(ctx, "my-app")
+	assert.ErrorContains(t, err, "failed parsing")
+}
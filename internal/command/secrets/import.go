@@ -45,5 +45,5 @@ func runImport(ctx context.Context) (err error) {
 		return errors.New("requires at least one SECRET=VALUE pair")
 	}
 
-	return SetSecretsAndDeploy(ctx, app, secrets, flag.GetBool(ctx, "stage"), flag.GetBool(ctx, "detach"))
+	return setSecretsAndDeploy(ctx, app, "import", secrets, flag.GetBool(ctx, "stage"), flag.GetBool(ctx, "detach"))
 }
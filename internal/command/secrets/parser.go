@@ -12,6 +12,11 @@ const (
 	parserStateMultiline  = iota
 )
 
+// ParseSecrets reads NAME=VALUE pairs in the format accepted by 'fly secrets import'.
+func ParseSecrets(reader io.Reader) (map[string]string, error) {
+	return parseSecrets(reader)
+}
+
 func parseSecrets(reader io.Reader) (map[string]string, error) {
 	secrets := map[string]string{}
 	scanner := bufio.NewScanner(reader)
@@ -0,0 +1,314 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/helpers"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flyerr"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/prompt"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+// previousSuffix names the secret that holds the outgoing value while a
+// rotation is in progress.
+const previousSuffix = "_PREVIOUS"
+
+// revertCheckSuffix names the secret a value to revert to is briefly stored
+// in, to compare its digest with NAME_PREVIOUS before NAME is touched.
+const revertCheckSuffix = "_REVERT_CHECK"
+
+func newRotate() (cmd *cobra.Command) {
+	const (
+		long = `Rotate a secret without downtime. Rotation happens in stages:
+
+  start     sets NAME to the new value and keeps the current value in
+            NAME_PREVIOUS, then deploys. The application should accept
+            either value while the rotation is in progress.
+  finalize  removes NAME_PREVIOUS and deploys once nothing uses the old
+            value anymore.
+  revert    restores the old value to NAME, removes NAME_PREVIOUS and deploys.
+
+Secret values can't be read back, so the current value has to be provided when
+starting and reverting a rotation. Values are prompted for, or read from stdin
+as NAME=VALUE and NAME_PREVIOUS=VALUE lines.`
+		short = "Rotate a secret in stages, keeping the previous value available"
+	)
+
+	cmd = command.New("rotate", short, long, nil)
+
+	cmd.AddCommand(
+		newRotateStart(),
+		newRotateFinalize(),
+		newRotateRevert(),
+	)
+
+	return cmd
+}
+
+func newRotateStart() (cmd *cobra.Command) {
+	const (
+		long  = `Set a new value for NAME, keep the current value in NAME_PREVIOUS and deploy`
+		short = long
+		usage = "start [flags] NAME"
+	)
+
+	cmd = command.New(usage, short, long, runRotateStart, command.RequireSession, command.RequireAppName)
+	flag.Add(cmd, sharedFlags)
+	cmd.Args = cobra.ExactArgs(1)
+
+	return cmd
+}
+
+func newRotateFinalize() (cmd *cobra.Command) {
+	const (
+		long  = `Remove NAME_PREVIOUS once the new value of NAME is in use, and deploy`
+		short = long
+		usage = "finalize [flags] NAME"
+	)
+
+	cmd = command.New(usage, short, long, runRotateFinalize, command.RequireSession, command.RequireAppName)
+	flag.Add(cmd, sharedFlags)
+	cmd.Args = cobra.ExactArgs(1)
+
+	return cmd
+}
+
+func newRotateRevert() (cmd *cobra.Command) {
+	const (
+		long  = `Restore the previous value of NAME, remove NAME_PREVIOUS and deploy`
+		short = long
+		usage = "revert [flags] NAME"
+	)
+
+	cmd = command.New(usage, short, long, runRotateRevert, command.RequireSession, command.RequireAppName)
+	flag.Add(cmd,
+		sharedFlags,
+		flag.Yes(),
+	)
+	cmd.Args = cobra.ExactArgs(1)
+
+	return cmd
+}
+
+func runRotateStart(ctx context.Context) error {
+	var (
+		client = flyutil.ClientFromContext(ctx)
+		out    = iostreams.FromContext(ctx).Out
+		name   = flag.FirstArg(ctx)
+		prev   = name + previousSuffix
+	)
+
+	app, digests, err := rotationState(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := digests[name]; !ok {
+		return fmt.Errorf("secret %s is not set; use 'fly secrets set' instead", name)
+	}
+	if _, ok := digests[prev]; ok {
+		return flyerr.GenericErr{
+			Err:     fmt.Sprintf("a rotation of %s is already in progress", name),
+			Suggest: fmt.Sprintf("Run 'fly secrets rotate finalize %s' or 'fly secrets rotate revert %s' first", name, name),
+		}
+	}
+
+	values, err := readRotationValues(ctx,
+		rotationValue{prev, fmt.Sprintf("Current value of %s (kept as %s):", name, prev)},
+		rotationValue{name, fmt.Sprintf("New value for %s:", name)},
+	)
+	if err != nil {
+		return err
+	}
+
+	release, err := client.SetSecrets(ctx, app.Name, values)
+	if err != nil {
+		return err
+	}
+	recordHistory(ctx, app.Name, "rotate-start", []string{name, prev}, release)
+
+	fmt.Fprintf(out, "Started rotation of %s; the previous value is available as %s\n", name, prev)
+
+	return DeploySecrets(ctx, app, flag.GetBool(ctx, "stage"), flag.GetBool(ctx, "detach"))
+}
+
+func runRotateFinalize(ctx context.Context) error {
+	var (
+		client = flyutil.ClientFromContext(ctx)
+		out    = iostreams.FromContext(ctx).Out
+		name   = flag.FirstArg(ctx)
+		prev   = name + previousSuffix
+	)
+
+	app, digests, err := rotationState(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := digests[prev]; !ok {
+		return fmt.Errorf("no rotation of %s is in progress", name)
+	}
+
+	release, err := client.UnsetSecrets(ctx, app.Name, []string{prev})
+	if err != nil {
+		return err
+	}
+	recordHistory(ctx, app.Name, "rotate-finalize", []string{name, prev}, release)
+
+	fmt.Fprintf(out, "Finalized rotation of %s\n", name)
+
+	return DeploySecrets(ctx, app, flag.GetBool(ctx, "stage"), flag.GetBool(ctx, "detach"))
+}
+
+func runRotateRevert(ctx context.Context) error {
+	var (
+		client = flyutil.ClientFromContext(ctx)
+		io     = iostreams.FromContext(ctx)
+		name   = flag.FirstArg(ctx)
+		prev   = name + previousSuffix
+	)
+
+	app, digests, err := rotationState(ctx)
+	if err != nil {
+		return err
+	}
+
+	prevDigest, ok := digests[prev]
+	if !ok {
+		return fmt.Errorf("no rotation of %s is in progress", name)
+	}
+
+	values, err := readRotationValues(ctx,
+		rotationValue{name, fmt.Sprintf("Previous value of %s:", name)},
+	)
+	if err != nil {
+		return err
+	}
+
+	matches, err := matchesDigest(ctx, app.Name, name+revertCheckSuffix, values[name], prevDigest)
+	if err != nil {
+		return err
+	}
+	if !matches && !flag.GetYes(ctx) {
+		fmt.Fprintf(io.ErrOut, "The value provided for %s does not match the digest of %s.\n", name, prev)
+		switch confirmed, err := prompt.Confirm(ctx, "Continue reverting anyway?"); {
+		case err == nil:
+			if !confirmed {
+				return fmt.Errorf("revert of %s aborted and nothing was changed; run revert again with the correct value", name)
+			}
+		case prompt.IsNonInteractive(err):
+			return prompt.NonInteractiveError("yes flag must be specified when not running interactively")
+		default:
+			return err
+		}
+	}
+
+	if _, err := client.SetSecrets(ctx, app.Name, values); err != nil {
+		return err
+	}
+	release, err := client.UnsetSecrets(ctx, app.Name, []string{prev})
+	if err != nil {
+		return err
+	}
+	recordHistory(ctx, app.Name, "rotate-revert", []string{name, prev}, release)
+
+	fmt.Fprintf(io.Out, "Reverted %s to its previous value\n", name)
+
+	return DeploySecrets(ctx, app, flag.GetBool(ctx, "stage"), flag.GetBool(ctx, "detach"))
+}
+
+// matchesDigest reports whether value has the given digest. Digests are
+// computed by the API, so value is stored as the secret check for as long as
+// it takes to read its digest back.
+func matchesDigest(ctx context.Context, appName, check, value, digest string) (bool, error) {
+	client := flyutil.ClientFromContext(ctx)
+
+	if _, err := client.SetSecrets(ctx, appName, map[string]string{check: value}); err != nil {
+		return false, err
+	}
+	secrets, err := client.GetAppSecrets(ctx, appName)
+	if _, unsetErr := client.UnsetSecrets(ctx, appName, []string{check}); err == nil {
+		err = unsetErr
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, secret := range secrets {
+		if secret.Name == check {
+			return secret.Digest == digest, nil
+		}
+	}
+	return false, fmt.Errorf("failed to read the digest of %s", check)
+}
+
+// rotationState returns the app along with the digests of its secrets, keyed
+// by name.
+func rotationState(ctx context.Context) (*fly.AppCompact, map[string]string, error) {
+	client := flyutil.ClientFromContext(ctx)
+	appName := appconfig.NameFromContext(ctx)
+
+	app, err := client.GetAppCompact(ctx, appName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secrets, err := client.GetAppSecrets(ctx, appName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	digests := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		digests[secret.Name] = secret.Digest
+	}
+
+	return app, digests, nil
+}
+
+type rotationValue struct {
+	name   string
+	prompt string
+}
+
+// readRotationValues reads a value for each of the named secrets, either from
+// NAME=VALUE lines on stdin or by prompting in order.
+func readRotationValues(ctx context.Context, wanted ...rotationValue) (map[string]string, error) {
+	values := make(map[string]string, len(wanted))
+
+	if helpers.HasPipedStdin() {
+		parsed, err := parseSecrets(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse secrets from stdin: %w", err)
+		}
+		for _, w := range wanted {
+			v, ok := parsed[w.name]
+			if !ok {
+				return nil, fmt.Errorf("expected a value for %s on stdin", w.name)
+			}
+			values[w.name] = v
+		}
+		return values, nil
+	}
+
+	for _, w := range wanted {
+		var v string
+		if err := prompt.Password(ctx, &v, w.prompt, true); err != nil {
+			if prompt.IsNonInteractive(err) {
+				return nil, prompt.NonInteractiveError(fmt.Sprintf("provide %s as NAME=VALUE on stdin when not running interactively", w.name))
+			}
+			return nil, err
+		}
+		values[w.name] = v
+	}
+
+	return values, nil
+}
@@ -0,0 +1,121 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/mock"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+// withStdin replaces stdin with a pipe holding input for the duration of the
+// test.
+func withStdin(t *testing.T, input string) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	_, err = w.WriteString(input)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		r.Close()
+	})
+}
+
+func Test_readRotationValues_stdin(t *testing.T) {
+	wanted := []rotationValue{{"API_KEY_PREVIOUS", ""}, {"API_KEY", ""}}
+
+	withStdin(t, "API_KEY=new\nAPI_KEY_PREVIOUS=old\nOTHER=ignored\n")
+	values, err := readRotationValues(context.Background(), wanted...)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"API_KEY": "new", "API_KEY_PREVIOUS": "old"}, values)
+
+	withStdin(t, "API_KEY=new\n")
+	_, err = readRotationValues(context.Background(), wanted...)
+	assert.ErrorContains(t, err, "expected a value for API_KEY_PREVIOUS on stdin")
+}
+
+func Test_readRotationValues_nonInteractive(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	require.NoError(t, err)
+	stdin := os.Stdin
+	os.Stdin = devNull
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		devNull.Close()
+	})
+
+	ios, _, _, _ := iostreams.Test()
+	ctx := iostreams.NewContext(context.Background(), ios)
+
+	_, err = readRotationValues(ctx, rotationValue{"API_KEY", "New value for API_KEY:"})
+	assert.ErrorContains(t, err, "provide API_KEY as NAME=VALUE on stdin")
+}
+
+func Test_rotationState(t *testing.T) {
+	client := &mock.Client{
+		GetAppCompactFunc: func(ctx context.Context, appName string) (*fly.AppCompact, error) {
+			return &fly.AppCompact{Name: appName}, nil
+		},
+		GetAppSecretsFunc: func(ctx context.Context, appName string) ([]fly.Secret, error) {
+			return []fly.Secret{
+				{Name: "API_KEY", Digest: "new"},
+				{Name: "API_KEY_PREVIOUS", Digest: "old"},
+			}, nil
+		},
+	}
+	ctx := flyutil.NewContextWithClient(context.Background(), client)
+	ctx = appconfig.WithName(ctx, "my-app")
+
+	app, digests, err := rotationState(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "my-app", app.Name)
+	assert.Equal(t, map[string]string{"API_KEY": "new", "API_KEY_PREVIOUS": "old"}, digests)
+}
+
+func Test_matchesDigest(t *testing.T) {
+	// Digests are the values themselves here.
+	secrets := map[string]string{"API_KEY": "new", "API_KEY_PREVIOUS": "old"}
+	client := &mock.Client{
+		SetSecretsFunc: func(ctx context.Context, appName string, values map[string]string) (*fly.Release, error) {
+			for name, value := range values {
+				secrets[name] = value
+			}
+			return &fly.Release{}, nil
+		},
+		UnsetSecretsFunc: func(ctx context.Context, appName string, keys []string) (*fly.Release, error) {
+			for _, name := range keys {
+				delete(secrets, name)
+			}
+			return &fly.Release{}, nil
+		},
+		GetAppSecretsFunc: func(ctx context.Context, appName string) ([]fly.Secret, error) {
+			var list []fly.Secret
+			for name, value := range secrets {
+				list = append(list, fly.Secret{Name: name, Digest: value})
+			}
+			return list, nil
+		},
+	}
+	ctx := flyutil.NewContextWithClient(context.Background(), client)
+
+	matches, err := matchesDigest(ctx, "my-app", "API_KEY_REVERT_CHECK", "old", "old")
+	require.NoError(t, err)
+	assert.True(t, matches)
+
+	matches, err = matchesDigest(ctx, "my-app", "API_KEY_REVERT_CHECK", "typo", "old")
+	require.NoError(t, err)
+	assert.False(t, matches)
+
+	// The rotation is left as it was.
+	assert.Equal(t, map[string]string{"API_KEY": "new", "API_KEY_PREVIOUS": "old"}, secrets)
+}
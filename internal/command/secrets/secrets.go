@@ -43,7 +43,10 @@ func New() *cobra.Command {
 		newSet(),
 		newUnset(),
 		newImport(),
+		newSync(),
 		newDeploy(),
+		newRotate(),
+		newHistory(),
 		newKeys(),
 	)
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/spf13/cobra"
 	fly "github.com/superfly/fly-go"
@@ -67,10 +69,18 @@ func runSet(ctx context.Context) (err error) {
 }
 
 func SetSecretsAndDeploy(ctx context.Context, app *fly.AppCompact, secrets map[string]string, stage bool, detach bool) error {
+	return setSecretsAndDeploy(ctx, app, "set", secrets, stage, detach)
+}
+
+// setSecretsAndDeploy is SetSecretsAndDeploy, recording the change in the
+// secrets history as action.
+func setSecretsAndDeploy(ctx context.Context, app *fly.AppCompact, action string, secrets map[string]string, stage bool, detach bool) error {
 	client := flyutil.ClientFromContext(ctx)
-	if _, err := client.SetSecrets(ctx, app.Name, secrets); err != nil {
+	release, err := client.SetSecrets(ctx, app.Name, secrets)
+	if err != nil {
 		return err
 	}
+	recordHistory(ctx, app.Name, action, slices.Collect(maps.Keys(secrets)), release)
 
 	return DeploySecrets(ctx, app, stage, detach)
 }
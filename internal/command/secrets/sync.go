@@ -0,0 +1,340 @@
+package secrets
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/prompt"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/internal/state"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+// syncStateDir is the directory, relative to the config directory, holding
+// what each app's secrets looked like after the last sync from this host.
+const syncStateDir = "secrets-sync"
+
+// syncState lets sync tell whether a secret needs updating without reading
+// its value back. For each synced secret it records the digest the platform
+// reported and a keyed hash of the value that was written. A secret is
+// unchanged when both the platform digest and the source value hash still
+// match. The key is random per app so the hashes can't be compared across
+// files.
+type syncState struct {
+	Key     string                     `json:"key"`
+	Secrets map[string]syncStateSecret `json:"secrets"`
+}
+
+type syncStateSecret struct {
+	Digest    string `json:"digest"`
+	ValueHash string `json:"value_hash"`
+}
+
+func syncStatePath(ctx context.Context, appName string) string {
+	return filepath.Join(state.ConfigDirectory(ctx), syncStateDir, appName+".json")
+}
+
+func loadSyncState(ctx context.Context, appName string) (*syncState, error) {
+	st := &syncState{Secrets: map[string]syncStateSecret{}}
+
+	data, err := os.ReadFile(syncStatePath(ctx, appName))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, st); err != nil {
+			return nil, fmt.Errorf("failed parsing secrets sync state: %w", err)
+		}
+	}
+
+	if st.Key == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		st.Key = hex.EncodeToString(key)
+		st.Secrets = map[string]syncStateSecret{}
+	}
+	if st.Secrets == nil {
+		st.Secrets = map[string]syncStateSecret{}
+	}
+
+	return st, nil
+}
+
+func (st *syncState) save(ctx context.Context, appName string) error {
+	path := syncStatePath(ctx, appName)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
+
+func (st *syncState) hash(value string) string {
+	mac := hmac.New(sha256.New, []byte(st.Key))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// syncPlan is the set of changes needed to make an app's secrets match a source.
+type syncPlan struct {
+	Create    []string `json:"create"`
+	Update    []string `json:"update"`
+	Delete    []string `json:"delete"`
+	Unchanged []string `json:"unchanged"`
+}
+
+func (p *syncPlan) empty() bool {
+	return len(p.Create) == 0 && len(p.Update) == 0 && len(p.Delete) == 0
+}
+
+// planSync compares the source values against the app's current digests.
+// Secrets without a sync record, or whose digest changed since the last sync,
+// are always updated since their value can't be compared.
+func planSync(st *syncState, source, digests map[string]string, prune bool) *syncPlan {
+	plan := &syncPlan{}
+
+	for name, value := range source {
+		digest, exists := digests[name]
+		rec, synced := st.Secrets[name]
+		switch {
+		case !exists:
+			plan.Create = append(plan.Create, name)
+		case synced && rec.Digest == digest && rec.ValueHash == st.hash(value):
+			plan.Unchanged = append(plan.Unchanged, name)
+		default:
+			plan.Update = append(plan.Update, name)
+		}
+	}
+
+	if prune {
+		for name := range digests {
+			if _, ok := source[name]; !ok && !managedSecret(name, digests) {
+				plan.Delete = append(plan.Delete, name)
+			}
+		}
+	}
+
+	sort.Strings(plan.Create)
+	sort.Strings(plan.Update)
+	sort.Strings(plan.Delete)
+	sort.Strings(plan.Unchanged)
+
+	return plan
+}
+
+// reviewRecordSecret is where fly review create records what a review app
+// is a copy of.
+const reviewRecordSecret = "FLY_REVIEW_APP"
+
+// managedSecret reports whether flyctl manages the secret itself, so that
+// --prune leaves it alone: the previous value of a rotation in progress, the
+// value rotate revert is checking, or a review app's record.
+func managedSecret(name string, digests map[string]string) bool {
+	if name == reviewRecordSecret {
+		return true
+	}
+	if base, ok := strings.CutSuffix(name, previousSuffix); ok {
+		_, rotating := digests[base]
+		return rotating
+	}
+	return strings.HasSuffix(name, revertCheckSuffix)
+}
+
+func newSync() (cmd *cobra.Command) {
+	const (
+		long = `Sync secrets from an external secret store. Only secrets that changed since the
+last sync from this machine are set, and with --prune, secrets that are not in
+the source are removed, except for the previous values of rotations in progress. Supported sources:
+
+  sops://path/to/secrets.enc.yaml   decrypted locally with the sops CLI, using
+                                    your age or PGP keys
+  vault://mount/path/to/secret      HashiCorp Vault KV v2 secret, using VAULT_ADDR,
+                                    VAULT_TOKEN and VAULT_NAMESPACE
+  op://vault/item                   fields of an item, read with a 1Password
+                                    compatible CLI
+  file://path/to/.env               plain NAME=VALUE pairs`
+		short = "Sync secrets from an external secret store"
+		usage = "sync [flags]"
+	)
+
+	cmd = command.New(usage, short, long, runSync, command.RequireSession, command.RequireAppName)
+
+	flag.Add(cmd,
+		sharedFlags,
+		flag.Yes(),
+		flag.String{
+			Name:        "from",
+			Description: "URL of the secret source, e.g. sops://secrets.enc.yaml or vault://secret/myapp",
+		},
+		flag.Bool{
+			Name:        "prune",
+			Description: "Remove secrets that are not present in the source",
+		},
+		flag.Bool{
+			Name:        "dry-run",
+			Description: "Show the changes without applying them",
+		},
+		flag.JSONOutput(),
+	)
+	cmd.Args = cobra.NoArgs
+
+	return cmd
+}
+
+func runSync(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		client  = flyutil.ClientFromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+	)
+
+	from := flag.GetString(ctx, "from")
+	if from == "" {
+		return errors.New("--from is required")
+	}
+
+	source, err := parseSecretSource(from)
+	if err != nil {
+		return err
+	}
+
+	values, err := source.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed loading secrets from %s: %w", source.Describe(), err)
+	}
+
+	app, digests, err := rotationState(ctx)
+	if err != nil {
+		return err
+	}
+
+	st, err := loadSyncState(ctx, appName)
+	if err != nil {
+		return err
+	}
+
+	plan := planSync(st, values, digests, flag.GetBool(ctx, "prune"))
+
+	if config.FromContext(ctx).JSONOutput {
+		if err := render.JSON(io.Out, plan); err != nil {
+			return err
+		}
+	} else {
+		printSyncPlan(io, source, plan)
+	}
+
+	if plan.empty() || flag.GetBool(ctx, "dry-run") {
+		return nil
+	}
+
+	if len(plan.Delete) > 0 && !flag.GetYes(ctx) {
+		switch confirmed, err := prompt.Confirmf(ctx, "Remove %d secret(s) not present in %s?", len(plan.Delete), source.Describe()); {
+		case err == nil:
+			if !confirmed {
+				fmt.Fprintf(io.Out, "Keeping %d secret(s) not present in %s\n", len(plan.Delete), source.Describe())
+				plan.Delete = nil
+			}
+		case prompt.IsNonInteractive(err):
+			return prompt.NonInteractiveError("yes flag must be specified when not running interactively")
+		default:
+			return err
+		}
+	}
+
+	if plan.empty() {
+		fmt.Fprintln(io.Out, "Nothing was changed")
+		return nil
+	}
+
+	changed := slices.Concat(plan.Create, plan.Update)
+	if len(changed) > 0 {
+		toSet := make(map[string]string, len(changed))
+		for _, name := range changed {
+			toSet[name] = values[name]
+		}
+		release, err := client.SetSecrets(ctx, app.Name, toSet)
+		if err != nil {
+			return err
+		}
+		recordHistory(ctx, app.Name, "sync", changed, release)
+	}
+
+	if len(plan.Delete) > 0 {
+		release, err := client.UnsetSecrets(ctx, app.Name, plan.Delete)
+		if err != nil {
+			return err
+		}
+		recordHistory(ctx, app.Name, "sync-prune", plan.Delete, release)
+	}
+
+	if err := updateSyncState(ctx, st, appName, values); err != nil {
+		fmt.Fprintf(io.ErrOut, "Warning: failed saving secrets sync state, the next sync will update every secret: %v\n", err)
+	}
+
+	return DeploySecrets(ctx, app, flag.GetBool(ctx, "stage"), flag.GetBool(ctx, "detach"))
+}
+
+func updateSyncState(ctx context.Context, st *syncState, appName string, values map[string]string) error {
+	_, digests, err := rotationState(ctx)
+	if err != nil {
+		return err
+	}
+
+	st.Secrets = make(map[string]syncStateSecret, len(values))
+	for name, value := range values {
+		st.Secrets[name] = syncStateSecret{
+			Digest:    digests[name],
+			ValueHash: st.hash(value),
+		}
+	}
+
+	return st.save(ctx, appName)
+}
+
+func printSyncPlan(io *iostreams.IOStreams, source secretSource, plan *syncPlan) {
+	if plan.empty() {
+		fmt.Fprintf(io.Out, "Secrets are in sync with %s\n", source.Describe())
+		return
+	}
+
+	cs := io.ColorScheme()
+	fmt.Fprintf(io.Out, "Changes from %s:\n", source.Describe())
+	for _, name := range plan.Create {
+		fmt.Fprintln(io.Out, cs.Green("  + "+name))
+	}
+	for _, name := range plan.Update {
+		fmt.Fprintln(io.Out, cs.Yellow("  ~ "+name))
+	}
+	for _, name := range plan.Delete {
+		fmt.Fprintln(io.Out, cs.Red("  - "+name))
+	}
+	if n := len(plan.Unchanged); n > 0 {
+		fmt.Fprintf(io.Out, "  %d unchanged\n", n)
+	}
+}
@@ -0,0 +1,252 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// secretSource is an external store that secrets can be synced from.
+type secretSource interface {
+	// Describe returns a human readable description of the source.
+	Describe() string
+	// Load returns the secrets held by the source, keyed by name.
+	Load(ctx context.Context) (map[string]string, error)
+}
+
+// parseSecretSource resolves a source URL passed to `fly secrets sync --from`.
+//
+//	sops://path/to/secrets.enc.yaml   decrypted locally with the sops CLI
+//	vault://mount/path/to/secret      HashiCorp Vault KV v2, using VAULT_ADDR and VAULT_TOKEN
+//	op://vault/item                   1Password CLI compatible item fields
+//	file://path/to/.env               plain NAME=VALUE file
+func parseSecretSource(raw string) (secretSource, error) {
+	scheme, rest, ok := strings.Cut(raw, "://")
+	if !ok || rest == "" {
+		return nil, fmt.Errorf("invalid secret source %q; expected a URL such as sops://secrets.enc.yaml", raw)
+	}
+
+	switch scheme {
+	case "sops":
+		return &sopsSource{path: rest}, nil
+	case "file":
+		return &fileSource{path: rest}, nil
+	case "vault":
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		path := strings.Trim(u.Path, "/")
+		if u.Host == "" || path == "" {
+			return nil, fmt.Errorf("invalid vault source %q; expected vault://<mount>/<path>", raw)
+		}
+		var version int
+		if v := u.Query().Get("version"); v != "" {
+			if version, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("invalid vault secret version %q", v)
+			}
+		}
+		return &vaultSource{mount: u.Host, path: path, version: version}, nil
+	case "op":
+		vault, item, ok := strings.Cut(rest, "/")
+		if !ok || vault == "" || item == "" {
+			return nil, fmt.Errorf("invalid 1Password source %q; expected op://<vault>/<item>", raw)
+		}
+		return &opSource{vault: vault, item: item}, nil
+	default:
+		return nil, fmt.Errorf("unsupported secret source %q; supported sources are sops, vault, op and file", scheme)
+	}
+}
+
+// sopsSource decrypts a sops encrypted file with the sops CLI, so age and PGP
+// keys are resolved the same way they are for `sops --decrypt`.
+type sopsSource struct {
+	path string
+}
+
+func (s *sopsSource) Describe() string {
+	return "sops file " + s.path
+}
+
+func (s *sopsSource) Load(ctx context.Context) (map[string]string, error) {
+	bin := os.Getenv("FLY_SOPS_PATH")
+	if bin == "" {
+		bin = "sops"
+	}
+
+	out, err := runSourceCLI(ctx, bin, "--decrypt", "--output-type", "json", s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return nil, fmt.Errorf("failed parsing decrypted %s: %w", s.path, err)
+	}
+	delete(doc, "sops")
+
+	return flattenSecretValues(doc)
+}
+
+// fileSource reads unencrypted NAME=VALUE pairs, as accepted by `fly secrets import`.
+type fileSource struct {
+	path string
+}
+
+func (s *fileSource) Describe() string {
+	return "file " + s.path
+}
+
+func (s *fileSource) Load(context.Context) (map[string]string, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // skipcq: GO-S2307
+
+	return parseSecrets(f)
+}
+
+// vaultSource reads a secret from a HashiCorp Vault KV version 2 engine.
+type vaultSource struct {
+	mount   string
+	path    string
+	version int
+}
+
+func (s *vaultSource) Describe() string {
+	return fmt.Sprintf("vault secret %s/%s", s.mount, s.path)
+}
+
+func (s *vaultSource) Load(ctx context.Context) (map[string]string, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	token := os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return nil, errors.New("VAULT_ADDR and VAULT_TOKEN must be set to sync from vault")
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimRight(addr, "/"), url.PathEscape(s.mount), s.path)
+	if s.version > 0 {
+		endpoint += "?version=" + strconv.Itoa(s.version)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // skipcq: GO-S2307
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %s reading %s", resp.Status, s.Describe())
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed decoding vault response: %w", err)
+	}
+
+	return flattenSecretValues(body.Data.Data)
+}
+
+// opSource reads the fields of an item with a 1Password compatible CLI. The
+// binary can be overridden with FLY_OP_PATH.
+type opSource struct {
+	vault string
+	item  string
+}
+
+func (s *opSource) Describe() string {
+	return fmt.Sprintf("1Password item %s/%s", s.vault, s.item)
+}
+
+func (s *opSource) Load(ctx context.Context) (map[string]string, error) {
+	bin := os.Getenv("FLY_OP_PATH")
+	if bin == "" {
+		bin = "op"
+	}
+
+	out, err := runSourceCLI(ctx, bin, "item", "get", s.item, "--vault", s.vault, "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var item struct {
+		Fields []struct {
+			Label string `json:"label"`
+			Value string `json:"value"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(out, &item); err != nil {
+		return nil, fmt.Errorf("failed parsing %s: %w", s.Describe(), err)
+	}
+
+	secrets := map[string]string{}
+	for _, field := range item.Fields {
+		if field.Label == "" || field.Value == "" {
+			continue
+		}
+		secrets[field.Label] = field.Value
+	}
+
+	return secrets, nil
+}
+
+func runSourceCLI(ctx context.Context, bin string, args ...string) ([]byte, error) {
+	path, err := exec.LookPath(bin)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be installed and in your PATH: %w", bin, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", bin, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// flattenSecretValues converts a decoded document with scalar values into
+// secrets. Nested values can't be represented as a single secret and are
+// rejected.
+func flattenSecretValues(doc map[string]any) (map[string]string, error) {
+	secrets := make(map[string]string, len(doc))
+	for name, v := range doc {
+		switch v := v.(type) {
+		case string:
+			secrets[name] = v
+		case float64:
+			secrets[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			secrets[name] = strconv.FormatBool(v)
+		case nil:
+			secrets[name] = ""
+		default:
+			return nil, fmt.Errorf("secret %s has a nested value; only flat NAME: value documents are supported", name)
+		}
+	}
+	return secrets, nil
+}
@@ -0,0 +1,63 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseSecretSource(t *testing.T) {
+	src, err := parseSecretSource("sops://config/secrets.enc.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, &sopsSource{path: "config/secrets.enc.yaml"}, src)
+
+	src, err = parseSecretSource("vault://secret/apps/web?version=3")
+	require.NoError(t, err)
+	assert.Equal(t, &vaultSource{mount: "secret", path: "apps/web", version: 3}, src)
+
+	src, err = parseSecretSource("op://Production/web")
+	require.NoError(t, err)
+	assert.Equal(t, &opSource{vault: "Production", item: "web"}, src)
+
+	for _, raw := range []string{"secrets.yaml", "vault://secret", "op://Production", "s3://bucket/key"} {
+		_, err := parseSecretSource(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func Test_planSync(t *testing.T) {
+	st := &syncState{Key: "test", Secrets: map[string]syncStateSecret{}}
+	st.Secrets["SAME"] = syncStateSecret{Digest: "d1", ValueHash: st.hash("same")}
+	st.Secrets["EDITED"] = syncStateSecret{Digest: "d2", ValueHash: st.hash("old")}
+	st.Secrets["DRIFTED"] = syncStateSecret{Digest: "d3", ValueHash: st.hash("drifted")}
+
+	source := map[string]string{
+		"SAME":    "same",
+		"EDITED":  "new",
+		"DRIFTED": "drifted",
+		"UNKNOWN": "value",
+		"NEW":     "value",
+	}
+	digests := map[string]string{
+		"SAME":    "d1",
+		"EDITED":  "d2",
+		"DRIFTED": "changed-outside-sync",
+		"UNKNOWN": "d4",
+		"EXTRA":   "d5",
+		// Rotations in progress are managed by flyctl.
+		"SAME_PREVIOUS":     "d6",
+		"GONE_PREVIOUS":     "d7",
+		"SAME_REVERT_CHECK": "d8",
+		"FLY_REVIEW_APP":    "d9",
+	}
+
+	plan := planSync(st, source, digests, false)
+	assert.Equal(t, []string{"NEW"}, plan.Create)
+	assert.Equal(t, []string{"DRIFTED", "EDITED", "UNKNOWN"}, plan.Update)
+	assert.Equal(t, []string{"SAME"}, plan.Unchanged)
+	assert.Empty(t, plan.Delete)
+
+	plan = planSync(st, source, digests, true)
+	assert.Equal(t, []string{"EXTRA", "GONE_PREVIOUS"}, plan.Delete)
+}
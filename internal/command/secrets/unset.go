@@ -42,9 +42,11 @@ func runUnset(ctx context.Context) (err error) {
 
 func UnsetSecretsAndDeploy(ctx context.Context, app *fly.AppCompact, secrets []string, stage bool, detach bool) error {
 	client := flyutil.ClientFromContext(ctx)
-	if _, err := client.UnsetSecrets(ctx, app.Name, secrets); err != nil {
+	release, err := client.UnsetSecrets(ctx, app.Name, secrets)
+	if err != nil {
 		return err
 	}
+	recordHistory(ctx, app.Name, "unset", secrets, release)
 
 	return DeploySecrets(ctx, app, stage, detach)
 }
@@ -0,0 +1,470 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/azazeal/pause"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/logs"
+)
+
+const (
+	dashboardEventCount = 50
+	dashboardLogLines   = 1000
+)
+
+// dashboard is the full-screen view of `fly status --watch`: machines per
+// process group with their checks, recent machine events, a live log tail and
+// the current release, with keys to act on the selected machine.
+type dashboard struct {
+	ctx     context.Context
+	appName string
+	client  flyutil.Client
+	flaps   flapsutil.FlapsClient
+
+	ui       *tview.Application
+	pages    *tview.Pages
+	header   *tview.TextView
+	machines *tview.Table
+	events   *tview.TextView
+	logs     *tview.TextView
+	footer   *tview.TextView
+
+	// rows are the machines in table order. Like the widgets, they're only
+	// touched on the UI goroutine.
+	rows []*fly.Machine
+}
+
+func runDashboard(ctx context.Context, rate time.Duration) error {
+	var (
+		appName = appconfig.NameFromContext(ctx)
+		client  = flyutil.ClientFromContext(ctx)
+	)
+
+	app, err := client.GetAppCompact(ctx, appName)
+	if err != nil {
+		return fmt.Errorf("failed to get app: %w", err)
+	}
+
+	flapsClient, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+		AppCompact: app,
+		AppName:    app.Name,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	d := newDashboard(ctx, appName, client, flapsClient)
+
+	go d.refreshLoop(rate)
+	go d.tailLogs()
+
+	return d.ui.Run()
+}
+
+func newDashboard(ctx context.Context, appName string, client flyutil.Client, flapsClient flapsutil.FlapsClient) *dashboard {
+	d := &dashboard{
+		ctx:     ctx,
+		appName: appName,
+		client:  client,
+		flaps:   flapsClient,
+		ui:      tview.NewApplication(),
+		pages:   tview.NewPages(),
+		header:  tview.NewTextView().SetDynamicColors(true),
+		machines: tview.NewTable().
+			SetSelectable(true, false).
+			SetFixed(1, 0),
+		events: tview.NewTextView().SetDynamicColors(true).SetScrollable(true),
+		logs: tview.NewTextView().SetDynamicColors(true).SetScrollable(true).
+			SetMaxLines(dashboardLogLines),
+		footer: tview.NewTextView().SetDynamicColors(true),
+	}
+
+	d.machines.SetBorder(true).SetTitle(" Machines ")
+	d.events.SetBorder(true).SetTitle(" Events ")
+	d.logs.SetBorder(true).SetTitle(" Logs ")
+	d.logs.SetChangedFunc(func() { d.logs.ScrollToEnd() })
+	d.setFooter("")
+
+	middle := tview.NewFlex().
+		AddItem(d.machines, 0, 3, true).
+		AddItem(d.events, 0, 2, false)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(d.header, 3, 0, false).
+		AddItem(middle, 0, 1, true).
+		AddItem(d.logs, 0, 1, false).
+		AddItem(d.footer, 1, 0, false)
+
+	d.pages.AddPage("main", layout, true, true)
+	d.machines.SetInputCapture(d.handleKey)
+	d.ui.SetRoot(d.pages, true)
+
+	return d
+}
+
+func (d *dashboard) setFooter(msg string) {
+	keys := "[::b]↑/↓[::-] select  [::b]r[::-] restart  [::b]s[::-] stop  [::b]u[::-] start  [::b]c[::-] console  [::b]q[::-] quit"
+	if msg != "" {
+		keys = msg + "  " + keys
+	}
+	d.footer.SetText(keys)
+}
+
+func (d *dashboard) refreshLoop(rate time.Duration) {
+	for d.ctx.Err() == nil {
+		d.refresh()
+		pause.For(d.ctx, rate)
+	}
+}
+
+func (d *dashboard) refresh() {
+	machines, err := d.flaps.ListActive(d.ctx)
+	if err != nil {
+		if d.ctx.Err() == nil {
+			d.ui.QueueUpdateDraw(func() {
+				d.setFooter(fmt.Sprintf("[red]failed listing machines: %v[-]", err))
+			})
+		}
+		return
+	}
+
+	// The release is informational; keep going without it.
+	release, _ := d.client.GetAppCurrentReleaseMachines(d.ctx, d.appName)
+
+	rows := dashboardRows(machines)
+	events := recentMachineEvents(machines, dashboardEventCount)
+	header := dashboardHeader(d.appName, release, rows, time.Now())
+
+	d.ui.QueueUpdateDraw(func() {
+		selected := ""
+		if m := d.selected(); m != nil {
+			selected = m.ID
+		}
+		d.rows = rows
+
+		d.header.SetText(header)
+		d.renderMachines(rows, selected)
+		d.renderEvents(events)
+	})
+}
+
+func (d *dashboard) renderMachines(rows []*fly.Machine, selected string) {
+	d.machines.Clear()
+	for col, title := range []string{"Process", "ID", "Version", "Region", "State", "Checks", "Last Updated"} {
+		d.machines.SetCell(0, col, tview.NewTableCell(title).
+			SetAttributes(tcell.AttrBold).
+			SetSelectable(false))
+	}
+
+	selectedRow := 1
+	for i, m := range rows {
+		row := i + 1
+		if m.ID == selected {
+			selectedRow = row
+		}
+		cells := []string{
+			getProcessgroup(m),
+			m.ID,
+			getReleaseVersion(m),
+			m.Region,
+			m.State,
+			render.MachineHealthChecksSummary(m),
+			m.UpdatedAt,
+		}
+		for col, text := range cells {
+			cell := tview.NewTableCell(text)
+			if col == 4 {
+				cell.SetTextColor(stateColor(m.State))
+			}
+			d.machines.SetCell(row, col, cell)
+		}
+	}
+
+	if len(rows) > 0 {
+		d.machines.Select(selectedRow, 0)
+	}
+}
+
+func (d *dashboard) renderEvents(events []machineEvent) {
+	var b strings.Builder
+	for _, e := range events {
+		fmt.Fprintf(&b, "[gray]%s[-] %s %s", e.Time.Format("15:04:05"), e.MachineID, e.Type)
+		if e.Status != "" {
+			fmt.Fprintf(&b, " (%s)", e.Status)
+		}
+		if e.Source != "" {
+			fmt.Fprintf(&b, " [gray]by %s[-]", e.Source)
+		}
+		b.WriteString("\n")
+	}
+	d.events.SetText(b.String())
+}
+
+func (d *dashboard) tailLogs() {
+	entries := make(chan logs.LogEntry)
+	go func() {
+		defer close(entries)
+		_ = logs.Poll(d.ctx, entries, d.client, &logs.LogOptions{AppName: d.appName})
+	}()
+
+	w := tview.ANSIWriter(d.logs)
+	for entry := range entries {
+		var buf bytes.Buffer
+		if err := render.LogEntry(&buf, entry, render.HideAllocID(), render.RemoveNewlines()); err != nil {
+			continue
+		}
+		d.ui.QueueUpdateDraw(func() {
+			w.Write(buf.Bytes())
+		})
+	}
+}
+
+func (d *dashboard) selected() *fly.Machine {
+	row, _ := d.machines.GetSelection()
+	if row < 1 || row > len(d.rows) {
+		return nil
+	}
+	return d.rows[row-1]
+}
+
+func (d *dashboard) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyCtrlC || event.Rune() == 'q' {
+		d.ui.Stop()
+		return nil
+	}
+
+	m := d.selected()
+	if m == nil {
+		return event
+	}
+
+	switch event.Rune() {
+	case 'r':
+		d.confirm(fmt.Sprintf("Restart machine %s?", m.ID), func() error {
+			return d.flaps.Restart(d.ctx, fly.RestartMachineInput{ID: m.ID}, "")
+		}, "Restarted "+m.ID)
+	case 's':
+		d.confirm(fmt.Sprintf("Stop machine %s?", m.ID), func() error {
+			return d.flaps.Stop(d.ctx, fly.StopMachineInput{ID: m.ID}, "")
+		}, "Stopped "+m.ID)
+	case 'u':
+		d.act(func() error {
+			_, err := d.flaps.Start(d.ctx, m.ID, "")
+			return err
+		}, "Started "+m.ID)
+	case 'c':
+		d.console(m)
+	default:
+		return event
+	}
+	return nil
+}
+
+func (d *dashboard) confirm(question string, action func() error, done string) {
+	modal := tview.NewModal().
+		SetText(question).
+		AddButtons([]string{"Yes", "No"}).
+		SetDoneFunc(func(_ int, label string) {
+			d.pages.RemovePage("confirm")
+			d.ui.SetFocus(d.machines)
+			if label == "Yes" {
+				d.act(action, done)
+			}
+		})
+	d.pages.AddPage("confirm", modal, true, true)
+	d.ui.SetFocus(modal)
+}
+
+// act runs action off the UI goroutine and reports the outcome in the footer.
+func (d *dashboard) act(action func() error, done string) {
+	d.setFooter("[yellow]working…[-]")
+	go func() {
+		msg := "[green]" + done + "[-]"
+		if err := action(); err != nil {
+			msg = fmt.Sprintf("[red]%v[-]", err)
+		}
+		d.ui.QueueUpdateDraw(func() { d.setFooter(msg) })
+		d.refresh()
+	}()
+}
+
+// console suspends the dashboard and runs `fly ssh console` against m.
+func (d *dashboard) console(m *fly.Machine) {
+	exe, err := os.Executable()
+	if err != nil {
+		d.setFooter(fmt.Sprintf("[red]%v[-]", err))
+		return
+	}
+
+	var runErr error
+	d.ui.Suspend(func() {
+		cmd := exec.CommandContext(d.ctx, exe, "ssh", "console", "--app", d.appName, "--machine", m.ID)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		runErr = cmd.Run()
+	})
+
+	var exitErr *exec.ExitError
+	if runErr != nil && !errors.As(runErr, &exitErr) {
+		d.setFooter(fmt.Sprintf("[red]%v[-]", runErr))
+	}
+}
+
+// dashboardRows orders machines by process group, then region and ID.
+func dashboardRows(machines []*fly.Machine) []*fly.Machine {
+	rows := make([]*fly.Machine, 0, len(machines))
+	for _, m := range machines {
+		if m.IsAppsV2() {
+			rows = append(rows, m)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if ga, gb := a.ProcessGroup(), b.ProcessGroup(); ga != gb {
+			return ga < gb
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		return a.ID < b.ID
+	})
+	return rows
+}
+
+func dashboardHeader(appName string, release *fly.Release, rows []*fly.Machine, now time.Time) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "[::b]%s[::-]", appName)
+	if release != nil {
+		fmt.Fprintf(&b, "  release v%d %s", release.Version, release.Status)
+		if release.User.Email != "" {
+			fmt.Fprintf(&b, " by %s", release.User.Email)
+		}
+		if !release.CreatedAt.IsZero() {
+			fmt.Fprintf(&b, " at %s", release.CreatedAt.UTC().Format(time.RFC3339))
+		}
+	}
+	fmt.Fprintf(&b, "  [gray]updated %s[-]\n", now.UTC().Format("15:04:05"))
+
+	for _, g := range processGroupSummaries(rows) {
+		fmt.Fprintf(&b, "%s: %s  ", g.Name, g.States)
+		if g.Checks != "" {
+			fmt.Fprintf(&b, "[gray](checks %s)[-]  ", g.Checks)
+		}
+	}
+
+	return b.String()
+}
+
+type processGroupSummary struct {
+	Name   string
+	States string
+	Checks string
+}
+
+// processGroupSummaries counts the machine states per process group, such as
+// "2 started, 1 stopped".
+func processGroupSummaries(rows []*fly.Machine) []processGroupSummary {
+	var (
+		names    []string
+		machines = map[string][]*fly.Machine{}
+	)
+	for _, m := range rows {
+		group := m.ProcessGroup()
+		if group == "" {
+			group = "<default>"
+		}
+		if _, ok := machines[group]; !ok {
+			names = append(names, group)
+		}
+		machines[group] = append(machines[group], m)
+	}
+	sort.Strings(names)
+
+	summaries := make([]processGroupSummary, 0, len(names))
+	for _, name := range names {
+		counts := map[string]int{}
+		var states []string
+		for _, m := range machines[name] {
+			if counts[m.State] == 0 {
+				states = append(states, m.State)
+			}
+			counts[m.State]++
+		}
+		sort.Strings(states)
+
+		parts := make([]string, 0, len(states))
+		for _, s := range states {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[s], s))
+		}
+		summaries = append(summaries, processGroupSummary{
+			Name:   name,
+			States: strings.Join(parts, ", "),
+			Checks: render.MachineHealthChecksSummary(machines[name]...),
+		})
+	}
+	return summaries
+}
+
+type machineEvent struct {
+	MachineID string
+	Type      string
+	Status    string
+	Source    string
+	Time      time.Time
+}
+
+// recentMachineEvents merges the events of all machines, newest first.
+func recentMachineEvents(machines []*fly.Machine, limit int) []machineEvent {
+	var events []machineEvent
+	for _, m := range machines {
+		for _, e := range m.Events {
+			events = append(events, machineEvent{
+				MachineID: m.ID,
+				Type:      e.Type,
+				Status:    e.Status,
+				Source:    e.Source,
+				Time:      e.Time(),
+			})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.After(events[j].Time)
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events
+}
+
+func stateColor(state string) tcell.Color {
+	switch state {
+	case fly.MachineStateStarted:
+		return tcell.ColorGreen
+	case fly.MachineStateStopped, "suspended":
+		return tcell.ColorGray
+	case "failed", "replacing", "destroying":
+		return tcell.ColorRed
+	default:
+		return tcell.ColorYellow
+	}
+}
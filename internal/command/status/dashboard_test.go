@@ -0,0 +1,65 @@
+package status
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+)
+
+func dashboardMachine(id, group, region, state string, events ...*fly.MachineEvent) *fly.Machine {
+	return &fly.Machine{
+		ID:     id,
+		Region: region,
+		State:  state,
+		Events: events,
+		Config: &fly.MachineConfig{
+			Metadata: map[string]string{
+				fly.MachineConfigMetadataKeyFlyPlatformVersion: fly.MachineFlyPlatformVersion2,
+				fly.MachineConfigMetadataKeyFlyProcessGroup:    group,
+			},
+		},
+	}
+}
+
+func TestDashboardRows(t *testing.T) {
+	machines := []*fly.Machine{
+		dashboardMachine("m3", "worker", "iad", "started"),
+		dashboardMachine("m2", "app", "iad", "stopped"),
+		dashboardMachine("m1", "app", "ams", "started"),
+		{ID: "unmanaged", Config: &fly.MachineConfig{}},
+	}
+
+	rows := dashboardRows(machines)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"m1", "m2", "m3"}, []string{rows[0].ID, rows[1].ID, rows[2].ID})
+
+	summaries := processGroupSummaries(rows)
+	assert.Equal(t, []processGroupSummary{
+		{Name: "app", States: "1 started, 1 stopped"},
+		{Name: "worker", States: "1 started"},
+	}, summaries)
+}
+
+func TestRecentMachineEvents(t *testing.T) {
+	at := func(sec int64) int64 { return time.Unix(sec, 0).UnixMilli() }
+
+	machines := []*fly.Machine{
+		dashboardMachine("m1", "app", "iad", "started",
+			&fly.MachineEvent{Type: "start", Status: "started", Timestamp: at(30)},
+			&fly.MachineEvent{Type: "launch", Status: "created", Timestamp: at(10)},
+		),
+		dashboardMachine("m2", "app", "iad", "stopped",
+			&fly.MachineEvent{Type: "exit", Source: "flyd", Timestamp: at(20)},
+		),
+	}
+
+	events := recentMachineEvents(machines, 2)
+	require.Len(t, events, 2)
+	assert.Equal(t, "m1", events[0].MachineID)
+	assert.Equal(t, "start", events[0].Type)
+	assert.Equal(t, "m2", events[1].MachineID)
+	assert.Equal(t, "flyd", events[1].Source)
+}
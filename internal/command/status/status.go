@@ -53,7 +53,11 @@ currently allocated.
 		},
 		flag.Bool{
 			Name:        "watch",
-			Description: "Refresh details",
+			Description: "Show a live dashboard of machines, checks, events and logs",
+		},
+		flag.Bool{
+			Name:        "plain",
+			Description: "With --watch, refresh the plain status output instead of showing the dashboard",
 		},
 		flag.Int{
 			Name:        "rate",
@@ -112,6 +116,10 @@ func runWatch(ctx context.Context) (err error) {
 		return
 	}
 
+	if !flag.GetBool(ctx, "plain") {
+		return runDashboard(ctx, time.Duration(sleep)*time.Second)
+	}
+
 	appName := appconfig.NameFromContext(ctx)
 
 	var buf bytes.Buffer
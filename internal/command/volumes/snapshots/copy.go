@@ -0,0 +1,124 @@
+package snapshots
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func newCopy() *cobra.Command {
+	const (
+		short = "Copy a volume snapshot to another region."
+		long  = short + ` The snapshot is restored into a new, unattached volume
+in the target region, which can then be mounted by machines in that region or
+kept as a standby copy.`
+		usage = "copy <snapshot id>"
+	)
+
+	cmd := command.New(usage, short, long, runCopy,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.ExactArgs(1)
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.JSONOutput(),
+		flag.String{
+			Name:        "to-region",
+			Description: "The region to copy the snapshot to",
+		},
+		flag.String{
+			Name:        "name",
+			Description: "Name of the new volume. Defaults to the source volume's name",
+		},
+		flag.String{
+			Name:        "volume",
+			Description: "ID of the volume the snapshot was taken from. Found automatically when omitted",
+		},
+	)
+
+	return cmd
+}
+
+func runCopy(ctx context.Context) error {
+	var (
+		io         = iostreams.FromContext(ctx)
+		appName    = appconfig.NameFromContext(ctx)
+		snapshotID = flag.FirstArg(ctx)
+		region     = flag.GetString(ctx, "to-region")
+	)
+
+	if region == "" {
+		return fmt.Errorf("--to-region is required")
+	}
+
+	flapsClient, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+		AppName: appName,
+	})
+	if err != nil {
+		return err
+	}
+
+	src, err := findSnapshotVolume(ctx, flapsClient, snapshotID, flag.GetString(ctx, "volume"))
+	if err != nil {
+		return err
+	}
+
+	name := flag.GetString(ctx, "name")
+	if name == "" {
+		name = src.Name
+	}
+
+	vol, err := restoreSnapshot(ctx, flapsClient, *src, snapshotID, region, name)
+	if err != nil {
+		return fmt.Errorf("failed copying snapshot %s to %s: %w", snapshotID, region, err)
+	}
+
+	if config.FromContext(ctx).JSONOutput {
+		return render.JSON(io.Out, vol)
+	}
+
+	fmt.Fprintf(io.Out, "Copied snapshot %s of volume %s (%s) into volume %s (%s) in %s\n",
+		snapshotID, src.ID, src.Region, vol.ID, vol.Name, vol.Region)
+
+	return nil
+}
+
+// findSnapshotVolume returns the volume snapshotID was taken from, looking
+// through the app's volumes unless volumeID is given.
+func findSnapshotVolume(ctx context.Context, flapsClient flapsutil.FlapsClient, snapshotID, volumeID string) (*fly.Volume, error) {
+	if volumeID != "" {
+		return flapsClient.GetVolume(ctx, volumeID)
+	}
+
+	volumes, err := flapsClient.GetVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, vol := range volumes {
+		snapshots, err := flapsClient.GetVolumeSnapshots(ctx, vol.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed listing snapshots of %s: %w", vol.ID, err)
+		}
+		for _, s := range snapshots {
+			if s.ID == snapshotID {
+				return &volumes[i], nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("snapshot %s was not found on any volume of the app; pass --volume if the source volume was destroyed", snapshotID)
+}
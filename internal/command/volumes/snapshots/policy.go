@@ -0,0 +1,584 @@
+package snapshots
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/gql"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/machine"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+const (
+	// policyRunnerMetadataKey marks the scheduled machine that runs snapshot
+	// policies. It lives in its own app, see policyRunnerApp; runners of
+	// older flyctl versions live in the app itself.
+	policyRunnerMetadataKey = "fly_snapshot_policy_runner"
+	policyRunnerName        = "snapshot-policy"
+	policyRunnerImage       = "flyio/flyctl:latest"
+
+	// The runner's deploy token is kept in a secret of the runner's app
+	// rather than in the machine config, where anyone able to read the
+	// machines would see it. The secret holds a flyctl config file, which is
+	// written to the runner's config directory.
+	policyConfigSecret = "FLY_SNAPSHOT_POLICY_CONFIG"
+	policyConfigDir    = "/fly-snapshot-policy"
+
+	// policiesEnvKey holds the JSON encoded policies on the runner machine,
+	// which has no fly.toml to read them from.
+	policiesEnvKey = "FLY_SNAPSHOT_POLICIES"
+
+	// drVolumeSuffix is appended to the name of volumes restored into other
+	// regions by a policy, so they can be told apart from the app's volumes.
+	drVolumeSuffix = "_dr"
+
+	// snapshotDueSlack accounts for the runner's schedule not firing at
+	// exactly the same time every period.
+	snapshotDueSlack = 5 * time.Minute
+)
+
+func newPolicy() *cobra.Command {
+	const (
+		short = "Manage scheduled snapshot policies."
+		long  = short + ` Policies are declared per mount in fly.toml:
+
+  [[mounts]]
+    source = "data"
+    destination = "/data"
+
+    [mounts.snapshot_policy]
+      frequency = "6h"
+      retain = 8
+      copy_to_regions = ["iad"]
+
+Snapshots are taken at most every frequency and kept long enough for retain
+of them to be available. When copy_to_regions is set, the latest snapshot is
+restored into a standby volume named <source>` + drVolumeSuffix + ` in each region.`
+		usage = "policy"
+	)
+
+	cmd := command.New(usage, short, long, nil)
+
+	cmd.AddCommand(
+		newPolicyApply(),
+		newPolicyRun(),
+	)
+
+	return cmd
+}
+
+func newPolicyApply() *cobra.Command {
+	const (
+		short = "Apply the snapshot policies in fly.toml."
+		long  = short + ` A small scheduled machine is created, updated or
+removed so that it runs 'fly volumes snapshots policy run' for the app. The
+machine lives in a separate app named <app>-snapshot-policy, and authenticates
+with a deploy token limited to the app, stored in the ` + policyConfigSecret + `
+secret of the separate app. The app's own machines can't read the token.`
+		usage = "apply"
+	)
+
+	cmd := command.New(usage, short, long, runPolicyApply,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Region(),
+		flag.String{
+			Name:        "image",
+			Description: "The flyctl image the policy machine runs",
+			Default:     policyRunnerImage,
+		},
+	)
+
+	return cmd
+}
+
+func newPolicyRun() *cobra.Command {
+	const (
+		short = "Take due snapshots and refresh cross-region copies."
+		long  = short + ` This is what the scheduled policy machine runs, but it
+can also be run by hand or from CI.`
+		usage = "run"
+	)
+
+	cmd := command.New(usage, short, long, runPolicyRun,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+	)
+
+	return cmd
+}
+
+// mountPolicies returns the snapshot policies in cfg, keyed by mount source.
+func mountPolicies(cfg *appconfig.Config) map[string]*appconfig.SnapshotPolicy {
+	policies := map[string]*appconfig.SnapshotPolicy{}
+	if cfg == nil {
+		return policies
+	}
+	for _, m := range cfg.Mounts {
+		if m.SnapshotPolicy != nil {
+			policies[m.Source] = m.SnapshotPolicy
+		}
+	}
+	return policies
+}
+
+// runnerSchedule picks the coarsest machine schedule that still runs often
+// enough for the most frequent policy.
+func runnerSchedule(policies map[string]*appconfig.SnapshotPolicy) string {
+	shortest := time.Duration(0)
+	for _, p := range policies {
+		if shortest == 0 || p.Frequency.Duration < shortest {
+			shortest = p.Frequency.Duration
+		}
+	}
+
+	switch {
+	case shortest < 24*time.Hour:
+		return "hourly"
+	case shortest < 7*24*time.Hour:
+		return "daily"
+	default:
+		return "weekly"
+	}
+}
+
+func drVolumeName(source string) string {
+	const maxVolumeNameLength = 30
+	if len(source)+len(drVolumeSuffix) > maxVolumeNameLength {
+		source = source[:maxVolumeNameLength-len(drVolumeSuffix)]
+	}
+	return source + drVolumeSuffix
+}
+
+func runPolicyApply(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		client  = flyutil.ClientFromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+		cfg     = appconfig.ConfigFromContext(ctx)
+	)
+
+	if cfg == nil {
+		return errors.New("an app config file is required to apply snapshot policies")
+	}
+	if err, extraInfo := cfg.Validate(ctx); err != nil {
+		fmt.Fprint(io.ErrOut, extraInfo)
+		return err
+	}
+
+	app, err := client.GetAppCompact(ctx, appName)
+	if err != nil {
+		return err
+	}
+
+	flapsClient, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+		AppCompact: app,
+		AppName:    app.Name,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := removeLegacyPolicyRunner(ctx, app, flapsClient); err != nil {
+		return err
+	}
+
+	runnerApp := policyRunnerApp(app.Name)
+	existing, err := client.GetAppCompact(ctx, runnerApp)
+	switch {
+	case fly.IsNotFoundError(err):
+		existing = nil
+	case err != nil:
+		return err
+	case existing.Organization.Slug != app.Organization.Slug:
+		return fmt.Errorf("app %s already exists in another organization, so snapshot policies of %s can't run there", runnerApp, app.Name)
+	}
+
+	policies := mountPolicies(cfg)
+	if len(policies) == 0 {
+		if existing == nil {
+			fmt.Fprintln(io.Out, "No snapshot policies are defined")
+			return nil
+		}
+		runnerFlaps, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+			AppName: runnerApp,
+		})
+		if err != nil {
+			return err
+		}
+		machines, err := runnerFlaps.List(ctx, "")
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(machines, func(m *fly.Machine) bool {
+			return m.Config == nil || m.Config.Metadata[policyRunnerMetadataKey] != "true"
+		}) {
+			return fmt.Errorf("app %s runs more than the snapshot policy machine, remove it yourself", runnerApp)
+		}
+		if err := client.DeleteApp(ctx, runnerApp); err != nil {
+			return fmt.Errorf("failed removing snapshot policy app %s: %w", runnerApp, err)
+		}
+		fmt.Fprintf(io.Out, "No snapshot policies are defined; removed snapshot policy app %s\n", runnerApp)
+		return nil
+	}
+
+	encoded, err := json.Marshal(policies)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		if _, err := client.CreateApp(ctx, fly.CreateAppInput{
+			OrganizationID: app.Organization.ID,
+			Name:           runnerApp,
+			Machines:       true,
+		}); err != nil {
+			return fmt.Errorf("failed creating snapshot policy app %s: %w", runnerApp, err)
+		}
+		fmt.Fprintf(io.Out, "Created snapshot policy app %s\n", runnerApp)
+	}
+
+	runnerFlaps, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+		AppName: runnerApp,
+	})
+	if err != nil {
+		return err
+	}
+	runner, err := findPolicyRunner(ctx, runnerFlaps)
+	if err != nil {
+		return err
+	}
+
+	hasSecret, err := appHasSecret(ctx, runnerApp, policyConfigSecret)
+	if err != nil {
+		return err
+	}
+	if !hasSecret {
+		resp, err := gql.CreateLimitedAccessToken(ctx, client.GenqClient(), "flyctl snapshot policy", app.Organization.ID, "deploy",
+			&gql.LimitedAccessTokenOptions{"app_id": app.ID}, "")
+		if err != nil {
+			return fmt.Errorf("failed creating a deploy token for the snapshot policy machine: %w", err)
+		}
+		token := resp.CreateLimitedAccessToken.LimitedAccessToken.TokenHeader
+
+		// Staged like 'fly secrets set --stage', the runner reads the secret
+		// when it is created or updated below.
+		if _, err := client.SetSecrets(ctx, runnerApp, map[string]string{
+			policyConfigSecret: policyRunnerConfigFile(token),
+		}); err != nil {
+			return fmt.Errorf("failed storing the snapshot policy token in the %s secret of %s: %w", policyConfigSecret, runnerApp, err)
+		}
+	}
+
+	schedule := runnerSchedule(policies)
+	machineConf := &fly.MachineConfig{
+		Image: flag.GetString(ctx, "image"),
+		Init: fly.MachineInit{
+			Cmd: []string{"volumes", "snapshots", "policy", "run", "--app", app.Name},
+		},
+		Env: map[string]string{
+			"FLY_CONFIG_DIR": policyConfigDir,
+			policiesEnvKey:   string(encoded),
+		},
+		Files: []*fly.File{{
+			GuestPath:  policyConfigDir + "/" + config.FileName,
+			SecretName: fly.Pointer(policyConfigSecret),
+		}},
+		Guest: &fly.MachineGuest{
+			CPUKind:  "shared",
+			CPUs:     1,
+			MemoryMB: 256,
+		},
+		Schedule: schedule,
+		Restart: &fly.MachineRestart{
+			Policy: fly.MachineRestartPolicyNo,
+		},
+		Metadata: map[string]string{
+			policyRunnerMetadataKey: "true",
+			machine.ToolMetadataKey: policyRunnerName,
+		},
+	}
+
+	if runner == nil {
+		region := flag.GetRegion(ctx)
+		if region == "" {
+			region = cfg.PrimaryRegion
+		}
+		m, err := runnerFlaps.Launch(ctx, fly.LaunchMachineInput{
+			Name:   policyRunnerName,
+			Region: region,
+			Config: machineConf,
+		})
+		if err != nil {
+			return fmt.Errorf("failed creating snapshot policy machine: %w", err)
+		}
+		fmt.Fprintf(io.Out, "Created snapshot policy machine %s, running %s\n", m.ID, schedule)
+	} else {
+		lease, err := runnerFlaps.AcquireLease(ctx, runner.ID, fly.IntPointer(60))
+		if err != nil {
+			return err
+		}
+		defer runnerFlaps.ReleaseLease(ctx, runner.ID, lease.Data.Nonce)
+
+		if _, err := runnerFlaps.Update(ctx, fly.LaunchMachineInput{
+			ID:     runner.ID,
+			Region: runner.Region,
+			Config: machineConf,
+		}, lease.Data.Nonce); err != nil {
+			return fmt.Errorf("failed updating snapshot policy machine %s: %w", runner.ID, err)
+		}
+		fmt.Fprintf(io.Out, "Updated snapshot policy machine %s, running %s\n", runner.ID, schedule)
+	}
+
+	sources := make([]string, 0, len(policies))
+	for source := range policies {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		p := policies[source]
+		fmt.Fprintf(io.Out, "  %s: every %s, keeping %d (%d days)", source, p.Frequency, p.Retain, p.RetentionDays())
+		if len(p.CopyToRegions) > 0 {
+			fmt.Fprintf(io.Out, ", copied to %s", strings.Join(p.CopyToRegions, ", "))
+		}
+		fmt.Fprintln(io.Out)
+	}
+
+	return nil
+}
+
+// policyRunnerApp is the app the runner machine of appName lives in.
+func policyRunnerApp(appName string) string {
+	return appName + "-" + policyRunnerName
+}
+
+// removeLegacyPolicyRunner removes the runner machine and token secret that
+// older flyctl versions kept in the app itself.
+func removeLegacyPolicyRunner(ctx context.Context, app *fly.AppCompact, flapsClient flapsutil.FlapsClient) error {
+	io := iostreams.FromContext(ctx)
+
+	runner, err := findPolicyRunner(ctx, flapsClient)
+	if err != nil {
+		return err
+	}
+	if runner != nil {
+		if err := flapsClient.Destroy(ctx, fly.RemoveMachineInput{ID: runner.ID, Kill: true}, ""); err != nil {
+			return fmt.Errorf("failed removing snapshot policy machine %s from %s: %w", runner.ID, app.Name, err)
+		}
+		fmt.Fprintf(io.Out, "Removed snapshot policy machine %s from %s\n", runner.ID, app.Name)
+	}
+
+	if hasSecret, err := appHasSecret(ctx, app.Name, policyConfigSecret); err != nil {
+		return err
+	} else if hasSecret {
+		if _, err := flyutil.ClientFromContext(ctx).UnsetSecrets(ctx, app.Name, []string{policyConfigSecret}); err != nil {
+			return fmt.Errorf("failed removing the %s secret from %s: %w", policyConfigSecret, app.Name, err)
+		}
+	}
+	return nil
+}
+
+// policyRunnerConfigFile returns the contents of the runner's flyctl config
+// file, base64 encoded as secret files have to be.
+func policyRunnerConfigFile(token string) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("access_token: %q\n", token)))
+}
+
+func appHasSecret(ctx context.Context, appName, name string) (bool, error) {
+	secrets, err := flyutil.ClientFromContext(ctx).GetAppSecrets(ctx, appName)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(secrets, func(s fly.Secret) bool { return s.Name == name }), nil
+}
+
+func findPolicyRunner(ctx context.Context, flapsClient flapsutil.FlapsClient) (*fly.Machine, error) {
+	machines, err := flapsClient.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range machines {
+		if m.Config != nil && m.Config.Metadata[policyRunnerMetadataKey] == "true" && m.State != fly.MachineStateDestroyed {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+func runPolicyRun(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+	)
+
+	policies := mountPolicies(appconfig.ConfigFromContext(ctx))
+	if encoded := os.Getenv(policiesEnvKey); encoded != "" {
+		policies = map[string]*appconfig.SnapshotPolicy{}
+		if err := json.Unmarshal([]byte(encoded), &policies); err != nil {
+			return fmt.Errorf("failed parsing %s: %w", policiesEnvKey, err)
+		}
+	}
+	if len(policies) == 0 {
+		fmt.Fprintln(io.Out, "No snapshot policies are defined")
+		return nil
+	}
+
+	flapsClient, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+		AppName: appName,
+	})
+	if err != nil {
+		return err
+	}
+
+	volumes, err := flapsClient.GetVolumes(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for source, policy := range policies {
+		if err := enforcePolicy(ctx, flapsClient, volumes, source, policy, time.Now()); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func enforcePolicy(ctx context.Context, flapsClient flapsutil.FlapsClient, volumes []fly.Volume, source string, policy *appconfig.SnapshotPolicy, now time.Time) error {
+	io := iostreams.FromContext(ctx)
+	retention := policy.RetentionDays()
+
+	var (
+		latest    *fly.VolumeSnapshot
+		latestVol fly.Volume
+	)
+	for _, vol := range volumes {
+		if vol.Name != source || vol.State == "destroyed" || vol.State == "pending_destroy" {
+			continue
+		}
+
+		if vol.SnapshotRetention != retention {
+			if _, err := flapsClient.UpdateVolume(ctx, vol.ID, fly.UpdateVolumeRequest{SnapshotRetention: &retention}); err != nil {
+				return fmt.Errorf("failed setting snapshot retention of %s: %w", vol.ID, err)
+			}
+			fmt.Fprintf(io.Out, "Set snapshot retention of volume %s to %d days\n", vol.ID, retention)
+		}
+
+		snapshots, err := flapsClient.GetVolumeSnapshots(ctx, vol.ID)
+		if err != nil {
+			return fmt.Errorf("failed listing snapshots of %s: %w", vol.ID, err)
+		}
+		last := latestSnapshot(snapshots)
+
+		if snapshotDue(last, policy.Frequency.Duration, now) {
+			if err := flapsClient.CreateVolumeSnapshot(ctx, vol.ID); err != nil {
+				return fmt.Errorf("failed snapshotting %s: %w", vol.ID, err)
+			}
+			fmt.Fprintf(io.Out, "Scheduled snapshot of volume %s (%s)\n", vol.ID, vol.Region)
+		}
+
+		if last != nil && (latest == nil || last.CreatedAt.After(latest.CreatedAt)) {
+			latest, latestVol = last, vol
+		}
+	}
+
+	if latest == nil || len(policy.CopyToRegions) == 0 {
+		return nil
+	}
+
+	drName := drVolumeName(source)
+	for _, region := range policy.CopyToRegions {
+		var copies []fly.Volume
+		for _, vol := range volumes {
+			if vol.Name == drName && vol.Region == region && vol.State != "destroyed" && vol.State != "pending_destroy" {
+				copies = append(copies, vol)
+			}
+		}
+
+		if slices.ContainsFunc(copies, func(v fly.Volume) bool { return v.CreatedAt.After(latest.CreatedAt) }) {
+			continue
+		}
+
+		restored, err := restoreSnapshot(ctx, flapsClient, latestVol, latest.ID, region, drName)
+		if err != nil {
+			return fmt.Errorf("failed copying snapshot %s to %s: %w", latest.ID, region, err)
+		}
+		fmt.Fprintf(io.Out, "Restored snapshot %s into volume %s in %s\n", latest.ID, restored.ID, region)
+
+		for _, old := range copies {
+			if old.IsAttached() {
+				continue
+			}
+			if _, err := flapsClient.DeleteVolume(ctx, old.ID); err != nil {
+				return fmt.Errorf("failed removing outdated copy %s: %w", old.ID, err)
+			}
+			fmt.Fprintf(io.Out, "Removed outdated copy %s in %s\n", old.ID, region)
+		}
+	}
+
+	return nil
+}
+
+// latestSnapshot returns the most recent snapshot that hasn't failed.
+func latestSnapshot(snapshots []fly.VolumeSnapshot) *fly.VolumeSnapshot {
+	var latest *fly.VolumeSnapshot
+	for i, s := range snapshots {
+		if s.Status == "failed" {
+			continue
+		}
+		if latest == nil || s.CreatedAt.After(latest.CreatedAt) {
+			latest = &snapshots[i]
+		}
+	}
+	return latest
+}
+
+func snapshotDue(latest *fly.VolumeSnapshot, frequency time.Duration, now time.Time) bool {
+	if latest == nil {
+		return true
+	}
+	return now.Sub(latest.CreatedAt) >= frequency-snapshotDueSlack
+}
+
+// restoreSnapshot creates a volume in region from a snapshot of src.
+func restoreSnapshot(ctx context.Context, flapsClient flapsutil.FlapsClient, src fly.Volume, snapshotID, region, name string) (*fly.Volume, error) {
+	return flapsClient.CreateVolume(ctx, fly.CreateVolumeRequest{
+		Name:              name,
+		Region:            region,
+		SizeGb:            fly.Pointer(src.SizeGb),
+		Encrypted:         fly.Pointer(src.Encrypted),
+		SnapshotID:        fly.Pointer(snapshotID),
+		SnapshotRetention: fly.Pointer(src.SnapshotRetention),
+		RequireUniqueZone: fly.Pointer(false),
+	})
+}
@@ -0,0 +1,274 @@
+package snapshots
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/inmem"
+	"github.com/superfly/flyctl/internal/mock"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func TestSnapshotDue(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	snap := func(age time.Duration) *fly.VolumeSnapshot {
+		return &fly.VolumeSnapshot{CreatedAt: now.Add(-age)}
+	}
+
+	assert.True(t, snapshotDue(nil, 6*time.Hour, now))
+	assert.False(t, snapshotDue(snap(time.Hour), 6*time.Hour, now))
+	assert.True(t, snapshotDue(snap(6*time.Hour), 6*time.Hour, now))
+	// The runner is hourly, so a snapshot taken slightly less than a period
+	// ago is still due.
+	assert.True(t, snapshotDue(snap(6*time.Hour-time.Minute), 6*time.Hour, now))
+}
+
+func TestLatestSnapshot(t *testing.T) {
+	now := time.Now()
+	snapshots := []fly.VolumeSnapshot{
+		{ID: "old", CreatedAt: now.Add(-2 * time.Hour), Status: "created"},
+		{ID: "failed", CreatedAt: now, Status: "failed"},
+		{ID: "new", CreatedAt: now.Add(-time.Hour), Status: "created"},
+	}
+
+	assert.Equal(t, "new", latestSnapshot(snapshots).ID)
+	assert.Nil(t, latestSnapshot(nil))
+}
+
+func TestRunnerSchedule(t *testing.T) {
+	policy := func(d time.Duration) *appconfig.SnapshotPolicy {
+		return &appconfig.SnapshotPolicy{Frequency: &fly.Duration{Duration: d}, Retain: 1}
+	}
+
+	assert.Equal(t, "hourly", runnerSchedule(map[string]*appconfig.SnapshotPolicy{
+		"a": policy(24 * time.Hour),
+		"b": policy(6 * time.Hour),
+	}))
+	assert.Equal(t, "daily", runnerSchedule(map[string]*appconfig.SnapshotPolicy{
+		"a": policy(24 * time.Hour),
+	}))
+	assert.Equal(t, "weekly", runnerSchedule(map[string]*appconfig.SnapshotPolicy{
+		"a": policy(14 * 24 * time.Hour),
+	}))
+}
+
+func TestDRVolumeName(t *testing.T) {
+	assert.Equal(t, "data_dr", drVolumeName("data"))
+
+	long := drVolumeName(strings.Repeat("x", 30))
+	assert.Len(t, long, 30)
+	assert.True(t, strings.HasSuffix(long, drVolumeSuffix))
+}
+
+func TestEnforcePolicy(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	// Keeping 8 snapshots taken every 6h takes a retention of 2 days.
+	policy := &appconfig.SnapshotPolicy{Frequency: &fly.Duration{Duration: 6 * time.Hour}, Retain: 8}
+	drPolicy := &appconfig.SnapshotPolicy{Frequency: &fly.Duration{Duration: 6 * time.Hour}, Retain: 8, CopyToRegions: []string{"iad"}}
+
+	vol := func(id, name, region string, age time.Duration) fly.Volume {
+		return fly.Volume{ID: id, Name: name, Region: region, State: "created", SnapshotRetention: 2, CreatedAt: now.Add(-age)}
+	}
+	snap := func(id string, age time.Duration) fly.VolumeSnapshot {
+		return fly.VolumeSnapshot{ID: id, Status: "created", CreatedAt: now.Add(-age)}
+	}
+	attached := func(v fly.Volume) fly.Volume {
+		v.AttachedMachine = fly.Pointer("m1")
+		return v
+	}
+	defaultRetention := func(v fly.Volume) fly.Volume {
+		v.SnapshotRetention = 5
+		return v
+	}
+	destroyed := func(v fly.Volume) fly.Volume {
+		v.State = "destroyed"
+		return v
+	}
+
+	tests := []struct {
+		name      string
+		policy    *appconfig.SnapshotPolicy
+		volumes   []fly.Volume
+		snapshots map[string][]fly.VolumeSnapshot
+		failWith  error
+
+		wantRetention []string
+		wantSnapshot  []string
+		wantRestore   []string
+		wantDelete    []string
+		wantErr       string
+	}{
+		{
+			name:          "first snapshot and retention",
+			policy:        policy,
+			volumes:       []fly.Volume{defaultRetention(vol("vol_a", "data", "ams", 48*time.Hour))},
+			wantRetention: []string{"vol_a"},
+			wantSnapshot:  []string{"vol_a"},
+		},
+		{
+			name:      "recent snapshot",
+			policy:    policy,
+			volumes:   []fly.Volume{vol("vol_a", "data", "ams", 48*time.Hour)},
+			snapshots: map[string][]fly.VolumeSnapshot{"vol_a": {snap("snap_1", time.Hour)}},
+		},
+		{
+			name:   "due snapshot on each volume of the mount",
+			policy: policy,
+			volumes: []fly.Volume{
+				vol("vol_a", "data", "ams", 48*time.Hour),
+				defaultRetention(vol("vol_b", "data", "fra", 48*time.Hour)),
+				defaultRetention(vol("vol_c", "other", "ams", 48*time.Hour)),
+				destroyed(vol("vol_d", "data", "ams", 48*time.Hour)),
+			},
+			snapshots: map[string][]fly.VolumeSnapshot{
+				"vol_a": {snap("snap_1", 7*time.Hour)},
+				"vol_b": {snap("snap_2", time.Hour)},
+			},
+			wantRetention: []string{"vol_b"},
+			wantSnapshot:  []string{"vol_a"},
+		},
+		{
+			name:   "copy latest snapshot and remove outdated copies",
+			policy: drPolicy,
+			volumes: []fly.Volume{
+				vol("vol_a", "data", "ams", 48*time.Hour),
+				vol("vol_b", "data", "fra", 48*time.Hour),
+				vol("vol_old", "data_dr", "iad", 24*time.Hour),
+				attached(vol("vol_used", "data_dr", "iad", 24*time.Hour)),
+				vol("vol_elsewhere", "data_dr", "syd", 24*time.Hour),
+			},
+			snapshots: map[string][]fly.VolumeSnapshot{
+				"vol_a": {snap("snap_1", 3*time.Hour)},
+				"vol_b": {snap("snap_2", 2*time.Hour), {ID: "snap_3", Status: "failed", CreatedAt: now}},
+			},
+			wantRestore: []string{"snap_2 from vol_b to iad"},
+			wantDelete:  []string{"vol_old"},
+		},
+		{
+			name:   "copy up to date",
+			policy: drPolicy,
+			volumes: []fly.Volume{
+				vol("vol_a", "data", "ams", 48*time.Hour),
+				vol("vol_copy", "data_dr", "iad", time.Hour),
+			},
+			snapshots: map[string][]fly.VolumeSnapshot{"vol_a": {snap("snap_1", 2*time.Hour)}},
+		},
+		{
+			name:         "nothing to copy yet",
+			policy:       drPolicy,
+			volumes:      []fly.Volume{vol("vol_a", "data", "ams", time.Hour)},
+			wantSnapshot: []string{"vol_a"},
+		},
+		{
+			name:         "snapshot failure",
+			policy:       policy,
+			volumes:      []fly.Volume{vol("vol_a", "data", "ams", 48*time.Hour)},
+			failWith:     errors.New("boom"),
+			wantSnapshot: []string{"vol_a"},
+			wantErr:      "failed snapshotting vol_a: boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var retention, snapshotted, restored, deleted []string
+			flapsClient := &mock.FlapsClient{
+				UpdateVolumeFunc: func(ctx context.Context, volumeId string, req fly.UpdateVolumeRequest) (*fly.Volume, error) {
+					assert.Equal(t, 2, *req.SnapshotRetention)
+					retention = append(retention, volumeId)
+					return &fly.Volume{ID: volumeId}, nil
+				},
+				GetVolumeSnapshotsFunc: func(ctx context.Context, volumeId string) ([]fly.VolumeSnapshot, error) {
+					return tt.snapshots[volumeId], nil
+				},
+				CreateVolumeSnapshotFunc: func(ctx context.Context, volumeId string) error {
+					snapshotted = append(snapshotted, volumeId)
+					return tt.failWith
+				},
+				CreateVolumeFunc: func(ctx context.Context, req fly.CreateVolumeRequest) (*fly.Volume, error) {
+					assert.Equal(t, "data_dr", req.Name)
+					var from string
+					for id, snapshots := range tt.snapshots {
+						for _, s := range snapshots {
+							if s.ID == *req.SnapshotID {
+								from = id
+							}
+						}
+					}
+					restored = append(restored, *req.SnapshotID+" from "+from+" to "+req.Region)
+					return &fly.Volume{ID: "vol_new", Name: req.Name, Region: req.Region}, nil
+				},
+				DeleteVolumeFunc: func(ctx context.Context, volumeId string) (*fly.Volume, error) {
+					deleted = append(deleted, volumeId)
+					return &fly.Volume{ID: volumeId}, nil
+				},
+			}
+
+			ios, _, _, _ := iostreams.Test()
+			ctx := iostreams.NewContext(context.Background(), ios)
+
+			err := enforcePolicy(ctx, flapsClient, tt.volumes, "data", tt.policy, now)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantRetention, retention, "retention updates")
+			assert.Equal(t, tt.wantSnapshot, snapshotted, "snapshots")
+			assert.Equal(t, tt.wantRestore, restored, "restores")
+			assert.Equal(t, tt.wantDelete, deleted, "deletes")
+		})
+	}
+}
+
+func TestPolicyRunnerConfigFile(t *testing.T) {
+	decoded, err := base64.StdEncoding.DecodeString(policyRunnerConfigFile("FlyV1 fm2_abc,fm2_def"))
+	require.NoError(t, err)
+	assert.Equal(t, "access_token: \"FlyV1 fm2_abc,fm2_def\"\n", string(decoded))
+}
+
+func TestRemoveLegacyPolicyRunner(t *testing.T) {
+	ios, _, _, _ := iostreams.Test()
+	ctx := iostreams.NewContext(context.Background(), ios)
+	server := inmem.NewServer()
+	server.CreateApp(&fly.App{Name: "app"})
+	client := server.Client()
+	ctx = flyutil.NewContextWithClient(ctx, client)
+	flapsClient := server.FlapsClient("app")
+
+	_, err := flapsClient.Launch(ctx, fly.LaunchMachineInput{Config: &fly.MachineConfig{
+		Image:    "app:v1",
+		Metadata: map[string]string{fly.MachineConfigMetadataKeyFlyPlatformVersion: fly.MachineFlyPlatformVersion2},
+	}})
+	require.NoError(t, err)
+	_, err = flapsClient.Launch(ctx, fly.LaunchMachineInput{Config: &fly.MachineConfig{
+		Image:    policyRunnerImage,
+		Metadata: map[string]string{policyRunnerMetadataKey: "true"},
+	}})
+	require.NoError(t, err)
+	_, err = client.SetSecrets(ctx, "app", map[string]string{policyConfigSecret: "token", "OTHER": "value"})
+	require.NoError(t, err)
+
+	require.NoError(t, removeLegacyPolicyRunner(ctx, &fly.AppCompact{Name: "app"}, flapsClient))
+
+	runner, err := findPolicyRunner(ctx, flapsClient)
+	require.NoError(t, err)
+	assert.Nil(t, runner)
+	machines, err := flapsClient.List(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, machines, 1)
+
+	secrets, err := client.GetAppSecrets(ctx, "app")
+	require.NoError(t, err)
+	require.Len(t, secrets, 1)
+	assert.Equal(t, "OTHER", secrets[0].Name)
+}
@@ -1,6 +1,8 @@
 package wireguard
 
 import (
+	"time"
+
 	"github.com/superfly/flyctl/internal/command"
 	"github.com/superfly/flyctl/internal/flag"
 
@@ -18,6 +20,9 @@ func New() *cobra.Command {
 		newWireguardList(),
 		newWireguardCreate(),
 		newWireguardRemove(),
+		newWireguardRotate(),
+		newWireguardPrune(),
+		newWireguardExport(),
 		newWireguardReset(),
 		newWireguardWebsockets(),
 		newWireguardToken(),
@@ -54,6 +59,10 @@ func newWireguardCreate() *cobra.Command {
 			Name:        "network",
 			Description: "Custom network name",
 		},
+		flag.Duration{
+			Name:        "ttl",
+			Description: "Expire the peer after this duration (e.g. 72h); expired peers are removed by 'fly wireguard prune'",
+		},
 	)
 	return cmd
 }
@@ -70,6 +79,66 @@ func newWireguardRemove() *cobra.Command {
 	return cmd
 }
 
+func newWireguardRotate() *cobra.Command {
+	const (
+		short = "Replace the keypair of a WireGuard peer connection"
+		long  = `Replace the keypair of a WireGuard peer connection, keeping its name and region.
+The peer is removed and re-added with a fresh keypair, so existing configurations
+for it stop working. If the peer is used by the flyctl agent, the local
+configuration is updated with the new keys.`
+	)
+	cmd := command.New("rotate [org] [name] [file]", short, long, runWireguardRotate,
+		command.RequireSession,
+	)
+	cmd.Args = cobra.MaximumNArgs(3)
+	flag.Add(cmd,
+		flag.String{
+			Name:        "network",
+			Description: "Custom network the peer belongs to, taken from the local configuration when the peer is in it",
+		},
+	)
+	return cmd
+}
+
+func newWireguardPrune() *cobra.Command {
+	const (
+		short = "Remove expired and abandoned WireGuard peer connections"
+		long  = `Remove WireGuard peer connections whose TTL (set with 'fly wireguard create --ttl')
+has passed, as well as peers the flyctl agent created from this host that are no
+longer referenced by the local configuration.`
+	)
+	cmd := command.New("prune [org]", short, long, runWireguardPrune,
+		command.RequireSession,
+	)
+	cmd.Args = cobra.MaximumNArgs(1)
+	flag.Add(cmd,
+		flag.Yes(),
+		flag.Bool{
+			Name:        "dry-run",
+			Description: "List the peers that would be removed without removing them",
+		},
+		flag.Duration{
+			Name:        "abandoned-after",
+			Description: "Only remove abandoned agent peers older than this",
+			Default:     24 * time.Hour,
+		},
+	)
+	return cmd
+}
+
+func newWireguardExport() *cobra.Command {
+	const (
+		short = "Export WireGuard peer connections as JSON"
+		long  = `Export WireGuard peer connections as JSON for auditing, including when each
+peer was created and expires, if known, and its last handshake.`
+	)
+	cmd := command.New("export [org]", short, long, runWireguardExport,
+		command.RequireSession,
+	)
+	cmd.Args = cobra.MaximumNArgs(1)
+	return cmd
+}
+
 func newWireguardReset() *cobra.Command {
 	const (
 		short = "Reset WireGuard peer connection for an organization"
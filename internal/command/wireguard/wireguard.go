@@ -13,6 +13,7 @@ import (
 	"github.com/superfly/flyctl/internal/config"
 	"github.com/superfly/flyctl/internal/flag"
 	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/internal/prompt"
 	"github.com/superfly/flyctl/internal/render"
 	"github.com/superfly/flyctl/internal/state"
 	"github.com/superfly/flyctl/internal/wireguard"
@@ -142,8 +143,9 @@ func runWireguardCreate(ctx context.Context) error {
 	}
 
 	network := flag.GetString(ctx, "network")
+	ttl := flag.GetDuration(ctx, "ttl")
 
-	state, err := wireguard.Create(apiClient, org, region, name, network, "static")
+	state, err := wireguard.Create(apiClient, org, region, name, network, "static", ttl)
 	if err != nil {
 		return err
 	}
@@ -205,3 +207,140 @@ func runWireguardRemove(ctx context.Context) error {
 
 	return wireguard.PruneInvalidPeers(ctx, apiClient)
 }
+
+func runWireguardRotate(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+	apiClient := flyutil.ClientFromContext(ctx)
+
+	org, err := orgByArg(ctx)
+	if err != nil {
+		return err
+	}
+
+	args := flag.Args(ctx)
+	var name string
+	if len(args) >= 2 {
+		name = args[1]
+	} else {
+		name, err = selectWireGuardPeer(ctx, apiClient, org.Slug)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(io.Out, "Rotating keys for WireGuard peer \"%s\" in organization %s\n", name, org.Slug)
+
+	state, err := wireguard.Rotate(ctx, apiClient, org, name, flag.GetString(ctx, "network"))
+	if err != nil {
+		return err
+	}
+
+	states, err := wireguard.GetWireGuardState()
+	if err != nil {
+		return err
+	}
+	for _, s := range states {
+		if s.Org == org.Slug && s.Name == state.Name {
+			fmt.Fprintln(io.Out, "Updated the agent's tunnel configuration; run `flyctl agent restart` to use the new keys.")
+			return nil
+		}
+	}
+
+	fmt.Fprintf(io.Out, `
+!!!! WARNING: Output includes private key. Private keys cannot be recovered !!!!
+!!!! after rotating the peer; if you lose the key, you'll need to rotate    !!!!
+!!!! the peer again.                                                        !!!!
+`)
+
+	w, shouldClose, err := resolveOutputWriter(ctx, 2, "Filename to store WireGuard configuration in, or 'stdout': ")
+	if err != nil {
+		return err
+	}
+	if shouldClose {
+		defer w.Close() // skipcq: GO-S2307
+	}
+
+	generateWgConf(&state.Peer, state.LocalPrivate, w)
+
+	if shouldClose {
+		filename := w.(*os.File).Name()
+		fmt.Fprintf(io.Out, "Wrote WireGuard configuration to %s; load in your WireGuard client\n", filename)
+	}
+
+	return nil
+}
+
+func runWireguardPrune(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+	apiClient := flyutil.ClientFromContext(ctx)
+
+	org, err := orgByArg(ctx)
+	if err != nil {
+		return err
+	}
+
+	stale, err := wireguard.PrunablePeers(ctx, apiClient, org.Slug, flag.GetDuration(ctx, "abandoned-after"))
+	if err != nil {
+		return err
+	}
+
+	if len(stale) == 0 {
+		fmt.Fprintf(io.Out, "No stale WireGuard peers found for organization %s\n", org.Slug)
+		return nil
+	}
+
+	table := tablewriter.NewWriter(io.Out)
+	table.SetHeader([]string{"Name", "Region", "Reason"})
+	for _, s := range stale {
+		table.Append([]string{s.Peer.Name, s.Peer.Region, s.Reason})
+	}
+	table.Render()
+
+	if flag.GetBool(ctx, "dry-run") {
+		return nil
+	}
+
+	if !flag.GetYes(ctx) {
+		switch confirmed, err := prompt.Confirmf(ctx, "Remove %d WireGuard peer(s)?", len(stale)); {
+		case err == nil:
+			if !confirmed {
+				return nil
+			}
+		case prompt.IsNonInteractive(err):
+			return prompt.NonInteractiveError("yes flag must be specified when not running interactively")
+		default:
+			return err
+		}
+	}
+
+	for _, s := range stale {
+		if err := apiClient.RemoveWireGuardPeer(ctx, org, s.Peer.Name); err != nil {
+			return fmt.Errorf("failed removing peer %s: %w", s.Peer.Name, err)
+		}
+		fmt.Fprintf(io.Out, "Removed peer %s\n", s.Peer.Name)
+	}
+
+	return wireguard.PruneInvalidPeers(ctx, apiClient)
+}
+
+func runWireguardExport(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+	apiClient := flyutil.ClientFromContext(ctx)
+
+	org, err := orgByArg(ctx)
+	if err != nil {
+		return err
+	}
+
+	peers, err := apiClient.GetWireGuardPeers(ctx, org.Slug)
+	if err != nil {
+		return err
+	}
+
+	states, err := wireguard.GetWireGuardState()
+	if err != nil {
+		return err
+	}
+
+	return render.JSON(io.Out, wireguard.DescribePeers(peers, states))
+}
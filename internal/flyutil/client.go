@@ -54,6 +54,7 @@ type Client interface {
 	GetAppCNAMETarget(ctx context.Context, appName string) (string, error)
 	GetAppHostIssues(ctx context.Context, appName string) ([]fly.HostIssue, error)
 	GetAppLimitedAccessTokens(ctx context.Context, appName string) ([]fly.LimitedAccessToken, error)
+	GetAppLock(ctx context.Context, name string) (*fly.App, error)
 	GetAppLogs(ctx context.Context, appName, token, region, instanceID string) (entries []fly.LogEntry, nextToken string, err error)
 	GetAppNameFromVolume(ctx context.Context, volID string) (*string, error)
 	GetAppNameStateFromVolume(ctx context.Context, volID string) (*string, *string, error)
@@ -88,6 +89,7 @@ type Client interface {
 	IssueSSHCertificate(ctx context.Context, org fly.OrganizationImpl, principals []string, appNames []string, valid_hours *int, publicKey ed25519.PublicKey) (*fly.IssuedCertificate, error)
 	LatestImage(ctx context.Context, appName string) (string, error)
 	ListPostgresClusterAttachments(ctx context.Context, appName, postgresAppName string) ([]*fly.PostgresClusterAttachment, error)
+	LockApp(ctx context.Context, input fly.LockAppInput) (*fly.LockApp, error)
 	Logger() fly.Logger
 	MoveApp(ctx context.Context, appName string, orgID string) (*fly.App, error)
 	NewRequest(q string) *graphql.Request
@@ -102,6 +104,7 @@ type Client interface {
 	SetGenqClient(client genq.Client)
 	SetSecrets(ctx context.Context, appName string, secrets map[string]string) (*fly.Release, error)
 	UpdateRelease(ctx context.Context, input fly.UpdateReleaseInput) (*fly.UpdateReleaseResponse, error)
+	UnlockApp(ctx context.Context, input fly.UnlockAppInput) (*fly.App, error)
 	UnsetSecrets(ctx context.Context, appName string, keys []string) (*fly.Release, error)
 	ValidateWireGuardPeers(ctx context.Context, peerIPs []string) (invalid []string, err error)
 }
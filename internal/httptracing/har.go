@@ -2,10 +2,13 @@ package httptracing
 
 import (
 	"encoding/json"
-	"github.com/haileys/go-harlog"
-	"github.com/superfly/flyctl/terminal"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
+
+	"github.com/haileys/go-harlog"
+	"github.com/superfly/flyctl/terminal"
 )
 
 type harOpt struct {
@@ -13,15 +16,35 @@ type harOpt struct {
 	Container *harlog.HARContainer
 }
 
-var har *harOpt
+var (
+	har    *harOpt
+	replay *ReplayTransport
+)
 
-func Init() {
-	if path := os.Getenv("FLYCTL_OUTPUT_HAR"); path != "" {
+// Init sets up recording of all HTTP traffic to the HAR file named by
+// FLYCTL_OUTPUT_HAR, or replaying of the HAR file named by FLYCTL_REPLAY_HAR.
+// Both can't be set at once, since a replay would only record what's being
+// replayed. A HAR file that can't be replayed is an error rather than a
+// reason to go to the network.
+func Init() error {
+	replayPath, outputPath := os.Getenv("FLYCTL_REPLAY_HAR"), os.Getenv("FLYCTL_OUTPUT_HAR")
+
+	switch {
+	case replayPath != "" && outputPath != "":
+		return errors.New("FLYCTL_REPLAY_HAR and FLYCTL_OUTPUT_HAR can't be set at the same time")
+	case replayPath != "":
+		t, err := LoadReplayTransport(replayPath)
+		if err != nil {
+			return fmt.Errorf("error loading HAR for replay: %w", err)
+		}
+		replay = t
+	case outputPath != "":
 		har = &harOpt{
-			Path:      path,
+			Path:      outputPath,
 			Container: harlog.NewHARContainer(),
 		}
 	}
+	return nil
 }
 
 func Finish() {
@@ -29,6 +52,8 @@ func Finish() {
 		return
 	}
 
+	scrubEntries(har.Container.Log.Entries)
+
 	harJson, err := json.MarshalIndent(har.Container, "", "    ")
 	if err != nil {
 		terminal.Warnf("error serializing HAR: %v\n", err)
@@ -41,7 +66,13 @@ func Finish() {
 	}
 }
 
+// NewTransport wraps transport to record traffic, or replaces it to replay
+// recorded traffic, depending on what Init set up.
 func NewTransport(transport http.RoundTripper) http.RoundTripper {
+	if replay != nil {
+		transport = replay
+	}
+
 	if har == nil {
 		return transport
 	}
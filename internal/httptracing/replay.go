@@ -0,0 +1,202 @@
+package httptracing
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/haileys/go-harlog"
+)
+
+// ReplayTransport serves responses recorded in a HAR file instead of going
+// to the network.
+//
+// Requests are matched on method and path, and on the operation name for
+// GraphQL requests. Among those, recorded entries are served in the order
+// they were recorded, preferring one with the same body, so polling a
+// machine until it starts replays the same sequence of states. Once every
+// matching entry has been served, the last one is served again.
+type ReplayTransport struct {
+	mu      sync.Mutex
+	entries []*replayEntry
+}
+
+type replayEntry struct {
+	key    string
+	body   string
+	served bool
+	entry  *harlog.Entry
+}
+
+// LoadReplayTransport reads a HAR file as recorded with FLYCTL_OUTPUT_HAR.
+func LoadReplayTransport(path string) (*ReplayTransport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var har harlog.HARContainer
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("failed to parse HAR file %s: %w", path, err)
+	}
+	if har.Log == nil {
+		return nil, fmt.Errorf("HAR file %s has no log", path)
+	}
+
+	t := &ReplayTransport{}
+	for _, entry := range har.Log.Entries {
+		if entry.Request == nil || entry.Response == nil {
+			continue
+		}
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("HAR file %s has an invalid url %q: %w", path, entry.Request.URL, err)
+		}
+		var body string
+		if entry.Request.PostData != nil {
+			body = entry.Request.PostData.Text
+		}
+		t.entries = append(t.entries, &replayEntry{
+			key:   replayKey(entry.Request.Method, u, body),
+			body:  normalizeBody(body),
+			entry: entry,
+		})
+	}
+	return t, nil
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	entry := t.match(replayKey(req.Method, req.URL, string(body)), normalizeBody(scrub(string(body))))
+	if entry == nil {
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL)
+	}
+	return replayResponse(req, entry.Response)
+}
+
+func (t *ReplayTransport) match(key, body string) *harlog.Entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var unserved, last *replayEntry
+	for _, e := range t.entries {
+		if e.key != key {
+			continue
+		}
+		last = e
+		if e.served {
+			continue
+		}
+		if e.body == body {
+			e.served = true
+			return e.entry
+		}
+		if unserved == nil {
+			unserved = e
+		}
+	}
+
+	switch {
+	case unserved != nil:
+		unserved.served = true
+		return unserved.entry
+	case last != nil:
+		return last.entry
+	default:
+		return nil
+	}
+}
+
+func replayResponse(req *http.Request, recorded *harlog.Response) (*http.Response, error) {
+	var body []byte
+	header := make(http.Header)
+	for _, h := range recorded.Headers {
+		header.Add(h.Name, h.Value)
+	}
+	if content := recorded.Content; content != nil {
+		body = []byte(content.Text)
+		if content.Encoding == "base64" {
+			var err error
+			if body, err = base64.StdEncoding.DecodeString(content.Text); err != nil {
+				return nil, fmt.Errorf("recorded response for %s %s has an invalid body: %w", req.Method, req.URL, err)
+			}
+		}
+	}
+	// The body is served decoded, whatever was negotiated when recording.
+	header.Del("Content-Encoding")
+	header.Set("Content-Length", fmt.Sprint(len(body)))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+var graphqlOperationPattern = regexp.MustCompile(`^\s*(?:query|mutation|subscription)\s+(\w+)`)
+
+// replayKey identifies the requests a recorded entry can answer.
+func replayKey(method string, u *url.URL, body string) string {
+	key := method + " " + u.Path
+	if q := u.Query(); len(q) > 0 {
+		key += "?" + q.Encode()
+	}
+	if strings.HasSuffix(u.Path, "/graphql") {
+		key += " " + graphqlOperation(body)
+	}
+	return key
+}
+
+// graphqlOperation returns the operation name of a GraphQL request body.
+func graphqlOperation(body string) string {
+	var req struct {
+		OperationName string `json:"operationName"`
+		Query         string `json:"query"`
+	}
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return ""
+	}
+	if req.OperationName != "" {
+		return req.OperationName
+	}
+	if m := graphqlOperationPattern.FindStringSubmatch(req.Query); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// normalizeBody makes JSON bodies comparable regardless of key order and
+// whitespace.
+func normalizeBody(body string) string {
+	var v any
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return body
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return string(b)
+}
@@ -0,0 +1,115 @@
+package httptracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/haileys/go-harlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// record sends requests to a test server through a recording transport and
+// writes the scrubbed HAR file.
+func record(t *testing.T, send func(client *http.Client, url string)) string {
+	t.Helper()
+
+	var polls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/graphql":
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, `{"data":{"op":%q}}`, graphqlOperation(string(body)))
+		case "/v1/apps/myapp/machines/m1":
+			fmt.Fprintf(w, `{"id":"m1","poll":%d}`, polls.Add(1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	container := harlog.NewHARContainer()
+	client := &http.Client{Transport: &harlog.Transport{Transport: http.DefaultTransport, Container: container}}
+	send(client, ts.URL)
+
+	scrubEntries(container.Log.Entries)
+	data, err := json.Marshal(container)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "fixture.har")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	return path
+}
+
+func do(t *testing.T, client *http.Client, method, url, body string) string {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "FlyV1 fm2_c2VjcmV0")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	out, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestRecordReplay(t *testing.T) {
+	path := record(t, func(client *http.Client, url string) {
+		do(t, client, "POST", url+"/graphql", `{"query":"query GetApp { app { name } }"}`)
+		do(t, client, "POST", url+"/graphql", `{"query":"mutation DeleteApp { deleteApp { app } }"}`)
+		do(t, client, "GET", url+"/v1/apps/myapp/machines/m1", "")
+		do(t, client, "GET", url+"/v1/apps/myapp/machines/m1", "")
+	})
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "fm2_c2VjcmV0", "tokens are scrubbed")
+
+	replay, err := LoadReplayTransport(path)
+	require.NoError(t, err)
+	client := &http.Client{Transport: replay}
+
+	// GraphQL requests are matched by operation, not by order.
+	assert.JSONEq(t, `{"data":{"op":"DeleteApp"}}`, do(t, client, "POST", "http://api.fly.test/graphql", `{"query":"mutation DeleteApp { deleteApp { app } }"}`))
+	assert.JSONEq(t, `{"data":{"op":"GetApp"}}`, do(t, client, "POST", "http://api.fly.test/graphql", `{"query":"query GetApp { app { name } }"}`))
+
+	// Repeated requests replay in order, then stick to the last response.
+	assert.JSONEq(t, `{"id":"m1","poll":1}`, do(t, client, "GET", "http://flaps.test/v1/apps/myapp/machines/m1", ""))
+	assert.JSONEq(t, `{"id":"m1","poll":2}`, do(t, client, "GET", "http://flaps.test/v1/apps/myapp/machines/m1", ""))
+	assert.JSONEq(t, `{"id":"m1","poll":2}`, do(t, client, "GET", "http://flaps.test/v1/apps/myapp/machines/m1", ""))
+
+	_, err = client.Get("http://flaps.test/v1/apps/otherapp/machines")
+	assert.ErrorContains(t, err, "no recorded response for GET")
+}
+
+func TestInit(t *testing.T) {
+	t.Cleanup(func() { har, replay = nil, nil })
+
+	t.Setenv("FLYCTL_REPLAY_HAR", filepath.Join(t.TempDir(), "missing.har"))
+	assert.ErrorContains(t, Init(), "error loading HAR for replay")
+	assert.Nil(t, replay)
+
+	t.Setenv("FLYCTL_OUTPUT_HAR", filepath.Join(t.TempDir(), "out.har"))
+	assert.ErrorContains(t, Init(), "can't be set at the same time")
+	assert.Nil(t, har)
+}
+
+func TestScrub(t *testing.T) {
+	assert.Equal(t, `{"token":"REDACTED"}`, scrub(`{"token":"fo1_abc-DEF_123"}`))
+	assert.Equal(t, "REDACTED", scrub("FlyV1 fm2_lJPE,fm2_lJPO"))
+	assert.Equal(t, "nothing to see", scrub("nothing to see"))
+}
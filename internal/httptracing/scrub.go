@@ -0,0 +1,84 @@
+package httptracing
+
+import (
+	"encoding/base64"
+	"net/http"
+	"regexp"
+
+	"github.com/haileys/go-harlog"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveHeaders are blanked out of recorded requests and responses.
+var sensitiveHeaders = map[string]bool{
+	http.CanonicalHeaderKey("Authorization"):       true,
+	http.CanonicalHeaderKey("Cookie"):              true,
+	http.CanonicalHeaderKey("Set-Cookie"):          true,
+	http.CanonicalHeaderKey("Proxy-Authorization"): true,
+	http.CanonicalHeaderKey("Fly-Authorization"):   true,
+}
+
+// tokenPattern matches the API tokens and macaroons flyctl deals with,
+// wherever they show up in a body.
+var tokenPattern = regexp.MustCompile(`(FlyV1 |Bearer )?\b(fo1|fm1[ar]|fm2)_[A-Za-z0-9+/=_\-,]+`)
+
+// scrub removes credentials from a string.
+func scrub(s string) string {
+	return tokenPattern.ReplaceAllString(s, redacted)
+}
+
+// scrubEntries removes credentials from recorded entries, so HAR files can be
+// shared and checked in as test fixtures.
+func scrubEntries(entries []*harlog.Entry) {
+	for _, entry := range entries {
+		if req := entry.Request; req != nil {
+			req.URL = scrub(req.URL)
+			scrubHeaders(req.Headers)
+			scrubCookies(req.Cookies)
+			for _, q := range req.QueryString {
+				q.Value = scrub(q.Value)
+			}
+			if req.PostData != nil {
+				req.PostData.Text = scrub(req.PostData.Text)
+				for _, p := range req.PostData.Params {
+					p.Value = scrub(p.Value)
+				}
+			}
+		}
+		if resp := entry.Response; resp != nil {
+			scrubHeaders(resp.Headers)
+			scrubCookies(resp.Cookies)
+			if resp.Content != nil {
+				resp.Content.Text = scrubContent(resp.Content)
+			}
+		}
+	}
+}
+
+func scrubHeaders(headers []*harlog.NVP) {
+	for _, h := range headers {
+		if sensitiveHeaders[http.CanonicalHeaderKey(h.Name)] {
+			h.Value = redacted
+		} else {
+			h.Value = scrub(h.Value)
+		}
+	}
+}
+
+func scrubCookies(cookies []*harlog.Cookie) {
+	for _, c := range cookies {
+		c.Value = redacted
+	}
+}
+
+func scrubContent(content *harlog.Content) string {
+	if content.Encoding != "base64" {
+		return scrub(content.Text)
+	}
+	body, err := base64.StdEncoding.DecodeString(content.Text)
+	if err != nil {
+		return content.Text
+	}
+	return base64.StdEncoding.EncodeToString([]byte(scrub(string(body))))
+}
@@ -0,0 +1,379 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	fly "github.com/superfly/fly-go"
+)
+
+// GetApp returns a copy of the app named appName.
+func (s *Server) GetApp(ctx context.Context, appName string) (*fly.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, err := s.app(appName)
+	if err != nil {
+		return nil, err
+	}
+	other := *app
+	return &other, nil
+}
+
+// ListApps returns the apps of the organization with slug org, or all apps if
+// org is empty.
+func (s *Server) ListApps(ctx context.Context, org string) ([]fly.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if org != "" {
+		if _, err := s.org(org); err != nil {
+			return nil, err
+		}
+	}
+
+	apps := make([]fly.App, 0, len(s.apps))
+	for _, app := range s.apps {
+		if org == "" || app.Organization.Slug == org {
+			apps = append(apps, *app)
+		}
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+	return apps, nil
+}
+
+// DeleteApp deletes an app along with its machines, volumes, secrets and IP
+// addresses.
+func (s *Server) DeleteApp(ctx context.Context, appName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.app(appName); err != nil {
+		return err
+	}
+	for _, machine := range s.machines[appName] {
+		delete(s.leases, machine.ID)
+		delete(s.cordoned, machine.ID)
+	}
+	for _, vol := range s.volumes[appName] {
+		delete(s.snapshots, vol.ID)
+	}
+	delete(s.apps, appName)
+	delete(s.machines, appName)
+	delete(s.volumes, appName)
+	delete(s.secrets, appName)
+	delete(s.ipAddresses, appName)
+	s.notify()
+	return nil
+}
+
+// MoveApp moves an app to the organization with id orgID.
+func (s *Server) MoveApp(ctx context.Context, appName, orgID string) (*fly.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, err := s.app(appName)
+	if err != nil {
+		return nil, err
+	}
+	org, err := s.orgByIDLocked(orgID)
+	if err != nil {
+		return nil, err
+	}
+	app.Organization = *org
+
+	other := *app
+	return &other, nil
+}
+
+// org returns the organization with slug. s.mu must be held.
+func (s *Server) org(slug string) (*fly.Organization, error) {
+	org := s.orgs[slug]
+	if org == nil {
+		return nil, notFoundError("organization not found: %q", slug)
+	}
+	return org, nil
+}
+
+// orgByIDLocked returns the organization with id. s.mu must be held.
+func (s *Server) orgByIDLocked(id string) (*fly.Organization, error) {
+	for _, org := range s.orgs {
+		if org.ID == id {
+			return org, nil
+		}
+	}
+	return nil, notFoundError("organization not found: %q", id)
+}
+
+// orgByID returns a copy of the organization with id.
+func (s *Server) orgByID(id string) (*fly.Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	org, err := s.orgByIDLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	other := *org
+	return &other, nil
+}
+
+// GetOrganization returns a copy of the organization with slug.
+func (s *Server) GetOrganization(ctx context.Context, slug string) (*fly.Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	org, err := s.org(slug)
+	if err != nil {
+		return nil, err
+	}
+	other := *org
+	return &other, nil
+}
+
+func (s *Server) ListOrganizations(ctx context.Context) []fly.Organization {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orgs := make([]fly.Organization, 0, len(s.orgs))
+	for _, org := range s.orgs {
+		orgs = append(orgs, *org)
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].Slug < orgs[j].Slug })
+	return orgs
+}
+
+// CreateOrganization creates a shared organization named name.
+func (s *Server) CreateOrganization(ctx context.Context, name string) (*fly.Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if name == "" {
+		return nil, badRequestError("organization name is required")
+	}
+	if _, ok := s.orgs[name]; ok {
+		return nil, apiError(http.StatusUnprocessableEntity, "organization %q already exists", name)
+	}
+
+	org := s.createOrg(name)
+	other := *org
+	return &other, nil
+}
+
+// createOrg registers a new organization with slug. s.mu must be held.
+func (s *Server) createOrg(slug string) *fly.Organization {
+	s.orgSeq++
+	org := &fly.Organization{
+		ID:                fmt.Sprintf("ORG%d", s.orgSeq),
+		Slug:              slug,
+		Name:              slug,
+		RawSlug:           slug,
+		PaidPlan:          true,
+		Type:              "SHARED",
+		InternalNumericID: strconv.Itoa(s.orgSeq),
+	}
+	if slug == "personal" {
+		org.Type = "PERSONAL"
+	}
+	s.orgs[slug] = org
+	return org
+}
+
+// DeleteOrganization deletes an organization that has no apps left.
+func (s *Server) DeleteOrganization(ctx context.Context, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	org, err := s.orgByIDLocked(id)
+	if err != nil {
+		return "", err
+	}
+	for _, app := range s.apps {
+		if app.Organization.ID == id {
+			return "", preconditionFailedError("organization %s still has apps", org.Slug)
+		}
+	}
+	delete(s.orgs, org.Slug)
+	return id, nil
+}
+
+// ListReleases returns the releases of appName, newest first. Releases are
+// filtered by status unless it's empty, and limited to limit unless it's 0.
+func (s *Server) ListReleases(ctx context.Context, appName, status string, limit int) ([]fly.Release, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, err := s.app(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []fly.Release
+	for _, r := range s.releases {
+		if r.AppID != app.ID || (status != "" && r.Status != status) {
+			continue
+		}
+		releases = append(releases, fly.Release{
+			ID:                 r.ID,
+			Version:            r.Version,
+			Stable:             r.Status == "complete",
+			InProgress:         r.Status == "pending" || r.Status == "running",
+			Status:             r.Status,
+			DeploymentStrategy: r.Strategy,
+			ImageRef:           r.Image,
+			CreatedAt:          r.CreatedAt,
+		})
+	}
+	sort.Slice(releases, func(i, j int) bool { return releases[i].Version > releases[j].Version })
+	if limit > 0 && len(releases) > limit {
+		releases = releases[:limit]
+	}
+	return releases, nil
+}
+
+// AllocateIPAddress allocates an address of addrType (v4, v6, shared_v4 or
+// private_v6) to appName.
+func (s *Server) AllocateIPAddress(ctx context.Context, appName, addrType, region, network string) (*fly.IPAddress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.app(appName); err != nil {
+		return nil, err
+	}
+
+	s.ipSeq++
+	n := s.ipSeq
+	ip := fly.IPAddress{
+		ID:        fmt.Sprintf("ip_%d", n),
+		Type:      addrType,
+		Region:    region,
+		CreatedAt: time.Now().UTC(),
+	}
+	if network != "" {
+		ip.Network = &struct {
+			Name         string
+			Organization *struct{ Slug string }
+		}{Name: network}
+	}
+	if region == "" {
+		ip.Region = "global"
+	}
+	switch addrType {
+	case "v4":
+		ip.Address = fmt.Sprintf("137.66.%d.%d", n>>8&0xff, n&0xff)
+	case "shared_v4":
+		ip.Address = "66.241.124.1"
+	case "v6":
+		ip.Address = fmt.Sprintf("2a09:8280:1::%x", n)
+	case "private_v6":
+		ip.Address = fmt.Sprintf("fdaa:0:1::%x", n)
+	default:
+		return nil, badRequestError("unsupported ip address type: %q", addrType)
+	}
+
+	s.ipAddresses[appName] = append(s.ipAddresses[appName], ip)
+	return &ip, nil
+}
+
+func (s *Server) ListIPAddresses(ctx context.Context, appName string) ([]fly.IPAddress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.app(appName); err != nil {
+		return nil, err
+	}
+	return append([]fly.IPAddress(nil), s.ipAddresses[appName]...), nil
+}
+
+func (s *Server) ReleaseIPAddress(ctx context.Context, appName, address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.app(appName); err != nil {
+		return err
+	}
+	for i, ip := range s.ipAddresses[appName] {
+		if ip.Address == address {
+			s.ipAddresses[appName] = append(s.ipAddresses[appName][:i:i], s.ipAddresses[appName][i+1:]...)
+			return nil
+		}
+	}
+	return notFoundError("ip address not found: %q", address)
+}
+
+// AppLockTTL is how long app locks last before they expire on their own.
+var AppLockTTL = 30 * time.Minute
+
+// appByID returns the app with id. s.mu must be held.
+func (s *Server) appByID(id string) (*fly.App, error) {
+	for _, app := range s.apps {
+		if app.ID == id {
+			return app, nil
+		}
+	}
+	return nil, notFoundError("app not found: %q", id)
+}
+
+// LockApp locks the app with appID, failing if it's already locked.
+func (s *Server) LockApp(ctx context.Context, appID string) (*fly.LockApp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, err := s.appByID(appID)
+	if err != nil {
+		return nil, err
+	}
+	if lock := s.appLockLocked(app.Name); lock != nil {
+		return nil, fmt.Errorf("app %s is already locked", app.Name)
+	}
+
+	lock := &fly.LockApp{LockID: "lock_" + randomHex(8), Expiration: time.Now().Add(AppLockTTL).UTC()}
+	s.appLocks[app.Name] = lock
+	other := *lock
+	return &other, nil
+}
+
+// UnlockApp releases the lock of the app with appID if its ID is lockID.
+func (s *Server) UnlockApp(ctx context.Context, appID, lockID string) (*fly.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, err := s.appByID(appID)
+	if err != nil {
+		return nil, err
+	}
+	if lock := s.appLockLocked(app.Name); lock == nil || lock.LockID != lockID {
+		return nil, fmt.Errorf("app %s isn't locked with lock %s", app.Name, lockID)
+	}
+	delete(s.appLocks, app.Name)
+	other := *app
+	return &other, nil
+}
+
+// AppLock returns the unexpired lock of appName, or nil if it isn't locked.
+func (s *Server) AppLock(ctx context.Context, appName string) (*fly.LockApp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.app(appName); err != nil {
+		return nil, err
+	}
+	if lock := s.appLockLocked(appName); lock != nil {
+		other := *lock
+		return &other, nil
+	}
+	return nil, nil
+}
+
+// appLockLocked returns the unexpired lock of appName. s.mu must be held.
+func (s *Server) appLockLocked(appName string) *fly.LockApp {
+	lock := s.appLocks[appName]
+	if lock == nil || time.Now().After(lock.Expiration) {
+		return nil
+	}
+	return lock
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/ed25519"
 	"fmt"
 	"net"
+	"slices"
+	"time"
 
 	genq "github.com/Khan/genqlient/graphql"
 	fly "github.com/superfly/fly-go"
@@ -22,7 +24,8 @@ var DefaultUser = fly.User{
 var _ flyutil.Client = (*Client)(nil)
 
 type Client struct {
-	server *Server
+	server     *Server
+	genqClient genq.Client
 
 	CurrentUser *fly.User
 }
@@ -37,27 +40,35 @@ func NewClient(s *Server) *Client {
 }
 
 func (m *Client) AddCertificate(ctx context.Context, appName, hostname string) (*fly.AppCertificate, *fly.HostnameCheck, error) {
-	panic("TODO")
+	return nil, nil, unsupported("AddCertificate")
 }
 
 func (m *Client) AllocateIPAddress(ctx context.Context, appName string, addrType string, region string, org *fly.Organization, network string) (*fly.IPAddress, error) {
-	panic("TODO")
+	return m.server.AllocateIPAddress(ctx, appName, addrType, region, network)
 }
 
 func (m *Client) AllocateSharedIPAddress(ctx context.Context, appName string) (net.IP, error) {
-	panic("TODO")
+	ip, err := m.server.AllocateIPAddress(ctx, appName, "shared_v4", "", "")
+	if err != nil {
+		return nil, err
+	}
+	return net.ParseIP(ip.Address), nil
 }
 
 func (m *Client) AllocateEgressIPAddress(ctx context.Context, appName string, machineId string) (net.IP, net.IP, error) {
-	panic("TODO")
+	return nil, nil, unsupported("AllocateEgressIPAddress")
 }
 
 func (m *Client) AppNameAvailable(ctx context.Context, appName string) (bool, error) {
-	panic("TODO")
+	m.server.mu.Lock()
+	defer m.server.mu.Unlock()
+
+	_, taken := m.server.apps[appName]
+	return !taken, nil
 }
 
 func (m *Client) AttachPostgresCluster(ctx context.Context, input fly.AttachPostgresClusterInput) (*fly.AttachPostgresClusterPayload, error) {
-	panic("TODO")
+	return nil, unsupported("AttachPostgresCluster")
 }
 
 func (m *Client) Authenticated() bool {
@@ -65,27 +76,35 @@ func (m *Client) Authenticated() bool {
 }
 
 func (m *Client) CanPerformBluegreenDeployment(ctx context.Context, appName string) (bool, error) {
-	panic("TODO")
+	_, err := m.GetAppCompact(ctx, appName)
+	return err == nil, err
 }
 
 func (m *Client) CheckAppCertificate(ctx context.Context, appName, hostname string) (*fly.AppCertificate, *fly.HostnameCheck, error) {
-	panic("TODO")
+	return nil, nil, unsupported("CheckAppCertificate")
 }
 
 func (m *Client) CheckDomain(ctx context.Context, name string) (*fly.CheckDomainResult, error) {
-	panic("TODO")
+	return nil, unsupported("CheckDomain")
 }
 
 func (m *Client) ClosestWireguardGatewayRegion(ctx context.Context) (*fly.Region, error) {
-	panic("TODO")
+	return m.GetNearestRegion(ctx)
 }
 
 func (m *Client) CreateAndRegisterDomain(organizationID string, name string) (*fly.Domain, error) {
-	panic("TODO")
+	return nil, unsupported("CreateAndRegisterDomain")
 }
 
 func (m *Client) CreateApp(ctx context.Context, input fly.CreateAppInput) (*fly.App, error) {
-	panic("TODO")
+	org, err := m.server.orgByID(input.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.server.CreateAppInOrg(ctx, input.Name, org.Slug); err != nil {
+		return nil, err
+	}
+	return m.GetApp(ctx, input.Name)
 }
 
 func (m *Client) CreateBuild(ctx context.Context, input fly.CreateBuildInput) (*fly.CreateBuildResponse, error) {
@@ -101,23 +120,23 @@ func (m *Client) CreateBuild(ctx context.Context, input fly.CreateBuildInput) (*
 }
 
 func (m *Client) CreateDelegatedWireGuardToken(ctx context.Context, org *fly.Organization, name string) (*fly.DelegatedWireGuardToken, error) {
-	panic("TODO")
+	return nil, unsupported("CreateDelegatedWireGuardToken")
 }
 
 func (m *Client) CreateDoctorUrl(ctx context.Context) (putUrl string, err error) {
-	panic("TODO")
+	return "", unsupported("CreateDoctorUrl")
 }
 
 func (m *Client) CreateDomain(organizationID string, name string) (*fly.Domain, error) {
-	panic("TODO")
+	return nil, unsupported("CreateDomain")
 }
 
 func (m *Client) CreateOrganization(ctx context.Context, organizationname string) (*fly.Organization, error) {
-	panic("TODO")
+	return m.server.CreateOrganization(ctx, organizationname)
 }
 
 func (m *Client) CreateOrganizationInvite(ctx context.Context, id, email string) (*fly.Invitation, error) {
-	panic("TODO")
+	return nil, unsupported("CreateOrganizationInvite")
 }
 
 func (m *Client) CreateRelease(ctx context.Context, input fly.CreateReleaseInput) (*fly.CreateReleaseResponse, error) {
@@ -133,47 +152,47 @@ func (m *Client) CreateRelease(ctx context.Context, input fly.CreateReleaseInput
 }
 
 func (m *Client) CreateWireGuardPeer(ctx context.Context, org *fly.Organization, region, name, pubkey, network string) (*fly.CreatedWireGuardPeer, error) {
-	panic("TODO")
+	return nil, unsupported("CreateWireGuardPeer")
 }
 
 func (m *Client) DeleteApp(ctx context.Context, appName string) error {
-	panic("TODO")
+	return m.server.DeleteApp(ctx, appName)
 }
 
 func (m *Client) DeleteCertificate(ctx context.Context, appName, hostname string) (*fly.DeleteCertificatePayload, error) {
-	panic("TODO")
+	return nil, unsupported("DeleteCertificate")
 }
 
 func (m *Client) DeleteDelegatedWireGuardToken(ctx context.Context, org *fly.Organization, name, token *string) error {
-	panic("TODO")
+	return unsupported("DeleteDelegatedWireGuardToken")
 }
 
 func (m *Client) DeleteOrganization(ctx context.Context, id string) (deletedid string, err error) {
-	panic("TODO")
+	return m.server.DeleteOrganization(ctx, id)
 }
 
 func (m *Client) DeleteOrganizationMembership(ctx context.Context, orgId, userId string) (string, string, error) {
-	panic("TODO")
+	return "", "", unsupported("DeleteOrganizationMembership")
 }
 
 func (m *Client) DetachPostgresCluster(ctx context.Context, input fly.DetachPostgresClusterInput) error {
-	panic("TODO")
+	return unsupported("DetachPostgresCluster")
 }
 
 func (m *Client) EnablePostgresConsul(ctx context.Context, appName string) (*fly.PostgresEnableConsulPayload, error) {
-	panic("TODO")
+	return nil, unsupported("EnablePostgresConsul")
 }
 
 func (m *Client) EnsureRemoteBuilder(ctx context.Context, orgID, appName, region string) (*fly.GqlMachine, *fly.App, error) {
-	panic("TODO")
+	return nil, nil, unsupported("EnsureRemoteBuilder")
 }
 
 func (m *Client) EnsureDepotRemoteBuilder(ctx context.Context, input *fly.EnsureDepotRemoteBuilderInput) (*fly.EnsureDepotRemoteBuilderResponse, error) {
-	panic("TODO")
+	return nil, unsupported("EnsureDepotRemoteBuilder")
 }
 
 func (m *Client) ExportDNSRecords(ctx context.Context, domainId string) (string, error) {
-	panic("TODO")
+	return "", unsupported("ExportDNSRecords")
 }
 
 func (m *Client) FinishBuild(ctx context.Context, input fly.FinishBuildInput) (*fly.FinishBuildResponse, error) {
@@ -189,83 +208,133 @@ func (m *Client) FinishBuild(ctx context.Context, input fly.FinishBuildInput) (*
 }
 
 func (m *Client) GetApp(ctx context.Context, appName string) (*fly.App, error) {
-	panic("TODO")
+	return m.server.GetApp(ctx, appName)
 }
 
 func (m *Client) GetAppBasic(ctx context.Context, appName string) (*fly.AppBasic, error) {
-	panic("TODO")
+	app, err := m.GetAppCompact(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+	return &fly.AppBasic{
+		ID:              app.ID,
+		Name:            app.Name,
+		PlatformVersion: app.PlatformVersion,
+		Organization:    app.Organization,
+	}, nil
 }
 
 func (m *Client) GetAppCertificates(ctx context.Context, appName string) ([]fly.AppCertificateCompact, error) {
-	panic("TODO")
+	return nil, unsupported("GetAppCertificates")
 }
 
 func (m *Client) GetAppCompact(ctx context.Context, appName string) (*fly.AppCompact, error) {
-	m.server.mu.Lock()
-	defer m.server.mu.Unlock()
-
-	app := m.server.apps[appName]
-	if app == nil {
-		return nil, fmt.Errorf("app not found: %q", appName) // TODO: Match actual error
+	app, err := m.server.GetApp(ctx, appName)
+	if err != nil {
+		return nil, err
 	}
-
 	return app.Compact(), nil
 }
 
 func (m *Client) GetAppCurrentReleaseMachines(ctx context.Context, appName string) (*fly.Release, error) {
-	panic("TODO")
+	releases, err := m.GetAppReleasesMachines(ctx, appName, "", 1)
+	if err != nil || len(releases) == 0 {
+		return nil, err
+	}
+	return &releases[0], nil
 }
 
 func (m *Client) GetAppCNAMETarget(ctx context.Context, appName string) (string, error) {
-	panic("TODO")
+	app, err := m.GetAppCompact(ctx, appName)
+	if err != nil {
+		return "", err
+	}
+	return app.Hostname, nil
 }
 
 func (m *Client) GetAppHostIssues(ctx context.Context, appName string) ([]fly.HostIssue, error) {
-	panic("TODO")
+	_, err := m.GetAppCompact(ctx, appName)
+	return nil, err
 }
 
 func (m *Client) GetAppLimitedAccessTokens(ctx context.Context, appName string) ([]fly.LimitedAccessToken, error) {
-	panic("TODO")
+	return nil, unsupported("GetAppLimitedAccessTokens")
 }
 
 func (m *Client) GetAppRemoteBuilder(ctx context.Context, appName string) (*fly.App, error) {
-	panic("TODO")
+	return nil, unsupported("GetAppRemoteBuilder")
 }
 
 func (m *Client) GetDeployerAppByOrg(ctx context.Context, orgID string) (*fly.App, error) {
-	panic("TODO")
+	return nil, unsupported("GetDeployerAppByOrg")
+}
+
+// GetAppLock returns the app with only its current lock set, like the
+// GraphQL query does.
+func (m *Client) GetAppLock(ctx context.Context, name string) (*fly.App, error) {
+	lock, err := m.server.AppLock(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	app := &fly.App{}
+	if lock != nil {
+		app.CurrentLock = &struct {
+			LockID     string
+			Expiration string
+		}{lock.LockID, lock.Expiration.Format(time.RFC3339)}
+	}
+	return app, nil
 }
 
 func (m *Client) GetAppLogs(ctx context.Context, appName, token, region, instanceID string) (entries []fly.LogEntry, nextToken string, err error) {
-	panic("TODO")
+	// Nothing runs, so there are never any logs.
+	_, err = m.GetAppCompact(ctx, appName)
+	return nil, token, err
 }
 
 func (m *Client) GetAppNameFromVolume(ctx context.Context, volID string) (*string, error) {
-	panic("TODO")
+	appName, _, err := m.GetAppNameStateFromVolume(ctx, volID)
+	return appName, err
 }
 
 func (m *Client) GetAppNameStateFromVolume(ctx context.Context, volID string) (*string, *string, error) {
-	panic("TODO")
+	m.server.mu.Lock()
+	defer m.server.mu.Unlock()
+
+	appName, vol := m.server.findVolume(volID)
+	if vol == nil {
+		return nil, nil, fmt.Errorf("volume not found: %q", volID)
+	}
+	state := vol.State
+	return &appName, &state, nil
 }
 
 func (m *Client) GetAppNetwork(ctx context.Context, appName string) (*string, error) {
-	panic("TODO")
+	app, err := m.GetAppCompact(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+	return &app.Network, nil
 }
 
 func (m *Client) GetAppReleasesMachines(ctx context.Context, appName, status string, limit int) ([]fly.Release, error) {
-	panic("TODO")
+	return m.server.ListReleases(ctx, appName, status, limit)
 }
 
 func (m *Client) GetAppSecrets(ctx context.Context, appName string) ([]fly.Secret, error) {
-	panic("TODO")
+	return m.server.graphqlSecrets(appName)
 }
 
 func (m *Client) GetApps(ctx context.Context, role *string) ([]fly.App, error) {
-	panic("TODO")
+	return m.server.ListApps(ctx, "")
 }
 
 func (m *Client) GetAppsForOrganization(ctx context.Context, orgID string) ([]fly.App, error) {
-	panic("TODO")
+	org, err := m.server.orgByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	return m.server.ListApps(ctx, org.Slug)
 }
 
 func (m *Client) GetCurrentUser(ctx context.Context) (*fly.User, error) {
@@ -273,131 +342,177 @@ func (m *Client) GetCurrentUser(ctx context.Context) (*fly.User, error) {
 }
 
 func (m *Client) GetDNSRecords(ctx context.Context, domainName string) ([]*fly.DNSRecord, error) {
-	panic("TODO")
+	return nil, unsupported("GetDNSRecords")
 }
 
 func (m *Client) GetDelegatedWireGuardTokens(ctx context.Context, slug string) ([]*fly.DelegatedWireGuardTokenHandle, error) {
-	panic("TODO")
+	return nil, unsupported("GetDelegatedWireGuardTokens")
 }
 
 func (m *Client) GetDetailedOrganizationBySlug(ctx context.Context, slug string) (*fly.OrganizationDetails, error) {
-	panic("TODO")
+	org, err := m.GetOrganizationBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	apps, err := m.server.ListApps(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &fly.OrganizationDetails{
+		ID:                org.ID,
+		InternalNumericID: org.InternalNumericID,
+		Name:              org.Name,
+		Slug:              org.Slug,
+		Type:              org.Type,
+		ViewerRole:        "admin",
+	}
+	details.Apps.Nodes = apps
+	details.Members.Edges = []fly.OrganizationMembershipEdge{{Node: *m.CurrentUser, Role: "admin"}}
+	return details, nil
 }
 
 func (m *Client) GetDomain(ctx context.Context, name string) (*fly.Domain, error) {
-	panic("TODO")
+	return nil, unsupported("GetDomain")
 }
 
 func (m *Client) GetDomains(ctx context.Context, organizationSlug string) ([]*fly.Domain, error) {
-	panic("TODO")
+	return nil, unsupported("GetDomains")
 }
 
 func (m *Client) GetIPAddresses(ctx context.Context, appName string) ([]fly.IPAddress, error) {
-	return nil, nil // TODO
+	return m.server.ListIPAddresses(ctx, appName)
 }
 
 func (m *Client) GetEgressIPAddresses(ctx context.Context, appName string) (map[string][]fly.EgressIPAddress, error) {
-	panic("TODO")
+	return nil, unsupported("GetEgressIPAddresses")
 }
 
 func (m *Client) GetLatestImageDetails(ctx context.Context, image string, flyVersion string) (*fly.ImageVersion, error) {
-	panic("TODO")
+	return nil, unsupported("GetLatestImageDetails")
 }
 
 func (m *Client) GetLatestImageTag(ctx context.Context, repository string, snapshotId *string) (string, error) {
-	panic("TODO")
+	return "", unsupported("GetLatestImageTag")
 }
 
 func (m *Client) GetLoggedCertificates(ctx context.Context, slug string) ([]fly.LoggedCertificate, error) {
-	panic("TODO")
+	return nil, unsupported("GetLoggedCertificates")
 }
 
 func (m *Client) GetMachine(ctx context.Context, machineId string) (*fly.GqlMachine, error) {
-	panic("TODO")
+	return nil, unsupported("GetMachine")
 }
 
 func (m *Client) GetNearestRegion(ctx context.Context) (*fly.Region, error) {
-	panic("TODO")
+	for _, region := range Regions {
+		if region.Code == nearestRegion {
+			return &region, nil
+		}
+	}
+	return nil, fmt.Errorf("region not found: %q", nearestRegion)
 }
 
 func (m *Client) GetOrganizationByApp(ctx context.Context, appName string) (*fly.Organization, error) {
-	app, err := m.GetAppCompact(ctx, appName)
+	app, err := m.server.GetApp(ctx, appName)
 	if err != nil {
 		return nil, err
 	}
-	return &fly.Organization{ID: app.Organization.ID}, nil
+	return &app.Organization, nil
 }
 
 func (m *Client) GetOrganizationBySlug(ctx context.Context, slug string) (*fly.Organization, error) {
-	panic("TODO")
+	return m.server.GetOrganization(ctx, slug)
 }
 
 func (m *Client) GetOrganizationRemoteBuilderBySlug(ctx context.Context, slug string) (*fly.Organization, error) {
-	panic("TODO")
+	return m.GetOrganizationBySlug(ctx, slug)
 }
 
 func (m *Client) GetOrganizations(ctx context.Context, filters ...fly.OrganizationFilter) ([]fly.Organization, error) {
-	panic("TODO")
+	return m.server.ListOrganizations(ctx), nil
 }
 
 func (m *Client) GetSnapshotsFromVolume(ctx context.Context, volID string) ([]fly.VolumeSnapshot, error) {
-	panic("TODO")
+	appName, err := m.GetAppNameFromVolume(ctx, volID)
+	if err != nil {
+		return nil, err
+	}
+	return m.server.GetVolumeSnapshots(ctx, *appName, volID)
 }
 
 func (m *Client) GetWireGuardPeer(ctx context.Context, slug, name string) (*fly.WireGuardPeer, error) {
-	panic("TODO")
+	return nil, unsupported("GetWireGuardPeer")
 }
 
 func (m *Client) GetWireGuardPeers(ctx context.Context, slug string) ([]*fly.WireGuardPeer, error) {
-	panic("TODO")
+	return nil, unsupported("GetWireGuardPeers")
 }
 
 func (m *Client) GenqClient() genq.Client {
-	panic("TODO")
+	if m.genqClient != nil {
+		return m.genqClient
+	}
+	return unsupportedGenqClient{}
 }
 
 func (m *Client) LatestImage(ctx context.Context, appName string) (string, error) {
-	panic("TODO")
+	releases, err := m.GetAppReleasesMachines(ctx, appName, "", 1)
+	if err != nil {
+		return "", err
+	}
+	if len(releases) == 0 {
+		return "", fmt.Errorf("app %q has no releases", appName)
+	}
+	return releases[0].ImageRef, nil
 }
 
 func (m *Client) ImportDNSRecords(ctx context.Context, domainId string, zonefile string) ([]fly.ImportDnsWarning, []fly.ImportDnsChange, error) {
-	panic("TODO")
+	return nil, nil, unsupported("ImportDNSRecords")
 }
 
 func (m *Client) IssueSSHCertificate(ctx context.Context, org fly.OrganizationImpl, principals []string, appNames []string, valid_hours *int, publicKey ed25519.PublicKey) (*fly.IssuedCertificate, error) {
-	panic("TODO")
+	return nil, unsupported("IssueSSHCertificate")
 }
 
 func (m *Client) ListPostgresClusterAttachments(ctx context.Context, appName, postgresAppName string) ([]*fly.PostgresClusterAttachment, error) {
-	panic("TODO")
+	return nil, unsupported("ListPostgresClusterAttachments")
+}
+
+func (m *Client) LockApp(ctx context.Context, input fly.LockAppInput) (*fly.LockApp, error) {
+	return m.server.LockApp(ctx, input.AppID)
 }
 
 func (m *Client) Logger() fly.Logger {
-	panic("TODO")
+	return nopLogger{}
 }
 
 func (m *Client) MoveApp(ctx context.Context, appName string, orgID string) (*fly.App, error) {
-	panic("TODO")
+	return m.server.MoveApp(ctx, appName, orgID)
 }
 
 func (m *Client) NewRequest(q string) *graphql.Request {
-	panic("TODO")
+	return graphql.NewRequest(q)
 }
 
 func (m *Client) PlatformRegions(ctx context.Context) ([]fly.Region, *fly.Region, error) {
-	panic("TODO")
+	nearest, err := m.GetNearestRegion(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return slices.Clone(Regions), nearest, nil
 }
 
 func (m *Client) ReleaseEgressIPAddress(ctx context.Context, appName string, machineID string) (net.IP, net.IP, error) {
-	panic("TODO")
+	return nil, nil, unsupported("ReleaseEgressIPAddress")
 }
 
 func (m *Client) ReleaseIPAddress(ctx context.Context, appName string, ip string) error {
-	panic("TODO")
+	return m.server.ReleaseIPAddress(ctx, appName, ip)
 }
 
 func (m *Client) RemoveWireGuardPeer(ctx context.Context, org *fly.Organization, name string) error {
-	panic("TODO")
+	return unsupported("RemoveWireGuardPeer")
 }
 
 func (m *Client) ResolveImageForApp(ctx context.Context, appName, imageRef string) (*fly.Image, error) {
@@ -412,23 +527,27 @@ func (m *Client) ResolveImageForApp(ctx context.Context, appName, imageRef strin
 }
 
 func (m *Client) RevokeLimitedAccessToken(ctx context.Context, id string) error {
-	panic("TODO")
+	return unsupported("RevokeLimitedAccessToken")
 }
 
 func (m *Client) Run(req *graphql.Request) (fly.Query, error) {
-	panic("TODO")
+	return fly.Query{}, unsupported("Run")
 }
 
 func (m *Client) RunWithContext(ctx context.Context, req *graphql.Request) (fly.Query, error) {
-	panic("TODO")
+	return fly.Query{}, unsupported("RunWithContext")
 }
 
 func (m *Client) SetGenqClient(client genq.Client) {
-	panic("TODO")
+	m.genqClient = client
 }
 
 func (m *Client) SetSecrets(ctx context.Context, appName string, secrets map[string]string) (*fly.Release, error) {
-	panic("TODO")
+	version, err := m.server.SetAppSecrets(ctx, appName, secrets, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &fly.Release{Version: int(version)}, nil
 }
 
 func (m *Client) UpdateRelease(ctx context.Context, input fly.UpdateReleaseInput) (*fly.UpdateReleaseResponse, error) {
@@ -441,10 +560,36 @@ func (m *Client) UpdateRelease(ctx context.Context, input fly.UpdateReleaseInput
 	return &resp, nil
 }
 
+func (m *Client) UnlockApp(ctx context.Context, input fly.UnlockAppInput) (*fly.App, error) {
+	return m.server.UnlockApp(ctx, input.AppID, input.LockID)
+}
+
 func (m *Client) UnsetSecrets(ctx context.Context, appName string, keys []string) (*fly.Release, error) {
-	panic("TODO")
+	version, err := m.server.SetAppSecrets(ctx, appName, nil, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &fly.Release{Version: int(version)}, nil
 }
 
 func (m *Client) ValidateWireGuardPeers(ctx context.Context, peerIPs []string) (invalid []string, err error) {
-	panic("TODO")
+	return nil, unsupported("ValidateWireGuardPeers")
 }
+
+// unsupported is returned by the GraphQL API calls the in-memory server
+// doesn't emulate.
+func unsupported(name string) error {
+	return fmt.Errorf("%s is not supported by the in-memory API", name)
+}
+
+// unsupportedGenqClient is used until a client is set with SetGenqClient.
+type unsupportedGenqClient struct{}
+
+func (unsupportedGenqClient) MakeRequest(ctx context.Context, req *genq.Request, resp *genq.Response) error {
+	return unsupported(req.OpName)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(v ...interface{})                 {}
+func (nopLogger) Debugf(format string, v ...interface{}) {}
@@ -1,9 +1,13 @@
 package inmem
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"time"
 
 	fly "github.com/superfly/fly-go"
@@ -25,59 +29,61 @@ func NewFlapsClient(server *Server, appName string) *FlapsClient {
 }
 
 func (m *FlapsClient) AcquireLease(ctx context.Context, machineID string, ttl *int) (*fly.MachineLease, error) {
-	panic("TODO")
+	return m.server.AcquireLease(ctx, m.appName, machineID, derefInt(ttl))
 }
 
 func (m *FlapsClient) Cordon(ctx context.Context, machineID string, nonce string) (err error) {
-	panic("TODO")
+	return m.server.SetCordon(ctx, m.appName, machineID, nonce, true)
 }
 
 func (m *FlapsClient) CreateApp(ctx context.Context, name string, org string) (err error) {
-	panic("TODO")
+	_, err = m.server.CreateAppInOrg(ctx, name, org)
+	return err
 }
 
 func (m *FlapsClient) CreateVolume(ctx context.Context, req fly.CreateVolumeRequest) (*fly.Volume, error) {
-	panic("TODO")
+	return m.server.CreateVolume(ctx, m.appName, req)
 }
 
 func (m *FlapsClient) CreateVolumeSnapshot(ctx context.Context, volumeId string) error {
-	panic("TODO")
+	return m.server.CreateVolumeSnapshot(ctx, m.appName, volumeId)
 }
 
 func (m *FlapsClient) DeleteMetadata(ctx context.Context, machineID, key string) error {
-	panic("TODO")
+	return m.server.DeleteMetadata(ctx, m.appName, machineID, key)
 }
 
 func (m *FlapsClient) DeleteAppSecret(ctx context.Context, name string) error {
-	panic("TODO")
+	_, err := m.server.SetAppSecrets(ctx, m.appName, nil, []string{name})
+	return err
 }
 
 func (m *FlapsClient) DeleteSecretKey(ctx context.Context, name string) error {
-	panic("TODO")
+	return m.server.DeleteSecretKey(ctx, m.appName, name)
 }
 
 func (m *FlapsClient) DeleteVolume(ctx context.Context, volumeId string) (*fly.Volume, error) {
-	panic("TODO")
+	return m.server.DeleteVolume(ctx, m.appName, volumeId)
 }
 
 func (m *FlapsClient) Destroy(ctx context.Context, input fly.RemoveMachineInput, nonce string) (err error) {
-	panic("TODO")
+	return m.server.DestroyMachine(ctx, m.appName, input, nonce)
 }
 
 func (m *FlapsClient) Exec(ctx context.Context, machineID string, in *fly.MachineExecRequest) (*fly.MachineExecResponse, error) {
-	panic("TODO")
+	return m.server.Exec(ctx, m.appName, machineID, in)
 }
 
 func (m *FlapsClient) ExtendVolume(ctx context.Context, volumeId string, size_gb int) (*fly.Volume, bool, error) {
-	panic("TODO")
+	return m.server.ExtendVolume(ctx, m.appName, volumeId, size_gb)
 }
 
 func (m *FlapsClient) FindLease(ctx context.Context, machineID string) (*fly.MachineLease, error) {
-	panic("TODO")
+	return m.server.FindLease(ctx, m.appName, machineID)
 }
 
 func (m *FlapsClient) GenerateSecretKey(ctx context.Context, name string, typ string) (*fly.SetSecretKeyResp, error) {
-	panic("TODO")
+	return m.server.SetSecretKey(ctx, m.appName, name, typ, nil)
 }
 
 func (m *FlapsClient) Get(ctx context.Context, machineID string) (*fly.Machine, error) {
@@ -85,64 +91,80 @@ func (m *FlapsClient) Get(ctx context.Context, machineID string) (*fly.Machine,
 }
 
 func (m *FlapsClient) GetAllVolumes(ctx context.Context) ([]fly.Volume, error) {
-	panic("TODO")
+	return m.server.ListVolumes(ctx, m.appName)
 }
 
 func (m *FlapsClient) GetMany(ctx context.Context, machineIDs []string) ([]*fly.Machine, error) {
-	panic("TODO")
+	machines := make([]*fly.Machine, 0, len(machineIDs))
+	for _, id := range machineIDs {
+		machine, err := m.Get(ctx, id)
+		if err != nil {
+			return machines, err
+		}
+		machines = append(machines, machine)
+	}
+	return machines, nil
 }
 
 func (m *FlapsClient) GetMetadata(ctx context.Context, machineID string) (map[string]string, error) {
-	panic("TODO")
+	return m.server.GetMetadata(ctx, m.appName, machineID)
 }
 
 func (m *FlapsClient) GetProcesses(ctx context.Context, machineID string) (fly.MachinePsResponse, error) {
-	return nil, nil // TODO
+	if _, err := m.Get(ctx, machineID); err != nil {
+		return nil, err
+	}
+	return fly.MachinePsResponse{}, nil
 }
 
 func (m *FlapsClient) GetVolume(ctx context.Context, volumeId string) (*fly.Volume, error) {
-	panic("TODO")
+	return m.server.GetVolume(ctx, m.appName, volumeId)
 }
 
 func (m *FlapsClient) GetVolumeSnapshots(ctx context.Context, volumeId string) ([]fly.VolumeSnapshot, error) {
-	panic("TODO")
+	return m.server.GetVolumeSnapshots(ctx, m.appName, volumeId)
 }
 
 func (m *FlapsClient) GetVolumes(ctx context.Context) ([]fly.Volume, error) {
-	panic("TODO")
+	volumes, err := m.GetAllVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(volumes, func(v fly.Volume) bool {
+		return v.State == volumeStatePendingDestroy
+	}), nil
 }
 
 func (m *FlapsClient) Kill(ctx context.Context, machineID string) (err error) {
-	panic("TODO")
+	return m.server.KillMachine(ctx, m.appName, machineID)
 }
 
 func (m *FlapsClient) Launch(ctx context.Context, builder fly.LaunchMachineInput) (out *fly.Machine, err error) {
-	return m.server.Launch(ctx, m.appName, builder.Name, builder.Region, builder.Config)
+	return m.server.LaunchMachine(ctx, m.appName, builder)
 }
 
 func (m *FlapsClient) List(ctx context.Context, state string) ([]*fly.Machine, error) {
-	panic("TODO")
+	return m.server.ListMachines(ctx, m.appName, state)
 }
 
 func (m *FlapsClient) ListActive(ctx context.Context) ([]*fly.Machine, error) {
-	m.server.mu.Lock()
-	defer m.server.mu.Unlock()
-
-	var a []*fly.Machine
-	for _, machine := range m.server.machines[m.appName] {
-		if !machine.IsReleaseCommandMachine() && !machine.IsFlyAppsConsole() && machine.IsActive() {
-			a = append(a, machine)
-		}
+	machines, err := m.List(ctx, "")
+	if err != nil {
+		return nil, err
 	}
-	return a, nil
+	return slices.DeleteFunc(machines, func(machine *fly.Machine) bool {
+		return machine.IsReleaseCommandMachine() || machine.IsFlyAppsConsole() || !machine.IsActive()
+	}), nil
 }
 
 func (m *FlapsClient) ListFlyAppsMachines(ctx context.Context) (machines []*fly.Machine, releaseCmdMachine *fly.Machine, err error) {
-	m.server.mu.Lock()
-	defer m.server.mu.Unlock()
+	all, err := m.List(ctx, "")
+	if err != nil {
+		return nil, nil, err
+	}
 
 	machines = make([]*fly.Machine, 0)
-	for _, machine := range m.server.machines[m.appName] {
+	for _, machine := range all {
 		if machine.IsFlyAppsPlatform() && machine.IsActive() && !machine.IsFlyAppsReleaseCommand() && !machine.IsFlyAppsConsole() {
 			machines = append(machines, machine)
 		} else if machine.IsFlyAppsReleaseCommand() {
@@ -153,79 +175,114 @@ func (m *FlapsClient) ListFlyAppsMachines(ctx context.Context) (machines []*fly.
 }
 
 func (m *FlapsClient) ListAppSecrets(ctx context.Context, version *uint64, showSecrets bool) ([]fly.AppSecret, error) {
-	panic("TODO")
+	return m.server.ListAppSecrets(ctx, m.appName, showSecrets)
 }
 
 func (m *FlapsClient) ListSecretKeys(ctx context.Context, version *uint64) ([]fly.SecretKey, error) {
-	panic("TODO")
+	return m.server.ListSecretKeys(ctx, m.appName)
 }
 
+// NewRequest builds a request for the Machines API of the app, to be served
+// by the server's Handler.
 func (m *FlapsClient) NewRequest(ctx context.Context, method, path string, in interface{}, headers map[string][]string) (*http.Request, error) {
-	panic("TODO")
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "http://inmem/v1"+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("could not create new request, %w", err)
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
 }
 
 func (m *FlapsClient) RefreshLease(ctx context.Context, machineID string, ttl *int, nonce string) (*fly.MachineLease, error) {
-	panic("TODO")
+	return m.server.RefreshLease(ctx, m.appName, machineID, derefInt(ttl), nonce)
 }
 
 func (m *FlapsClient) ReleaseLease(ctx context.Context, machineID, nonce string) error {
-	panic("TODO")
+	return m.server.ReleaseLease(ctx, m.appName, machineID, nonce)
 }
 
 func (m *FlapsClient) Restart(ctx context.Context, in fly.RestartMachineInput, nonce string) (err error) {
-	panic("TODO")
+	return m.server.RestartMachine(ctx, m.appName, in, nonce)
 }
 
 func (m *FlapsClient) SetMetadata(ctx context.Context, machineID, key, value string) error {
-	panic("TODO")
+	return m.server.SetMetadata(ctx, m.appName, machineID, key, value)
 }
 
 func (m *FlapsClient) SetAppSecret(ctx context.Context, name string, value string) (*fly.SetAppSecretResp, error) {
-	panic("TODO")
+	version, err := m.server.SetAppSecrets(ctx, m.appName, map[string]string{name: value}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &fly.SetAppSecretResp{
+		AppSecret: fly.AppSecret{Name: name, Digest: secretDigest(value)},
+		Version:   version,
+	}, nil
 }
 
 func (m *FlapsClient) SetSecretKey(ctx context.Context, name string, typ string, value []byte) (*fly.SetSecretKeyResp, error) {
-	panic("TODO")
+	if value == nil {
+		value = []byte{}
+	}
+	return m.server.SetSecretKey(ctx, m.appName, name, typ, value)
 }
 
 func (m *FlapsClient) Start(ctx context.Context, machineID string, nonce string) (out *fly.MachineStartResponse, err error) {
-	panic("TODO")
+	return m.server.StartMachine(ctx, m.appName, machineID, nonce)
 }
 
 func (m *FlapsClient) Stop(ctx context.Context, in fly.StopMachineInput, nonce string) (err error) {
-	panic("TODO")
+	return m.server.StopMachine(ctx, m.appName, in, nonce)
 }
 
 func (m *FlapsClient) Suspend(ctx context.Context, machineID, nonce string) (err error) {
-	panic("TODO")
+	return m.server.SuspendMachine(ctx, m.appName, machineID, nonce)
 }
 
 func (m *FlapsClient) Uncordon(ctx context.Context, machineID string, nonce string) (err error) {
-	panic("TODO")
+	return m.server.SetCordon(ctx, m.appName, machineID, nonce, false)
 }
 
 func (m *FlapsClient) Update(ctx context.Context, builder fly.LaunchMachineInput, nonce string) (out *fly.Machine, err error) {
-	panic("TODO")
+	return m.server.UpdateMachine(ctx, m.appName, builder, nonce)
 }
 
 func (m *FlapsClient) UpdateVolume(ctx context.Context, volumeId string, req fly.UpdateVolumeRequest) (*fly.Volume, error) {
-	panic("TODO")
+	return m.server.UpdateVolume(ctx, m.appName, volumeId, req)
 }
 
 func (m *FlapsClient) Wait(ctx context.Context, machine *fly.Machine, state string, timeout time.Duration) (err error) {
-	if state == "" {
-		state = "started"
-	}
-	mach, err := m.server.GetMachine(ctx, m.appName, machine.ID)
-	if err != nil {
-		return err
-	}
-	if mach.State != state {
-		return fmt.Errorf("machine did not reach state %q, current state is %q", state, mach.State)
+	if timeout < time.Second {
+		timeout = time.Second
 	}
-	return nil
+	return m.server.WaitMachine(ctx, m.appName, machine.ID, state, timeout)
 }
 
 func (m *FlapsClient) WaitForApp(ctx context.Context, name string) error {
-	panic("TODO")
+	m.server.mu.Lock()
+	defer m.server.mu.Unlock()
+
+	_, err := m.server.app(name)
+	return err
+}
+
+func derefInt(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
 }
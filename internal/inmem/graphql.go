@@ -0,0 +1,626 @@
+package inmem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	fly "github.com/superfly/fly-go"
+)
+
+// graphqlRequest is the body of a GraphQL request, as sent by both the
+// fly-go client and genqlient.
+type graphqlRequest struct {
+	Query         string                     `json:"query"`
+	Variables     map[string]json.RawMessage `json:"variables"`
+	OperationName string                     `json:"operationName"`
+}
+
+// graphqlField is a field of a GraphQL operation. Fields of inline
+// fragments are merged into their parent's selections; named fragments are
+// ignored.
+type graphqlField struct {
+	alias      string
+	name       string
+	args       map[string]json.RawMessage
+	selections []graphqlField
+}
+
+// graphqlResolver answers one top-level field. Its result is encoded as is,
+// so it may hold more than the query selected: fly-go and genqlient both
+// ignore what they don't ask for.
+type graphqlResolver func(ctx context.Context, f graphqlField) (any, error)
+
+// graphqlResolvers returns the top-level fields the emulator answers, which
+// are the ones 'fly deploy' and 'fly scale count' need.
+func (h *handler) graphqlResolvers() map[string]graphqlResolver {
+	c := NewClient(h.server)
+
+	return map[string]graphqlResolver{
+		"viewer": func(ctx context.Context, f graphqlField) (any, error) {
+			user, err := c.GetCurrentUser(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return typed("User", user)
+		},
+		"app": func(ctx context.Context, f graphqlField) (any, error) {
+			var name string
+			if err := graphqlArg(f.args, "name", &name); err != nil {
+				return nil, err
+			}
+			app, err := c.GetApp(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			out, err := typed("App", app)
+			if err != nil {
+				return nil, err
+			}
+			for _, sel := range f.selections {
+				v, ok, err := h.appField(ctx, name, sel)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					setField(out, sel.alias, v)
+				}
+			}
+			return out, nil
+		},
+		"latestImageDetails": func(ctx context.Context, f graphqlField) (any, error) {
+			var image string
+			if err := graphqlArg(f.args, "image", &image); err != nil {
+				return nil, err
+			}
+			// Only Fly.io's own images have tracked versions.
+			return nil, fmt.Errorf("Unknown repository: %s", parseImageRef(image).Repository)
+		},
+		"lockApp": func(ctx context.Context, f graphqlField) (any, error) {
+			var input struct {
+				AppID string `json:"appId"`
+			}
+			if err := graphqlArg(f.args, "input", &input); err != nil {
+				return nil, err
+			}
+			return h.server.LockApp(ctx, input.AppID)
+		},
+		"unlockApp": func(ctx context.Context, f graphqlField) (any, error) {
+			var input struct {
+				AppID  string `json:"appId"`
+				LockID string `json:"lockId"`
+			}
+			if err := graphqlArg(f.args, "input", &input); err != nil {
+				return nil, err
+			}
+			app, err := h.server.UnlockApp(ctx, input.AppID, input.LockID)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"app": app}, nil
+		},
+		"createRelease": func(ctx context.Context, f graphqlField) (any, error) {
+			var input fly.CreateReleaseInput
+			if err := graphqlArg(f.args, "input", &input); err != nil {
+				return nil, err
+			}
+			resp, err := c.CreateRelease(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+			return resp.CreateRelease, nil
+		},
+		"updateRelease": func(ctx context.Context, f graphqlField) (any, error) {
+			var input fly.UpdateReleaseInput
+			if err := graphqlArg(f.args, "input", &input); err != nil {
+				return nil, err
+			}
+			resp, err := c.UpdateRelease(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+			return resp.UpdateRelease, nil
+		},
+		"createBuild": func(ctx context.Context, f graphqlField) (any, error) {
+			var input fly.CreateBuildInput
+			if err := graphqlArg(f.args, "input", &input); err != nil {
+				return nil, err
+			}
+			resp, err := c.CreateBuild(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+			return resp.CreateBuild, nil
+		},
+		"finishBuild": func(ctx context.Context, f graphqlField) (any, error) {
+			var input fly.FinishBuildInput
+			if err := graphqlArg(f.args, "input", &input); err != nil {
+				return nil, err
+			}
+			resp, err := c.FinishBuild(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+			return resp.FinishBuild, nil
+		},
+	}
+}
+
+// appField resolves the fields of an app that take arguments or aren't
+// part of fly.App. It returns false for the others, which the encoded
+// fly.App already holds.
+func (h *handler) appField(ctx context.Context, appName string, f graphqlField) (any, bool, error) {
+	switch f.name {
+	case "image":
+		var ref string
+		if err := graphqlArg(f.args, "ref", &ref); err != nil {
+			return nil, true, err
+		}
+		return h.server.resolveImage(appName, ref), true, nil
+
+	case "releasesUnprocessed", "releases":
+		var (
+			first  int
+			status string
+		)
+		if err := graphqlArg(f.args, "first", &first); err != nil {
+			return nil, true, err
+		}
+		if err := graphqlArg(f.args, "status", &status); err != nil {
+			return nil, true, err
+		}
+		releases, err := h.server.ListReleases(ctx, appName, status, first)
+		return map[string]any{"nodes": releases}, true, err
+
+	case "currentReleaseUnprocessed", "currentRelease":
+		// The config of a release isn't kept, so flyctl reads it from the
+		// machines instead.
+		releases, err := h.server.ListReleases(ctx, appName, "", 1)
+		if err != nil || len(releases) == 0 {
+			return nil, true, err
+		}
+		return releases[0], true, nil
+
+	case "currentLock":
+		lock, err := h.server.AppLock(ctx, appName)
+		if err != nil || lock == nil {
+			return nil, true, err
+		}
+		return map[string]any{"lockId": lock.LockID, "expiration": lock.Expiration}, true, nil
+	}
+	return nil, false, nil
+}
+
+// setField sets key in out, replacing fields whose names only differ in
+// case. Encoded fly-go types use Go field names, which decoding matches
+// regardless of case.
+func setField(out map[string]any, key string, v any) {
+	for k := range out {
+		if strings.EqualFold(k, key) {
+			delete(out, k)
+		}
+	}
+	out[key] = v
+}
+
+// typed returns v with its GraphQL __typename, which genqlient needs to
+// decode interfaces and unions.
+func typed(typename string, v any) (map[string]any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	out["__typename"] = typename
+	return out, nil
+}
+
+func (h *handler) graphql(w http.ResponseWriter, r *http.Request) {
+	var req graphqlRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	reply := func(data any, err error) {
+		out := map[string]any{"data": data}
+		if err != nil {
+			out["errors"] = []map[string]string{{"message": err.Error()}}
+		}
+		h.reply(w, http.StatusOK, out, nil)
+	}
+
+	fields, err := parseGraphQL(req.Query, req.Variables)
+	if err != nil {
+		reply(nil, err)
+		return
+	}
+
+	resolvers := h.graphqlResolvers()
+	data := map[string]any{}
+	for _, f := range fields {
+		resolve, ok := resolvers[f.name]
+		if !ok {
+			reply(nil, fmt.Errorf("the GraphQL field %q is not emulated; only the fields 'fly deploy' and 'fly scale count' need are", f.name))
+			return
+		}
+		v, err := resolve(r.Context(), f)
+		if err != nil {
+			reply(nil, err)
+			return
+		}
+		data[f.alias] = v
+	}
+	reply(data, nil)
+}
+
+// parseGraphQL returns the top-level fields of the operation in query, with
+// their arguments resolved against vars. Only what flyctl sends is
+// understood: one operation, optionally preceded or followed by fragments.
+func parseGraphQL(query string, vars map[string]json.RawMessage) ([]graphqlField, error) {
+	p := &graphqlParser{src: query, vars: vars}
+
+	for {
+		p.skipSpace()
+		if p.done() {
+			return nil, fmt.Errorf("no operation in GraphQL query")
+		}
+		if p.peekWord() == "fragment" {
+			if err := p.skipTo('{'); err != nil {
+				return nil, err
+			}
+			if err := p.skipBlock('{', '}'); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// Skip the operation type, name and variable definitions.
+		if err := p.skipTo('{'); err != nil {
+			return nil, err
+		}
+		return p.selectionSet()
+	}
+}
+
+type graphqlParser struct {
+	src  string
+	pos  int
+	vars map[string]json.RawMessage
+}
+
+func (p *graphqlParser) done() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *graphqlParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *graphqlParser) skipSpace() {
+	for !p.done() {
+		switch c := p.src[p.pos]; {
+		case c == '#':
+			for !p.done() && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == ',' || unicode.IsSpace(rune(c)):
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *graphqlParser) peekWord() string {
+	end := p.pos
+	for end < len(p.src) && isNameByte(p.src[end]) {
+		end++
+	}
+	return p.src[p.pos:end]
+}
+
+func (p *graphqlParser) name() (string, error) {
+	p.skipSpace()
+	w := p.peekWord()
+	if w == "" {
+		return "", p.errorf("expected a name")
+	}
+	p.pos += len(w)
+	return w, nil
+}
+
+func (p *graphqlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid GraphQL query at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skipTo advances to the next c at the current nesting level, which for
+// flyctl's queries only means skipping variable definitions.
+func (p *graphqlParser) skipTo(c byte) error {
+	for !p.done() {
+		switch p.src[p.pos] {
+		case c:
+			return nil
+		case '"':
+			if _, err := p.stringValue(); err != nil {
+				return err
+			}
+			continue
+		case '(':
+			if err := p.skipBlock('(', ')'); err != nil {
+				return err
+			}
+			continue
+		}
+		p.pos++
+	}
+	return p.errorf("expected %q", c)
+}
+
+// skipBlock skips a balanced open ... close block starting at the current
+// position.
+func (p *graphqlParser) skipBlock(open, close byte) error {
+	depth := 0
+	for !p.done() {
+		switch p.src[p.pos] {
+		case '"':
+			if _, err := p.stringValue(); err != nil {
+				return err
+			}
+			continue
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				p.pos++
+				return nil
+			}
+		}
+		p.pos++
+	}
+	return p.errorf("unbalanced %q", open)
+}
+
+// selectionSet reads the fields of the selection set starting at the
+// current '{'.
+func (p *graphqlParser) selectionSet() ([]graphqlField, error) {
+	p.pos++ // '{'
+
+	var fields []graphqlField
+	for {
+		p.skipSpace()
+		if p.done() {
+			return nil, p.errorf("unterminated selection set")
+		}
+		if p.peek() == '}' {
+			p.pos++
+			return fields, nil
+		}
+
+		if strings.HasPrefix(p.src[p.pos:], "...") {
+			p.pos += 3
+			p.skipSpace()
+			if p.peekWord() != "on" {
+				if _, err := p.name(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			p.pos += 2
+			if _, err := p.name(); err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+			if p.peek() != '{' {
+				return nil, p.errorf("expected '{' after inline fragment")
+			}
+			inline, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, inline...)
+			continue
+		}
+
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		f := graphqlField{alias: name, name: name, args: map[string]json.RawMessage{}}
+
+		p.skipSpace()
+		if p.peek() == ':' {
+			p.pos++
+			if f.name, err = p.name(); err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+		}
+
+		if p.peek() == '(' {
+			if f.args, err = p.arguments(); err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+		}
+
+		for p.peek() == '@' {
+			p.pos++
+			if _, err := p.name(); err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+			if p.peek() == '(' {
+				if err := p.skipBlock('(', ')'); err != nil {
+					return nil, err
+				}
+				p.skipSpace()
+			}
+		}
+
+		if p.peek() == '{' {
+			if f.selections, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+		}
+
+		fields = append(fields, f)
+	}
+}
+
+func (p *graphqlParser) arguments() (map[string]json.RawMessage, error) {
+	p.pos++ // '('
+
+	args := map[string]json.RawMessage{}
+	for {
+		p.skipSpace()
+		if p.peek() == ')' {
+			p.pos++
+			return args, nil
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.peek() != ':' {
+			return nil, p.errorf("expected ':' after argument %s", name)
+		}
+		p.pos++
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		args[name] = v
+	}
+}
+
+// value reads an argument value as JSON, substituting variables.
+func (p *graphqlParser) value() (json.RawMessage, error) {
+	p.skipSpace()
+
+	switch c := p.peek(); {
+	case c == '$':
+		p.pos++
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := p.vars[name]; ok {
+			return v, nil
+		}
+		return json.RawMessage("null"), nil
+
+	case c == '"':
+		s, err := p.stringValue()
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(s)
+
+	case c == '[':
+		p.pos++
+		var items []json.RawMessage
+		for {
+			p.skipSpace()
+			if p.peek() == ']' {
+				p.pos++
+				return json.Marshal(items)
+			}
+			if p.done() {
+				return nil, p.errorf("unterminated list")
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+
+	case c == '{':
+		p.pos++
+		obj := map[string]json.RawMessage{}
+		for {
+			p.skipSpace()
+			if p.peek() == '}' {
+				p.pos++
+				return json.Marshal(obj)
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+			if p.peek() != ':' {
+				return nil, p.errorf("expected ':' after field %s", name)
+			}
+			p.pos++
+			if obj[name], err = p.value(); err != nil {
+				return nil, err
+			}
+		}
+
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for !p.done() && strings.IndexByte("0123456789.eE+-", p.peek()) >= 0 {
+			p.pos++
+		}
+		return json.RawMessage(p.src[start:p.pos]), nil
+
+	default:
+		word, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		switch word {
+		case "true", "false", "null":
+			return json.RawMessage(word), nil
+		}
+		// Enum values are passed on as strings.
+		return json.Marshal(word)
+	}
+}
+
+func (p *graphqlParser) stringValue() (string, error) {
+	start := p.pos
+	p.pos++ // '"'
+	for !p.done() {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos += 2
+			continue
+		case '"':
+			p.pos++
+			return strconv.Unquote(p.src[start:p.pos])
+		}
+		p.pos++
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// graphqlArg decodes the argument name into v, leaving v alone if it's
+// missing.
+func graphqlArg(args map[string]json.RawMessage, name string, v any) error {
+	raw, ok := args[name]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("invalid GraphQL argument %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,483 @@
+package inmem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+)
+
+// Handler returns an http.Handler speaking the Machines API wire format,
+// backed by s. Point FLY_FLAPS_BASE_URL at it to run flyctl against s.
+// Authentication is not checked.
+//
+// Only the part of the GraphQL API that 'fly deploy' and 'fly scale count'
+// need is emulated, see graphql.go. Other queries get an error, so pointing
+// FLY_API_BASE_URL at the handler too makes commands that need more fail
+// clearly instead of reaching a real account.
+func (s *Server) Handler() http.Handler {
+	h := &handler{server: s}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /v1/apps", h.createApp)
+	mux.HandleFunc("GET /v1/apps/{app}", h.getApp)
+
+	mux.HandleFunc("GET /v1/apps/{app}/machines", h.listMachines)
+	mux.HandleFunc("POST /v1/apps/{app}/machines", h.launchMachine)
+	mux.HandleFunc("GET /v1/apps/{app}/machines/{id}", h.getMachine)
+	mux.HandleFunc("POST /v1/apps/{app}/machines/{id}", h.updateMachine)
+	mux.HandleFunc("DELETE /v1/apps/{app}/machines/{id}", h.destroyMachine)
+	mux.HandleFunc("POST /v1/apps/{app}/machines/{id}/start", h.startMachine)
+	mux.HandleFunc("POST /v1/apps/{app}/machines/{id}/stop", h.stopMachine)
+	mux.HandleFunc("POST /v1/apps/{app}/machines/{id}/restart", h.restartMachine)
+	mux.HandleFunc("POST /v1/apps/{app}/machines/{id}/suspend", h.suspendMachine)
+	mux.HandleFunc("POST /v1/apps/{app}/machines/{id}/signal", h.signalMachine)
+	mux.HandleFunc("POST /v1/apps/{app}/machines/{id}/cordon", h.cordonMachine(true))
+	mux.HandleFunc("POST /v1/apps/{app}/machines/{id}/uncordon", h.cordonMachine(false))
+	mux.HandleFunc("GET /v1/apps/{app}/machines/{id}/wait", h.waitMachine)
+	mux.HandleFunc("GET /v1/apps/{app}/machines/{id}/lease", h.findLease)
+	mux.HandleFunc("POST /v1/apps/{app}/machines/{id}/lease", h.acquireLease)
+	mux.HandleFunc("DELETE /v1/apps/{app}/machines/{id}/lease", h.releaseLease)
+	mux.HandleFunc("POST /v1/apps/{app}/machines/{id}/exec", h.exec)
+	mux.HandleFunc("GET /v1/apps/{app}/machines/{id}/ps", h.processes)
+	mux.HandleFunc("GET /v1/apps/{app}/machines/{id}/metadata", h.getMetadata)
+	mux.HandleFunc("POST /v1/apps/{app}/machines/{id}/metadata/{key}", h.setMetadata)
+	mux.HandleFunc("DELETE /v1/apps/{app}/machines/{id}/metadata/{key}", h.deleteMetadata)
+
+	mux.HandleFunc("GET /v1/apps/{app}/volumes", h.listVolumes)
+	mux.HandleFunc("POST /v1/apps/{app}/volumes", h.createVolume)
+	mux.HandleFunc("GET /v1/apps/{app}/volumes/{id}", h.getVolume)
+	mux.HandleFunc("PUT /v1/apps/{app}/volumes/{id}", h.updateVolume)
+	mux.HandleFunc("DELETE /v1/apps/{app}/volumes/{id}", h.deleteVolume)
+	mux.HandleFunc("PUT /v1/apps/{app}/volumes/{id}/extend", h.extendVolume)
+	mux.HandleFunc("GET /v1/apps/{app}/volumes/{id}/snapshots", h.listSnapshots)
+	mux.HandleFunc("POST /v1/apps/{app}/volumes/{id}/snapshots", h.createSnapshot)
+
+	mux.HandleFunc("GET /v1/apps/{app}/secrets", h.listSecrets)
+	mux.HandleFunc("GET /v1/apps/{app}/secrets/{name}", h.getSecret)
+	mux.HandleFunc("POST /v1/apps/{app}/secrets/{name}", h.setSecret)
+	mux.HandleFunc("DELETE /v1/apps/{app}/secrets/{name}", h.deleteSecret)
+
+	mux.HandleFunc("GET /v1/apps/{app}/secretkeys", h.listSecretKeys)
+	mux.HandleFunc("GET /v1/apps/{app}/secretkeys/{name}", h.getSecretKey)
+	mux.HandleFunc("POST /v1/apps/{app}/secretkeys/{name}", h.setSecretKey)
+	mux.HandleFunc("POST /v1/apps/{app}/secretkeys/{name}/generate", h.generateSecretKey)
+	mux.HandleFunc("DELETE /v1/apps/{app}/secretkeys/{name}", h.deleteSecretKey)
+
+	mux.HandleFunc("GET /v1/platform/regions", h.regions)
+
+	mux.HandleFunc("POST /graphql", h.graphql)
+
+	return mux
+}
+
+// ListenAndServe serves the Machines API on addr until it fails. The
+// listener is returned through ready once it's open, which is useful when
+// addr has port 0.
+func (s *Server) ListenAndServe(addr string, ready func(net.Addr)) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if ready != nil {
+		ready(l.Addr())
+	}
+	srv := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	return srv.Serve(l)
+}
+
+type handler struct {
+	server *Server
+}
+
+func (h *handler) reply(w http.ResponseWriter, status int, out any, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if out != nil {
+		_ = json.NewEncoder(w).Encode(out)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var ferr *flaps.FlapsError
+	if errors.As(err, &ferr) {
+		w.WriteHeader(ferr.ResponseStatusCode)
+		_, _ = w.Write(ferr.ResponseBody)
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// decode reads the JSON body of r into in, replying with an error if it
+// can't.
+func decode(w http.ResponseWriter, r *http.Request, in any) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		writeError(w, badRequestError("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+func nonce(r *http.Request) string {
+	return r.Header.Get(flaps.NonceHeader)
+}
+
+func queryInt(r *http.Request, name string) int {
+	n, _ := strconv.Atoi(r.URL.Query().Get(name))
+	return n
+}
+
+func (h *handler) createApp(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		AppName string `json:"app_name"`
+		OrgSlug string `json:"org_slug"`
+	}
+	if !decode(w, r, &in) {
+		return
+	}
+	_, err := h.server.CreateAppInOrg(r.Context(), in.AppName, in.OrgSlug)
+	h.reply(w, http.StatusCreated, nil, err)
+}
+
+func (h *handler) getApp(w http.ResponseWriter, r *http.Request) {
+	h.server.mu.Lock()
+	app, err := h.server.app(r.PathValue("app"))
+	var out any
+	if err == nil {
+		out = map[string]any{
+			"id":           app.ID,
+			"name":         app.Name,
+			"status":       app.Status,
+			"organization": map[string]string{"name": app.Organization.Name, "slug": app.Organization.Slug},
+		}
+	}
+	h.server.mu.Unlock()
+	h.reply(w, http.StatusOK, out, err)
+}
+
+func (h *handler) listMachines(w http.ResponseWriter, r *http.Request) {
+	// flaps.Client.List passes the state as a bare query string.
+	state := r.URL.Query().Get("state")
+	if state == "" && r.URL.RawQuery != "" && !r.URL.Query().Has("include_deleted") {
+		state = r.URL.RawQuery
+	}
+	machines, err := h.server.ListMachines(r.Context(), r.PathValue("app"), state)
+	h.reply(w, http.StatusOK, machines, err)
+}
+
+func (h *handler) launchMachine(w http.ResponseWriter, r *http.Request) {
+	var in fly.LaunchMachineInput
+	if !decode(w, r, &in) {
+		return
+	}
+	machine, err := h.server.LaunchMachine(r.Context(), r.PathValue("app"), in)
+	h.reply(w, http.StatusOK, machine, err)
+}
+
+func (h *handler) getMachine(w http.ResponseWriter, r *http.Request) {
+	machine, err := h.server.GetMachine(r.Context(), r.PathValue("app"), r.PathValue("id"))
+	h.reply(w, http.StatusOK, machine, err)
+}
+
+func (h *handler) updateMachine(w http.ResponseWriter, r *http.Request) {
+	var in fly.LaunchMachineInput
+	if !decode(w, r, &in) {
+		return
+	}
+	in.ID = r.PathValue("id")
+	machine, err := h.server.UpdateMachine(r.Context(), r.PathValue("app"), in, nonce(r))
+	h.reply(w, http.StatusOK, machine, err)
+}
+
+func (h *handler) destroyMachine(w http.ResponseWriter, r *http.Request) {
+	in := fly.RemoveMachineInput{
+		ID:   r.PathValue("id"),
+		Kill: r.URL.Query().Get("kill") == "true" || r.URL.Query().Get("force") == "true",
+	}
+	err := h.server.DestroyMachine(r.Context(), r.PathValue("app"), in, nonce(r))
+	h.reply(w, http.StatusOK, map[string]bool{"ok": true}, err)
+}
+
+func (h *handler) startMachine(w http.ResponseWriter, r *http.Request) {
+	out, err := h.server.StartMachine(r.Context(), r.PathValue("app"), r.PathValue("id"), nonce(r))
+	h.reply(w, http.StatusOK, out, err)
+}
+
+func (h *handler) stopMachine(w http.ResponseWriter, r *http.Request) {
+	var in fly.StopMachineInput
+	if !decode(w, r, &in) {
+		return
+	}
+	in.ID = r.PathValue("id")
+	err := h.server.StopMachine(r.Context(), r.PathValue("app"), in, nonce(r))
+	h.reply(w, http.StatusOK, map[string]bool{"ok": true}, err)
+}
+
+func (h *handler) restartMachine(w http.ResponseWriter, r *http.Request) {
+	in := fly.RestartMachineInput{
+		ID:        r.PathValue("id"),
+		ForceStop: r.URL.Query().Get("force_stop") == "true",
+	}
+	err := h.server.RestartMachine(r.Context(), r.PathValue("app"), in, nonce(r))
+	h.reply(w, http.StatusOK, map[string]bool{"ok": true}, err)
+}
+
+func (h *handler) suspendMachine(w http.ResponseWriter, r *http.Request) {
+	err := h.server.SuspendMachine(r.Context(), r.PathValue("app"), r.PathValue("id"), nonce(r))
+	h.reply(w, http.StatusOK, map[string]bool{"ok": true}, err)
+}
+
+func (h *handler) signalMachine(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Signal int `json:"signal"`
+	}
+	if !decode(w, r, &in) {
+		return
+	}
+
+	var err error
+	switch in.Signal {
+	case 9:
+		err = h.server.KillMachine(r.Context(), r.PathValue("app"), r.PathValue("id"))
+	default:
+		// Other signals are delivered to the process, which doesn't exist.
+		_, err = h.server.GetMachine(r.Context(), r.PathValue("app"), r.PathValue("id"))
+	}
+	h.reply(w, http.StatusOK, map[string]bool{"ok": true}, err)
+}
+
+func (h *handler) cordonMachine(cordoned bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := h.server.SetCordon(r.Context(), r.PathValue("app"), r.PathValue("id"), nonce(r), cordoned)
+		h.reply(w, http.StatusOK, map[string]bool{"ok": true}, err)
+	}
+}
+
+func (h *handler) waitMachine(w http.ResponseWriter, r *http.Request) {
+	timeout := time.Duration(queryInt(r, "timeout")) * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	err := h.server.WaitMachine(r.Context(), r.PathValue("app"), r.PathValue("id"), r.URL.Query().Get("state"), timeout)
+	h.reply(w, http.StatusOK, map[string]bool{"ok": true}, err)
+}
+
+func (h *handler) findLease(w http.ResponseWriter, r *http.Request) {
+	lease, err := h.server.FindLease(r.Context(), r.PathValue("app"), r.PathValue("id"))
+	h.reply(w, http.StatusOK, lease, err)
+}
+
+func (h *handler) acquireLease(w http.ResponseWriter, r *http.Request) {
+	var (
+		lease *fly.MachineLease
+		err   error
+	)
+	if n := nonce(r); n != "" {
+		lease, err = h.server.RefreshLease(r.Context(), r.PathValue("app"), r.PathValue("id"), queryInt(r, "ttl"), n)
+	} else {
+		lease, err = h.server.AcquireLease(r.Context(), r.PathValue("app"), r.PathValue("id"), queryInt(r, "ttl"))
+	}
+	h.reply(w, http.StatusOK, lease, err)
+}
+
+func (h *handler) releaseLease(w http.ResponseWriter, r *http.Request) {
+	err := h.server.ReleaseLease(r.Context(), r.PathValue("app"), r.PathValue("id"), nonce(r))
+	h.reply(w, http.StatusOK, map[string]bool{"ok": true}, err)
+}
+
+func (h *handler) exec(w http.ResponseWriter, r *http.Request) {
+	var in fly.MachineExecRequest
+	if !decode(w, r, &in) {
+		return
+	}
+	out, err := h.server.Exec(r.Context(), r.PathValue("app"), r.PathValue("id"), &in)
+	h.reply(w, http.StatusOK, out, err)
+}
+
+func (h *handler) processes(w http.ResponseWriter, r *http.Request) {
+	_, err := h.server.GetMachine(r.Context(), r.PathValue("app"), r.PathValue("id"))
+	h.reply(w, http.StatusOK, fly.MachinePsResponse{}, err)
+}
+
+func (h *handler) getMetadata(w http.ResponseWriter, r *http.Request) {
+	metadata, err := h.server.GetMetadata(r.Context(), r.PathValue("app"), r.PathValue("id"))
+	h.reply(w, http.StatusOK, metadata, err)
+}
+
+func (h *handler) setMetadata(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Value string `json:"value"`
+	}
+	if !decode(w, r, &in) {
+		return
+	}
+	err := h.server.SetMetadata(r.Context(), r.PathValue("app"), r.PathValue("id"), r.PathValue("key"), in.Value)
+	h.reply(w, http.StatusNoContent, nil, err)
+}
+
+func (h *handler) deleteMetadata(w http.ResponseWriter, r *http.Request) {
+	err := h.server.DeleteMetadata(r.Context(), r.PathValue("app"), r.PathValue("id"), r.PathValue("key"))
+	h.reply(w, http.StatusNoContent, nil, err)
+}
+
+func (h *handler) listVolumes(w http.ResponseWriter, r *http.Request) {
+	volumes, err := h.server.ListVolumes(r.Context(), r.PathValue("app"))
+	h.reply(w, http.StatusOK, volumes, err)
+}
+
+func (h *handler) createVolume(w http.ResponseWriter, r *http.Request) {
+	var in fly.CreateVolumeRequest
+	if !decode(w, r, &in) {
+		return
+	}
+	vol, err := h.server.CreateVolume(r.Context(), r.PathValue("app"), in)
+	h.reply(w, http.StatusOK, vol, err)
+}
+
+func (h *handler) getVolume(w http.ResponseWriter, r *http.Request) {
+	vol, err := h.server.GetVolume(r.Context(), r.PathValue("app"), r.PathValue("id"))
+	h.reply(w, http.StatusOK, vol, err)
+}
+
+func (h *handler) updateVolume(w http.ResponseWriter, r *http.Request) {
+	var in fly.UpdateVolumeRequest
+	if !decode(w, r, &in) {
+		return
+	}
+	vol, err := h.server.UpdateVolume(r.Context(), r.PathValue("app"), r.PathValue("id"), in)
+	h.reply(w, http.StatusOK, vol, err)
+}
+
+func (h *handler) deleteVolume(w http.ResponseWriter, r *http.Request) {
+	vol, err := h.server.DeleteVolume(r.Context(), r.PathValue("app"), r.PathValue("id"))
+	h.reply(w, http.StatusOK, vol, err)
+}
+
+func (h *handler) extendVolume(w http.ResponseWriter, r *http.Request) {
+	var in flaps.ExtendVolumeRequest
+	if !decode(w, r, &in) {
+		return
+	}
+	vol, needsRestart, err := h.server.ExtendVolume(r.Context(), r.PathValue("app"), r.PathValue("id"), in.SizeGB)
+	h.reply(w, http.StatusOK, flaps.ExtendVolumeResponse{Volume: vol, NeedsRestart: needsRestart}, err)
+}
+
+func (h *handler) listSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := h.server.GetVolumeSnapshots(r.Context(), r.PathValue("app"), r.PathValue("id"))
+	h.reply(w, http.StatusOK, snapshots, err)
+}
+
+func (h *handler) createSnapshot(w http.ResponseWriter, r *http.Request) {
+	err := h.server.CreateVolumeSnapshot(r.Context(), r.PathValue("app"), r.PathValue("id"))
+	h.reply(w, http.StatusOK, nil, err)
+}
+
+func (h *handler) listSecrets(w http.ResponseWriter, r *http.Request) {
+	secrets, err := h.server.ListAppSecrets(r.Context(), r.PathValue("app"), r.URL.Query().Get("show_secrets") == "true")
+	h.reply(w, http.StatusOK, fly.ListAppSecretsResp{Secrets: secrets}, err)
+}
+
+func (h *handler) getSecret(w http.ResponseWriter, r *http.Request) {
+	secrets, err := h.server.ListAppSecrets(r.Context(), r.PathValue("app"), r.URL.Query().Get("show_secrets") == "true")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	for _, secret := range secrets {
+		if secret.Name == r.PathValue("name") {
+			h.reply(w, http.StatusOK, secret, nil)
+			return
+		}
+	}
+	writeError(w, notFoundError("secret not found: %q", r.PathValue("name")))
+}
+
+func (h *handler) setSecret(w http.ResponseWriter, r *http.Request) {
+	var in fly.SetAppSecretRequest
+	if !decode(w, r, &in) {
+		return
+	}
+	name := r.PathValue("name")
+	version, err := h.server.SetAppSecrets(r.Context(), r.PathValue("app"), map[string]string{name: in.Value}, nil)
+	h.reply(w, http.StatusCreated, fly.SetAppSecretResp{
+		AppSecret: fly.AppSecret{Name: name, Digest: secretDigest(in.Value)},
+		Version:   version,
+	}, err)
+}
+
+func (h *handler) deleteSecret(w http.ResponseWriter, r *http.Request) {
+	_, err := h.server.SetAppSecrets(r.Context(), r.PathValue("app"), nil, []string{r.PathValue("name")})
+	h.reply(w, http.StatusOK, nil, err)
+}
+
+func (h *handler) listSecretKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.server.ListSecretKeys(r.Context(), r.PathValue("app"))
+	h.reply(w, http.StatusOK, fly.ListSecretKeysResp{Secrets: keys}, err)
+}
+
+func (h *handler) getSecretKey(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.server.ListSecretKeys(r.Context(), r.PathValue("app"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	for _, key := range keys {
+		if key.Name == r.PathValue("name") {
+			h.reply(w, http.StatusOK, key, nil)
+			return
+		}
+	}
+	writeError(w, notFoundError("secret key not found: %q", r.PathValue("name")))
+}
+
+func (h *handler) setSecretKey(w http.ResponseWriter, r *http.Request) {
+	var in fly.SetSecretKeyRequest
+	if !decode(w, r, &in) {
+		return
+	}
+	if in.Value == nil {
+		in.Value = []byte{}
+	}
+	out, err := h.server.SetSecretKey(r.Context(), r.PathValue("app"), r.PathValue("name"), in.Type, in.Value)
+	h.reply(w, http.StatusCreated, out, err)
+}
+
+func (h *handler) generateSecretKey(w http.ResponseWriter, r *http.Request) {
+	var in fly.SetSecretKeyRequest
+	if !decode(w, r, &in) {
+		return
+	}
+	out, err := h.server.SetSecretKey(r.Context(), r.PathValue("app"), r.PathValue("name"), in.Type, nil)
+	h.reply(w, http.StatusCreated, out, err)
+}
+
+func (h *handler) deleteSecretKey(w http.ResponseWriter, r *http.Request) {
+	err := h.server.DeleteSecretKey(r.Context(), r.PathValue("app"), r.PathValue("name"))
+	h.reply(w, http.StatusOK, nil, err)
+}
+
+func (h *handler) regions(w http.ResponseWriter, r *http.Request) {
+	h.reply(w, http.StatusOK, struct{ Regions []fly.Region }{Regions}, nil)
+}
+
+// URL returns the base URL to set FLY_FLAPS_BASE_URL to for a server
+// listening on addr.
+func URL(addr net.Addr) string {
+	return fmt.Sprintf("http://%s", addr)
+}
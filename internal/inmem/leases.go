@@ -0,0 +1,133 @@
+package inmem
+
+import (
+	"context"
+	"time"
+
+	fly "github.com/superfly/fly-go"
+)
+
+// defaultLeaseTTL is the TTL of leases acquired without one, in seconds.
+const defaultLeaseTTL = 30
+
+// LeaseOwner is the owner recorded on leases, since the in-memory server has
+// a single user.
+var LeaseOwner = DefaultUser.Email
+
+// activeLease returns the unexpired lease on a machine, if any. Expired
+// leases are dropped. s.mu must be held.
+func (s *Server) activeLease(machineID string) *fly.MachineLeaseData {
+	lease := s.leases[machineID]
+	if lease == nil {
+		return nil
+	}
+	if time.Now().Unix() >= lease.ExpiresAt {
+		delete(s.leases, machineID)
+		return nil
+	}
+	return lease
+}
+
+// checkLease fails if someone else holds a lease on a machine, that is if
+// there's an active lease and nonce isn't its nonce. s.mu must be held.
+func (s *Server) checkLease(machineID, nonce string) error {
+	lease := s.activeLease(machineID)
+	if lease == nil || lease.Nonce == nonce {
+		return nil
+	}
+	return conflictError("machine ID %s lease currently held by %s, expires at %s",
+		machineID, lease.Owner, time.Unix(lease.ExpiresAt, 0).UTC().Format(time.RFC3339))
+}
+
+// newLease grants a lease on a machine. s.mu must be held.
+func (s *Server) newLease(machineID string, ttl int) *fly.MachineLeaseData {
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	lease := &fly.MachineLeaseData{
+		Nonce:     randomHex(6),
+		ExpiresAt: time.Now().Add(time.Duration(ttl) * time.Second).Unix(),
+		Owner:     LeaseOwner,
+		Version:   newInstanceID(),
+	}
+	s.leases[machineID] = lease
+	return lease
+}
+
+func leaseResponse(lease *fly.MachineLeaseData) *fly.MachineLease {
+	data := *lease
+	return &fly.MachineLease{Status: "success", Data: &data}
+}
+
+// AcquireLease grants a lease on a machine, failing if one is already held.
+func (s *Server) AcquireLease(ctx context.Context, appName, machineID string, ttl int) (*fly.MachineLease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.machine(appName, machineID); err != nil {
+		return nil, err
+	}
+	// Leases always have a nonce, so this fails for any active lease.
+	if err := s.checkLease(machineID, ""); err != nil {
+		return nil, err
+	}
+	return leaseResponse(s.newLease(machineID, ttl)), nil
+}
+
+// FindLease returns the active lease on a machine.
+func (s *Server) FindLease(ctx context.Context, appName, machineID string) (*fly.MachineLease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.machine(appName, machineID); err != nil {
+		return nil, err
+	}
+	lease := s.activeLease(machineID)
+	if lease == nil {
+		return nil, notFoundError("lease not found")
+	}
+	return leaseResponse(lease), nil
+}
+
+// RefreshLease extends the lease held with nonce, or grants a new one if the
+// previous one expired.
+func (s *Server) RefreshLease(ctx context.Context, appName, machineID string, ttl int, nonce string) (*fly.MachineLease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.machine(appName, machineID); err != nil {
+		return nil, err
+	}
+	if err := s.checkLease(machineID, nonce); err != nil {
+		return nil, err
+	}
+
+	lease := s.activeLease(machineID)
+	if lease == nil {
+		lease = s.newLease(machineID, ttl)
+		if nonce != "" {
+			lease.Nonce = nonce
+		}
+		return leaseResponse(lease), nil
+	}
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	lease.ExpiresAt = time.Now().Add(time.Duration(ttl) * time.Second).Unix()
+	return leaseResponse(lease), nil
+}
+
+// ReleaseLease drops the lease held with nonce.
+func (s *Server) ReleaseLease(ctx context.Context, appName, machineID, nonce string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.machine(appName, machineID); err != nil {
+		return err
+	}
+	if err := s.checkLease(machineID, nonce); err != nil {
+		return err
+	}
+	delete(s.leases, machineID)
+	return nil
+}
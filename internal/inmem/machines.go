@@ -0,0 +1,491 @@
+package inmem
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/helpers"
+)
+
+// maxMachineEvents is how many events are kept per machine, newest first,
+// like the Machines API does.
+const maxMachineEvents = 20
+
+func (s *Server) Launch(ctx context.Context, appName, name, region string, config *fly.MachineConfig) (*fly.Machine, error) {
+	return s.LaunchMachine(ctx, appName, fly.LaunchMachineInput{Name: name, Region: region, Config: config})
+}
+
+// LaunchMachine creates a machine, starting it unless input.SkipLaunch is
+// set. Volumes mounted by the machine are attached to it.
+func (s *Server) LaunchMachine(ctx context.Context, appName string, input fly.LaunchMachineInput) (*fly.Machine, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.app(appName); err != nil {
+		return nil, err
+	}
+	if input.Config == nil {
+		return nil, badRequestError("config is required")
+	}
+
+	s.machineSeq++
+	id := fmt.Sprintf("%014x", s.machineSeq)
+
+	name := input.Name
+	if name == "" {
+		name = fmt.Sprintf("machine-%d", s.machineSeq)
+	}
+	region := input.Region
+	if region == "" {
+		region = "iad"
+	}
+
+	machine := &fly.Machine{
+		ID:         id,
+		Name:       name,
+		Region:     region,
+		State:      fly.MachineStateCreated,
+		PrivateIP:  fmt.Sprintf("fdaa:0:1:a7b:1::%x", s.machineSeq),
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+		HostStatus: fly.HostStatusOk,
+	}
+	if err := s.applyConfig(appName, machine, input.Config); err != nil {
+		return nil, err
+	}
+	s.recordEvent(machine, "launch", fly.MachineStateCreated, "user", nil)
+
+	if input.SkipLaunch || input.Config.Schedule != "" {
+		s.setState(machine, fly.MachineStateStopped)
+	} else {
+		s.recordEvent(machine, "start", fly.MachineStateStarted, "user", nil)
+		s.setState(machine, fly.MachineStateStarted)
+	}
+
+	if input.LeaseTTL > 0 {
+		lease := s.newLease(id, input.LeaseTTL)
+		machine.LeaseNonce = lease.Nonce
+	}
+
+	s.machines[appName] = append(s.machines[appName], machine)
+
+	return helpers.Clone(machine), nil
+}
+
+// UpdateMachine replaces the config of a machine, restarting it unless
+// input.SkipLaunch is set.
+func (s *Server) UpdateMachine(ctx context.Context, appName string, input fly.LaunchMachineInput, nonce string) (*fly.Machine, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machine, err := s.machine(appName, input.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkLease(machine.ID, nonce); err != nil {
+		return nil, err
+	}
+	if input.Config == nil {
+		return nil, badRequestError("config is required")
+	}
+
+	if err := s.applyConfig(appName, machine, input.Config); err != nil {
+		return nil, err
+	}
+	s.detachVolumes(appName, machine.ID, machine.Config.Mounts)
+	if input.Region != "" {
+		machine.Region = input.Region
+	}
+	s.recordEvent(machine, "update", "replacing", "user", nil)
+
+	if input.SkipLaunch || input.Config.Schedule != "" {
+		if machine.State == fly.MachineStateStarted {
+			s.recordEvent(machine, "exit", fly.MachineStateStopped, "flyd", &fly.MachineExitEvent{RequestedStop: true, ExitedAt: time.Now()})
+		}
+		s.setState(machine, fly.MachineStateStopped)
+	} else {
+		s.recordEvent(machine, "start", fly.MachineStateStarted, "flyd", nil)
+		s.setState(machine, fly.MachineStateStarted)
+	}
+
+	return helpers.Clone(machine), nil
+}
+
+// applyConfig sets config on machine, giving it a new version. Volumes it
+// mounts are attached to machine. s.mu must be held.
+func (s *Server) applyConfig(appName string, machine *fly.Machine, config *fly.MachineConfig) error {
+	var attach []*fly.Volume
+	for _, mount := range config.Mounts {
+		if mount.Volume == "" {
+			return badRequestError("mount at %s has no volume", mount.Path)
+		}
+		vol, err := s.volume(appName, mount.Volume)
+		if err != nil {
+			return err
+		}
+		if vol.AttachedMachine != nil && *vol.AttachedMachine != machine.ID {
+			return preconditionFailedError("volume %s is already attached to machine %s", vol.ID, *vol.AttachedMachine)
+		}
+		if machine.Region != "" && vol.Region != machine.Region {
+			return badRequestError("volume %s is in region %s, but the machine is in %s", vol.ID, vol.Region, machine.Region)
+		}
+		attach = append(attach, vol)
+	}
+	for _, vol := range attach {
+		vol.AttachedMachine = fly.Pointer(machine.ID)
+	}
+
+	machine.Config = helpers.Clone(config)
+	if machine.Config.Guest == nil {
+		// The Machines API defaults to the smallest size.
+		machine.Config.Guest = &fly.MachineGuest{CPUKind: "shared", CPUs: 1, MemoryMB: 256}
+	}
+	machine.ImageRef = parseImageRef(config.Image)
+	machine.InstanceID = newInstanceID()
+	machine.Version = machine.InstanceID
+	return nil
+}
+
+// machine returns a machine of appName that isn't destroyed. s.mu must be
+// held.
+func (s *Server) machine(appName, id string) (*fly.Machine, error) {
+	for _, machine := range s.machines[appName] {
+		if machine.ID == id && machine.State != fly.MachineStateDestroyed {
+			return machine, nil
+		}
+	}
+	return nil, notFoundError("machine not found: %q", id)
+}
+
+func (s *Server) GetMachine(ctx context.Context, appName, machineID string) (*fly.Machine, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machine, err := s.machine(appName, machineID)
+	if err != nil {
+		return nil, err
+	}
+	return helpers.Clone(machine), nil
+}
+
+// ListMachines returns the machines of appName, optionally only those in
+// state. Destroyed machines are only returned when asked for explicitly.
+func (s *Server) ListMachines(ctx context.Context, appName, state string) ([]*fly.Machine, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machines := make([]*fly.Machine, 0, len(s.machines[appName]))
+	for _, machine := range s.machines[appName] {
+		switch {
+		case state == "" && machine.State == fly.MachineStateDestroyed:
+		case state != "" && machine.State != state:
+		default:
+			machines = append(machines, helpers.Clone(machine))
+		}
+	}
+	return machines, nil
+}
+
+func (s *Server) StartMachine(ctx context.Context, appName, machineID, nonce string) (*fly.MachineStartResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machine, err := s.machine(appName, machineID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkLease(machineID, nonce); err != nil {
+		return nil, err
+	}
+
+	previous := machine.State
+	if previous != fly.MachineStateStarted {
+		s.recordEvent(machine, "start", fly.MachineStateStarted, "user", nil)
+		s.setState(machine, fly.MachineStateStarted)
+	}
+	return &fly.MachineStartResponse{Status: fly.MachineStateStarted, PreviousState: previous}, nil
+}
+
+func (s *Server) StopMachine(ctx context.Context, appName string, in fly.StopMachineInput, nonce string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machine, err := s.machine(appName, in.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkLease(in.ID, nonce); err != nil {
+		return err
+	}
+
+	if machine.State == fly.MachineStateStarted {
+		s.recordEvent(machine, "exit", fly.MachineStateStopped, "user", &fly.MachineExitEvent{RequestedStop: true, ExitedAt: time.Now()})
+	}
+	s.setState(machine, fly.MachineStateStopped)
+	return nil
+}
+
+func (s *Server) SuspendMachine(ctx context.Context, appName, machineID, nonce string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machine, err := s.machine(appName, machineID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkLease(machineID, nonce); err != nil {
+		return err
+	}
+	if machine.State != fly.MachineStateStarted && machine.State != fly.MachineStateSuspended {
+		return preconditionFailedError("unable to suspend machine, not currently started")
+	}
+
+	s.recordEvent(machine, "suspend", fly.MachineStateSuspended, "user", nil)
+	s.setState(machine, fly.MachineStateSuspended)
+	return nil
+}
+
+func (s *Server) RestartMachine(ctx context.Context, appName string, in fly.RestartMachineInput, nonce string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machine, err := s.machine(appName, in.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkLease(in.ID, nonce); err != nil {
+		return err
+	}
+
+	if machine.State == fly.MachineStateStarted {
+		s.recordEvent(machine, "exit", fly.MachineStateStopped, "user", &fly.MachineExitEvent{RequestedStop: true, Restarting: true, ExitedAt: time.Now()})
+	}
+	s.recordEvent(machine, "restart", fly.MachineStateStarted, "user", nil)
+	s.setState(machine, fly.MachineStateStarted)
+	return nil
+}
+
+func (s *Server) KillMachine(ctx context.Context, appName, machineID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machine, err := s.machine(appName, machineID)
+	if err != nil {
+		return err
+	}
+	if machine.State != fly.MachineStateStarted {
+		return preconditionFailedError("unable to kill machine, not currently started")
+	}
+
+	s.recordEvent(machine, "exit", fly.MachineStateStopped, "user", &fly.MachineExitEvent{ExitCode: 137, Signal: 9, ExitedAt: time.Now()})
+	s.setState(machine, fly.MachineStateStopped)
+	return nil
+}
+
+// DestroyMachine destroys a machine and detaches its volumes. Started
+// machines are only destroyed when in.Kill is set.
+func (s *Server) DestroyMachine(ctx context.Context, appName string, in fly.RemoveMachineInput, nonce string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machine, err := s.machine(appName, in.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkLease(in.ID, nonce); err != nil {
+		return err
+	}
+	if machine.State == fly.MachineStateStarted && !in.Kill {
+		return preconditionFailedError("unable to destroy machine, not currently stopped")
+	}
+
+	s.detachVolumes(appName, machine.ID, nil)
+	delete(s.leases, machine.ID)
+	delete(s.cordoned, machine.ID)
+	s.recordEvent(machine, "destroy", fly.MachineStateDestroyed, "user", nil)
+	s.setState(machine, fly.MachineStateDestroyed)
+	return nil
+}
+
+func (s *Server) SetCordon(ctx context.Context, appName, machineID, nonce string, cordoned bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.machine(appName, machineID); err != nil {
+		return err
+	}
+	if err := s.checkLease(machineID, nonce); err != nil {
+		return err
+	}
+
+	if cordoned {
+		s.cordoned[machineID] = true
+	} else {
+		delete(s.cordoned, machineID)
+	}
+	return nil
+}
+
+// IsCordoned reports whether a machine is cordoned, which the Machines API
+// doesn't expose on the machine itself.
+func (s *Server) IsCordoned(machineID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cordoned[machineID]
+}
+
+// WaitMachine blocks until a machine reaches state, or timeout passes.
+func (s *Server) WaitMachine(ctx context.Context, appName, machineID, state string, timeout time.Duration) error {
+	if state == "" {
+		state = fly.MachineStateStarted
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		var current string
+		for _, machine := range s.machines[appName] {
+			if machine.ID == machineID {
+				current = machine.State
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		switch {
+		case current == "":
+			return notFoundError("machine not found: %q", machineID)
+		case current == state:
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return apiError(408, "machine did not reach state %q, current state is %q", state, current)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Server) GetMetadata(ctx context.Context, appName, machineID string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machine, err := s.machine(appName, machineID)
+	if err != nil {
+		return nil, err
+	}
+	metadata := make(map[string]string, len(machine.Config.Metadata))
+	for k, v := range machine.Config.Metadata {
+		metadata[k] = v
+	}
+	return metadata, nil
+}
+
+func (s *Server) SetMetadata(ctx context.Context, appName, machineID, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machine, err := s.machine(appName, machineID)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return badRequestError("metadata key is required")
+	}
+	if machine.Config.Metadata == nil {
+		machine.Config.Metadata = make(map[string]string)
+	}
+	machine.Config.Metadata[key] = value
+	return nil
+}
+
+func (s *Server) DeleteMetadata(ctx context.Context, appName, machineID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machine, err := s.machine(appName, machineID)
+	if err != nil {
+		return err
+	}
+	delete(machine.Config.Metadata, key)
+	return nil
+}
+
+// Exec pretends to run a command on a started machine. Nothing actually
+// runs, so the command always succeeds without output.
+func (s *Server) Exec(ctx context.Context, appName, machineID string, in *fly.MachineExecRequest) (*fly.MachineExecResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machine, err := s.machine(appName, machineID)
+	if err != nil {
+		return nil, err
+	}
+	if machine.State != fly.MachineStateStarted {
+		return nil, preconditionFailedError("machine %s is not started", machineID)
+	}
+	return &fly.MachineExecResponse{}, nil
+}
+
+// setState moves machine to state. s.mu must be held.
+func (s *Server) setState(machine *fly.Machine, state string) {
+	machine.State = state
+	machine.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	s.notify()
+}
+
+// recordEvent adds an event to machine. s.mu must be held.
+func (s *Server) recordEvent(machine *fly.Machine, typ, status, source string, exit *fly.MachineExitEvent) {
+	event := &fly.MachineEvent{
+		Type:      typ,
+		Status:    status,
+		Source:    source,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	if exit != nil {
+		event.Request = &fly.MachineRequest{ExitEvent: exit}
+	}
+	machine.Events = slices.Insert(machine.Events, 0, event)
+	if len(machine.Events) > maxMachineEvents {
+		machine.Events = machine.Events[:maxMachineEvents]
+	}
+}
+
+// parseImageRef splits an image reference like
+// registry.fly.io/app:tag@sha256:... into its parts.
+func parseImageRef(image string) fly.MachineImageRef {
+	var ref fly.MachineImageRef
+
+	image, ref.Digest, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image, ref.Tag = image[:i], image[i+1:]
+	}
+	if registry, repository, ok := strings.Cut(image, "/"); ok && strings.ContainsAny(registry, ".:") {
+		ref.Registry, ref.Repository = registry, repository
+	} else {
+		ref.Registry, ref.Repository = "docker.io", image
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref
+}
+
+func newInstanceID() string {
+	return strings.ToUpper(randomHex(13))
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
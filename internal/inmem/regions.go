@@ -0,0 +1,22 @@
+package inmem
+
+import fly "github.com/superfly/fly-go"
+
+// Regions are the regions the in-memory server pretends to run in.
+var Regions = []fly.Region{
+	{Code: "ams", Name: "Amsterdam, Netherlands", Latitude: 52.374342, Longitude: 4.895439, GatewayAvailable: true},
+	{Code: "cdg", Name: "Paris, France", Latitude: 48.860875, Longitude: 2.353477},
+	{Code: "fra", Name: "Frankfurt, Germany", Latitude: 50.1167, Longitude: 8.6833, GatewayAvailable: true},
+	{Code: "gru", Name: "Sao Paulo, Brazil", Latitude: -23.549664, Longitude: -46.654351},
+	{Code: "iad", Name: "Ashburn, Virginia (US)", Latitude: 39.02214, Longitude: -77.462556, GatewayAvailable: true},
+	{Code: "lax", Name: "Los Angeles, California (US)", Latitude: 33.9416, Longitude: -118.4085, GatewayAvailable: true},
+	{Code: "lhr", Name: "London, United Kingdom", Latitude: 51.516434, Longitude: -0.125656, GatewayAvailable: true},
+	{Code: "nrt", Name: "Tokyo, Japan", Latitude: 35.621313, Longitude: 139.741424, GatewayAvailable: true},
+	{Code: "ord", Name: "Chicago, Illinois (US)", Latitude: 41.891544, Longitude: -87.630386, GatewayAvailable: true},
+	{Code: "sin", Name: "Singapore, Singapore", Latitude: 1.3, Longitude: 103.8, GatewayAvailable: true},
+	{Code: "sjc", Name: "San Jose, California (US)", Latitude: 37.351601, Longitude: -121.896744, GatewayAvailable: true},
+	{Code: "syd", Name: "Sydney, Australia", Latitude: -33.866034, Longitude: 151.213178, GatewayAvailable: true},
+}
+
+// nearestRegion is the region the in-memory server considers closest.
+const nearestRegion = "iad"
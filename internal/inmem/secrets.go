@@ -0,0 +1,173 @@
+package inmem
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	fly "github.com/superfly/fly-go"
+)
+
+// secretStore holds the secrets and secret keys of an app. Every change
+// bumps version, like the Machines API does.
+type secretStore struct {
+	version uint64
+	secrets map[string]storedSecret
+	keys    map[string]storedSecretKey
+}
+
+type storedSecret struct {
+	value     string
+	createdAt time.Time
+}
+
+type storedSecretKey struct {
+	typ   string
+	value []byte
+}
+
+// secretStore returns the secrets of appName, creating them if needed. s.mu
+// must be held.
+func (s *Server) secretStore(appName string) (*secretStore, error) {
+	if _, err := s.app(appName); err != nil {
+		return nil, err
+	}
+	store := s.secrets[appName]
+	if store == nil {
+		store = &secretStore{
+			secrets: make(map[string]storedSecret),
+			keys:    make(map[string]storedSecretKey),
+		}
+		s.secrets[appName] = store
+	}
+	return store, nil
+}
+
+func secretDigest(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *Server) ListAppSecrets(ctx context.Context, appName string, showSecrets bool) ([]fly.AppSecret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, err := s.secretStore(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]fly.AppSecret, 0, len(store.secrets))
+	for name, secret := range store.secrets {
+		appSecret := fly.AppSecret{Name: name, Digest: secretDigest(secret.value)}
+		if showSecrets {
+			appSecret.Value = fly.Pointer(secret.value)
+		}
+		secrets = append(secrets, appSecret)
+	}
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].Name < secrets[j].Name })
+	return secrets, nil
+}
+
+// SetAppSecrets sets and unsets secrets of appName in one version.
+func (s *Server) SetAppSecrets(ctx context.Context, appName string, set map[string]string, unset []string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, err := s.secretStore(appName)
+	if err != nil {
+		return 0, err
+	}
+	for name, value := range set {
+		if name == "" {
+			return 0, badRequestError("secret name is required")
+		}
+		store.secrets[name] = storedSecret{value: value, createdAt: time.Now().UTC()}
+	}
+	for _, name := range unset {
+		delete(store.secrets, name)
+	}
+	store.version++
+	return store.version, nil
+}
+
+func (s *Server) ListSecretKeys(ctx context.Context, appName string) ([]fly.SecretKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, err := s.secretStore(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]fly.SecretKey, 0, len(store.keys))
+	for name, key := range store.keys {
+		keys = append(keys, fly.SecretKey{Name: name, Type: key.typ})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys, nil
+}
+
+// SetSecretKey stores a secret key. A random 32 byte key is generated if
+// value is nil.
+func (s *Server) SetSecretKey(ctx context.Context, appName, name, typ string, value []byte) (*fly.SetSecretKeyResp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, err := s.secretStore(appName)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || typ == "" {
+		return nil, badRequestError("secret key name and type are required")
+	}
+	if value == nil {
+		value = make([]byte, 32)
+		_, _ = rand.Read(value)
+	}
+
+	store.keys[name] = storedSecretKey{typ: typ, value: value}
+	store.version++
+	return &fly.SetSecretKeyResp{
+		SecretKey: fly.SecretKey{Name: name, Type: typ},
+		Version:   store.version,
+	}, nil
+}
+
+func (s *Server) DeleteSecretKey(ctx context.Context, appName, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, err := s.secretStore(appName)
+	if err != nil {
+		return err
+	}
+	if _, ok := store.keys[name]; !ok {
+		return notFoundError("secret key not found: %q", name)
+	}
+	delete(store.keys, name)
+	store.version++
+	return nil
+}
+
+// graphqlSecrets returns the secrets of appName as returned by the GraphQL
+// API.
+func (s *Server) graphqlSecrets(appName string) ([]fly.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, err := s.secretStore(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]fly.Secret, 0, len(store.secrets))
+	for name, secret := range store.secrets {
+		secrets = append(secrets, fly.Secret{Name: name, Digest: secretDigest(secret.value), CreatedAt: secret.createdAt})
+	}
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].Name < secrets[j].Name })
+	return secrets, nil
+}
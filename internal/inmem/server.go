@@ -2,22 +2,43 @@ package inmem
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
 	fly "github.com/superfly/fly-go"
-	"github.com/superfly/flyctl/helpers"
+	"github.com/superfly/fly-go/flaps"
 )
 
 type Server struct {
 	mu sync.Mutex
 
-	apps   map[string]*fly.App     // apps by app name
-	images map[imageKey]*fly.Image // images by app name & image ref
+	// changed is closed and replaced whenever a machine changes, to wake up
+	// those waiting for a machine to reach a state.
+	changed chan struct{}
+
+	orgSeq   int                          // organization id generation
+	orgs     map[string]*fly.Organization // organizations by slug
+	apps     map[string]*fly.App          // apps by app name
+	appLocks map[string]*fly.LockApp      // app locks by app name
+	images   map[imageKey]*fly.Image      // images by app name & image ref
 
 	machineSeq int                       // machine id generation
 	machines   map[string][]*fly.Machine // machines by app name
+	leases     map[string]*fly.MachineLeaseData
+	cordoned   map[string]bool // cordoned machines by id
+
+	volumeSeq int                             // volume id generation
+	volumes   map[string][]*fly.Volume        // volumes by app name
+	snapshots map[string][]fly.VolumeSnapshot // snapshots by volume id
+
+	secrets     map[string]*secretStore // secrets and secret keys by app name
+	ipSeq       int                     // ip address id generation
+	ipAddresses map[string][]fly.IPAddress
 
 	buildSeq int               // build id generation
 	builds   map[string]*Build // builds by id
@@ -28,11 +49,20 @@ type Server struct {
 
 func NewServer() *Server {
 	return &Server{
-		apps:     make(map[string]*fly.App),
-		machines: make(map[string][]*fly.Machine),
-		images:   make(map[imageKey]*fly.Image),
-		builds:   make(map[string]*Build),
-		releases: make(map[string]*Release),
+		changed:     make(chan struct{}),
+		orgs:        make(map[string]*fly.Organization),
+		apps:        make(map[string]*fly.App),
+		appLocks:    make(map[string]*fly.LockApp),
+		machines:    make(map[string][]*fly.Machine),
+		leases:      make(map[string]*fly.MachineLeaseData),
+		cordoned:    make(map[string]bool),
+		volumes:     make(map[string][]*fly.Volume),
+		snapshots:   make(map[string][]fly.VolumeSnapshot),
+		secrets:     make(map[string]*secretStore),
+		ipAddresses: make(map[string][]fly.IPAddress),
+		images:      make(map[imageKey]*fly.Image),
+		builds:      make(map[string]*Build),
+		releases:    make(map[string]*Release),
 	}
 }
 
@@ -50,15 +80,52 @@ func (s *Server) CreateApp(app *fly.App) {
 	if _, ok := s.apps[app.Name]; ok {
 		panic(fmt.Sprintf("app name already exists: %q", app.Name))
 	}
+	s.createApp(app)
+}
+
+// createApp registers app, and its organization if it's new. s.mu must be
+// held.
+func (s *Server) createApp(app *fly.App) {
+	if app.ID == "" {
+		app.ID = app.Name
+	}
+	if app.Status == "" {
+		app.Status = "deployed"
+	}
+	if app.PlatformVersion == "" {
+		app.PlatformVersion = "machines"
+	}
+	if app.Hostname == "" {
+		app.Hostname = app.Name + ".fly.dev"
+	}
+	if app.Organization.Slug == "" {
+		app.Organization.Slug = "personal"
+	}
+
+	org := s.orgs[app.Organization.Slug]
+	if org == nil {
+		org = s.createOrg(app.Organization.Slug)
+	}
+	app.Organization = *org
+
 	s.apps[app.Name] = app
 }
 
+// app returns the app named appName. s.mu must be held.
+func (s *Server) app(appName string) (*fly.App, error) {
+	app := s.apps[appName]
+	if app == nil {
+		return nil, notFoundError("app not found: %q", appName)
+	}
+	return app, nil
+}
+
 func (s *Server) CreateBuild(ctx context.Context, appName string) (*Build, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.apps[appName]; ok {
-		return nil, fmt.Errorf("app not found: %q", appName)
+	if _, err := s.app(appName); err != nil {
+		return nil, err
 	}
 
 	s.buildSeq++
@@ -79,7 +146,7 @@ func (s *Server) FinishBuild(ctx context.Context, id, status string) (*Build, er
 	defer s.mu.Unlock()
 
 	build, ok := s.builds[id]
-	if ok {
+	if !ok {
 		return nil, fmt.Errorf("build not found: %q", id)
 	}
 	build.Status = status
@@ -110,6 +177,7 @@ func (s *Server) CreateRelease(ctx context.Context, appID, clientMutationID, ima
 		Status:           "pending",
 		Strategy:         strategy,
 		Version:          n + 1,
+		CreatedAt:        time.Now(),
 	}
 	s.releases[release.ID] = release
 
@@ -134,8 +202,8 @@ func (s *Server) CreateImage(ctx context.Context, appName, imageRef string, imag
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.apps[appName]; !ok {
-		return fmt.Errorf("app not found: %q", appName)
+	if _, err := s.app(appName); err != nil {
+		return err
 	}
 
 	other := *image
@@ -143,35 +211,36 @@ func (s *Server) CreateImage(ctx context.Context, appName, imageRef string, imag
 	return nil
 }
 
-func (s *Server) Launch(ctx context.Context, appName, name, region string, config *fly.MachineConfig) (*fly.Machine, error) {
+// resolveImage returns the image imageRef of appName, registering it if it
+// wasn't yet: there's no registry to check, so every image exists. It returns
+// nil if the app doesn't.
+func (s *Server) resolveImage(appName, imageRef string) *fly.Image {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.machineSeq++
-	id := s.machineSeq
-
-	machine := &fly.Machine{
-		ID:     fmt.Sprintf("%014x", id),
-		Name:   name,
-		Region: region,
-		State:  "started",
-		Config: helpers.Clone(config),
+	if _, err := s.app(appName); err != nil {
+		return nil
 	}
-	s.machines[appName] = append(s.machines[appName], machine)
-
-	return machine, nil
-}
-
-func (s *Server) GetMachine(ctx context.Context, appName, machineID string) (*fly.Machine, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
 
-	for _, machine := range s.machines[appName] {
-		if machine.ID == machineID {
-			return helpers.Clone(machine), nil
+	key := imageKey{appName, imageRef}
+	if s.images[key] == nil {
+		sum := sha256.Sum256([]byte(imageRef))
+		digest := "sha256:" + hex.EncodeToString(sum[:])
+		s.images[key] = &fly.Image{
+			ID:             "img_" + hex.EncodeToString(sum[:8]),
+			Digest:         digest,
+			Ref:            imageRef,
+			CompressedSize: "0",
 		}
 	}
-	return nil, fmt.Errorf("machine not found: %q", machineID)
+	return s.images[key]
+}
+
+// notify wakes up everyone waiting for a machine to change. s.mu must be
+// held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
 }
 
 type Build struct {
@@ -192,8 +261,56 @@ type Release struct {
 	Status           string
 	Strategy         string
 	Version          int
+	CreatedAt        time.Time
 }
 
 type imageKey struct {
 	appName, imageRef string
 }
+
+// apiError returns an error that looks like one returned by the Machines
+// API, so callers checking for status codes behave the same.
+func apiError(statusCode int, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	body, _ := json.Marshal(map[string]string{"error": msg})
+	return &flaps.FlapsError{
+		OriginalError:      fmt.Errorf("%s", msg),
+		ResponseStatusCode: statusCode,
+		ResponseBody:       body,
+	}
+}
+
+func notFoundError(format string, args ...any) error {
+	return apiError(http.StatusNotFound, format, args...)
+}
+
+func badRequestError(format string, args ...any) error {
+	return apiError(http.StatusBadRequest, format, args...)
+}
+
+func conflictError(format string, args ...any) error {
+	return apiError(http.StatusConflict, format, args...)
+}
+
+func preconditionFailedError(format string, args ...any) error {
+	return apiError(http.StatusPreconditionFailed, format, args...)
+}
+
+// CreateAppInOrg creates an app in the organization with slug org, failing
+// if the name is taken.
+func (s *Server) CreateAppInOrg(ctx context.Context, name, org string) (*fly.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if name == "" {
+		return nil, badRequestError("app name is required")
+	}
+	if _, ok := s.apps[name]; ok {
+		return nil, apiError(http.StatusUnprocessableEntity, "app name %q is already taken", name)
+	}
+	app := &fly.App{Name: name, Organization: fly.Organization{Slug: org}, Status: "pending"}
+	s.createApp(app)
+
+	other := *app
+	return &other, nil
+}
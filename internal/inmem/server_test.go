@@ -0,0 +1,157 @@
+package inmem
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/fly-go/tokens"
+)
+
+func newTestServer(t *testing.T) (*Server, *FlapsClient) {
+	t.Helper()
+
+	server := NewServer()
+	server.CreateApp(&fly.App{Name: "myapp"})
+	return server, server.FlapsClient("myapp")
+}
+
+func TestMachineLifecycle(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestServer(t)
+
+	machine, err := client.Launch(ctx, fly.LaunchMachineInput{Region: "ord", Config: &fly.MachineConfig{Image: "nginx:latest"}})
+	require.NoError(t, err)
+	assert.Equal(t, fly.MachineStateStarted, machine.State)
+	assert.Equal(t, "ord", machine.Region)
+	assert.Equal(t, "nginx", machine.ImageRef.Repository)
+
+	require.NoError(t, client.Stop(ctx, fly.StopMachineInput{ID: machine.ID}, ""))
+	require.NoError(t, client.Wait(ctx, machine, fly.MachineStateStopped, time.Second))
+
+	_, err = client.Start(ctx, machine.ID, "")
+	require.NoError(t, err)
+
+	err = client.Destroy(ctx, fly.RemoveMachineInput{ID: machine.ID}, "")
+	assert.ErrorIs(t, err, &flaps.FlapsError{ResponseStatusCode: http.StatusPreconditionFailed}, "destroying a started machine without kill")
+
+	require.NoError(t, client.Destroy(ctx, fly.RemoveMachineInput{ID: machine.ID, Kill: true}, ""))
+
+	machines, err := client.List(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, machines)
+}
+
+func TestLeaseNonce(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestServer(t)
+
+	machine, err := client.Launch(ctx, fly.LaunchMachineInput{Config: &fly.MachineConfig{Image: "nginx"}})
+	require.NoError(t, err)
+
+	lease, err := client.AcquireLease(ctx, machine.ID, fly.Pointer(60))
+	require.NoError(t, err)
+	nonce := lease.Data.Nonce
+
+	_, err = client.AcquireLease(ctx, machine.ID, fly.Pointer(60))
+	assert.ErrorIs(t, err, &flaps.FlapsError{ResponseStatusCode: http.StatusConflict})
+
+	err = client.Stop(ctx, fly.StopMachineInput{ID: machine.ID}, "wrong")
+	assert.ErrorIs(t, err, &flaps.FlapsError{ResponseStatusCode: http.StatusConflict})
+
+	require.NoError(t, client.Cordon(ctx, machine.ID, nonce))
+	require.NoError(t, client.ReleaseLease(ctx, machine.ID, nonce))
+
+	_, err = client.FindLease(ctx, machine.ID)
+	assert.ErrorIs(t, err, &flaps.FlapsError{ResponseStatusCode: http.StatusNotFound})
+}
+
+func TestVolumeAttachment(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestServer(t)
+
+	vol, err := client.CreateVolume(ctx, fly.CreateVolumeRequest{Name: "data", Region: "iad", SizeGb: fly.Pointer(3)})
+	require.NoError(t, err)
+
+	machine, err := client.Launch(ctx, fly.LaunchMachineInput{Region: "iad", Config: &fly.MachineConfig{
+		Image:  "nginx",
+		Mounts: []fly.MachineMount{{Volume: vol.ID, Path: "/data"}},
+	}})
+	require.NoError(t, err)
+
+	vol, err = client.GetVolume(ctx, vol.ID)
+	require.NoError(t, err)
+	require.NotNil(t, vol.AttachedMachine)
+	assert.Equal(t, machine.ID, *vol.AttachedMachine)
+
+	_, err = client.DeleteVolume(ctx, vol.ID)
+	assert.ErrorIs(t, err, &flaps.FlapsError{ResponseStatusCode: http.StatusPreconditionFailed}, "deleting an attached volume")
+
+	_, err = client.Launch(ctx, fly.LaunchMachineInput{Region: "iad", Config: &fly.MachineConfig{
+		Image:  "nginx",
+		Mounts: []fly.MachineMount{{Volume: vol.ID, Path: "/data"}},
+	}})
+	assert.Error(t, err, "a volume can only be attached to one machine")
+
+	require.NoError(t, client.Destroy(ctx, fly.RemoveMachineInput{ID: machine.ID, Kill: true}, ""))
+	_, err = client.DeleteVolume(ctx, vol.ID)
+	require.NoError(t, err)
+
+	volumes, err := client.GetVolumes(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, volumes)
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	server, _ := newTestServer(t)
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	t.Setenv("FLY_FLAPS_BASE_URL", ts.URL)
+
+	client, err := flaps.NewWithOptions(ctx, flaps.NewClientOpts{AppName: "myapp", Tokens: tokens.Parse("test")})
+	require.NoError(t, err)
+
+	machine, err := client.Launch(ctx, fly.LaunchMachineInput{Config: &fly.MachineConfig{Image: "nginx"}})
+	require.NoError(t, err)
+
+	lease, err := client.AcquireLease(ctx, machine.ID, fly.Pointer(30))
+	require.NoError(t, err)
+
+	err = client.Stop(ctx, fly.StopMachineInput{ID: machine.ID}, "")
+	assert.ErrorIs(t, err, &flaps.FlapsError{ResponseStatusCode: http.StatusConflict})
+
+	require.NoError(t, client.Stop(ctx, fly.StopMachineInput{ID: machine.ID}, lease.Data.Nonce))
+	require.NoError(t, client.Wait(ctx, machine, fly.MachineStateStopped, 5*time.Second))
+	require.NoError(t, client.SetMetadata(ctx, machine.ID, "role", "primary"))
+
+	got, err := client.Get(ctx, machine.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fly.MachineStateStopped, got.State)
+	assert.Equal(t, "primary", got.Config.Metadata["role"])
+
+	_, err = client.Get(ctx, "missing")
+	assert.ErrorIs(t, err, &flaps.FlapsError{ResponseStatusCode: http.StatusNotFound})
+
+	_, err = client.SetAppSecret(ctx, "DATABASE_URL", "postgres://")
+	require.NoError(t, err)
+	secrets, err := server.FlapsClient("myapp").ListAppSecrets(ctx, nil, false)
+	require.NoError(t, err)
+	require.Len(t, secrets, 1)
+	assert.Equal(t, "DATABASE_URL", secrets[0].Name)
+
+	api := fly.NewClientFromOptions(fly.ClientOptions{BaseURL: ts.URL, Tokens: tokens.Parse("test")})
+	app, err := api.GetAppCompact(ctx, "myapp")
+	require.NoError(t, err)
+	assert.Equal(t, "myapp", app.Name)
+
+	_, err = api.GetOrganizations(ctx)
+	assert.ErrorContains(t, err, "is not emulated")
+}
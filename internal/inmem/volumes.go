@@ -0,0 +1,239 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/helpers"
+)
+
+const (
+	defaultVolumeSizeGb       = 1
+	defaultSnapshotRetention  = 5
+	volumeStateCreated        = "created"
+	volumeStatePendingDestroy = "pending_destroy"
+)
+
+// volume returns a volume of appName that isn't destroyed. s.mu must be
+// held.
+func (s *Server) volume(appName, id string) (*fly.Volume, error) {
+	for _, vol := range s.volumes[appName] {
+		if vol.ID == id && vol.State != volumeStatePendingDestroy {
+			return vol, nil
+		}
+	}
+	return nil, notFoundError("volume not found: %q", id)
+}
+
+// detachVolumes detaches the volumes attached to a machine, except those in
+// keep. s.mu must be held.
+func (s *Server) detachVolumes(appName, machineID string, keep []fly.MachineMount) {
+	for _, vol := range s.volumes[appName] {
+		if vol.AttachedMachine == nil || *vol.AttachedMachine != machineID {
+			continue
+		}
+		if slices.ContainsFunc(keep, func(m fly.MachineMount) bool { return m.Volume == vol.ID }) {
+			continue
+		}
+		vol.AttachedMachine = nil
+	}
+}
+
+// findVolume returns a volume by id along with its app, whichever app it
+// belongs to. s.mu must be held.
+func (s *Server) findVolume(id string) (string, *fly.Volume) {
+	for appName, volumes := range s.volumes {
+		for _, vol := range volumes {
+			if vol.ID == id {
+				return appName, vol
+			}
+		}
+	}
+	return "", nil
+}
+
+// CreateVolume creates a volume, possibly restored from a snapshot or forked
+// from another volume.
+func (s *Server) CreateVolume(ctx context.Context, appName string, req fly.CreateVolumeRequest) (*fly.Volume, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.app(appName); err != nil {
+		return nil, err
+	}
+	if req.Name == "" {
+		return nil, badRequestError("volume name is required")
+	}
+	if req.Region == "" {
+		return nil, badRequestError("volume region is required")
+	}
+
+	vol := &fly.Volume{
+		Name:              req.Name,
+		Region:            req.Region,
+		SizeGb:            defaultVolumeSizeGb,
+		Encrypted:         true,
+		State:             volumeStateCreated,
+		SnapshotRetention: defaultSnapshotRetention,
+		AutoBackupEnabled: true,
+		HostStatus:        string(fly.HostStatusOk),
+		CreatedAt:         time.Now().UTC(),
+	}
+	if req.SizeGb != nil {
+		vol.SizeGb = *req.SizeGb
+	}
+	if req.Encrypted != nil {
+		vol.Encrypted = *req.Encrypted
+	}
+	if req.SnapshotRetention != nil {
+		vol.SnapshotRetention = *req.SnapshotRetention
+	}
+	if req.AutoBackupEnabled != nil {
+		vol.AutoBackupEnabled = *req.AutoBackupEnabled
+	}
+
+	switch {
+	case req.SourceVolumeID != nil:
+		_, source := s.findVolume(*req.SourceVolumeID)
+		if source == nil {
+			return nil, notFoundError("source volume not found: %q", *req.SourceVolumeID)
+		}
+		vol.SizeGb = max(vol.SizeGb, source.SizeGb)
+	case req.SnapshotID != nil:
+		snapshot, ok := s.snapshot(*req.SnapshotID)
+		if !ok {
+			return nil, notFoundError("snapshot not found: %q", *req.SnapshotID)
+		}
+		vol.SizeGb = max(vol.SizeGb, (snapshot.Size+1<<30-1)>>30)
+	}
+	if vol.SizeGb <= 0 {
+		return nil, badRequestError("volume size must be at least 1GB")
+	}
+
+	s.volumeSeq++
+	vol.ID = fmt.Sprintf("vol_%016x", s.volumeSeq)
+	vol.Zone = fmt.Sprintf("%04x", s.volumeSeq%4)
+	s.volumes[appName] = append(s.volumes[appName], vol)
+
+	return helpers.Clone(vol), nil
+}
+
+// ListVolumes returns all volumes of appName, including those being
+// destroyed.
+func (s *Server) ListVolumes(ctx context.Context, appName string) ([]fly.Volume, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	volumes := make([]fly.Volume, 0, len(s.volumes[appName]))
+	for _, vol := range s.volumes[appName] {
+		volumes = append(volumes, *helpers.Clone(vol))
+	}
+	return volumes, nil
+}
+
+func (s *Server) GetVolume(ctx context.Context, appName, volumeID string) (*fly.Volume, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vol, err := s.volume(appName, volumeID)
+	if err != nil {
+		return nil, err
+	}
+	return helpers.Clone(vol), nil
+}
+
+func (s *Server) UpdateVolume(ctx context.Context, appName, volumeID string, req fly.UpdateVolumeRequest) (*fly.Volume, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vol, err := s.volume(appName, volumeID)
+	if err != nil {
+		return nil, err
+	}
+	if req.SnapshotRetention != nil {
+		vol.SnapshotRetention = *req.SnapshotRetention
+	}
+	if req.AutoBackupEnabled != nil {
+		vol.AutoBackupEnabled = *req.AutoBackupEnabled
+	}
+	return helpers.Clone(vol), nil
+}
+
+// ExtendVolume grows a volume. Volumes can't shrink. Attached volumes grow
+// online, so a restart is never needed.
+func (s *Server) ExtendVolume(ctx context.Context, appName, volumeID string, sizeGb int) (*fly.Volume, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vol, err := s.volume(appName, volumeID)
+	if err != nil {
+		return nil, false, err
+	}
+	if sizeGb <= vol.SizeGb {
+		return nil, false, badRequestError("new size %dGB must be larger than current size %dGB", sizeGb, vol.SizeGb)
+	}
+	vol.SizeGb = sizeGb
+	return helpers.Clone(vol), false, nil
+}
+
+// DeleteVolume destroys a volume that isn't attached to a machine.
+func (s *Server) DeleteVolume(ctx context.Context, appName, volumeID string) (*fly.Volume, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vol, err := s.volume(appName, volumeID)
+	if err != nil {
+		return nil, err
+	}
+	if vol.AttachedMachine != nil {
+		return nil, preconditionFailedError("volume %s is attached to machine %s, destroy the machine first", vol.ID, *vol.AttachedMachine)
+	}
+	vol.State = volumeStatePendingDestroy
+	return helpers.Clone(vol), nil
+}
+
+func (s *Server) CreateVolumeSnapshot(ctx context.Context, appName, volumeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vol, err := s.volume(appName, volumeID)
+	if err != nil {
+		return err
+	}
+
+	retention := vol.SnapshotRetention
+	s.snapshots[vol.ID] = append(s.snapshots[vol.ID], fly.VolumeSnapshot{
+		ID:            fmt.Sprintf("vs_%s", randomHex(8)),
+		Size:          vol.SizeGb << 30,
+		Digest:        randomHex(32),
+		CreatedAt:     time.Now().UTC(),
+		Status:        "created",
+		RetentionDays: &retention,
+	})
+	return nil
+}
+
+func (s *Server) GetVolumeSnapshots(ctx context.Context, appName, volumeID string) ([]fly.VolumeSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.volume(appName, volumeID); err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.snapshots[volumeID]), nil
+}
+
+// snapshot returns a snapshot by id, of any volume. s.mu must be held.
+func (s *Server) snapshot(id string) (fly.VolumeSnapshot, bool) {
+	for _, snapshots := range s.snapshots {
+		for _, snapshot := range snapshots {
+			if snapshot.ID == id {
+				return snapshot, true
+			}
+		}
+	}
+	return fly.VolumeSnapshot{}, false
+}
@@ -8,6 +8,16 @@ import (
 	"github.com/superfly/flyctl/internal/flapsutil"
 )
 
+// ToolMetadataKey marks machines flyctl runs in an app for its own purposes,
+// such as the snapshot policy runner. Its value names the tool. These machines
+// aren't part of the app's process groups, so ListActive leaves them out.
+const ToolMetadataKey = "fly_flyctl_tool"
+
+// IsTool reports whether m is a machine flyctl runs for its own purposes.
+func IsTool(m *fly.Machine) bool {
+	return m.GetMetadataByKey(ToolMetadataKey) != ""
+}
+
 func ListActive(ctx context.Context) ([]*fly.Machine, error) {
 	flapsClient := flapsutil.ClientFromContext(ctx)
 
@@ -17,7 +27,7 @@ func ListActive(ctx context.Context) ([]*fly.Machine, error) {
 	}
 
 	machines = lo.Filter(machines, func(m *fly.Machine, _ int) bool {
-		return m.Config != nil && m.IsActive() && !m.IsReleaseCommandMachine() && !m.IsFlyAppsConsole()
+		return m.Config != nil && m.IsActive() && !m.IsReleaseCommandMachine() && !m.IsFlyAppsConsole() && !IsTool(m)
 	})
 
 	return machines, nil
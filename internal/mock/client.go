@@ -56,6 +56,7 @@ type Client struct {
 	GetAppCNAMETargetFunc                  func(ctx context.Context, appName string) (string, error)
 	GetAppHostIssuesFunc                   func(ctx context.Context, appName string) ([]fly.HostIssue, error)
 	GetAppLimitedAccessTokensFunc          func(ctx context.Context, appName string) ([]fly.LimitedAccessToken, error)
+	GetAppLockFunc                         func(ctx context.Context, name string) (*fly.App, error)
 	GetAppLogsFunc                         func(ctx context.Context, appName, token, region, instanceID string) (entries []fly.LogEntry, nextToken string, err error)
 	GetAppNameFromVolumeFunc               func(ctx context.Context, volID string) (*string, error)
 	GetAppNameStateFromVolumeFunc          func(ctx context.Context, volID string) (*string, *string, error)
@@ -89,6 +90,7 @@ type Client struct {
 	IssueSSHCertificateFunc                func(ctx context.Context, org fly.OrganizationImpl, principals []string, appNames []string, valid_hours *int, publicKey ed25519.PublicKey) (*fly.IssuedCertificate, error)
 	LatestImageFunc                        func(ctx context.Context, appName string) (string, error)
 	ListPostgresClusterAttachmentsFunc     func(ctx context.Context, appName, postgresAppName string) ([]*fly.PostgresClusterAttachment, error)
+	LockAppFunc                            func(ctx context.Context, input fly.LockAppInput) (*fly.LockApp, error)
 	LoggerFunc                             func() fly.Logger
 	MoveAppFunc                            func(ctx context.Context, appName string, orgID string) (*fly.App, error)
 	NewRequestFunc                         func(q string) *graphql.Request
@@ -104,6 +106,7 @@ type Client struct {
 	SetRemoteBuilderFunc                   func(ctx context.Context, appName string) error
 	SetSecretsFunc                         func(ctx context.Context, appName string, secrets map[string]string) (*fly.Release, error)
 	UpdateReleaseFunc                      func(ctx context.Context, input fly.UpdateReleaseInput) (*fly.UpdateReleaseResponse, error)
+	UnlockAppFunc                          func(ctx context.Context, input fly.UnlockAppInput) (*fly.App, error)
 	UnsetSecretsFunc                       func(ctx context.Context, appName string, keys []string) (*fly.Release, error)
 	ValidateWireGuardPeersFunc             func(ctx context.Context, peerIPs []string) (invalid []string, err error)
 }
@@ -276,6 +279,10 @@ func (m *Client) GetAppLimitedAccessTokens(ctx context.Context, appName string)
 	return m.GetAppLimitedAccessTokensFunc(ctx, appName)
 }
 
+func (m *Client) GetAppLock(ctx context.Context, name string) (*fly.App, error) {
+	return m.GetAppLockFunc(ctx, name)
+}
+
 func (m *Client) GetAppLogs(ctx context.Context, appName, token, region, instanceID string) (entries []fly.LogEntry, nextToken string, err error) {
 	return m.GetAppLogsFunc(ctx, appName, token, region, instanceID)
 }
@@ -392,6 +399,10 @@ func (m *Client) GenqClient() genq.Client {
 	return m.GenqClientFunc()
 }
 
+func (m *Client) LockApp(ctx context.Context, input fly.LockAppInput) (*fly.LockApp, error) {
+	return m.LockAppFunc(ctx, input)
+}
+
 func (m *Client) LatestImage(ctx context.Context, appName string) (string, error) {
 	return m.LatestImageFunc(ctx, appName)
 }
@@ -468,6 +479,10 @@ func (m *Client) UpdateRelease(ctx context.Context, input fly.UpdateReleaseInput
 	return m.UpdateReleaseFunc(ctx, input)
 }
 
+func (m *Client) UnlockApp(ctx context.Context, input fly.UnlockAppInput) (*fly.App, error) {
+	return m.UnlockAppFunc(ctx, input)
+}
+
 func (m *Client) UnsetSecrets(ctx context.Context, appName string, keys []string) (*fly.Release, error) {
 	return m.UnsetSecretsFunc(ctx, appName, keys)
 }
@@ -0,0 +1,246 @@
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/flyutil"
+	"github.com/superfly/flyctl/terminal"
+	"github.com/superfly/flyctl/wg"
+)
+
+// agentPeerPrefix is prepended to the names of peers the agent creates on
+// demand, as opposed to "static" peers created with `fly wireguard create`.
+const agentPeerPrefix = "interactive"
+
+// The WireGuard API has nowhere to store metadata about a peer, so the expiry
+// of peers created with a TTL is appended to the peer name as "-exp<unix>".
+var expirySuffixPattern = regexp.MustCompile(`-exp(\d+)$`)
+
+// NameWithExpiry appends an expiry marker for t to name.
+func NameWithExpiry(name string, t time.Time) string {
+	return fmt.Sprintf("%s-exp%d", name, t.Unix())
+}
+
+// PeerExpiry returns the expiry encoded in a peer name, if there is one.
+func PeerExpiry(name string) (time.Time, bool) {
+	m := expirySuffixPattern.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	secs, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(secs, 0), true
+}
+
+// PeerCreatedAt returns the creation time of a peer whose name was generated
+// by flyctl. Generated names end with a ULID, which embeds a timestamp.
+func PeerCreatedAt(name string) (time.Time, bool) {
+	name = expirySuffixPattern.ReplaceAllString(name, "")
+
+	idx := strings.LastIndex(name, "-")
+	if idx < 0 {
+		return time.Time{}, false
+	}
+
+	id, err := ulid.ParseStrict(name[idx+1:])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return ulid.Time(id.Time()), true
+}
+
+// Rotate replaces the keypair of an existing peer. There's no API to rekey an
+// organization peer, so the peer is removed and added again with the same name
+// and region. If the peer backs one of the agent's tunnels, it is re-added on
+// the network of that tunnel and the local state is updated with the new
+// keys. Otherwise it is re-added on network, which is the default network
+// when empty.
+func Rotate(ctx context.Context, apiClient flyutil.Client, org *fly.Organization, name, network string) (*wg.WireGuardState, error) {
+	peer, err := apiClient.GetWireGuardPeer(ctx, org.Slug, name)
+	if err != nil {
+		return nil, err
+	}
+
+	states, err := GetWireGuardState()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for key, state := range states {
+		if state.Org != org.Slug || state.Name != peer.Name {
+			continue
+		}
+		stateNetwork := networkFromStateKey(key, org.Slug)
+		if network != "" && network != stateNetwork {
+			return nil, fmt.Errorf("peer %s is on network %q in the local configuration, not %q", peer.Name, stateNetwork, network)
+		}
+		network = stateNetwork
+		keys = append(keys, key)
+	}
+
+	if err := apiClient.RemoveWireGuardPeer(ctx, org, peer.Name); err != nil {
+		return nil, fmt.Errorf("failed removing peer %s: %w", peer.Name, err)
+	}
+
+	pubkey, privatekey := C25519pair()
+
+	data, err := apiClient.CreateWireGuardPeer(ctx, org, peer.Region, peer.Name, pubkey, network)
+	if err != nil {
+		return nil, fmt.Errorf("peer %s was removed but could not be re-added: %w", peer.Name, err)
+	}
+
+	rotated := &wg.WireGuardState{
+		Name:         peer.Name,
+		Region:       peer.Region,
+		Org:          org.Slug,
+		LocalPublic:  pubkey,
+		LocalPrivate: privatekey,
+		Peer:         *data,
+	}
+
+	if len(keys) == 0 {
+		return rotated, nil
+	}
+
+	for _, key := range keys {
+		rotated.DNS = states[key].DNS
+		states[key] = rotated
+	}
+	if err := setWireGuardState(ctx, states); err != nil {
+		return nil, err
+	}
+
+	return rotated, nil
+}
+
+// networkFromStateKey returns the custom network of a WireGuard state entry.
+// Entries are keyed by organization slug, followed by "-<network>" for peers
+// on a custom network.
+func networkFromStateKey(key, orgSlug string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(key, orgSlug), "-")
+}
+
+// PeerInfo describes a peer for auditing purposes.
+type PeerInfo struct {
+	Name          string     `json:"name"`
+	Region        string     `json:"region"`
+	PeerIP        string     `json:"peer_ip"`
+	Pubkey        string     `json:"pubkey"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Agent         bool       `json:"agent"`
+	InLocalConfig bool       `json:"in_local_config"`
+	LastHandshake string     `json:"last_handshake,omitempty"`
+}
+
+// DescribePeers annotates peers with what flyctl knows about them from their
+// names and the local WireGuard state.
+func DescribePeers(peers []*fly.WireGuardPeer, states wg.States) []PeerInfo {
+	local := map[string]bool{}
+	for _, state := range states {
+		local[state.Name] = true
+	}
+
+	infos := make([]PeerInfo, 0, len(peers))
+	for _, peer := range peers {
+		info := PeerInfo{
+			Name:          peer.Name,
+			Region:        peer.Region,
+			PeerIP:        peer.Peerip,
+			Pubkey:        peer.Pubkey,
+			Agent:         strings.HasPrefix(peer.Name, agentPeerPrefix+"-"),
+			InLocalConfig: local[peer.Name],
+		}
+		if t, ok := PeerCreatedAt(peer.Name); ok {
+			info.CreatedAt = &t
+		}
+		if t, ok := PeerExpiry(peer.Name); ok {
+			info.ExpiresAt = &t
+		}
+		if peer.GatewayStatus != nil {
+			info.LastHandshake = peer.GatewayStatus.LastHandshake
+		}
+		infos = append(infos, info)
+	}
+
+	return infos
+}
+
+// StalePeer is a peer that PrunablePeers has selected for removal.
+type StalePeer struct {
+	Peer   *fly.WireGuardPeer
+	Reason string
+}
+
+// PrunablePeers returns the organization's peers that have outlived their TTL,
+// along with peers the agent created from this host for this user that are no
+// longer referenced by the local WireGuard state. Abandoned agent peers are
+// only returned once they are older than abandonedAfter, so that a tunnel that
+// is being established concurrently isn't pulled out from under the agent.
+func PrunablePeers(ctx context.Context, apiClient flyutil.Client, orgSlug string, abandonedAfter time.Duration) ([]StalePeer, error) {
+	peers, err := apiClient.GetWireGuardPeers(ctx, orgSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	states, err := GetWireGuardState()
+	if err != nil {
+		return nil, err
+	}
+
+	stem, err := peerNameStem(ctx, apiClient)
+	if err != nil {
+		return nil, err
+	}
+	agentPrefix := fmt.Sprintf("%s-%s-", agentPeerPrefix, stem)
+
+	return selectStalePeers(peers, states, agentPrefix, time.Now(), abandonedAfter), nil
+}
+
+func selectStalePeers(peers []*fly.WireGuardPeer, states wg.States, agentPrefix string, now time.Time, abandonedAfter time.Duration) []StalePeer {
+	local := map[string]bool{}
+	for _, state := range states {
+		local[state.Name] = true
+	}
+
+	var stale []StalePeer
+	for _, peer := range peers {
+		if expiry, ok := PeerExpiry(peer.Name); ok && now.After(expiry) {
+			stale = append(stale, StalePeer{
+				Peer:   peer,
+				Reason: fmt.Sprintf("expired %s", expiry.Format(time.RFC3339)),
+			})
+			continue
+		}
+
+		if !strings.HasPrefix(peer.Name, agentPrefix) || local[peer.Name] {
+			continue
+		}
+
+		created, ok := PeerCreatedAt(peer.Name)
+		if !ok || now.Sub(created) < abandonedAfter {
+			continue
+		}
+
+		terminal.Debugf("agent peer %s is not referenced by local state\n", peer.Name)
+		stale = append(stale, StalePeer{
+			Peer:   peer,
+			Reason: "abandoned agent peer",
+		})
+	}
+
+	return stale
+}
@@ -0,0 +1,76 @@
+package wireguard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/wg"
+)
+
+func TestPeerExpiry(t *testing.T) {
+	expiry := time.Unix(1700000000, 0)
+	name := NameWithExpiry("static-laptop-jane-example-com", expiry)
+	assert.Equal(t, "static-laptop-jane-example-com-exp1700000000", name)
+
+	got, ok := PeerExpiry(name)
+	require.True(t, ok)
+	assert.True(t, expiry.Equal(got))
+
+	_, ok = PeerExpiry("static-laptop-jane-example-com")
+	assert.False(t, ok)
+}
+
+func TestPeerCreatedAt(t *testing.T) {
+	created := time.UnixMilli(1700000000000)
+	id := ulid.MustNew(ulid.Timestamp(created), nil)
+
+	got, ok := PeerCreatedAt("interactive-laptop-jane-example-com-" + id.String())
+	require.True(t, ok)
+	assert.True(t, created.Equal(got))
+
+	got, ok = PeerCreatedAt(NameWithExpiry("static-laptop-"+id.String(), created.Add(time.Hour)))
+	require.True(t, ok)
+	assert.True(t, created.Equal(got))
+
+	_, ok = PeerCreatedAt("my-peer")
+	assert.False(t, ok)
+}
+
+func TestSelectStalePeers(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	agentPeer := func(age time.Duration) string {
+		return "interactive-laptop-jane-example-com-" + ulid.MustNew(ulid.Timestamp(now.Add(-age)), nil).String()
+	}
+
+	var (
+		current   = agentPeer(48 * time.Hour)
+		abandoned = agentPeer(24 * time.Hour)
+		recent    = agentPeer(time.Minute)
+		expired   = NameWithExpiry("static-ci", now.Add(-time.Second))
+		live      = NameWithExpiry("static-ci", now.Add(time.Hour))
+		foreign   = "interactive-desktop-joe-example-com-" + ulid.MustNew(ulid.Timestamp(now.Add(-72*time.Hour)), nil).String()
+	)
+
+	peers := []*fly.WireGuardPeer{
+		{Name: current}, {Name: abandoned}, {Name: recent}, {Name: expired}, {Name: live}, {Name: foreign},
+	}
+	states := wg.States{"personal": {Name: current}}
+
+	stale := selectStalePeers(peers, states, "interactive-laptop-jane-example-com-", now, time.Hour)
+
+	var names []string
+	for _, s := range stale {
+		names = append(names, s.Peer.Name)
+	}
+	assert.Equal(t, []string{abandoned, expired}, names)
+}
+
+func TestNetworkFromStateKey(t *testing.T) {
+	assert.Equal(t, "", networkFromStateKey("acme", "acme"))
+	assert.Equal(t, "staging", networkFromStateKey("acme-staging", "acme"))
+	assert.Equal(t, "blue-green", networkFromStateKey("acme-corp-blue-green", "acme-corp"))
+}
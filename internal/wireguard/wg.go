@@ -8,6 +8,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/pkg/errors"
@@ -28,7 +29,9 @@ type WebClient interface {
 	ValidateWireGuardPeers(ctx context.Context, peerIPs []string) (invalid []string, err error)
 }
 
-func generatePeerName(ctx context.Context, apiClient flyutil.Client) (string, error) {
+// peerNameStem returns the host and user portion of peer names generated on
+// this machine, e.g. "laptop-jane-example-com".
+func peerNameStem(ctx context.Context, apiClient flyutil.Client) (string, error) {
 	user, err := apiClient.GetCurrentUser(ctx)
 	if err != nil {
 		return "", err
@@ -41,7 +44,16 @@ func generatePeerName(ctx context.Context, apiClient flyutil.Client) (string, er
 	}
 	hostSlug := cleanDNSPattern.ReplaceAllString(strings.Split(host, ".")[0], "-")
 
-	name := fmt.Sprintf("%s-%s-%s", hostSlug, emailSlug, ulid.Make())
+	return fmt.Sprintf("%s-%s", hostSlug, emailSlug), nil
+}
+
+func generatePeerName(ctx context.Context, apiClient flyutil.Client) (string, error) {
+	stem, err := peerNameStem(ctx, apiClient)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s-%s", stem, ulid.Make())
 	return name, nil
 }
 
@@ -56,7 +68,7 @@ func StateForOrg(ctx context.Context, apiClient flyutil.Client, org *fly.Organiz
 
 	terminal.Debugf("Can't find matching WireGuard configuration; creating new one\n")
 
-	stateb, err := Create(apiClient, org, regionCode, name, network, "interactive")
+	stateb, err := Create(apiClient, org, regionCode, name, network, agentPeerPrefix, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -68,7 +80,10 @@ func StateForOrg(ctx context.Context, apiClient flyutil.Client, org *fly.Organiz
 	return stateb, nil
 }
 
-func Create(apiClient flyutil.Client, org *fly.Organization, regionCode, name, network string, namePrefix string) (*wg.WireGuardState, error) {
+// Create adds a new peer to the organization. When ttl is non-zero the peer's
+// expiry is encoded in its name so that `fly wireguard prune` can remove it
+// once it has expired.
+func Create(apiClient flyutil.Client, org *fly.Organization, regionCode, name, network string, namePrefix string, ttl time.Duration) (*wg.WireGuardState, error) {
 	ctx := context.TODO()
 	var (
 		err error
@@ -100,6 +115,10 @@ func Create(apiClient flyutil.Client, org *fly.Organization, regionCode, name, n
 		return nil, errors.New("name must consist solely of letters, numbers, and the dash character")
 	}
 
+	if ttl > 0 {
+		name = NameWithExpiry(name, time.Now().Add(ttl))
+	}
+
 	fmt.Printf("Creating WireGuard peer \"%s\" in region \"%s\" for organization %s\n", name, regionCode, org.Slug)
 
 	pubkey, privatekey := C25519pair()
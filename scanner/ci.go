@@ -0,0 +1,44 @@
+package scanner
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// CIProviders are the CI systems launch can generate pipelines for. "script"
+// is a plain shell script that can be called from any other system.
+var CIProviders = []string{"github", "gitlab", "buildkite", "circleci", "script"}
+
+// CIVars are the values pipeline templates are rendered with.
+type CIVars struct {
+	AppName string
+	OrgSlug string
+	Region  string
+	// Review adds jobs that create a review app for each merge or pull
+	// request and destroy it afterwards.
+	Review bool
+}
+
+// CIFiles returns the pipeline files for provider.
+func CIFiles(provider string, vars CIVars) ([]SourceFile, error) {
+	switch {
+	case provider == "github":
+		// GitHub workflow syntax clashes with text/template, so these aren't
+		// rendered.
+		files := templates("templates/github")
+		if vars.Review {
+			files = append(files, templates("templates/ci/github-review")...)
+		}
+		return files, nil
+	case slices.Contains(CIProviders, provider):
+		return templatesExecute("templates/ci/"+provider, map[string]interface{}{
+			"appName": vars.AppName,
+			"orgSlug": vars.OrgSlug,
+			"region":  vars.Region,
+			"review":  vars.Review,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported CI provider %q; supported providers are %s", provider, strings.Join(CIProviders, ", "))
+	}
+}
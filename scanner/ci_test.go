@@ -0,0 +1,47 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIFiles(t *testing.T) {
+	vars := CIVars{AppName: "shop", OrgSlug: "acme", Region: "iad"}
+
+	for _, provider := range CIProviders {
+		t.Run(provider, func(t *testing.T) {
+			files, err := CIFiles(provider, vars)
+			require.NoError(t, err)
+			require.NotEmpty(t, files)
+			for _, f := range files {
+				assert.NotContains(t, string(f.Contents), "review")
+			}
+
+			vars := vars
+			vars.Review = true
+			files, err = CIFiles(provider, vars)
+			require.NoError(t, err)
+
+			var all strings.Builder
+			for _, f := range files {
+				all.Write(f.Contents)
+			}
+			assert.Contains(t, all.String(), "review")
+			assert.Contains(t, all.String(), "FLY_REVIEW_API_TOKEN")
+			if provider == "circleci" {
+				assert.Contains(t, all.String(), `if [ -z "$CIRCLE_PULL_REQUEST" ]`)
+				assert.Contains(t, all.String(), "pr: << pipeline.parameters.review-teardown-pr >>")
+			}
+			if provider != "github" {
+				assert.Contains(t, all.String(), "shop")
+				assert.NotContains(t, all.String(), "{{")
+			}
+		})
+	}
+
+	_, err := CIFiles("jenkins", vars)
+	assert.ErrorContains(t, err, "unsupported CI provider")
+}
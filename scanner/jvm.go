@@ -0,0 +1,170 @@
+package scanner
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/superfly/flyctl/internal/command/launch/plan"
+)
+
+const defaultJDKVersion = "21"
+
+var (
+	mavenJavaVersionPattern  = regexp.MustCompile(`<(?:java\.version|maven\.compiler\.release|maven\.compiler\.target|maven\.compiler\.source)>\s*(?:1\.)?(\d+)\s*</`)
+	gradleJavaVersionPattern = regexp.MustCompile(`(?:JavaLanguageVersion\.of\(\s*|JavaVersion\.VERSION_(?:1_)?|(?:source|target)Compatibility\s*=\s*['"]?(?:1\.)?)(\d+)`)
+)
+
+// configureJVM handles Java and Kotlin services built with Maven or Gradle,
+// with specific support for Spring Boot, Quarkus and Micronaut.
+func configureJVM(sourceDir string, _ *ScannerConfig) (*SourceInfo, error) {
+	var (
+		buildFiles []string
+		buildTool  string
+		wrapper    bool
+	)
+
+	switch {
+	case checksPass(sourceDir, fileExists("pom.xml")):
+		buildTool = "maven"
+		buildFiles = []string{"pom.xml"}
+		wrapper = checksPass(sourceDir, fileExists("mvnw"))
+	case checksPass(sourceDir, fileExists("build.gradle", "build.gradle.kts")):
+		buildTool = "gradle"
+		buildFiles = []string{"build.gradle", "build.gradle.kts", "settings.gradle", "settings.gradle.kts", "gradle/libs.versions.toml"}
+		wrapper = checksPass(sourceDir, fileExists("gradlew"))
+	default:
+		return nil, nil
+	}
+
+	buildFileContains := func(patterns ...string) bool {
+		for _, name := range buildFiles {
+			if checksPass(sourceDir, dirContains(name, patterns...)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var (
+		framework   = "Java"
+		portKey     = "server.port"
+		checkPath   string
+		memoryMB    = 1024
+		quarkusLike bool
+	)
+
+	switch {
+	case buildFileContains(`spring-boot`, `org\.springframework\.boot`):
+		framework = "Spring Boot"
+		memoryMB = 2048
+		if buildFileContains(`spring-boot-starter-actuator`) {
+			checkPath = "/actuator/health"
+		}
+	case buildFileContains(`io\.quarkus`):
+		framework = "Quarkus"
+		portKey = "quarkus.http.port"
+		quarkusLike = true
+		if buildFileContains(`quarkus-smallrye-health`) {
+			checkPath = "/q/health"
+		}
+	case buildFileContains(`io\.micronaut`):
+		framework = "Micronaut"
+		portKey = "micronaut.server.port"
+		if buildFileContains(`micronaut-management`) {
+			checkPath = "/health"
+		}
+	}
+
+	if buildTool == "gradle" && buildFileContains(`kotlin`) && framework == "Java" {
+		framework = "Kotlin"
+	}
+
+	jdkVersion := jvmVersion(sourceDir, buildTool, buildFiles)
+
+	port := 8080
+	if p := jvmConfiguredPort(sourceDir, portKey); p > 0 {
+		port = p
+	}
+
+	vars := map[string]interface{}{
+		"jdkVersion": jdkVersion,
+		"maven":      buildTool == "maven",
+		"gradle":     buildTool == "gradle",
+		"wrapper":    wrapper,
+		"quarkus":    quarkusLike,
+		"port":       port,
+	}
+
+	s := &SourceInfo{
+		Files:  templatesExecute("templates/jvm", vars),
+		Family: framework,
+		Port:   port,
+		Env: map[string]string{
+			"PORT": strconv.Itoa(port),
+			// Size the heap from the machine's memory rather than the JVM's
+			// defaults, which only use a quarter of it.
+			"JAVA_TOOL_OPTIONS": "-XX:MaxRAMPercentage=75.0",
+		},
+		HttpCheckPath: checkPath,
+		MemoryMB:      memoryMB,
+		SkipDatabase:  true,
+		Runtime:       plan.RuntimeStruct{Language: "java", Version: jdkVersion},
+	}
+
+	if checkPath == "" {
+		s.DeployDocs = "No health check endpoint was detected. Consider adding one (for example Spring Boot Actuator, Quarkus SmallRye Health or Micronaut Management) and configuring it in fly.toml.\n"
+	}
+
+	return s, nil
+}
+
+// jvmVersion returns the major JDK version the build targets, falling back to
+// the current LTS release.
+func jvmVersion(sourceDir, buildTool string, buildFiles []string) string {
+	pattern := mavenJavaVersionPattern
+	if buildTool == "gradle" {
+		pattern = gradleJavaVersionPattern
+	}
+
+	for _, name := range buildFiles {
+		data, err := os.ReadFile(filepath.Join(sourceDir, name))
+		if err != nil {
+			continue
+		}
+		if m := pattern.FindSubmatch(data); m != nil {
+			if v, err := strconv.Atoi(string(m[1])); err == nil && v >= 8 {
+				return strconv.Itoa(v)
+			}
+		}
+	}
+
+	return defaultJDKVersion
+}
+
+// jvmConfiguredPort reads the HTTP port from application.properties, returning
+// zero when it isn't set.
+func jvmConfiguredPort(sourceDir, key string) int {
+	file, err := os.Open(filepath.Join(sourceDir, "src", "main", "resources", "application.properties"))
+	if err != nil {
+		return 0
+	}
+	defer file.Close() //skipcq: GO-S2307
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		name, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(name) != key {
+			continue
+		}
+		if port, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return port
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,119 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJVMScanner(t *testing.T) {
+	type testcase struct {
+		name       string
+		files      map[string]string
+		family     string
+		jdk        string
+		port       int
+		checkPath  string
+		memoryMB   int
+		dockerfile []string
+	}
+
+	testcases := []testcase{
+		{
+			name: "spring boot with maven wrapper and actuator",
+			files: map[string]string{
+				"pom.xml": `<project>
+  <parent><groupId>org.springframework.boot</groupId><artifactId>spring-boot-starter-parent</artifactId></parent>
+  <properties><java.version>17</java.version></properties>
+  <dependencies>
+    <dependency><artifactId>spring-boot-starter-actuator</artifactId></dependency>
+  </dependencies>
+</project>`,
+				"mvnw": "",
+				"src/main/resources/application.properties": "server.port = 9090\n",
+			},
+			family:     "Spring Boot",
+			jdk:        "17",
+			port:       9090,
+			checkPath:  "/actuator/health",
+			memoryMB:   2048,
+			dockerfile: []string{"ARG JDK_VERSION=17", "./mvnw -B package", "EXPOSE 9090", "/app/app.jar"},
+		},
+		{
+			name: "quarkus with gradle",
+			files: map[string]string{
+				"build.gradle.kts": `plugins { id("io.quarkus") }
+dependencies { implementation("io.quarkus:quarkus-smallrye-health") }
+java { toolchain { languageVersion = JavaLanguageVersion.of(21) } }`,
+			},
+			family:     "Quarkus",
+			jdk:        "21",
+			port:       8080,
+			checkPath:  "/q/health",
+			memoryMB:   1024,
+			dockerfile: []string{"FROM gradle:jdk${JDK_VERSION} AS build", "build/quarkus-app", "quarkus-run.jar"},
+		},
+		{
+			name: "micronaut with gradle wrapper",
+			files: map[string]string{
+				"build.gradle": `plugins { id 'io.micronaut.application' }
+sourceCompatibility = JavaVersion.VERSION_11`,
+				"gradlew": "",
+			},
+			family:     "Micronaut",
+			jdk:        "11",
+			port:       8080,
+			memoryMB:   1024,
+			dockerfile: []string{"./gradlew --no-daemon build", "build/libs"},
+		},
+		{
+			name: "plain maven project",
+			files: map[string]string{
+				"pom.xml": `<project><properties><maven.compiler.source>1.8</maven.compiler.source></properties></project>`,
+			},
+			family:     "Java",
+			jdk:        "8",
+			port:       8080,
+			memoryMB:   1024,
+			dockerfile: []string{"FROM maven:3-eclipse-temurin-${JDK_VERSION} AS build", "mvn -B package"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, contents := range tc.files {
+				path := filepath.Join(dir, name)
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+				require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+			}
+
+			si, err := configureJVM(dir, &ScannerConfig{})
+			require.NoError(t, err)
+			require.NotNil(t, si)
+
+			assert.Equal(t, tc.family, si.Family)
+			assert.Equal(t, tc.jdk, si.Runtime.Version)
+			assert.Equal(t, tc.port, si.Port)
+			assert.Equal(t, tc.checkPath, si.HttpCheckPath)
+			assert.Equal(t, tc.memoryMB, si.MemoryMB)
+
+			var dockerfile string
+			for _, f := range si.Files {
+				if f.Path == "Dockerfile" {
+					dockerfile = string(f.Contents)
+				}
+			}
+			for _, want := range tc.dockerfile {
+				assert.Contains(t, dockerfile, want)
+			}
+		})
+	}
+
+	si, err := configureJVM(t.TempDir(), &ScannerConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, si)
+}
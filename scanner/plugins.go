@@ -0,0 +1,253 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/superfly/flyctl/terminal"
+)
+
+// Scanner plugins are executables, or WASI modules run with a WASM runtime,
+// found in ScannerConfig.PluginDir. They let teams detect in-house frameworks
+// without changes to flyctl. Each plugin is called in two ways:
+//
+//	<plugin> describe
+//
+// prints a pluginDescription as JSON, and
+//
+//	<plugin> scan
+//
+// reads a pluginScanRequest as JSON on stdin and prints a pluginSourceInfo as
+// JSON, or nothing or null when the source isn't something the plugin handles.
+// Plugins with a priority of zero or more run before the built-in scanners,
+// highest first; plugins with a negative priority run after them.
+
+const (
+	pluginDescribeTimeout = 10 * time.Second
+	pluginScanTimeout     = 2 * time.Minute
+)
+
+type pluginDescription struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+}
+
+type pluginScanRequest struct {
+	SourceDir    string `json:"source_dir"`
+	Mode         string `json:"mode"`
+	ExistingPort int    `json:"existing_port"`
+}
+
+// pluginSourceInfo is the subset of SourceInfo plugins can return.
+type pluginSourceInfo struct {
+	Family        string            `json:"family"`
+	Dockerfile    string            `json:"dockerfile"`
+	BuildArgs     map[string]string `json:"build_args"`
+	Port          int               `json:"port"`
+	Env           map[string]string `json:"env"`
+	Processes     map[string]string `json:"processes"`
+	Statics       []Static          `json:"statics"`
+	Volumes       []Volume          `json:"volumes"`
+	Secrets       []pluginSecret    `json:"secrets"`
+	HttpCheckPath string            `json:"http_check_path"`
+	ReleaseCmd    string            `json:"release_command"`
+	Notice        string            `json:"notice"`
+	SkipDatabase  bool              `json:"skip_database"`
+}
+
+type pluginSecret struct {
+	Key   string `json:"key"`
+	Help  string `json:"help"`
+	Value string `json:"value"`
+}
+
+type scannerPlugin struct {
+	path string
+	pluginDescription
+}
+
+func (p *scannerPlugin) command(ctx context.Context, sourceDir string, args ...string) *exec.Cmd {
+	if filepath.Ext(p.path) == ".wasm" {
+		runtime := os.Getenv("FLY_WASM_RUNTIME")
+		if runtime == "" {
+			runtime = "wasmtime"
+		}
+		wasmArgs := []string{"run"}
+		if sourceDir != "" {
+			wasmArgs = append(wasmArgs, "--dir", sourceDir)
+		}
+		wasmArgs = append(wasmArgs, p.path)
+		return exec.CommandContext(ctx, runtime, append(wasmArgs, args...)...)
+	}
+	return exec.CommandContext(ctx, p.path, args...)
+}
+
+func (p *scannerPlugin) run(sourceDir string, timeout time.Duration, stdin []byte, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := p.command(ctx, sourceDir, args...)
+	cmd.Dir = sourceDir
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("scanner plugin %s failed: %w: %s", filepath.Base(p.path), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+var (
+	pluginCacheMu sync.Mutex
+	pluginCache   = map[string][]*scannerPlugin{}
+)
+
+// loadPlugins returns the plugins in dir sorted by descending priority. A
+// plugin that can't describe itself is skipped with a warning, so one broken
+// plugin doesn't stop launches.
+func loadPlugins(dir string) []*scannerPlugin {
+	if dir == "" {
+		return nil
+	}
+
+	pluginCacheMu.Lock()
+	defer pluginCacheMu.Unlock()
+	if plugins, ok := pluginCache[dir]; ok {
+		return plugins
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			terminal.Warnf("failed reading scanner plugins from %s: %v", dir, err)
+		}
+		pluginCache[dir] = nil
+		return nil
+	}
+
+	var plugins []*scannerPlugin
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if filepath.Ext(entry.Name()) != ".wasm" && info.Mode()&0o111 == 0 {
+			continue
+		}
+
+		p := &scannerPlugin{path: filepath.Join(dir, entry.Name())}
+		out, err := p.run("", pluginDescribeTimeout, nil, "describe")
+		if err == nil {
+			err = json.Unmarshal(out, &p.pluginDescription)
+		}
+		if err != nil {
+			terminal.Warnf("ignoring scanner plugin %s: %v", entry.Name(), err)
+			continue
+		}
+		if p.Name == "" {
+			p.Name = entry.Name()
+		}
+		plugins = append(plugins, p)
+	}
+
+	sort.SliceStable(plugins, func(i, j int) bool {
+		if plugins[i].Priority != plugins[j].Priority {
+			return plugins[i].Priority > plugins[j].Priority
+		}
+		return plugins[i].Name < plugins[j].Name
+	})
+
+	pluginCache[dir] = plugins
+	return plugins
+}
+
+// pluginScanners wraps the plugins in dir as scanners, split into those that
+// run before and after the built-in scanners.
+func pluginScanners(dir string) (before, after []sourceScanner) {
+	for _, p := range loadPlugins(dir) {
+		if p.Priority >= 0 {
+			before = append(before, p.scanOrSkip)
+		} else {
+			after = append(after, p.scanOrSkip)
+		}
+	}
+	return before, after
+}
+
+// scanOrSkip scans like scan, but a failing plugin is skipped with a warning
+// rather than failing the whole scan, as with plugins failing to describe
+// themselves.
+func (p *scannerPlugin) scanOrSkip(sourceDir string, config *ScannerConfig) (*SourceInfo, error) {
+	si, err := p.scan(sourceDir, config)
+	if err != nil {
+		terminal.Warnf("ignoring scanner plugin %s: %v", p.Name, err)
+		return nil, nil
+	}
+	return si, nil
+}
+
+func (p *scannerPlugin) scan(sourceDir string, config *ScannerConfig) (*SourceInfo, error) {
+	req, err := json.Marshal(pluginScanRequest{
+		SourceDir:    sourceDir,
+		Mode:         config.Mode,
+		ExistingPort: config.ExistingPort,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := p.run(sourceDir, pluginScanTimeout, req, "scan")
+	if err != nil {
+		return nil, err
+	}
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 || bytes.Equal(out, []byte("null")) {
+		return nil, nil
+	}
+
+	var psi pluginSourceInfo
+	if err := json.Unmarshal(out, &psi); err != nil {
+		return nil, fmt.Errorf("scanner plugin %s returned invalid output: %w", p.Name, err)
+	}
+
+	return psi.sourceInfo(p.Name), nil
+}
+
+func (psi *pluginSourceInfo) sourceInfo(pluginName string) *SourceInfo {
+	s := &SourceInfo{
+		Family:        psi.Family,
+		BuildArgs:     psi.BuildArgs,
+		Port:          psi.Port,
+		Env:           psi.Env,
+		Processes:     psi.Processes,
+		Statics:       psi.Statics,
+		Volumes:       psi.Volumes,
+		HttpCheckPath: psi.HttpCheckPath,
+		ReleaseCmd:    psi.ReleaseCmd,
+		Notice:        psi.Notice,
+		SkipDatabase:  psi.SkipDatabase,
+	}
+	if s.Family == "" {
+		s.Family = pluginName
+	}
+	if psi.Dockerfile != "" {
+		s.Files = []SourceFile{{Path: "Dockerfile", Contents: []byte(psi.Dockerfile)}}
+	}
+	for _, secret := range psi.Secrets {
+		s.Secrets = append(s.Secrets, Secret{Key: secret.Key, Help: secret.Help, Value: secret.Value})
+	}
+	return s
+}
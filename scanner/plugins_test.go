@@ -0,0 +1,84 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePlugin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755))
+}
+
+func TestScannerPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+
+	pluginDir := t.TempDir()
+	writePlugin(t, pluginDir, "inhouse", `
+case "$1" in
+  describe) echo '{"name": "inhouse", "priority": 10}' ;;
+  scan)
+    if [ -f inhouse.yml ]; then
+      echo '{"family": "Inhouse", "dockerfile": "FROM inhouse/runtime", "port": 9000, "env": {"MODE": "prod"}, "processes": {"app": "serve"}, "secrets": [{"key": "API_KEY", "help": "API key"}], "volumes": [{"source": "data", "destination": "/data"}]}'
+    fi ;;
+esac
+`)
+	writePlugin(t, pluginDir, "fallback", `
+case "$1" in
+  describe) echo '{"priority": -5}' ;;
+  scan) echo '{"family": "Fallback"}' ;;
+esac
+`)
+	writePlugin(t, pluginDir, "broken", "exit 1\n")
+	writePlugin(t, pluginDir, "failing", `
+case "$1" in
+  describe) echo '{"priority": 20}' ;;
+  scan) echo 'not json' ;;
+esac
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "README"), []byte("not a plugin"), 0o644))
+
+	plugins := loadPlugins(pluginDir)
+	require.Len(t, plugins, 3)
+	assert.Equal(t, "failing", plugins[0].Name)
+	assert.Equal(t, "inhouse", plugins[1].Name)
+	assert.Equal(t, "fallback", plugins[2].Name)
+
+	t.Run("plugin takes precedence over built-in scanners", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "inhouse.yml"), nil, 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM scratch"), 0o644))
+
+		si, err := Scan(dir, &ScannerConfig{PluginDir: pluginDir})
+		require.NoError(t, err)
+		require.NotNil(t, si)
+
+		assert.Equal(t, "Inhouse", si.Family)
+		assert.Equal(t, 9000, si.Port)
+		assert.Equal(t, map[string]string{"MODE": "prod"}, si.Env)
+		assert.Equal(t, map[string]string{"app": "serve"}, si.Processes)
+		assert.Equal(t, []SourceFile{{Path: "Dockerfile", Contents: []byte("FROM inhouse/runtime")}}, si.Files)
+		assert.Equal(t, "API_KEY", si.Secrets[0].Key)
+		assert.Equal(t, "/data", si.Volumes[0].Destination)
+	})
+
+	t.Run("negative priority runs after built-in scanners", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM scratch"), 0o644))
+
+		si, err := Scan(dir, &ScannerConfig{PluginDir: pluginDir})
+		require.NoError(t, err)
+		assert.Equal(t, "Dockerfile", si.Family)
+
+		si, err = Scan(t.TempDir(), &ScannerConfig{PluginDir: pluginDir})
+		require.NoError(t, err)
+		assert.Equal(t, "Fallback", si.Family)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"embed"
 	"io/fs"
 	"path/filepath"
+	"slices"
 	"strings"
 	"text/template"
 
@@ -14,6 +15,7 @@ import (
 )
 
 //go:embed templates templates/*/.dockerignore templates/**/.fly templates/**/.github
+//go:embed all:templates/ci
 var content embed.FS
 
 type InitCommand struct {
@@ -56,6 +58,7 @@ type SourceInfo struct {
 	DockerEntrypoint string
 	KillSignal       string
 	SwapSizeMB       int
+	MemoryMB         int
 	Buildpacks       []string
 	Secrets          []Secret
 
@@ -103,6 +106,8 @@ type ScannerConfig struct {
 	Mode         string
 	ExistingPort int
 	Colorize     *iostreams.ColorScheme
+	// PluginDir holds external scanner plugins; see plugins.go.
+	PluginDir string
 }
 
 type GitHubActionsStruct struct {
@@ -137,6 +142,12 @@ func Scan(sourceDir string, config *ScannerConfig) (*SourceInfo, error) {
 		configureStatic,
 		configureDotnet,
 		configureRust,
+		configureJVM,
+	}
+
+	if config != nil && config.PluginDir != "" {
+		before, after := pluginScanners(config.PluginDir)
+		scanners = slices.Concat(before, scanners, after)
 	}
 
 	for _, scanner := range scanners {
@@ -0,0 +1,45 @@
+#!/bin/sh
+# Deploys {{ .appName }} from any CI system. See
+# https://fly.io/docs/launch/continuous-deployment/
+#
+# FLY_API_TOKEN must be set. Deploys need a deploy token:
+#   fly tokens create deploy -x 999999h
+{{- if .review }}
+# Review apps create and destroy apps, so review jobs use an org token set as
+# FLY_REVIEW_API_TOKEN. It expires after 30 days; create a new one with:
+#   fly tokens create org {{ .orgSlug }} -x 720h
+#
+# Usage:
+#   bin/fly-deploy.sh                       deploy {{ .appName }}
+#   bin/fly-deploy.sh review <id>           create or update a review app
+#   bin/fly-deploy.sh review-teardown <id>  destroy a review app
+{{- end }}
+set -eu
+export FLY_API_TOKEN
+
+if ! command -v flyctl >/dev/null 2>&1; then
+  curl -L https://fly.io/install.sh | sh
+  PATH="$HOME/.fly/bin:$PATH"
+fi
+
+case "${1:-deploy}" in
+  deploy)
+    flyctl deploy --remote-only --app {{ .appName }}
+    ;;
+{{- if .review }}
+  review)
+    FLY_API_TOKEN="${FLY_REVIEW_API_TOKEN:?FLY_REVIEW_API_TOKEN must be set}"
+    review_app="{{ .appName }}-pr-${2:?review id required}"
+    flyctl apps create "$review_app" --org {{ .orgSlug }} || true
+    flyctl deploy --remote-only --app "$review_app" --region {{ .region }} --ha=false
+    ;;
+  review-teardown)
+    FLY_API_TOKEN="${FLY_REVIEW_API_TOKEN:?FLY_REVIEW_API_TOKEN must be set}"
+    flyctl apps destroy "{{ .appName }}-pr-${2:?review id required}" --yes
+    ;;
+{{- end }}
+  *)
+    echo "unknown command: $1" >&2
+    exit 1
+    ;;
+esac